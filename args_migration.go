@@ -0,0 +1,50 @@
+package work
+
+import "fmt"
+
+// ArgsMigration upgrades a job's arguments by one version, eg by renaming or filling in a key that a newer handler
+// expects. It returns the upgraded arguments; it may modify and return args in place.
+type ArgsMigration func(args map[string]interface{}) (map[string]interface{}, error)
+
+// MigrateArgs registers fn to upgrade the arguments of jobName jobs from fromVersion to fromVersion+1. When a job is
+// fetched, every migration from its Job.ArgsVersion onwards is applied in order before middleware and the handler
+// run, so payloads enqueued before a change to a handler's arguments remain processable. Jobs are enqueued at version
+// 0 unless the producer calls Enqueuer.SetArgsVersion. A migration that errors fails the job like a handler error.
+//
+// jobName must already be registered with Job or JobWithOptions.
+func (wp *WorkerPool) MigrateArgs(jobName string, fromVersion uint, fn ArgsMigration) *WorkerPool {
+	jt, ok := wp.jobTypes[jobName]
+	if !ok {
+		panic("work: MigrateArgs needs a registered job; call Job or JobWithOptions for " + jobName + " first")
+	}
+	if fn == nil {
+		panic("work: MigrateArgs needs a non-nil ArgsMigration")
+	}
+
+	if jt.argsMigrations == nil {
+		jt.argsMigrations = make(map[uint]ArgsMigration)
+	}
+	jt.argsMigrations[fromVersion] = fn
+
+	return wp
+}
+
+// migrateArgs brings job's arguments up to the latest version jt has migrations for.
+func (jt *jobType) migrateArgs(job *Job) error {
+	for {
+		fn, ok := jt.argsMigrations[job.ArgsVersion]
+		if !ok {
+			return nil
+		}
+		if err := job.decodeArgs(); err != nil {
+			return err
+		}
+
+		args, err := fn(job.Args)
+		if err != nil {
+			return fmt.Errorf("migrating args from version %d: %v", job.ArgsVersion, err)
+		}
+		job.Args = args
+		job.ArgsVersion++
+	}
+}
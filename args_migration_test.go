@@ -0,0 +1,70 @@
+package work
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateArgs(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	var got []string
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job(job1, func(job *Job) error {
+		got = append(got, job.ArgString("full_name"))
+		return job.ArgError()
+	})
+	// v0 -> v1: "name" was renamed to "first_name"
+	wp.MigrateArgs(job1, 0, func(args map[string]interface{}) (map[string]interface{}, error) {
+		args["first_name"] = args["name"]
+		delete(args, "name")
+		return args, nil
+	})
+	// v1 -> v2: "full_name" is required
+	wp.MigrateArgs(job1, 1, func(args map[string]interface{}) (map[string]interface{}, error) {
+		first, ok := args["first_name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("no first_name")
+		}
+		args["full_name"] = first + " " + fmt.Sprint(args["last_name"])
+		return args, nil
+	})
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue(job1, Q{"name": "ada", "last_name": "lovelace"})
+	assert.NoError(t, err)
+	enqueuer.SetArgsVersion(job1, 1)
+	_, err = enqueuer.Enqueue(job1, Q{"first_name": "grace", "last_name": "hopper"})
+	assert.NoError(t, err)
+	_, err = enqueuer.Enqueue(job1, Q{"name": "bad"})
+	assert.NoError(t, err)
+	enqueuer.SetArgsVersion(job1, 2)
+	_, err = enqueuer.Enqueue(job1, Q{"full_name": "alan turing"})
+	assert.NoError(t, err)
+
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.Equal(t, []string{"ada lovelace", "grace hopper", "alan turing"}, got)
+
+	// The version 1 job without a first_name failed its migration and went to retry
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyRetry(ns)))
+	_, job := jobOnZset(pool, redisKeyRetry(ns))
+	assert.EqualValues(t, 1, job.ArgsVersion)
+	assert.Equal(t, "migrating args from version 1: no first_name", job.LastErr)
+}
+
+func TestMigrateArgsUnregisteredJob(t *testing.T) {
+	pool := newTestPool(":6379")
+	wp := NewWorkerPool(TestContext{}, 1, "work", pool)
+
+	assert.Panics(t, func() {
+		wp.MigrateArgs("nope", 0, func(args map[string]interface{}) (map[string]interface{}, error) { return args, nil })
+	})
+}
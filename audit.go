@@ -0,0 +1,97 @@
+package work
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// The audit log keeps this many of the most recent entries.
+const auditLogMaxLen = 10000
+
+// AuditEntry records an administrative action taken through a Client, such as retrying a dead job or pausing a queue.
+type AuditEntry struct {
+	Action   string                 `json:"action"`            // eg "retry_dead_job"
+	Actor    string                 `json:"actor,omitempty"`   // Whoever the Client was acting for, see WithActor
+	Hostname string                 `json:"hostname"`          // Host the Client ran on
+	Pid      int                    `json:"pid"`               // Process the Client ran in
+	At       int64                  `json:"at"`                // When the action was taken, in epoch seconds
+	Details  map[string]interface{} `json:"details,omitempty"` // The action's arguments, eg the job ID
+}
+
+// WithActor returns a copy of the client that records actor, eg the name of the operator or service using it, in the
+// audit log entries of the actions it takes.
+func (c *Client) WithActor(actor string) *Client {
+	cc := *c
+	cc.actor = actor
+	return &cc
+}
+
+// audit records a successful action in the audit log. Failing to do so is logged but doesn't fail the action, which
+// has already been taken.
+func (c *Client) audit(action string, details map[string]interface{}) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "hostname_errored"
+	}
+	rawJSON, err := json.Marshal(&AuditEntry{
+		Action:   action,
+		Actor:    c.actor,
+		Hostname: hostname,
+		Pid:      os.Getpid(),
+		At:       nowEpochSeconds(),
+		Details:  details,
+	})
+	if err != nil {
+		logError("client.audit.marshal", err)
+		return
+	}
+
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	key := redisKeyAuditLog(c.namespace)
+	conn.Send("MULTI")
+	conn.Send("LPUSH", key, rawJSON)
+	conn.Send("LTRIM", key, 0, auditLogMaxLen-1)
+	if _, err := conn.Do("EXEC"); err != nil {
+		logError("client.audit", err)
+	}
+}
+
+// AuditLog returns the audit log of administrative actions taken through Clients (and so the web UI and workctl),
+// most recent first. The page param is 1-based; each page is 20 items. The total number of entries kept, up to the
+// last 10000, is also returned.
+func (c *Client) AuditLog(page uint) ([]*AuditEntry, int64, error) {
+	if page == 0 {
+		page = 1
+	}
+	start := int64(page-1) * 20
+
+	conn := c.readConn()
+	defer conn.Close()
+
+	key := redisKeyAuditLog(c.namespace)
+	rawJSONs, err := redis.ByteSlices(conn.Do("LRANGE", key, start, start+19))
+	if err != nil {
+		logError("client.audit_log.lrange", err)
+		return nil, 0, err
+	}
+	count, err := redis.Int64(conn.Do("LLEN", key))
+	if err != nil {
+		logError("client.audit_log.llen", err)
+		return nil, 0, err
+	}
+
+	entries := make([]*AuditEntry, 0, len(rawJSONs))
+	for _, rawJSON := range rawJSONs {
+		var entry AuditEntry
+		if err := json.Unmarshal(rawJSON, &entry); err != nil {
+			logError("client.audit_log.unmarshal", err)
+			return nil, 0, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, count, nil
+}
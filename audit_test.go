@@ -0,0 +1,46 @@
+package work
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientAuditLog(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	client := NewClient(ns, pool).WithActor("ada")
+
+	job := insertDeadJob(ns, pool, "wat", 1, 2)
+	assert.NoError(t, client.RetryDeadJob(2, job.ID))
+	assert.Equal(t, ErrNotRetried, client.RetryDeadJob(2, job.ID))
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigPaused, "wat"), "true"))
+	assert.NoError(t, NewClient(ns, pool).DeleteAllDeadJobs())
+
+	entries, count, err := client.AuditLog(1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, count)
+	if assert.Len(t, entries, 3) {
+		// Most recent first; the retry that didn't retry anything isn't recorded
+		assert.Equal(t, "delete_all_dead_jobs", entries[0].Action)
+		assert.Equal(t, "", entries[0].Actor)
+		assert.Nil(t, entries[0].Details)
+
+		assert.Equal(t, "set_config", entries[1].Action)
+		assert.Equal(t, map[string]interface{}{"key": "paused:wat", "value": "true"}, entries[1].Details)
+
+		assert.Equal(t, "retry_dead_job", entries[2].Action)
+		assert.Equal(t, "ada", entries[2].Actor)
+		assert.Equal(t, os.Getpid(), entries[2].Pid)
+		assert.EqualValues(t, 2, entries[2].Details["died_at"])
+		assert.Equal(t, job.ID, entries[2].Details["job_id"])
+		assert.True(t, entries[2].At > 0)
+	}
+
+	entries, count, err = client.AuditLog(2)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, count)
+	assert.Len(t, entries, 0)
+}
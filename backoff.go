@@ -0,0 +1,38 @@
+package work
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ConstantBackoff returns a BackoffCalculator that waits delay before each retry, eg for an API that's down for a
+// while when it's down at all.
+func ConstantBackoff(delay time.Duration) BackoffCalculator {
+	return func(job *Job) int64 {
+		return ceilSeconds(delay)
+	}
+}
+
+// LinearBackoff returns a BackoffCalculator that waits step longer before each retry than the one before, starting at
+// step, up to max.
+func LinearBackoff(step, max time.Duration) BackoffCalculator {
+	return func(job *Job) int64 {
+		return ceilSeconds(time.Duration(math.Min(float64(step)*float64(job.Fails), float64(max))))
+	}
+}
+
+// ExponentialBackoff returns a BackoffCalculator that waits twice as long before each retry as before the one before,
+// starting at base, up to max, eg for rate limited APIs. Each delay is spread by up to 25% either way, so that jobs
+// that failed together aren't all retried together.
+func ExponentialBackoff(base, max time.Duration) BackoffCalculator {
+	return func(job *Job) int64 {
+		delay := float64(base) * math.Pow(2, float64(job.Fails-1)) * (0.75 + rand.Float64()*0.5)
+		return ceilSeconds(time.Duration(math.Min(delay, float64(max))))
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds, which is what retries are scheduled in.
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,32 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffs(t *testing.T) {
+	job := &Job{}
+	backoff := func(calc BackoffCalculator, fails int64) int64 {
+		job.Fails = fails
+		return calc(job)
+	}
+
+	constant := ConstantBackoff(1500 * time.Millisecond)
+	assert.EqualValues(t, 2, backoff(constant, 1))
+	assert.EqualValues(t, 2, backoff(constant, 10))
+
+	linear := LinearBackoff(30*time.Second, 2*time.Minute)
+	assert.EqualValues(t, 30, backoff(linear, 1))
+	assert.EqualValues(t, 90, backoff(linear, 3))
+	assert.EqualValues(t, 120, backoff(linear, 10))
+
+	exponential := ExponentialBackoff(10*time.Second, time.Hour)
+	for i := 0; i < 100; i++ {
+		assert.InDelta(t, 10, backoff(exponential, 1), 3)
+		assert.InDelta(t, 80, backoff(exponential, 4), 20)
+		assert.True(t, backoff(exponential, 30) <= 3600)
+	}
+}
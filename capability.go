@@ -0,0 +1,105 @@
+package work
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Capabilities name behaviors of the package that older versions of it would mishandle, eg by never reading a new
+// queue. Each worker pool advertises the capabilities its version supports in its heartbeat, and only uses one once
+// every live pool in the namespace advertises it, so the package itself can be upgraded with a rolling deploy: the new
+// behavior starts once the last old pool is gone. Capabilities listed in the namespace's ConfigDisabledCapabilities
+// stay off regardless, eg to roll a behavior back.
+//
+// Maintainers don't heartbeat, so they aren't taken into account; upgrade them before the pools.
+const (
+	// CapabilityExpressRetries retries the jobs of types with JobOptions.ExpressRetries through the express retry
+	// queue, which older pools' requeuers don't read. Until it's active, they go to the retry queue as usual.
+	CapabilityExpressRetries = "express_retries"
+)
+
+// capabilities are the capabilities this version of the package supports.
+var capabilities = []string{CapabilityExpressRetries}
+
+// capabilityCheckInterval is how often the config watcher works out which capabilities are active, which reads every
+// pool's heartbeat. Heartbeats don't change any faster.
+const capabilityCheckInterval = beatPeriod
+
+// activeCapabilities returns the capabilities of this version that every pool with a heartbeat younger than deadTime
+// supports, except those listed in the namespace config cfg as disabled.
+func activeCapabilities(conn redis.Conn, namespace string, cfg map[string]string) (map[string]bool, error) {
+	active := make(map[string]bool, len(capabilities))
+	for _, capability := range capabilities {
+		active[capability] = true
+	}
+	for capability := range parseNames(cfg[ConfigDisabledCapabilities]) {
+		delete(active, capability)
+	}
+
+	workerPoolIDs, err := redis.Strings(conn.Do("SMEMBERS", redisKeyWorkerPools(namespace)))
+	if err != nil {
+		return nil, err
+	}
+	for _, wpid := range workerPoolIDs {
+		conn.Send("HMGET", redisKeyHeartbeat(namespace, wpid), "heartbeat_at", "capabilities")
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	for range workerPoolIDs {
+		vals, err := redis.Values(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		var heartbeatAt int64
+		var supported string
+		if _, err := redis.Scan(vals, &heartbeatAt, &supported); err != nil {
+			return nil, err
+		}
+		if time.Unix(heartbeatAt, 0).Add(deadTime).Before(time.Now()) {
+			continue // gone, or about to be reaped
+		}
+
+		pool := parseNames(supported)
+		for capability := range active {
+			if !pool[capability] {
+				delete(active, capability)
+			}
+		}
+	}
+	return active, nil
+}
+
+// Capabilities returns the capabilities that are active in the namespace right now: those that every live worker pool
+// supports and that aren't disabled in the config. See CapabilityExpressRetries.
+func (c *Client) Capabilities() ([]string, error) {
+	conn := c.readConn()
+	defer conn.Close()
+
+	cfg, err := redis.StringMap(conn.Do("HGETALL", redisKeyConfig(c.namespace)))
+	if err != nil {
+		logError("client.capabilities.config", err)
+		return nil, err
+	}
+	active, err := activeCapabilities(conn, c.namespace, cfg)
+	if err != nil {
+		logError("client.capabilities", err)
+		return nil, err
+	}
+
+	names := make([]string, 0, len(active))
+	for capability := range active {
+		names = append(names, capability)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// supportedCapabilities is what pools advertise in their heartbeats.
+func supportedCapabilities() string {
+	return strings.Join(capabilities, ",")
+}
@@ -0,0 +1,52 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCapabilities(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	client := NewClient(ns, pool)
+
+	// With no pools, this version's capabilities are all active
+	active, err := client.Capabilities()
+	assert.NoError(t, err)
+	assert.Equal(t, capabilities, active)
+
+	conn := pool.Get()
+	defer conn.Close()
+	beat := func(wpid string, heartbeatAt int64, supported string) {
+		_, err := conn.Do("SADD", redisKeyWorkerPools(ns), wpid)
+		assert.NoError(t, err)
+		_, err = conn.Do("HMSET", redisKeyHeartbeat(ns, wpid), "heartbeat_at", heartbeatAt, "capabilities", supported)
+		assert.NoError(t, err)
+	}
+
+	// A live pool of an older version holds them back, a dead one doesn't
+	beat("new", nowEpochSeconds(), supportedCapabilities())
+	beat("dead", nowEpochSeconds()-60, "")
+	active, err = client.Capabilities()
+	assert.NoError(t, err)
+	assert.Equal(t, capabilities, active)
+
+	beat("old", nowEpochSeconds(), "")
+	active, err = client.Capabilities()
+	assert.NoError(t, err)
+	assert.Empty(t, active)
+
+	// Once it's upgraded, they're active unless disabled
+	beat("old", nowEpochSeconds(), supportedCapabilities())
+	assert.NoError(t, client.SetConfig(ConfigDisabledCapabilities, CapabilityExpressRetries))
+	active, err = client.Capabilities()
+	assert.NoError(t, err)
+	assert.Empty(t, active)
+
+	assert.NoError(t, client.DeleteConfig(ConfigDisabledCapabilities))
+	active, err = client.Capabilities()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{CapabilityExpressRetries}, active)
+}
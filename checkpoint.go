@@ -0,0 +1,74 @@
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// checkpointTTL is how long a job's checkpoint is kept after it was last saved, which covers its retries with the
+// default backoff and gives some time to revive it from the dead queue.
+const checkpointTTL = 7 * 24 * time.Hour
+
+// checkpointStore saves the checkpoints of the jobs a worker runs, under their job IDs.
+type checkpointStore struct {
+	namespace    string
+	pool         *redis.Pool
+	redisTimeout time.Duration
+}
+
+var errNoCheckpoints = fmt.Errorf("work: checkpoints are only available to jobs run by a worker pool")
+
+// Checkpoint saves state, which must marshal to JSON, as how far the job has got, so that if it fails or its process
+// dies, its next attempt can resume from there with LoadCheckpoint rather than start over, eg at row 1.2M of an
+// import. Unlike annotations, which are only saved once the job has failed, the checkpoint is written to Redis right
+// away, so it survives a crash. It's kept for 7 days after it was last saved, and deleted once the job succeeds.
+func (j *Job) Checkpoint(state interface{}) error {
+	if j.checkpoints == nil {
+		return errNoCheckpoints
+	}
+	rawJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	conn := getConn(j.checkpoints.pool, j.checkpoints.redisTimeout)
+	defer conn.Close()
+	if _, err := conn.Do("SET", redisKeyCheckpoint(j.checkpoints.namespace, j.ID), rawJSON, "EX", int64(checkpointTTL/time.Second)); err != nil {
+		return err
+	}
+	j.checkpointed = true
+	return nil
+}
+
+// LoadCheckpoint unmarshals the state last saved with Checkpoint, by this attempt of the job or an earlier one, into
+// state. It returns false, leaving state untouched, if there's no checkpoint, eg on the job's first attempt.
+func (j *Job) LoadCheckpoint(state interface{}) (bool, error) {
+	if j.checkpoints == nil {
+		return false, errNoCheckpoints
+	}
+
+	conn := getConn(j.checkpoints.pool, j.checkpoints.redisTimeout)
+	defer conn.Close()
+	rawJSON, err := redis.Bytes(conn.Do("GET", redisKeyCheckpoint(j.checkpoints.namespace, j.ID)))
+	if err == redis.ErrNil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	j.checkpointed = true
+	return true, json.Unmarshal(rawJSON, state)
+}
+
+// clear deletes job's checkpoint, if it has one, as it's done.
+func (s *checkpointStore) clear(job *Job) error {
+	if !job.checkpointed {
+		return nil
+	}
+	conn := getConn(s.pool, s.redisTimeout)
+	defer conn.Close()
+	_, err := conn.Do("DEL", redisKeyCheckpoint(s.namespace, job.ID))
+	return err
+}
@@ -0,0 +1,70 @@
+package work
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobCheckpoint(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	type progress struct {
+		Row int `json:"row"`
+	}
+	var resumedAt []int
+	jobTypes := map[string]*jobType{
+		"import": {
+			Name:       "import",
+			JobOptions: JobOptions{Priority: 1, MaxFails: 3, Backoff: func(*Job) int64 { return 0 }},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				var p progress
+				found, err := job.LoadCheckpoint(&p)
+				assert.NoError(t, err)
+				assert.Equal(t, p.Row > 0, found)
+				resumedAt = append(resumedAt, p.Row)
+				if p.Row == 2000 {
+					return nil
+				}
+				assert.NoError(t, job.Checkpoint(progress{Row: p.Row + 1000}))
+				return fmt.Errorf("sorry kid")
+			},
+		},
+	}
+	job, err := NewEnqueuer(ns, pool).Enqueue("import", nil)
+	assert.NoError(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	requeuer := newRequeuer(ns, pool, redisKeyRetry(ns), []string{"import"})
+	for i := 0; i < 2; i++ {
+		fetched, err := w.fetchJob()
+		assert.NoError(t, err)
+		w.processJob(fetched)
+		requeuer.processAll()
+	}
+
+	// The checkpoint is in Redis while the job is being retried
+	conn := pool.Get()
+	defer conn.Close()
+	ttl, err := redis.Int64(conn.Do("TTL", redisKeyCheckpoint(ns, job.ID)))
+	assert.NoError(t, err)
+	assert.True(t, ttl > 0 && ttl <= int64(checkpointTTL.Seconds()))
+
+	// And is gone once it succeeds
+	fetched, err := w.fetchJob()
+	assert.NoError(t, err)
+	w.processJob(fetched)
+	assert.Equal(t, []int{0, 1000, 2000}, resumedAt)
+	assert.False(t, keyExists(pool, redisKeyCheckpoint(ns, job.ID)))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyRetry(ns)))
+
+	// Jobs that aren't run by a worker can't checkpoint
+	assert.Equal(t, errNoCheckpoints, (&Job{}).Checkpoint(1))
+	_, err = (&Job{}).LoadCheckpoint(new(int))
+	assert.Equal(t, errNoCheckpoints, err)
+}
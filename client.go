@@ -1,10 +1,12 @@
 package work
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
@@ -21,6 +23,8 @@ var ErrNotRetried = fmt.Errorf("nothing retried")
 type Client struct {
 	namespace string
 	pool      *redis.Pool
+	replica   *redis.Pool
+	actor     string
 }
 
 // NewClient creates a new Client with the specified redis namespace and connection pool.
@@ -31,6 +35,24 @@ func NewClient(namespace string, pool *redis.Pool) *Client {
 	}
 }
 
+// WithReplica returns a copy of the client that reads from a replica of its Redis, eg to keep a dashboard's listings
+// and stats off the primary that workers and enqueuers use. Only the read-only methods use the replica, so what they
+// return may lag behind by the replica's delay; anything that changes jobs or config, and WaitForEmpty, still goes to
+// the primary.
+func (c *Client) WithReplica(replica *redis.Pool) *Client {
+	cc := *c
+	cc.replica = replica
+	return &cc
+}
+
+// readConn returns a connection to the replica if there is one, or else to the primary.
+func (c *Client) readConn() redis.Conn {
+	if c.replica != nil {
+		return c.replica.Get()
+	}
+	return c.pool.Get()
+}
+
 // WorkerPoolHeartbeat represents the heartbeat from a worker pool. WorkerPool's write a heartbeat every 5 seconds so we know they're alive and includes config information.
 type WorkerPoolHeartbeat struct {
 	WorkerPoolID string   `json:"worker_pool_id"`
@@ -41,11 +63,23 @@ type WorkerPoolHeartbeat struct {
 	Host         string   `json:"host"`
 	Pid          int      `json:"pid"`
 	WorkerIDs    []string `json:"worker_ids"`
+
+	// DisabledJobNames are the job types this pool has stopped fetching with WorkerPool.DisableJobType.
+	DisabledJobNames []string `json:"disabled_job_names"`
+
+	// Quiet is whether the pool has stopped fetching jobs altogether, with WorkerPool.Quiet.
+	Quiet bool `json:"quiet,omitempty"`
+
+	// Sampler has the pool's priority sampler decisions by job type, as in WorkerPoolStats.
+	Sampler map[string]SamplerStats `json:"sampler,omitempty"`
+
+	// Capabilities are the capabilities the pool's version of the package supports, see CapabilityExpressRetries.
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
 // WorkerPoolHeartbeats queries Redis and returns all WorkerPoolHeartbeat's it finds (even for those worker pools which don't have a current heartbeat).
 func (c *Client) WorkerPoolHeartbeats() ([]*WorkerPoolHeartbeat, error) {
-	conn := c.pool.Get()
+	conn := c.readConn()
 	defer conn.Close()
 
 	workerPoolsKey := redisKeyWorkerPools(c.namespace)
@@ -104,6 +138,14 @@ func (c *Client) WorkerPoolHeartbeats() ([]*WorkerPoolHeartbeat, error) {
 			} else if key == "worker_ids" {
 				heartbeat.WorkerIDs = strings.Split(value, ",")
 				sort.Strings(heartbeat.WorkerIDs)
+			} else if key == "disabled_job_names" && value != "" {
+				heartbeat.DisabledJobNames = strings.Split(value, ",")
+			} else if key == "quiet" {
+				heartbeat.Quiet, err = strconv.ParseBool(value)
+			} else if key == "sampler" && value != "" {
+				err = json.Unmarshal([]byte(value), &heartbeat.Sampler)
+			} else if key == "capabilities" && value != "" {
+				heartbeat.Capabilities = strings.Split(value, ",")
 			}
 			if err != nil {
 				logError("worker_pool_statuses.parse", err)
@@ -117,6 +159,71 @@ func (c *Client) WorkerPoolHeartbeats() ([]*WorkerPoolHeartbeat, error) {
 	return heartbeats, nil
 }
 
+// SamplerReport compares the share of fetches each job type gets to the share its priority entitles it to, summed over
+// the worker pools with a heartbeat.
+type SamplerReport struct {
+	JobName       string  `json:"job_name"`
+	Priority      uint    `json:"priority"`
+	Picks         int64   `json:"picks"`
+	Fetched       int64   `json:"fetched"`
+	ExpectedShare float64 `json:"expected_share"` // Priority over the sum of the reported job types' priorities
+	PickShare     float64 `json:"pick_share"`     // Share of the picks; should approach ExpectedShare under load
+	FetchShare    float64 `json:"fetch_share"`    // Share of the fetched jobs; lower than PickShare when the queue is often empty
+}
+
+// SamplerReport returns how the priority sampler has divided fetches among job types since the worker pools started,
+// sorted by job name. A job type is picked when the sampler ranks it first for a fetch. If every queue has jobs, each
+// job type's PickShare and FetchShare approach its ExpectedShare; a FetchShare well below the PickShare means the
+// queue is often empty, and one above it means higher priority queues are. Pools refresh their counts with their
+// heartbeat, every 5 seconds.
+func (c *Client) SamplerReport() ([]*SamplerReport, error) {
+	heartbeats, err := c.WorkerPoolHeartbeats()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*SamplerReport)
+	for _, heartbeat := range heartbeats {
+		for jobName, stats := range heartbeat.Sampler {
+			report := byName[jobName]
+			if report == nil {
+				report = &SamplerReport{JobName: jobName}
+				byName[jobName] = report
+			}
+			// Pools could disagree on a job type's priority; the highest is the one that shapes traffic the most
+			if stats.Priority > report.Priority {
+				report.Priority = stats.Priority
+			}
+			report.Picks += stats.Picks
+			report.Fetched += stats.Fetched
+		}
+	}
+
+	var prioritySum uint
+	var picks, fetched int64
+	reports := make([]*SamplerReport, 0, len(byName))
+	for _, report := range byName {
+		prioritySum += report.Priority
+		picks += report.Picks
+		fetched += report.Fetched
+		reports = append(reports, report)
+	}
+	for _, report := range reports {
+		if prioritySum > 0 {
+			report.ExpectedShare = float64(report.Priority) / float64(prioritySum)
+		}
+		if picks > 0 {
+			report.PickShare = float64(report.Picks) / float64(picks)
+		}
+		if fetched > 0 {
+			report.FetchShare = float64(report.Fetched) / float64(fetched)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].JobName < reports[j].JobName })
+
+	return reports, nil
+}
+
 // WorkerObservation represents the latest observation taken from a worker. The observation indicates whether the worker is busy processing a job, and if so, information about that job.
 type WorkerObservation struct {
 	WorkerID string `json:"worker_id"`
@@ -133,7 +240,7 @@ type WorkerObservation struct {
 
 // WorkerObservations returns all of the WorkerObservation's it finds for all worker pools' workers.
 func (c *Client) WorkerObservations() ([]*WorkerObservation, error) {
-	conn := c.pool.Get()
+	conn := c.readConn()
 	defer conn.Close()
 
 	hbs, err := c.WorkerPoolHeartbeats()
@@ -202,6 +309,43 @@ func (c *Client) WorkerObservations() ([]*WorkerObservation, error) {
 	return observations, nil
 }
 
+// AckFailure represents a job that finished running but that its worker has repeatedly failed to remove from its in-progress queue. The worker keeps retrying; the record is removed once it succeeds.
+type AckFailure struct {
+	JobName      string `json:"job_name"`
+	JobID        string `json:"job_id"`
+	WorkerPoolID string `json:"worker_pool_id"`
+	WorkerID     string `json:"worker_id"`
+	Failures     int64  `json:"failures"`
+	LastErr      string `json:"last_err"`
+	LastFailedAt int64  `json:"last_failed_at"`
+}
+
+// AckFailures returns the AckFailure's it finds, sorted by job ID.
+func (c *Client) AckFailures() ([]*AckFailure, error) {
+	conn := c.readConn()
+	defer conn.Close()
+
+	vals, err := redis.StringMap(conn.Do("HGETALL", redisKeyAckFailures(c.namespace)))
+	if err != nil {
+		logError("ack_failures.hgetall", err)
+		return nil, err
+	}
+
+	failures := make([]*AckFailure, 0, len(vals))
+	for _, v := range vals {
+		var af AckFailure
+		if err := json.Unmarshal([]byte(v), &af); err != nil {
+			logError("ack_failures.unmarshal", err)
+			return nil, err
+		}
+		failures = append(failures, &af)
+	}
+
+	sort.Slice(failures, func(i, j int) bool { return failures[i].JobID < failures[j].JobID })
+
+	return failures, nil
+}
+
 // Queue represents a queue that holds jobs with the same name. It indicates their name, count, and latency (in seconds). Latency is a measurement of how long ago the next job to be processed was enqueued.
 type Queue struct {
 	JobName string `json:"job_name"`
@@ -211,7 +355,7 @@ type Queue struct {
 
 // Queues returns the Queue's it finds.
 func (c *Client) Queues() ([]*Queue, error) {
-	conn := c.pool.Get()
+	conn := c.readConn()
 	defer conn.Close()
 
 	key := redisKeyKnownJobs(c.namespace)
@@ -279,6 +423,169 @@ func (c *Client) Queues() ([]*Queue, error) {
 	return queues, nil
 }
 
+// SetStandby marks this Redis as the standby side of a ReplicatedEnqueuer, or clears the mark. Worker pools don't fetch
+// jobs from a standby Redis, so the jobs mirrored to it wait there until it's promoted with SetStandby(false).
+func (c *Client) SetStandby(standby bool) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	var err error
+	if standby {
+		_, err = conn.Do("SET", redisKeyStandby(c.namespace), "1")
+	} else {
+		_, err = conn.Do("DEL", redisKeyStandby(c.namespace))
+	}
+	if err != nil {
+		logError("client.set_standby", err)
+		return err
+	}
+	c.audit("set_standby", map[string]interface{}{"standby": standby})
+	return nil
+}
+
+// IsStandby returns whether this Redis is marked as a standby. See SetStandby.
+func (c *Client) IsStandby() (bool, error) {
+	conn := c.readConn()
+	defer conn.Close()
+
+	standby, err := redis.Bool(conn.Do("EXISTS", redisKeyStandby(c.namespace)))
+	if err != nil {
+		logError("client.is_standby", err)
+		return false, err
+	}
+	return standby, nil
+}
+
+// SetConfig stores an operational setting in the namespace's config, eg
+// SetConfig(JobConfigKey(ConfigRateLimit, "send_email"), "10"). See ConfigPaused and the other settings for what can be
+// set. Running worker pools pick up the change within a few seconds.
+func (c *Client) SetConfig(key, value string) error {
+	if err := validateConfig(key, value); err != nil {
+		return err
+	}
+
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("HSET", redisKeyConfig(c.namespace), key, value)
+	switch setting, jobName := splitConfigKey(key); setting {
+	case ConfigPaused:
+		if paused, _ := strconv.ParseBool(value); paused {
+			conn.Send("SET", redisKeyJobsPaused(c.namespace, jobName), "1")
+		} else {
+			conn.Send("DEL", redisKeyJobsPaused(c.namespace, jobName))
+		}
+	case ConfigMaxConcurrency:
+		conn.Send("SET", redisKeyJobsConcurrency(c.namespace, jobName), value)
+	}
+	if _, err := conn.Do("EXEC"); err != nil {
+		logError("client.set_config", err)
+		return err
+	}
+	c.audit("set_config", map[string]interface{}{"key": key, "value": value})
+	return nil
+}
+
+// PauseFor pauses jobName's queue like SetConfig(JobConfigKey(ConfigPaused, jobName), "true"), but only for d, eg as
+// an incident mitigation that can't be forgotten and left in place. The pause lapses on its own once d is up, and the
+// worker pools drop it from the config soon after. Pausing or unpausing the queue again in the meantime replaces it.
+func (c *Client) PauseFor(jobName string, d time.Duration) error {
+	if d < time.Second {
+		return fmt.Errorf("work: can't pause %q for less than a second", jobName)
+	}
+
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("HSET", redisKeyConfig(c.namespace), JobConfigKey(ConfigPaused, jobName), "true")
+	conn.Send("SET", redisKeyJobsPaused(c.namespace, jobName), "1", "EX", int64(d/time.Second))
+	if _, err := conn.Do("EXEC"); err != nil {
+		logError("client.pause_for", err)
+		return err
+	}
+	c.audit("pause_for", map[string]interface{}{"job_name": jobName, "duration": d.String()})
+	return nil
+}
+
+// SetQueuePriority overrides the priority of jobName's queue in all worker pools, eg to deprioritize bulk jobs during
+// peak hours, until the override is deleted with DeleteConfig(JobConfigKey(ConfigPriority, jobName)). Running pools
+// pick it up within a few seconds. The priority is from 1 to 100000, like JobOptions.Priority.
+func (c *Client) SetQueuePriority(jobName string, priority uint) error {
+	return c.SetConfig(JobConfigKey(ConfigPriority, jobName), strconv.FormatUint(uint64(priority), 10))
+}
+
+// SetShed has all worker pools drop the jobs of the given types as soon as they fetch them, without running them, to
+// keep the other queues healthy during an overload, eg SetShed("thumbnails", "reports"). It replaces the job types
+// shed before; with none, nothing is shed any more. Running pools pick it up within a few seconds. How many jobs
+// were shed is counted in ShedCounts.
+func (c *Client) SetShed(jobNames ...string) error {
+	if len(jobNames) == 0 {
+		return c.DeleteConfig(ConfigShed)
+	}
+	return c.SetConfig(ConfigShed, strings.Join(jobNames, ","))
+}
+
+// ShedCounts returns how many jobs of each type have been shed since the counts were last reset with
+// ResetShedCounts.
+func (c *Client) ShedCounts() (map[string]int64, error) {
+	conn := c.readConn()
+	defer conn.Close()
+
+	counts, err := redis.Int64Map(conn.Do("HGETALL", redisKeyShedCounts(c.namespace)))
+	if err != nil {
+		logError("client.shed_counts", err)
+		return nil, err
+	}
+	return counts, nil
+}
+
+// ResetShedCounts sets the ShedCounts of all job types back to 0.
+func (c *Client) ResetShedCounts() error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("DEL", redisKeyShedCounts(c.namespace)); err != nil {
+		logError("client.reset_shed_counts", err)
+		return err
+	}
+	c.audit("reset_shed_counts", nil)
+	return nil
+}
+
+// DeleteConfig removes a setting from the namespace's config. Deleting a pause unpauses the queue, and deleting a max
+// concurrency override puts the worker pools' own JobOptions.MaxConcurrency back in effect.
+func (c *Client) DeleteConfig(key string) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("HDEL", redisKeyConfig(c.namespace), key)
+	if setting, jobName := splitConfigKey(key); setting == ConfigPaused {
+		conn.Send("DEL", redisKeyJobsPaused(c.namespace, jobName))
+	}
+	if _, err := conn.Do("EXEC"); err != nil {
+		logError("client.delete_config", err)
+		return err
+	}
+	c.audit("delete_config", map[string]interface{}{"key": key})
+	return nil
+}
+
+// Config returns the namespace's config, keyed like SetConfig.
+func (c *Client) Config() (map[string]string, error) {
+	conn := c.readConn()
+	defer conn.Close()
+
+	cfg, err := redis.StringMap(conn.Do("HGETALL", redisKeyConfig(c.namespace)))
+	if err != nil {
+		logError("client.config", err)
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // RetryJob represents a job in the retry queue.
 type RetryJob struct {
 	RetryAt int64 `json:"retry_at"`
@@ -300,7 +607,7 @@ type DeadJob struct {
 // ScheduledJobs returns a list of ScheduledJob's. The page param is 1-based; each page is 20 items. The total number of items (not pages) in the list of scheduled jobs is also returned.
 func (c *Client) ScheduledJobs(page uint) ([]*ScheduledJob, int64, error) {
 	key := redisKeyScheduled(c.namespace)
-	jobsWithScores, count, err := c.getZsetPage(key, page)
+	jobsWithScores, count, err := c.getZsetPage([]string{key}, page)
 	if err != nil {
 		logError("client.scheduled_jobs.get_zset_page", err)
 		return nil, 0, err
@@ -315,10 +622,13 @@ func (c *Client) ScheduledJobs(page uint) ([]*ScheduledJob, int64, error) {
 	return jobs, count, nil
 }
 
-// RetryJobs returns a list of RetryJob's. The page param is 1-based; each page is 20 items. The total number of items (not pages) in the list of retry jobs is also returned.
+// RetryJobs returns a list of RetryJob's, including those in the express retry queue and the retry queues of job types with JobOptions.OwnFailureQueues. The page param is 1-based; each page is 20 items. The total number of items (not pages) in the list of retry jobs is also returned.
 func (c *Client) RetryJobs(page uint) ([]*RetryJob, int64, error) {
-	key := redisKeyRetry(c.namespace)
-	jobsWithScores, count, err := c.getZsetPage(key, page)
+	keys, err := c.failureQueueKeys(redisKeyRetry(c.namespace), redisKeyRetryOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	jobsWithScores, count, err := c.getZsetPage(keys, page)
 	if err != nil {
 		logError("client.retry_jobs.get_zset_page", err)
 		return nil, 0, err
@@ -333,10 +643,13 @@ func (c *Client) RetryJobs(page uint) ([]*RetryJob, int64, error) {
 	return jobs, count, nil
 }
 
-// DeadJobs returns a list of DeadJob's. The page param is 1-based; each page is 20 items. The total number of items (not pages) in the list of dead jobs is also returned.
+// DeadJobs returns a list of DeadJob's, including those in the dead queues of job types with JobOptions.OwnFailureQueues. The page param is 1-based; each page is 20 items. The total number of items (not pages) in the list of dead jobs is also returned.
 func (c *Client) DeadJobs(page uint) ([]*DeadJob, int64, error) {
-	key := redisKeyDead(c.namespace)
-	jobsWithScores, count, err := c.getZsetPage(key, page)
+	keys, err := c.failureQueueKeys(redisKeyDead(c.namespace), redisKeyDeadOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	jobsWithScores, count, err := c.getZsetPage(keys, page)
 	if err != nil {
 		logError("client.dead_jobs.get_zset_page", err)
 		return nil, 0, err
@@ -353,13 +666,18 @@ func (c *Client) DeadJobs(page uint) ([]*DeadJob, int64, error) {
 
 // DeleteDeadJob deletes a dead job from Redis.
 func (c *Client) DeleteDeadJob(diedAt int64, jobID string) error {
-	ok, _, err := c.deleteZsetJob(redisKeyDead(c.namespace), diedAt, jobID)
+	keys, err := c.failureQueueKeys(redisKeyDead(c.namespace), redisKeyDeadOf)
+	if err != nil {
+		return err
+	}
+	ok, err := c.deleteZsetsJob(keys, diedAt, jobID)
 	if err != nil {
 		return err
 	}
 	if !ok {
 		return ErrNotDeleted
 	}
+	c.audit("delete_dead_job", map[string]interface{}{"died_at": diedAt, "job_id": jobID})
 	return nil
 }
 
@@ -378,10 +696,15 @@ func (c *Client) RetryDeadJob(diedAt int64, jobID string) error {
 		jobNames = append(jobNames, q.JobName)
 	}
 
+	deadKeys, err := c.failureQueueKeys(redisKeyDead(c.namespace), redisKeyDeadOf)
+	if err != nil {
+		return err
+	}
+
 	script := redis.NewScript(len(jobNames)+1, redisLuaRequeueSingleDeadCmd)
 
 	args := make([]interface{}, 0, len(jobNames)+1+3)
-	args = append(args, redisKeyDead(c.namespace)) // KEY[1]
+	args = append(args, "") // KEY[1], the dead queue
 	for _, jobName := range jobNames {
 		args = append(args, redisKeyJobs(c.namespace, jobName)) // KEY[2, 3, ...]
 	}
@@ -393,16 +716,24 @@ func (c *Client) RetryDeadJob(diedAt int64, jobID string) error {
 	conn := c.pool.Get()
 	defer conn.Close()
 
-	cnt, err := redis.Int64(script.Do(conn, args...))
-	if err != nil {
-		logError("client.retry_dead_job.do", err)
-		return err
+	var cnt int64
+	for _, deadKey := range deadKeys {
+		args[0] = deadKey
+		cnt, err = redis.Int64(script.Do(conn, args...))
+		if err != nil {
+			logError("client.retry_dead_job.do", err)
+			return err
+		}
+		if cnt > 0 {
+			break
+		}
 	}
 
 	if cnt == 0 {
 		return ErrNotRetried
 	}
 
+	c.audit("retry_dead_job", map[string]interface{}{"died_at": diedAt, "job_id": jobID})
 	return nil
 }
 
@@ -421,10 +752,15 @@ func (c *Client) RetryAllDeadJobs() error {
 		jobNames = append(jobNames, q.JobName)
 	}
 
+	deadKeys, err := c.failureQueueKeys(redisKeyDead(c.namespace), redisKeyDeadOf)
+	if err != nil {
+		return err
+	}
+
 	script := redis.NewScript(len(jobNames)+1, redisLuaRequeueAllDeadCmd)
 
 	args := make([]interface{}, 0, len(jobNames)+1+3)
-	args = append(args, redisKeyDead(c.namespace)) // KEY[1]
+	args = append(args, "") // KEY[1], the dead queue
 	for _, jobName := range jobNames {
 		args = append(args, redisKeyJobs(c.namespace, jobName)) // KEY[2, 3, ...]
 	}
@@ -435,33 +771,44 @@ func (c *Client) RetryAllDeadJobs() error {
 	conn := c.pool.Get()
 	defer conn.Close()
 
-	// Cap iterations for safety (which could reprocess 1k*1k jobs).
-	// This is conceptually an infinite loop but let's be careful.
-	for i := 0; i < 1000; i++ {
-		res, err := redis.Int64(script.Do(conn, args...))
-		if err != nil {
-			logError("client.retry_all_dead_jobs.do", err)
-			return err
-		}
+	for _, deadKey := range deadKeys {
+		args[0] = deadKey
 
-		if res == 0 {
-			break
+		// Cap iterations for safety (which could reprocess 1k*1k jobs).
+		// This is conceptually an infinite loop but let's be careful.
+		for i := 0; i < 1000; i++ {
+			res, err := redis.Int64(script.Do(conn, args...))
+			if err != nil {
+				logError("client.retry_all_dead_jobs.do", err)
+				return err
+			}
+
+			if res == 0 {
+				break
+			}
 		}
 	}
 
+	c.audit("retry_all_dead_jobs", nil)
 	return nil
 }
 
 // DeleteAllDeadJobs deletes all dead jobs.
 func (c *Client) DeleteAllDeadJobs() error {
+	keys, err := c.failureQueueKeys(redisKeyDead(c.namespace), redisKeyDeadOf)
+	if err != nil {
+		return err
+	}
+
 	conn := c.pool.Get()
 	defer conn.Close()
-	_, err := conn.Do("DEL", redisKeyDead(c.namespace))
+	_, err = conn.Do("DEL", redis.Args{}.AddFlat(keys)...)
 	if err != nil {
 		logError("client.delete_all_dead_jobs", err)
 		return err
 	}
 
+	c.audit("delete_all_dead_jobs", nil)
 	return nil
 }
 
@@ -500,18 +847,24 @@ func (c *Client) DeleteScheduledJob(scheduledFor int64, jobID string) error {
 	if !ok {
 		return ErrNotDeleted
 	}
+	c.audit("delete_scheduled_job", map[string]interface{}{"scheduled_for": scheduledFor, "job_id": jobID})
 	return nil
 }
 
 // DeleteRetryJob deletes a job in the retry queue.
 func (c *Client) DeleteRetryJob(retryAt int64, jobID string) error {
-	ok, _, err := c.deleteZsetJob(redisKeyRetry(c.namespace), retryAt, jobID)
+	keys, err := c.failureQueueKeys(redisKeyRetry(c.namespace), redisKeyRetryOf)
+	if err != nil {
+		return err
+	}
+	ok, err := c.deleteZsetsJob(keys, retryAt, jobID)
 	if err != nil {
 		return err
 	}
 	if !ok {
 		return ErrNotDeleted
 	}
+	c.audit("delete_retry_job", map[string]interface{}{"retry_at": retryAt, "job_id": jobID})
 	return nil
 }
 
@@ -538,34 +891,103 @@ func (c *Client) deleteZsetJob(zsetKey string, zscore int64, jobID string) (bool
 		return false, nil, err
 	}
 
+	// The job may be held back by a closed gate, see heldScoreOffset
+	if cnt == 0 && zscore < heldScoreOffset {
+		return c.deleteZsetJob(zsetKey, zscore+heldScoreOffset, jobID)
+	}
 	return cnt > 0, jobBytes, nil
 }
 
+// deleteZsetsJob deletes the job from the first of zsetKeys it's in. See deleteZsetJob.
+func (c *Client) deleteZsetsJob(zsetKeys []string, zscore int64, jobID string) (bool, error) {
+	for _, key := range zsetKeys {
+		ok, _, err := c.deleteZsetJob(key, zscore, jobID)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// failureQueueKeys returns key, the namespace's retry or dead queue, followed by the express retry queue if key is the
+// retry queue, and the ownKey queues of the job types with JobOptions.OwnFailureQueues.
+func (c *Client) failureQueueKeys(key string, ownKey func(namespace, jobName string) string) ([]string, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	jobNames, err := redis.Strings(conn.Do("SMEMBERS", redisKeyOwnFailureQueues(c.namespace)))
+	if err != nil {
+		logError("client.failure_queue_keys", err)
+		return nil, err
+	}
+	sort.Strings(jobNames)
+
+	keys := make([]string, 0, len(jobNames)+2)
+	keys = append(keys, key)
+	if key == redisKeyRetry(c.namespace) {
+		keys = append(keys, redisKeyRetryExpress(c.namespace))
+	}
+	for _, jobName := range jobNames {
+		keys = append(keys, ownKey(c.namespace, jobName))
+	}
+	return keys, nil
+}
+
 type jobScore struct {
 	JobBytes []byte
 	Score    int64
 	job      *Job
 }
 
-func (c *Client) getZsetPage(key string, page uint) ([]jobScore, int64, error) {
-	conn := c.pool.Get()
+// getZsetPage returns a page of the jobs in keys, ordered by score as if they were all in one zset.
+func (c *Client) getZsetPage(keys []string, page uint) ([]jobScore, int64, error) {
+	conn := c.readConn()
 	defer conn.Close()
 
 	if page == 0 {
 		page = 1
 	}
 
-	values, err := redis.Values(conn.Do("ZRANGEBYSCORE", key, "-inf", "+inf", "WITHSCORES", "LIMIT", (page-1)*20, 20))
-	if err != nil {
-		logError("client.get_zset_page.values", err)
-		return nil, 0, err
+	// With several zsets, the page can only be picked out once the first page*20 jobs of each are merged
+	offset, limit := (page-1)*20, uint(20)
+	if len(keys) > 1 {
+		offset, limit = 0, page*20
 	}
 
 	var jobsWithScores []jobScore
+	var count int64
+	for _, key := range keys {
+		values, err := redis.Values(conn.Do("ZRANGEBYSCORE", key, "-inf", "+inf", "WITHSCORES", "LIMIT", offset, limit))
+		if err != nil {
+			logError("client.get_zset_page.values", err)
+			return nil, 0, err
+		}
 
-	if err := redis.ScanSlice(values, &jobsWithScores); err != nil {
-		logError("client.get_zset_page.scan_slice", err)
-		return nil, 0, err
+		var keyJobsWithScores []jobScore
+		if err := redis.ScanSlice(values, &keyJobsWithScores); err != nil {
+			logError("client.get_zset_page.scan_slice", err)
+			return nil, 0, err
+		}
+		jobsWithScores = append(jobsWithScores, keyJobsWithScores...)
+
+		keyCount, err := redis.Int64(conn.Do("ZCARD", key))
+		if err != nil {
+			logError("client.get_zset_page.int64", err)
+			return nil, 0, err
+		}
+		count += keyCount
+	}
+
+	if len(keys) > 1 {
+		sort.SliceStable(jobsWithScores, func(i, j int) bool { return jobsWithScores[i].Score < jobsWithScores[j].Score })
+		if start := int((page - 1) * 20); start < len(jobsWithScores) {
+			jobsWithScores = jobsWithScores[start:]
+		} else {
+			jobsWithScores = nil
+		}
+		if len(jobsWithScores) > 20 {
+			jobsWithScores = jobsWithScores[:20]
+		}
 	}
 
 	for i, jws := range jobsWithScores {
@@ -576,12 +998,9 @@ func (c *Client) getZsetPage(key string, page uint) ([]jobScore, int64, error) {
 		}
 
 		jobsWithScores[i].job = job
-	}
-
-	count, err := redis.Int64(conn.Do("ZCARD", key))
-	if err != nil {
-		logError("client.get_zset_page.int64", err)
-		return nil, 0, err
+		if jws.Score >= heldScoreOffset { // held back by a closed gate
+			jobsWithScores[i].Score -= heldScoreOffset
+		}
 	}
 
 	return jobsWithScores, count, nil
@@ -24,6 +24,8 @@ func TestClientWorkerPoolHeartbeats(t *testing.T) {
 	wp2 := NewWorkerPool(TestContext{}, 11, ns, pool)
 	wp2.Job("foo", func(job *Job) error { return nil })
 	wp2.Job("bar", func(job *Job) error { return nil })
+	wp2.DisableJobType("foo")
+	wp2.Quiet()
 	wp2.Start()
 
 	time.Sleep(20 * time.Millisecond)
@@ -48,11 +50,15 @@ func TestClientWorkerPoolHeartbeats(t *testing.T) {
 		assert.EqualValues(t, uint(10), hbwp.Concurrency)
 		assert.Equal(t, []string{"bob", "wat"}, hbwp.JobNames)
 		assert.Equal(t, wp.workerIDs(), hbwp.WorkerIDs)
+		assert.Nil(t, hbwp.DisabledJobNames)
+		assert.False(t, hbwp.Quiet)
 
 		assert.Equal(t, wp2.workerPoolID, hbwp2.WorkerPoolID)
 		assert.EqualValues(t, uint(11), hbwp2.Concurrency)
 		assert.Equal(t, []string{"bar", "foo"}, hbwp2.JobNames)
 		assert.Equal(t, wp2.workerIDs(), hbwp2.WorkerIDs)
+		assert.Equal(t, []string{"foo"}, hbwp2.DisabledJobNames)
+		assert.True(t, hbwp2.Quiet)
 	}
 
 	wp.Stop()
@@ -63,6 +69,42 @@ func TestClientWorkerPoolHeartbeats(t *testing.T) {
 	assert.Equal(t, 0, len(hbs))
 }
 
+func TestClientSamplerReport(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	client := NewClient(ns, pool)
+	reports, err := client.SamplerReport()
+	assert.NoError(t, err)
+	assert.Empty(t, reports)
+
+	jobTypes := map[string]*jobType{"bulk": nil, "urgent": nil}
+	stats1, stats2 := &poolStats{}, &poolStats{}
+	for i := 0; i < 30; i++ {
+		stats1.samplerPicked("urgent", 3)
+		stats1.samplerFetched("urgent")
+	}
+	for i := 0; i < 10; i++ {
+		stats2.samplerPicked("bulk", 1)
+	}
+	for i := 0; i < 5; i++ {
+		stats2.samplerFetched("bulk")
+	}
+	for i, stats := range []*poolStats{stats1, stats2} {
+		heart := newWorkerPoolHeartbeater(ns, pool, fmt.Sprint("pool", i), jobTypes, 1, nil)
+		heart.disabled, heart.stats = newJobNameSet(), stats
+		heart.heartbeat()
+	}
+
+	reports, err = client.SamplerReport()
+	assert.NoError(t, err)
+	assert.Equal(t, []*SamplerReport{
+		{JobName: "bulk", Priority: 1, Picks: 10, Fetched: 5, ExpectedShare: 0.25, PickShare: 0.25, FetchShare: 5.0 / 35},
+		{JobName: "urgent", Priority: 3, Picks: 30, Fetched: 30, ExpectedShare: 0.75, PickShare: 0.75, FetchShare: 30.0 / 35},
+	}, reports)
+}
+
 func TestClientWorkerObservations(t *testing.T) {
 	pool := newTestPool(":6379")
 	ns := "work"
@@ -183,6 +225,40 @@ func TestClientQueues(t *testing.T) {
 	assert.EqualValues(t, 0, queues[2].Latency)
 }
 
+func TestClientWithReplica(t *testing.T) {
+	pool := newTestPool(":6379")
+	replica := newTestPoolDB(":6379", 1)
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	cleanKeyspace(ns, replica)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue("wat", nil)
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueIn("wat", 300, nil)
+	assert.NoError(t, err)
+
+	// A second database stands in for a replica that hasn't caught up, so reads come back empty
+	client := NewClient(ns, pool).WithReplica(replica)
+	queues, err := client.Queues()
+	assert.NoError(t, err)
+	assert.Empty(t, queues)
+	jobs, count, err := client.ScheduledJobs(1)
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+	assert.EqualValues(t, 0, count)
+
+	// While writes go to the primary
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigPaused, "wat"), "true"))
+	config, err := NewClient(ns, pool).Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "true", config[JobConfigKey(ConfigPaused, "wat")])
+
+	queues, err = NewClient(ns, pool).Queues()
+	assert.NoError(t, err)
+	assert.Len(t, queues, 1)
+}
+
 func TestClientScheduledJobs(t *testing.T) {
 	pool := newTestPool(":6379")
 	ns := "work"
@@ -724,3 +800,62 @@ func getQueuedJob(ns string, pool *redis.Pool, name string) *Job {
 	}
 	return job
 }
+
+func TestClientOwnFailureQueues(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 25; i++ {
+		_, err := enqueuer.Enqueue("flood", Q{"i": i})
+		assert.NoError(t, err)
+	}
+	_, err := enqueuer.Enqueue("wat", nil)
+	assert.NoError(t, err)
+	_, err = enqueuer.Enqueue("wat", nil)
+	assert.NoError(t, err)
+
+	wp := NewWorkerPool(TestContext{}, 2, ns, pool)
+	fail := func(job *Job) error { return fmt.Errorf("ohno") }
+	wp.JobWithOptions("flood", JobOptions{Priority: 1, MaxFails: 1, OwnFailureQueues: true}, fail)
+	wp.JobWithOptions("wat", JobOptions{Priority: 1, MaxFails: 1}, fail)
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.EqualValues(t, 2, zsetSize(pool, redisKeyDead(ns)))
+	assert.EqualValues(t, 25, zsetSize(pool, redisKeyDeadOf(ns, "flood")))
+
+	// Listings cover every dead queue
+	client := NewClient(ns, pool)
+	page1, count, err := client.DeadJobs(1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 27, count)
+	assert.Len(t, page1, 20)
+	page2, count, err := client.DeadJobs(2)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 27, count)
+	assert.Len(t, page2, 7)
+
+	ids := make(map[string]bool)
+	var watJob *DeadJob
+	for _, j := range append(page1, page2...) {
+		ids[j.ID] = true
+		if j.Name == "wat" {
+			watJob = j
+		}
+	}
+	assert.Len(t, ids, 27)
+
+	// As do the operations on dead jobs
+	assert.NoError(t, client.RetryDeadJob(page1[0].DiedAt, page1[0].ID))
+	assert.NoError(t, client.DeleteDeadJob(watJob.DiedAt, watJob.ID))
+	_, count, err = client.DeadJobs(1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 25, count)
+
+	assert.NoError(t, client.DeleteAllDeadJobs())
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyDead(ns)))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyDeadOf(ns, "flood")))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/gocraft/work/workbench"
+	"github.com/gomodule/redigo/redis"
+)
+
+var redisHostPort = flag.String("redis", ":6379", "redis hostport")
+var redisNamespace = flag.String("ns", "workbench", "redis namespace; all keys in it are deleted")
+var jobs = flag.Int("jobs", 10000, "number of jobs per benchmark")
+var jobTypes = flag.Int("job-types", 1, "number of job types")
+var concurrency = flag.Uint("concurrency", 10, "number of workers")
+var handlerTime = flag.Duration("handler-time", 0, "time each handler takes")
+var batchSize = flag.Uint("batch-size", 0, "JobOptions.BatchSize for each job type")
+var out = flag.String("out", "", "write results as JSON to this file")
+var baseline = flag.String("baseline", "", "compare results to the JSON results in this file and exit 1 on regressions")
+var tolerance = flag.Float64("tolerance", 0.2, "allowed slowdown relative to -baseline, eg 0.2 for 20%")
+
+func main() {
+	flag.Parse()
+
+	cfg := workbench.Config{
+		Pool:        newPool(*redisHostPort),
+		Namespace:   *redisNamespace,
+		Jobs:        *jobs,
+		JobTypes:    *jobTypes,
+		Concurrency: *concurrency,
+		HandlerTime: *handlerTime,
+		BatchSize:   *batchSize,
+	}
+
+	var results []workbench.Result
+	for _, bench := range []func(workbench.Config) (workbench.Result, error){
+		workbench.EnqueueRate,
+		workbench.FetchRate,
+		workbench.EndToEndLatency,
+	} {
+		r, err := bench(cfg)
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		fmt.Println(r)
+		results = append(results, r)
+	}
+
+	if *out != "" {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		if err := ioutil.WriteFile(*out, b, 0644); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+	}
+
+	if *baseline != "" {
+		b, err := ioutil.ReadFile(*baseline)
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		var base []workbench.Result
+		if err := json.Unmarshal(b, &base); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		if err := workbench.Compare(base, results, *tolerance); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+}
+
+func newPool(addr string) *redis.Pool {
+	return &redis.Pool{
+		MaxActive:   int(*concurrency) + 10,
+		MaxIdle:     int(*concurrency) + 10,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+		Wait: true,
+	}
+}
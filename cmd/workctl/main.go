@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+)
+
+var redisHostPort = flag.String("redis", ":6379", "redis hostport")
+var redisNamespace = flag.String("ns", "work", "redis namespace")
+var actor = flag.String("actor", os.Getenv("USER"), "who to record in the audit log as having made changes")
+
+const usage = `usage: workctl [flags] <command> [args]
+
+commands:
+  config                  print the namespace config
+  config set <key> <val>  set a config key, eg "config set rate_limit:send_email 10"
+  config unset <key>      delete a config key
+  audit [page]            print the audit log, most recent first
+  watch [interval]        print the queues and workers every interval, 2s by default, until interrupted
+  top                     show a live view of the queues, rates, workers and recent failures
+
+flags:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := work.NewClient(*redisNamespace, newPool(*redisHostPort)).WithActor("workctl:" + *actor)
+
+	var err error
+	switch args[0] {
+	case "config":
+		err = configCommand(client, args[1:])
+	case "audit":
+		err = auditCommand(client, args[1:])
+	case "watch":
+		err = watchCommand(client, args[1:])
+	case "top":
+		err = topCommand(client)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "workctl:", err)
+		os.Exit(1)
+	}
+}
+
+func configCommand(client *work.Client, args []string) error {
+	switch {
+	case len(args) == 0:
+		cfg, err := client.Config()
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(cfg))
+		for k := range cfg {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("%s = %s\n", k, cfg[k])
+		}
+		return nil
+	case args[0] == "set" && len(args) == 3:
+		return client.SetConfig(args[1], args[2])
+	case args[0] == "unset" && len(args) == 2:
+		return client.DeleteConfig(args[1])
+	}
+	flag.Usage()
+	os.Exit(2)
+	return nil
+}
+
+func auditCommand(client *work.Client, args []string) error {
+	page := uint64(1)
+	if len(args) > 0 {
+		var err error
+		if page, err = strconv.ParseUint(args[0], 10, 0); err != nil {
+			return err
+		}
+	}
+
+	entries, count, err := client.AuditLog(uint(page))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		fmt.Printf("%s %-22s %-20s %s:%d %v\n", time.Unix(e.At, 0).Format(time.RFC3339), e.Action, e.Actor, e.Hostname, e.Pid, e.Details)
+	}
+	fmt.Printf("(%d entries)\n", count)
+	return nil
+}
+
+func watchCommand(client *work.Client, args []string) error {
+	interval := 2 * time.Second
+	if len(args) > 0 {
+		var err error
+		if interval, err = time.ParseDuration(args[0]); err != nil {
+			return err
+		}
+	}
+
+	ctx := interruptContext()
+
+	client.Watch(ctx, interval, func(stats work.Stats) {
+		fmt.Printf("%s  workers %d/%d busy  scheduled %d  retry %d  dead %d\n", time.Unix(stats.At, 0).Format(time.RFC3339),
+			stats.BusyWorkers, stats.Workers, stats.Scheduled, stats.Retry, stats.Dead)
+		for _, q := range stats.Queues {
+			fmt.Printf("  %-30s %8d  latency %ds\n", q.JobName, q.Count, q.Latency)
+		}
+	})
+	return nil
+}
+
+// interruptContext returns a context that's done once the process is interrupted, eg with ctrl-C.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	return ctx
+}
+
+func newPool(addr string) *redis.Pool {
+	return &redis.Pool{
+		MaxActive:   2,
+		MaxIdle:     2,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+		Wait: true,
+	}
+}
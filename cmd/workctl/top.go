@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gocraft/work"
+)
+
+// topFailures is how many of the latest failures top shows.
+const topFailures = 5
+
+// topCommand redraws a live view of the namespace every second until interrupted, eg over SSH where the web UI can't
+// be reached. Rates are worked out from the change since the previous snapshot, so they're blank on the first.
+func topCommand(client *work.Client) error {
+	ctx := interruptContext()
+
+	var prev *work.Stats
+	client.Watch(ctx, time.Second, func(stats work.Stats) {
+		failures, _, err := client.RetryJobs(1)
+		if err != nil {
+			failures = nil
+		}
+		renderTop(&stats, prev, failures)
+		prev = &stats
+	})
+	return nil
+}
+
+func renderTop(stats, prev *work.Stats, failures []*work.RetryJob) {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J") // move home and clear the screen
+	fmt.Fprintf(&b, "workctl top - %s - %s\n\n", *redisNamespace, time.Unix(stats.At, 0).Format(time.RFC3339))
+	fmt.Fprintf(&b, "pools %d  workers %d/%d busy  scheduled %d  retry %d%s  dead %d%s\n\n",
+		len(stats.WorkerPools), stats.BusyWorkers, stats.Workers, stats.Scheduled,
+		stats.Retry, rate(prev, stats, func(s *work.Stats) int64 { return s.Retry }),
+		stats.Dead, rate(prev, stats, func(s *work.Stats) int64 { return s.Dead }))
+
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "QUEUE\tJOBS\tRATE\tLATENCY")
+	for _, q := range stats.Queues {
+		jobName := q.JobName
+		count := func(s *work.Stats) int64 {
+			for _, q := range s.Queues {
+				if q.JobName == jobName {
+					return q.Count
+				}
+			}
+			return 0
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%ds\n", q.JobName, q.Count, strings.TrimSpace(rate(prev, stats, count)), q.Latency)
+	}
+	w.Flush()
+
+	sort.Slice(failures, func(i, j int) bool { return failures[i].FailedAt > failures[j].FailedAt })
+	if len(failures) > topFailures {
+		failures = failures[:topFailures]
+	}
+	if len(failures) > 0 {
+		b.WriteString("\nRECENT FAILURES\n")
+		for _, f := range failures {
+			fmt.Fprintf(&b, "%s  %-24s %s  %s\n", time.Unix(f.FailedAt, 0).Format("15:04:05"), f.Name, f.ID, f.LastErr)
+		}
+	}
+
+	os.Stdout.WriteString(b.String())
+}
+
+// rate returns how fast value changed per second since prev, eg " (+2.5/s)", or "" if there's no prev.
+func rate(prev, stats *work.Stats, value func(*work.Stats) int64) string {
+	if prev == nil || stats.At <= prev.At {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.1f/s)", float64(value(stats)-value(prev))/float64(stats.At-prev.At))
+}
@@ -15,6 +15,7 @@ import (
 var (
 	redisHostPort  = flag.String("redis", ":6379", "redis hostport")
 	redisDatabase  = flag.String("database", "0", "redis database")
+	redisReplica   = flag.String("replica", "", "redis hostport of a replica to read listings and stats from")
 	redisNamespace = flag.String("ns", "work", "redis namespace")
 	webHostPort    = flag.String("listen", ":5040", "hostport to listen for HTTP JSON API")
 )
@@ -25,6 +26,7 @@ func main() {
 	fmt.Println("Starting workwebui:")
 	fmt.Println("redis = ", *redisHostPort)
 	fmt.Println("database = ", *redisDatabase)
+	fmt.Println("replica = ", *redisReplica)
 	fmt.Println("namespace = ", *redisNamespace)
 	fmt.Println("listen = ", *webHostPort)
 
@@ -37,6 +39,9 @@ func main() {
 	pool := newPool(*redisHostPort, database)
 
 	server := webui.NewServer(*redisNamespace, pool, *webHostPort)
+	if *redisReplica != "" {
+		server.UseReplica(newPool(*redisReplica, database))
+	}
 	server.Start()
 
 	c := make(chan os.Signal, 1)
@@ -0,0 +1,463 @@
+package work
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Settings that can be stored in a namespace's config with Client.SetConfig. Worker pools watch the config and apply
+// changes within a few seconds, without being restarted. Per job type settings are keyed by JobConfigKey(setting,
+// jobName); the others apply to the whole namespace and are keyed by the setting itself.
+const (
+	ConfigPaused         = "paused"          // Per job type. "true" pauses the queue: no pool fetches from it.
+	ConfigMaxConcurrency = "max_concurrency" // Per job type. Overrides JobOptions.MaxConcurrency; 0 means no max.
+	ConfigRateLimit      = "rate_limit"      // Per job type. The most jobs per second each worker pool fetches, eg "2.5".
+	ConfigPriority       = "priority"        // Per job type. Overrides JobOptions.Priority, from 1 to 100000.
+	ConfigDeadRetention  = "dead_retention"  // Dead jobs that died longer ago than this duration, eg "720h", are deleted, unless their type has a JobOptions.DeadRetention.
+	ConfigMaxDeadJobs    = "max_dead_jobs"   // Only this many of the most recently dead jobs are kept.
+	ConfigShed           = "shed"            // Job types whose jobs are dropped as soon as they're fetched, comma separated, eg "thumbnails,reports". See Client.SetShed.
+
+	ConfigDisabledCapabilities = "disabled_capabilities" // Capabilities that stay off even once every pool supports them, comma separated. See CapabilityExpressRetries.
+)
+
+const (
+	configPollInterval = 2 * time.Second
+	deadTrimInterval   = 1 * time.Minute
+	deadTrimMaxScanned = 1000
+)
+
+// JobConfigKey returns the config key of a per job type setting, eg JobConfigKey(ConfigPaused, "send_email").
+func JobConfigKey(setting, jobName string) string {
+	return setting + ":" + jobName
+}
+
+func splitConfigKey(key string) (setting, jobName string) {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
+
+func validateConfig(key, value string) error {
+	setting, jobName := splitConfigKey(key)
+	perJobType := setting == ConfigPaused || setting == ConfigMaxConcurrency || setting == ConfigRateLimit || setting == ConfigPriority
+	if perJobType && jobName == "" {
+		return fmt.Errorf("config %q needs a job name, see JobConfigKey", setting)
+	}
+	if !perJobType && jobName != "" {
+		return fmt.Errorf("config %q doesn't take a job name", setting)
+	}
+
+	var err error
+	switch setting {
+	case ConfigPaused:
+		_, err = strconv.ParseBool(value)
+	case ConfigMaxConcurrency:
+		_, err = strconv.ParseUint(value, 10, 32)
+	case ConfigRateLimit:
+		err = validatePositive(strconv.ParseFloat(value, 64))
+	case ConfigPriority:
+		var n uint64
+		if n, err = strconv.ParseUint(value, 10, 32); err == nil && (n < 1 || n > 100000) {
+			err = fmt.Errorf("must be between 1 and 100000")
+		}
+	case ConfigDeadRetention:
+		var d time.Duration
+		d, err = time.ParseDuration(value)
+		err = validatePositive(float64(d), err)
+	case ConfigMaxDeadJobs:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 64)
+		err = validatePositive(float64(n), err)
+	case ConfigShed:
+		if len(parseNames(value)) == 0 {
+			err = fmt.Errorf("needs at least one job name")
+		}
+	case ConfigDisabledCapabilities:
+		if len(parseNames(value)) == 0 {
+			err = fmt.Errorf("needs at least one capability")
+		}
+	default:
+		return fmt.Errorf("unknown config %q", setting)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for config %q: %v", value, key, err)
+	}
+	return nil
+}
+
+// parseNames returns the names listed by a comma separated config value, eg ConfigShed's job names.
+func parseNames(value string) map[string]bool {
+	shed := make(map[string]bool)
+	for _, jobName := range strings.Split(value, ",") {
+		if jobName = strings.TrimSpace(jobName); jobName != "" {
+			shed[jobName] = true
+		}
+	}
+	return shed
+}
+
+func validatePositive(v float64, err error) error {
+	if err == nil && v <= 0 {
+		err = fmt.Errorf("must be positive")
+	}
+	return err
+}
+
+// liveConfig holds the settings from the namespace config that workers consult, as last read by the configWatcher. A
+// nil *liveConfig has no settings.
+type liveConfig struct {
+	mtx          sync.RWMutex
+	rateLimiters map[string]*rateLimiter
+	priorities   map[string]uint // replaced rather than modified
+	version      uint64          // bumped whenever priorities change, so workers only re-weigh their samplers then
+	shed         map[string]bool // replaced rather than modified
+	capabilities map[string]bool // replaced rather than modified; nil until the first check
+}
+
+func newLiveConfig() *liveConfig {
+	return &liveConfig{rateLimiters: make(map[string]*rateLimiter)}
+}
+
+func (c *liveConfig) setRateLimits(rates map[string]float64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for jobName, rate := range rates {
+		if l := c.rateLimiters[jobName]; l != nil {
+			l.setRate(rate)
+		} else {
+			c.rateLimiters[jobName] = newRateLimiter(rate)
+		}
+	}
+	for jobName := range c.rateLimiters {
+		if _, ok := rates[jobName]; !ok {
+			delete(c.rateLimiters, jobName)
+		}
+	}
+}
+
+func (c *liveConfig) setPriorities(priorities map[string]uint) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if reflect.DeepEqual(priorities, c.priorities) {
+		return
+	}
+	c.priorities = priorities
+	atomic.AddUint64(&c.version, 1)
+}
+
+// prioritiesVersion is cheap enough to check on every fetch.
+func (c *liveConfig) prioritiesVersion() uint64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.version)
+}
+
+// priorityOverrides returns the job types whose priority is overridden, which mustn't be modified, and their version.
+func (c *liveConfig) priorityOverrides() (map[string]uint, uint64) {
+	if c == nil {
+		return nil, 0
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.priorities, atomic.LoadUint64(&c.version)
+}
+
+func (c *liveConfig) setShed(shed map[string]bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.shed = shed
+}
+
+// isShed returns whether jobs named jobName are to be dropped rather than run.
+func (c *liveConfig) isShed(jobName string) bool {
+	if c == nil {
+		return false
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.shed[jobName]
+}
+
+func (c *liveConfig) setCapabilities(capabilities map[string]bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.capabilities = capabilities
+}
+
+// capable returns whether capability is active in the namespace. See CapabilityExpressRetries.
+func (c *liveConfig) capable(capability string) bool {
+	if c == nil {
+		return false
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.capabilities[capability]
+}
+
+func (c *liveConfig) rateLimiter(jobName string) *rateLimiter {
+	if c == nil {
+		return nil
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.rateLimiters[jobName]
+}
+
+// rateLimiter is a token bucket holding up to a second's worth of jobs. Workers check ready before fetching and take
+// a token for each job they fetched, so concurrent workers can overshoot slightly; the bucket goes into debt to make
+// up for it. A nil *rateLimiter doesn't limit anything.
+type rateLimiter struct {
+	mtx    sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{rate: rate, tokens: math.Max(rate, 1), last: time.Now()}
+}
+
+func (l *rateLimiter) setRate(rate float64) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.refill(time.Now())
+	l.rate = rate
+}
+
+func (l *rateLimiter) refill(now time.Time) {
+	l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*l.rate, math.Max(l.rate, 1))
+	l.last = now
+}
+
+// available returns how many of n jobs can be fetched now.
+func (l *rateLimiter) available(n uint, now time.Time) uint {
+	if l == nil {
+		return n
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.refill(now)
+	if l.tokens < 1 {
+		return 0
+	}
+	return uint(math.Min(float64(n), l.tokens))
+}
+
+func (l *rateLimiter) ready(now time.Time) bool {
+	return l.available(1, now) == 1
+}
+
+func (l *rateLimiter) take(n int) {
+	if l == nil {
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.tokens -= float64(n)
+}
+
+// configWatcher periodically reads the namespace config and applies it to the pool: rate limits, priorities and the
+// job types to shed go to the workers through config, dead job retention (the namespace's, and that of the pool's job
+// types with JobOptions.DeadRetention) is enforced on the dead queue, and job types whose max concurrency override was
+// removed get the pool's own JobOptions.MaxConcurrency back. Pauses and max concurrency overrides are written to their
+// keys by Client.SetConfig, so they take effect right away.
+type configWatcher struct {
+	namespace    string
+	pool         *redis.Pool
+	jobTypes     map[string]*jobType
+	period       time.Duration
+	redisTimeout time.Duration
+	errorHook    ErrorHook
+	logger       Logger
+	config       *liveConfig
+
+	overridden   map[string]bool // job types with a max concurrency override at the last poll
+	lastDeadTrim time.Time
+	deadCursors  map[string]deadTrimCursor // of each dead queue, where the last trim left off
+	trimScanned  int                       // max number of dead jobs each trim looks at per queue
+
+	lastCapabilityCheck time.Time
+	trimScript          *redis.Script
+	lapseScript         *redis.Script
+
+	stopChan         chan struct{}
+	doneStoppingChan chan struct{}
+}
+
+func newConfigWatcher(namespace string, pool *redis.Pool, jobTypes map[string]*jobType, config *liveConfig) *configWatcher {
+	return &configWatcher{
+		namespace:        namespace,
+		pool:             pool,
+		jobTypes:         jobTypes,
+		period:           configPollInterval,
+		config:           config,
+		overridden:       make(map[string]bool),
+		trimScript:       redis.NewScript(1, redisLuaTrimDead),
+		deadCursors:      make(map[string]deadTrimCursor),
+		trimScanned:      deadTrimMaxScanned,
+		lapseScript:      redis.NewScript(2, redisLuaLapsePause),
+		stopChan:         make(chan struct{}),
+		doneStoppingChan: make(chan struct{}),
+	}
+}
+
+// start reads the config right away, so the pool's workers start out with it.
+func (cw *configWatcher) start() {
+	cw.poll()
+	go cw.loop()
+}
+
+func (cw *configWatcher) stop() {
+	cw.stopChan <- struct{}{}
+	<-cw.doneStoppingChan
+}
+
+func (cw *configWatcher) loop() {
+	ticker := time.NewTicker(cw.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cw.stopChan:
+			cw.doneStoppingChan <- struct{}{}
+			return
+		case <-ticker.C:
+			cw.poll()
+		}
+	}
+}
+
+func (cw *configWatcher) poll() {
+	conn := getConn(cw.pool, cw.redisTimeout)
+	defer conn.Close()
+
+	cfg, err := redis.StringMap(conn.Do("HGETALL", redisKeyConfig(cw.namespace)))
+	if err != nil {
+		reportError(cw.logger, cw.errorHook, "config_watcher.read", err)
+		return
+	}
+
+	rates := make(map[string]float64)
+	priorities := make(map[string]uint)
+	for jobName, jt := range cw.jobTypes {
+		if v, ok := cfg[JobConfigKey(ConfigRateLimit, jobName)]; ok {
+			if rate, err := strconv.ParseFloat(v, 64); err == nil && rate > 0 {
+				rates[jobName] = rate
+			}
+		}
+		if v, ok := cfg[JobConfigKey(ConfigPriority, jobName)]; ok {
+			if priority, err := strconv.ParseUint(v, 10, 32); err == nil && priority >= 1 && priority <= 100000 {
+				priorities[jobName] = uint(priority)
+			}
+		}
+
+		_, overridden := cfg[JobConfigKey(ConfigMaxConcurrency, jobName)]
+		if cw.overridden[jobName] && !overridden {
+			if _, err := conn.Do("SET", redisKeyJobsConcurrency(cw.namespace, jobName), jt.MaxConcurrency); err != nil {
+				reportError(cw.logger, cw.errorHook, "config_watcher.restore_max_concurrency", err)
+				continue
+			}
+		}
+		cw.overridden[jobName] = overridden
+
+		// A timed pause's key expires on its own, but the config still says it's paused
+		pausedKey := JobConfigKey(ConfigPaused, jobName)
+		if paused, _ := strconv.ParseBool(cfg[pausedKey]); paused {
+			_, err := cw.lapseScript.Do(conn, redisKeyConfig(cw.namespace), redisKeyJobsPaused(cw.namespace, jobName), pausedKey, cfg[pausedKey])
+			if err != nil {
+				reportError(cw.logger, cw.errorHook, "config_watcher.lapse_pause", err)
+			}
+		}
+	}
+	cw.config.setRateLimits(rates)
+	cw.config.setPriorities(priorities)
+	cw.config.setShed(parseNames(cfg[ConfigShed]))
+
+	if time.Since(cw.lastCapabilityCheck) >= capabilityCheckInterval {
+		cw.lastCapabilityCheck = time.Now()
+		if active, err := activeCapabilities(conn, cw.namespace, cfg); err != nil {
+			reportError(cw.logger, cw.errorHook, "config_watcher.capabilities", err)
+		} else {
+			cw.config.setCapabilities(active)
+		}
+	}
+
+	deadKeys := []string{redisKeyDead(cw.namespace)}
+	if _, ok := cfg[ConfigMaxDeadJobs]; ok || time.Since(cw.lastDeadTrim) >= deadTrimInterval {
+		jobNames, err := redis.Strings(conn.Do("SMEMBERS", redisKeyOwnFailureQueues(cw.namespace)))
+		if err != nil {
+			reportError(cw.logger, cw.errorHook, "config_watcher.own_failure_queues", err)
+		}
+		for _, jobName := range jobNames {
+			deadKeys = append(deadKeys, redisKeyDeadOf(cw.namespace, jobName))
+		}
+	}
+
+	if time.Since(cw.lastDeadTrim) >= deadTrimInterval {
+		cw.lastDeadTrim = time.Now()
+		cw.trimDead(conn, cfg, deadKeys)
+	}
+	// Job types with their own dead queue get their own max, so that a flood of them doesn't push out other job types
+	if v, ok := cfg[ConfigMaxDeadJobs]; ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			for _, key := range deadKeys {
+				if _, err := conn.Do("ZREMRANGEBYRANK", key, 0, -n-1); err != nil {
+					reportError(cw.logger, cw.errorHook, "config_watcher.max_dead_jobs", err)
+				}
+			}
+		}
+	}
+}
+
+// trimDead deletes the dead jobs that are past their retention. Retention is days rather than seconds, so this is done
+// less often than the config is read.
+func (cw *configWatcher) trimDead(conn redis.Conn, cfg map[string]string, deadKeys []string) {
+	var retention time.Duration
+	if d, err := time.ParseDuration(cfg[ConfigDeadRetention]); err == nil && d > 0 {
+		retention = d
+	}
+
+	// Nothing younger than the shortest retention can be past it
+	shortest := retention
+	for _, jt := range cw.jobTypes {
+		if jt.DeadRetention > 0 && (shortest == 0 || jt.DeadRetention < shortest) {
+			shortest = jt.DeadRetention
+		}
+	}
+	if shortest == 0 {
+		return
+	}
+
+	now := nowEpochSeconds()
+	for _, key := range deadKeys {
+		cursor, ok := cw.deadCursors[key]
+		if !ok {
+			cursor.score = "-inf"
+		}
+		values, err := redis.Values(cw.trimScript.Do(conn, key, now, int64(retention/time.Second), now-int64(shortest/time.Second)+1, cw.trimScanned, cursor.score, cursor.kept))
+		if err == nil {
+			_, err = redis.Scan(values, new(int64), &cursor.score, &cursor.kept)
+		}
+		if err != nil {
+			reportError(cw.logger, cw.errorHook, "config_watcher.dead_retention", err)
+			continue
+		}
+		cw.deadCursors[key] = cursor
+	}
+}
+
+// deadTrimCursor is where trimDead left off in a dead queue, see redisLuaTrimDead.
+type deadTrimCursor struct {
+	score string
+	kept  int64
+}
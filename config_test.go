@@ -0,0 +1,424 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	assert.NoError(t, validateConfig(JobConfigKey(ConfigPaused, "job1"), "true"))
+	assert.NoError(t, validateConfig(JobConfigKey(ConfigMaxConcurrency, "job1"), "0"))
+	assert.NoError(t, validateConfig(JobConfigKey(ConfigRateLimit, "job1"), "0.5"))
+	assert.NoError(t, validateConfig(ConfigDeadRetention, "720h"))
+	assert.NoError(t, validateConfig(ConfigMaxDeadJobs, "1000"))
+	assert.NoError(t, validateConfig(ConfigShed, "job1, job2"))
+
+	assert.Error(t, validateConfig("nope", "1"))
+	assert.Error(t, validateConfig(ConfigPaused, "true"))
+	assert.Error(t, validateConfig(JobConfigKey(ConfigMaxDeadJobs, "job1"), "1"))
+	assert.Error(t, validateConfig(JobConfigKey(ConfigPaused, "job1"), "maybe"))
+	assert.Error(t, validateConfig(JobConfigKey(ConfigMaxConcurrency, "job1"), "-1"))
+	assert.Error(t, validateConfig(JobConfigKey(ConfigRateLimit, "job1"), "0"))
+	assert.Error(t, validateConfig(JobConfigKey(ConfigPriority, "job1"), "0"))
+	assert.Error(t, validateConfig(JobConfigKey(ConfigPriority, "job1"), "100001"))
+	assert.Error(t, validateConfig(ConfigDeadRetention, "a while"))
+	assert.Error(t, validateConfig(ConfigMaxDeadJobs, "0"))
+	assert.Error(t, validateConfig(ConfigShed, " , "))
+	assert.Error(t, validateConfig(JobConfigKey(ConfigShed, "job1"), "job1"))
+}
+
+func TestClientSetConfig(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+	client := NewClient(ns, pool)
+
+	assert.Error(t, client.SetConfig(ConfigMaxDeadJobs, "lots"))
+
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigPaused, job1), "true"))
+	assert.EqualValues(t, 1, getInt64(pool, redisKeyJobsPaused(ns, job1)))
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigMaxConcurrency, job1), "3"))
+	assert.EqualValues(t, 3, getInt64(pool, redisKeyJobsConcurrency(ns, job1)))
+	assert.NoError(t, client.SetConfig(ConfigDeadRetention, "24h"))
+
+	cfg, err := client.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"paused:job1":          "true",
+		"max_concurrency:job1": "3",
+		"dead_retention":       "24h",
+	}, cfg)
+
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigPaused, job1), "false"))
+	assert.False(t, keyExists(pool, redisKeyJobsPaused(ns, job1)))
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigPaused, job1), "true"))
+	assert.NoError(t, client.DeleteConfig(JobConfigKey(ConfigPaused, job1)))
+	assert.False(t, keyExists(pool, redisKeyJobsPaused(ns, job1)))
+
+	cfg, err = client.Config()
+	assert.NoError(t, err)
+	assert.Len(t, cfg, 2)
+}
+
+func TestConfigWatcher(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+	client := NewClient(ns, pool)
+
+	jobTypes := map[string]*jobType{
+		job1: {Name: job1, JobOptions: JobOptions{Priority: 1, MaxConcurrency: 5}},
+	}
+
+	now := nowEpochSeconds()
+	insertDeadJob(ns, pool, job1, now-3600, now-3600)
+	insertDeadJob(ns, pool, job1, now-60, now-60)
+	insertDeadJob(ns, pool, job1, now-50, now-50)
+	insertDeadJob(ns, pool, job1, now-40, now-40)
+
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigMaxConcurrency, job1), "2"))
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigRateLimit, job1), "10"))
+	assert.NoError(t, client.SetConfig(ConfigDeadRetention, "30m"))
+	assert.NoError(t, client.SetConfig(ConfigMaxDeadJobs, "2"))
+
+	config := newLiveConfig()
+	cw := newConfigWatcher(ns, pool, jobTypes, config)
+	cw.start()
+
+	assert.NotNil(t, config.rateLimiter(job1))
+	assert.EqualValues(t, 2, zsetSize(pool, redisKeyDead(ns)))
+	assert.EqualValues(t, 2, getInt64(pool, redisKeyJobsConcurrency(ns, job1)))
+
+	// Removing the override puts the job type's own max concurrency back, and removing the rate limit lifts it
+	assert.NoError(t, client.DeleteConfig(JobConfigKey(ConfigMaxConcurrency, job1)))
+	assert.NoError(t, client.DeleteConfig(JobConfigKey(ConfigRateLimit, job1)))
+	cw.poll()
+	cw.stop()
+
+	assert.Nil(t, config.rateLimiter(job1))
+	assert.EqualValues(t, 5, getInt64(pool, redisKeyJobsConcurrency(ns, job1)))
+}
+
+func TestClientPauseFor(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1, job2 := "job1", "job2"
+	cleanKeyspace(ns, pool)
+	client := NewClient(ns, pool)
+
+	assert.Error(t, client.PauseFor(job1, time.Millisecond))
+	assert.NoError(t, client.PauseFor(job1, time.Hour))
+	assert.NoError(t, client.SetConfig(JobConfigKey(ConfigPaused, job2), "true"))
+	assert.True(t, keyExists(pool, redisKeyJobsPaused(ns, job1)))
+
+	conn := pool.Get()
+	ttl, err := redis.Int64(conn.Do("TTL", redisKeyJobsPaused(ns, job1)))
+	assert.NoError(t, err)
+	assert.True(t, ttl > 3590 && ttl <= 3600)
+
+	// Once the pause lapses, the config watcher drops it from the config, but leaves untimed pauses alone
+	_, err = conn.Do("DEL", redisKeyJobsPaused(ns, job1))
+	assert.NoError(t, err)
+	conn.Close()
+
+	jobTypes := map[string]*jobType{job1: {Name: job1}, job2: {Name: job2}}
+	newConfigWatcher(ns, pool, jobTypes, newLiveConfig()).poll()
+	cfg, err := client.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"paused:job2": "true"}, cfg)
+	assert.True(t, keyExists(pool, redisKeyJobsPaused(ns, job2)))
+}
+
+func TestClientSetQueuePriority(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	client := NewClient(ns, pool)
+
+	jobTypes := map[string]*jobType{
+		"bulk":   {Name: "bulk", JobOptions: JobOptions{Priority: 100}},
+		"urgent": {Name: "urgent", JobOptions: JobOptions{Priority: 10}},
+	}
+	config := newLiveConfig()
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.config = config
+	priorities := func() map[string]uint {
+		p := make(map[string]uint)
+		for _, s := range w.sampler.samples {
+			p[s.jobName] = s.priority
+		}
+		return p
+	}
+
+	assert.Error(t, client.SetQueuePriority("bulk", 0))
+	assert.NoError(t, client.SetQueuePriority("bulk", 1))
+
+	cw := newConfigWatcher(ns, pool, jobTypes, config)
+	cw.poll()
+	_, err := w.fetchJob()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint{"bulk": 1, "urgent": 10}, priorities())
+	assert.EqualValues(t, 11, w.sampler.sum)
+
+	// Polling an unchanged config leaves the samplers be
+	version := config.prioritiesVersion()
+	cw.poll()
+	assert.Equal(t, version, config.prioritiesVersion())
+
+	assert.NoError(t, client.DeleteConfig(JobConfigKey(ConfigPriority, "bulk")))
+	cw.poll()
+	_, err = w.fetchJob()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint{"bulk": 100, "urgent": 10}, priorities())
+	assert.EqualValues(t, 110, w.sampler.sum)
+}
+
+func TestWorkerPoolMaxConcurrencyOverride(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	assert.NoError(t, NewClient(ns, pool).SetConfig(JobConfigKey(ConfigMaxConcurrency, job1), "1"))
+
+	wp := setupTestWorkerPool(pool, ns, job1, 3, JobOptions{Priority: 1, MaxConcurrency: 3})
+	wp.Start()
+	wp.Stop()
+
+	// The pool didn't clobber the override with its JobOptions
+	assert.EqualValues(t, 1, getInt64(pool, redisKeyJobsConcurrency(ns, job1)))
+}
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Now()
+	l := newRateLimiter(2)
+	l.last = start
+
+	assert.EqualValues(t, 2, l.available(5, start))
+	l.take(2)
+	assert.False(t, l.ready(start))
+	assert.True(t, l.ready(start.Add(500*time.Millisecond)))
+
+	// Overshooting puts the bucket in debt
+	l.take(3)
+	assert.False(t, l.ready(start.Add(1500*time.Millisecond)))
+	assert.True(t, l.ready(start.Add(2000*time.Millisecond)))
+
+	// It never holds more than a second's worth
+	assert.EqualValues(t, 2, l.available(5, start.Add(time.Hour)))
+
+	var unlimited *rateLimiter
+	assert.EqualValues(t, 5, unlimited.available(5, start))
+	unlimited.take(1)
+}
+
+func TestWorkerRateLimit(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	var ran int
+	jobTypes := map[string]*jobType{
+		job1: {
+			Name:       job1,
+			JobOptions: JobOptions{Priority: 1},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				ran++
+				return nil
+			},
+		},
+	}
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 3; i++ {
+		_, err := enqueuer.Enqueue(job1, nil)
+		assert.NoError(t, err)
+	}
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.config = newLiveConfig()
+	w.config.setRateLimits(map[string]float64{job1: 0.1})
+	w.start()
+	w.drain()
+	w.stop()
+
+	// One job's worth of tokens to start with, then nothing for the next 10 seconds
+	assert.Equal(t, 1, ran)
+	assert.EqualValues(t, 2, listSize(pool, redisKeyJobs(ns, job1)))
+}
+
+func TestWorkerShed(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	job2 := "job2"
+	cleanKeyspace(ns, pool)
+	client := NewClient(ns, pool)
+
+	var ran []string
+	handler := func(job *Job) error {
+		ran = append(ran, job.Name)
+		return nil
+	}
+	jobTypes := map[string]*jobType{
+		job1: {Name: job1, JobOptions: JobOptions{Priority: 1}, IsGeneric: true, GenericHandler: handler},
+		job2: {Name: job2, JobOptions: JobOptions{Priority: 1}, IsGeneric: true, GenericHandler: handler},
+	}
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 2; i++ {
+		_, err := enqueuer.Enqueue(job1, nil)
+		assert.NoError(t, err)
+	}
+	_, err := enqueuer.Enqueue(job2, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.SetShed(job1))
+	config := newLiveConfig()
+	newConfigWatcher(ns, pool, jobTypes, config).poll()
+	assert.True(t, config.isShed(job1))
+
+	stats := &poolStats{}
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.config = config
+	w.stats = stats
+	w.start()
+	w.drain()
+	w.stop()
+
+	// The shed jobs were acked without running
+	assert.Equal(t, []string{job2}, ran)
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, job1)))
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobsInProgress(ns, "1", job1)))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyDead(ns)))
+	assert.EqualValues(t, 2, stats.snapshot().Shed)
+
+	counts, err := client.ShedCounts()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{job1: 2}, counts)
+
+	assert.NoError(t, client.ResetShedCounts())
+	counts, err = client.ShedCounts()
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+
+	// Shedding nothing puts the job type back to work
+	assert.NoError(t, client.SetShed())
+	newConfigWatcher(ns, pool, jobTypes, config).poll()
+	assert.False(t, config.isShed(job1))
+}
+
+func keyExists(pool *redis.Pool, key string) bool {
+	conn := pool.Get()
+	defer conn.Close()
+
+	exists, err := redis.Bool(conn.Do("EXISTS", key))
+	if err != nil {
+		panic("could not EXISTS: " + err.Error())
+	}
+	return exists
+}
+
+func TestConfigWatcherDeadRetentionPerJobType(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	day := int64(24 * 60 * 60)
+	jobTypes := map[string]*jobType{
+		"payment":    {Name: "payment", JobOptions: JobOptions{Priority: 1, MaxFails: 1, DeadRetention: 365 * 24 * time.Hour}},
+		"cache_warm": {Name: "cache_warm", JobOptions: JobOptions{Priority: 1, MaxFails: 1, DeadRetention: 24 * time.Hour}},
+	}
+
+	// Dead jobs as workers leave them, with their type's retention
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	now := nowEpochSeconds()
+	insertDead := func(jobName string, diedAt int64) {
+		fate := w.jobFate(jobTypes[jobName], &Job{Name: jobName, ID: makeIdentifier(), Fails: 1}, Dead("unrecoverable"))
+		zadd(pool, fate.zsetKey, diedAt, fate.rawJSON)
+	}
+	insertDead("payment", now-300*day)
+	insertDead("payment", now-400*day)
+	insertDead("cache_warm", now-2*day)
+	insertDead("cache_warm", now-3600)
+	insertDeadJob(ns, pool, "other", now-40*day, now-40*day)
+	insertDeadJob(ns, pool, "other", now-2*day, now-2*day)
+
+	cw := newConfigWatcher(ns, pool, jobTypes, newLiveConfig())
+	cw.poll()
+
+	// Without a namespace retention, only the job types with their own are trimmed
+	assert.EqualValues(t, 4, zsetSize(pool, redisKeyDead(ns)))
+
+	assert.NoError(t, NewClient(ns, pool).SetConfig(ConfigDeadRetention, "720h"))
+	cw.lastDeadTrim = time.Time{}
+	cw.poll()
+
+	jobs, _, err := NewClient(ns, pool).DeadJobs(1)
+	assert.NoError(t, err)
+	var kept []int64
+	for _, j := range jobs {
+		kept = append(kept, (now-j.DiedAt)/day)
+	}
+	assert.Equal(t, []int64{300, 2, 0}, kept)
+}
+
+func TestConfigWatcherDeadRetentionCursor(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	day := int64(24 * 60 * 60)
+	jobTypes := map[string]*jobType{
+		"payment":    {Name: "payment", JobOptions: JobOptions{Priority: 1, MaxFails: 1, DeadRetention: 365 * 24 * time.Hour}},
+		"cache_warm": {Name: "cache_warm", JobOptions: JobOptions{Priority: 1, MaxFails: 1, DeadRetention: 24 * time.Hour}},
+	}
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	now := nowEpochSeconds()
+	insertDead := func(jobName string, diedAt int64) {
+		fate := w.jobFate(jobTypes[jobName], &Job{Name: jobName, ID: makeIdentifier(), Fails: 1}, Dead("unrecoverable"))
+		zadd(pool, fate.zsetKey, diedAt, fate.rawJSON)
+	}
+	// More payments kept for a year than a trim looks at, all older than the cache warming jobs past their retention
+	for i := int64(0); i < 5; i++ {
+		insertDead("payment", now-10*day-i)
+	}
+	insertDead("payment", now-10*day) // with the same score as another
+	insertDead("cache_warm", now-3*day)
+	insertDead("cache_warm", now-2*day)
+
+	cw := newConfigWatcher(ns, pool, jobTypes, newLiveConfig())
+	cw.trimScanned = 4
+	trim := func() {
+		conn := pool.Get()
+		defer conn.Close()
+		cw.trimDead(conn, map[string]string{}, []string{redisKeyDead(ns)})
+	}
+
+	// The first trim only gets through payments, the next picks up after them
+	trim()
+	assert.EqualValues(t, 8, zsetSize(pool, redisKeyDead(ns)))
+	trim()
+	assert.EqualValues(t, 6, zsetSize(pool, redisKeyDead(ns)))
+
+	// Having reached the end, the next trim starts over
+	trim()
+	assert.Equal(t, deadTrimCursor{score: "-inf"}, cw.deadCursors[redisKeyDead(ns)])
+	assert.EqualValues(t, 6, zsetSize(pool, redisKeyDead(ns)))
+}
+
+func zadd(pool *redis.Pool, key string, score int64, member []byte) {
+	conn := pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("ZADD", key, score, member); err != nil {
+		panic("could not ZADD: " + err.Error())
+	}
+}
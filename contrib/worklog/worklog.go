@@ -0,0 +1,33 @@
+// Package worklog is middleware that logs each job a worker pool runs through a work.Logger, so the jobs show up in
+// the same logs, and in the same structured form, as the package's own messages. Loggers such as zap's or slog's are
+// adapted with work.LoggerFunc.
+package worklog
+
+import (
+	"time"
+
+	"github.com/gocraft/work"
+)
+
+// Middleware returns middleware that logs each job to logger: "job.started" at debug level before it runs, then
+// "job.done" at info level or "job.failed" at warn level, with the job's name, ID, attempt and how long it took.
+// Failures are warnings since most are retried. Add it first so the time includes the other middleware:
+//
+//	pool.Middleware(worklog.Middleware(logger))
+func Middleware(logger work.Logger) func(*work.Job, work.NextMiddlewareFunc) error {
+	return func(job *work.Job, next work.NextMiddlewareFunc) error {
+		attempt := job.Fails + 1
+		logger.Log(work.LogDebug, "job.started", "job", job.Name, "job_id", job.ID, "attempt", attempt)
+
+		startedAt := time.Now()
+		err := next()
+		took := time.Since(startedAt)
+
+		if err != nil {
+			logger.Log(work.LogWarn, "job.failed", "job", job.Name, "job_id", job.ID, "attempt", attempt, "took", took, "error", err)
+		} else {
+			logger.Log(work.LogInfo, "job.done", "job", job.Name, "job_id", job.ID, "attempt", attempt, "took", took)
+		}
+		return err
+	}
+}
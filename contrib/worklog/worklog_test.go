@@ -0,0 +1,30 @@
+package worklog
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gocraft/work"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var logged []string
+	logger := work.LoggerFunc(func(level work.LogLevel, msg string, keyvals ...interface{}) {
+		logged = append(logged, fmt.Sprintf("%s %s %v", level, msg, keyvals[:6]))
+	})
+	mw := Middleware(logger)
+	job := &work.Job{Name: "export", ID: "abc", Fails: 1}
+
+	assert.NoError(t, mw(job, func() error { return nil }))
+	errFailed := errors.New("failed")
+	assert.Equal(t, errFailed, mw(job, func() error { return errFailed }))
+
+	assert.Equal(t, []string{
+		"DEBUG job.started [job export job_id abc attempt 2]",
+		"INFO job.done [job export job_id abc attempt 2]",
+		"DEBUG job.started [job export job_id abc attempt 2]",
+		"WARN job.failed [job export job_id abc attempt 2]",
+	}, logged)
+}
@@ -0,0 +1,94 @@
+// Package workotel traces jobs with OpenTelemetry: an enqueue hook records a producer span and carries its trace
+// context with the job, and middleware continues the trace with a consumer span around the job, so a job's spans,
+// through all its retries, are part of the trace of the request that enqueued it:
+//
+//	enqueuer.AddHook(workotel.EnqueueHook(workotel.Options{}))
+//	job, err := enqueuer.EnqueueContext(r.Context(), "send_email", work.Q{"address": "test@example.com"})
+//
+//	pool.Middleware(workotel.Middleware(workotel.Options{}))
+//
+// Handlers get the consumer span's context as job.Context(). It's a module of its own, so that the work module
+// doesn't depend on OpenTelemetry.
+package workotel
+
+import (
+	"github.com/gocraft/work"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/gocraft/work/contrib/workotel"
+
+// Options configures the hook and the middleware.
+type Options struct {
+	TracerProvider trace.TracerProvider          // Default is otel.GetTracerProvider()
+	Propagator     propagation.TextMapPropagator // Default is otel.GetTextMapPropagator()
+}
+
+func (o Options) tracer() trace.Tracer {
+	tp := o.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+func (o Options) propagator() propagation.TextMapPropagator {
+	if o.Propagator == nil {
+		return otel.GetTextMapPropagator()
+	}
+	return o.Propagator
+}
+
+// EnqueueHook returns an enqueue hook that records a producer span for each job, as a child of the span in the job's
+// Context, which is the context passed to Enqueuer.EnqueueContext, and injects its trace context into the job's
+// Trace.
+func EnqueueHook(opts Options) work.EnqueueHook {
+	tracer, propagator := opts.tracer(), opts.propagator()
+	return func(job *work.Job) error {
+		ctx, span := tracer.Start(job.Context(), job.Name+" send",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(attributes(job)...),
+		)
+		defer span.End()
+
+		if job.Trace == nil {
+			job.Trace = work.TraceCarrier{}
+		}
+		propagator.Inject(ctx, job.Trace)
+		return nil
+	}
+}
+
+// Middleware returns middleware that runs each job in a consumer span, continuing the trace in the job's Trace if it
+// has one. The span records the job's error, if any. Add it first so the span covers the other middleware.
+func Middleware(opts Options) func(*work.Job, work.NextMiddlewareFunc) error {
+	tracer, propagator := opts.tracer(), opts.propagator()
+	return func(job *work.Job, next work.NextMiddlewareFunc) error {
+		ctx := propagator.Extract(job.Context(), job.Trace)
+		ctx, span := tracer.Start(ctx, job.Name+" process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(append(attributes(job), attribute.Int64("messaging.gocraft_work.attempt", job.Fails+1))...),
+		)
+		defer span.End()
+
+		job.SetContext(ctx)
+		err := next()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+func attributes(job *work.Job) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "gocraft_work"),
+		attribute.String("messaging.destination.name", job.Name),
+		attribute.String("messaging.message.id", job.ID),
+	}
+}
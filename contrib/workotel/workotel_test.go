@@ -0,0 +1,68 @@
+package workotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gocraft/work"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	opts := Options{TracerProvider: tp, Propagator: propagation.TraceContext{}}
+
+	// The request that enqueues the job
+	ctx, request := tp.Tracer("test").Start(context.Background(), "request")
+	job := &work.Job{Name: "send_email", ID: "abc", Fails: 1}
+	job.SetContext(ctx)
+	assert.NoError(t, EnqueueHook(opts)(job))
+	request.End()
+	assert.NotEmpty(t, job.Trace.Get("traceparent"))
+
+	// The job, as a worker gets it, failing
+	processed := &work.Job{Name: job.Name, ID: job.ID, Fails: job.Fails, Trace: job.Trace}
+	var handlerSpan trace.SpanContext
+	errBoom := errors.New("boom")
+	err := Middleware(opts)(processed, func() error {
+		handlerSpan = trace.SpanContextFromContext(processed.Context())
+		return errBoom
+	})
+	assert.Equal(t, errBoom, err)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 3) {
+		send, process := spans[0], spans[2]
+		assert.Equal(t, "send_email send", send.Name())
+		assert.Equal(t, trace.SpanKindProducer, send.SpanKind())
+		assert.Equal(t, request.SpanContext().SpanID(), send.Parent().SpanID())
+
+		assert.Equal(t, "send_email process", process.Name())
+		assert.Equal(t, trace.SpanKindConsumer, process.SpanKind())
+		assert.Equal(t, send.SpanContext().TraceID(), process.SpanContext().TraceID())
+		assert.Equal(t, send.SpanContext().SpanID(), process.Parent().SpanID())
+		assert.Equal(t, process.SpanContext(), handlerSpan)
+		assert.Equal(t, codes.Error, process.Status().Code)
+	}
+}
+
+func TestMiddlewareWithoutTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	job := &work.Job{Name: "wat", ID: "abc"}
+	assert.NoError(t, Middleware(Options{TracerProvider: tp})(job, func() error { return nil }))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.False(t, spans[0].Parent().IsValid())
+		assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	}
+}
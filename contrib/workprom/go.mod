@@ -0,0 +1,13 @@
+module github.com/gocraft/work/contrib/workprom
+
+go 1.14
+
+require (
+	github.com/gocraft/work v0.5.1
+	github.com/gomodule/redigo v2.0.0+incompatible
+	github.com/prometheus/client_golang v1.11.1
+	github.com/prometheus/client_model v0.2.0
+	github.com/stretchr/testify v1.5.1
+)
+
+replace github.com/gocraft/work => ../..
@@ -0,0 +1,114 @@
+// Package workprom exports metrics of gocraft/work to Prometheus: a worker pool's job counters, a histogram of how
+// long jobs take, and the depth and latency of the namespace's queues. A Collector is a prometheus.Collector, so it's
+// registered and served like any other:
+//
+//	collector := workprom.New(pool, work.NewClient("my_app_namespace", redisPool))
+//	pool.Middleware(collector.Middleware)
+//	prometheus.MustRegister(collector)
+//	http.Handle("/metrics", promhttp.Handler())
+//
+// It's a module of its own, so that the work module doesn't depend on the Prometheus client library.
+package workprom
+
+import (
+	"time"
+
+	"github.com/gocraft/work"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the job duration histogram's buckets: Prometheus's defaults,
+// plus some for jobs that take minutes.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 900}
+
+var (
+	processedDesc   = prometheus.NewDesc("work_jobs_processed_total", "Jobs that ran to completion, successfully or not.", nil, nil)
+	failedDesc      = prometheus.NewDesc("work_jobs_failed_total", "Jobs that failed.", nil, nil)
+	retriedDesc     = prometheus.NewDesc("work_jobs_retried_total", "Failed jobs sent to the retry queue.", nil, nil)
+	diedDesc        = prometheus.NewDesc("work_jobs_dead_total", "Failed jobs sent to the dead queue.", nil, nil)
+	discardedDesc   = prometheus.NewDesc("work_jobs_discarded_total", "Failed jobs dropped.", nil, nil)
+	shedDesc        = prometheus.NewDesc("work_jobs_shed_total", "Jobs dropped without running because their type was shed.", nil, nil)
+	fetchErrorsDesc = prometheus.NewDesc("work_fetch_errors_total", "Errors fetching jobs from Redis.", nil, nil)
+	inFlightDesc    = prometheus.NewDesc("work_jobs_in_flight", "Jobs currently running.", nil, nil)
+
+	queueDepthDesc   = prometheus.NewDesc("work_queue_depth", "Jobs waiting in the queue.", []string{"job"}, nil)
+	queueLatencyDesc = prometheus.NewDesc("work_queue_latency_seconds", "How long the next job in the queue has waited.", []string{"job"}, nil)
+)
+
+// Collector collects the metrics. Create one with New.
+type Collector struct {
+	pool      *work.WorkerPool
+	client    *work.Client
+	durations *prometheus.HistogramVec
+}
+
+// New returns a Collector of pool's job counters and of the queues client reads. Either can be nil to leave its
+// metrics out, eg to export the queues from a process that doesn't run a pool. Job durations are only collected once
+// the Collector's Middleware is added to a pool.
+func New(pool *work.WorkerPool, client *work.Client) *Collector {
+	return &Collector{
+		pool:   pool,
+		client: client,
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "work_job_duration_seconds",
+			Help:    "How long jobs took to run.",
+			Buckets: DefaultBuckets,
+		}, []string{"job"}),
+	}
+}
+
+// Middleware observes how long each job takes, including the middleware added after it, in the
+// work_job_duration_seconds histogram. Add it first to include all of the middleware.
+func (c *Collector) Middleware(job *work.Job, next work.NextMiddlewareFunc) error {
+	startedAt := time.Now()
+	err := next()
+	c.durations.WithLabelValues(job.Name).Observe(time.Since(startedAt).Seconds())
+	return err
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	if c.pool != nil {
+		for _, desc := range []*prometheus.Desc{processedDesc, failedDesc, retriedDesc, diedDesc, discardedDesc, shedDesc, fetchErrorsDesc, inFlightDesc} {
+			ch <- desc
+		}
+	}
+	c.durations.Describe(ch)
+	if c.client != nil {
+		ch <- queueDepthDesc
+		ch <- queueLatencyDesc
+	}
+}
+
+// Collect implements prometheus.Collector. If the queues can't be read, their metrics are reported as invalid, which
+// fails the scrape.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	if c.pool != nil {
+		stats := c.pool.Stats()
+		counter := func(desc *prometheus.Desc, value int64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value))
+		}
+		counter(processedDesc, stats.Processed)
+		counter(failedDesc, stats.Failed)
+		counter(retriedDesc, stats.Retried)
+		counter(diedDesc, stats.Died)
+		counter(discardedDesc, stats.Discarded)
+		counter(shedDesc, stats.Shed)
+		counter(fetchErrorsDesc, stats.FetchErrors)
+		ch <- prometheus.MustNewConstMetric(inFlightDesc, prometheus.GaugeValue, float64(stats.InFlight))
+	}
+
+	c.durations.Collect(ch)
+
+	if c.client != nil {
+		queues, err := c.client.Queues()
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(queueDepthDesc, err)
+			return
+		}
+		for _, q := range queues {
+			ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(q.Count), q.JobName)
+			ch <- prometheus.MustNewConstMetric(queueLatencyDesc, prometheus.GaugeValue, float64(q.Latency), q.JobName)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package workprom
+
+import (
+	"testing"
+
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	pool := work.NewWorkerPool(struct{}{}, 1, "work", &redis.Pool{})
+	c := New(pool, nil)
+	c.durations.WithLabelValues("export").Observe(0.3)
+	c.durations.WithLabelValues("export").Observe(20)
+	c.durations.WithLabelValues(`say "hi"`).Observe(0.001)
+	assert.NoError(t, c.Middleware(&work.Job{Name: "export"}, func() error { return nil }))
+
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(c))
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	if assert.Contains(t, byName, "work_jobs_processed_total") {
+		assert.Equal(t, dto.MetricType_COUNTER, byName["work_jobs_processed_total"].GetType())
+		assert.EqualValues(t, 0, byName["work_jobs_processed_total"].Metric[0].GetCounter().GetValue())
+	}
+	if assert.Contains(t, byName, "work_jobs_in_flight") {
+		assert.Equal(t, dto.MetricType_GAUGE, byName["work_jobs_in_flight"].GetType())
+	}
+	assert.NotContains(t, byName, "work_queue_depth")
+
+	if assert.Contains(t, byName, "work_job_duration_seconds") {
+		durations := byName["work_job_duration_seconds"]
+		assert.Equal(t, dto.MetricType_HISTOGRAM, durations.GetType())
+		assert.Len(t, durations.Metric, 2)
+		for _, m := range durations.Metric {
+			if m.Label[0].GetValue() != "export" {
+				continue
+			}
+			h := m.GetHistogram()
+			assert.EqualValues(t, 3, h.GetSampleCount())
+			for _, b := range h.Bucket {
+				switch b.GetUpperBound() {
+				case 0.25:
+					assert.EqualValues(t, 1, b.GetCumulativeCount())
+				case 0.5:
+					assert.EqualValues(t, 2, b.GetCumulativeCount())
+				case 30:
+					assert.EqualValues(t, 3, b.GetCumulativeCount())
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, 2, testutil.CollectAndCount(c, "work_job_duration_seconds"))
+}
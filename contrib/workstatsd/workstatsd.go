@@ -0,0 +1,88 @@
+// Package workstatsd is a work.MetricsSink that sends a worker pool's metrics to a StatsD server, or a DogStatsD
+// agent, over UDP:
+//
+//	sink, err := workstatsd.New("127.0.0.1:8125", workstatsd.Options{Prefix: "myapp.", DogStatsD: true})
+//	if err != nil { ... }
+//	defer sink.Close()
+//	pool := work.NewWorkerPoolWithOptions(Context{}, 10, "my_app_namespace", redisPool, work.WorkerPoolOptions{Metrics: sink})
+package workstatsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures a Sink.
+type Options struct {
+	Prefix string // put in front of every metric name, eg "myapp."
+
+	// If true, tags are sent in DogStatsD's format, eg "work.job.duration:12|ms|#job:export". Plain StatsD has no
+	// tags, so otherwise their values are appended to the metric name instead, eg "work.job.duration.export:12|ms".
+	DogStatsD bool
+}
+
+// Sink sends each metric in a packet of its own as it's recorded. Like StatsD itself, it's fire and forget: metrics
+// that can't be sent are dropped.
+type Sink struct {
+	conn net.Conn
+	opts Options
+}
+
+// New returns a Sink that sends to the StatsD server at addr, eg "127.0.0.1:8125".
+func New(addr string, opts Options) (*Sink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{conn: conn, opts: opts}, nil
+}
+
+// Count sends a counter.
+func (s *Sink) Count(name string, value int64, tags ...string) {
+	s.send(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+// Timing sends a timer, in milliseconds.
+func (s *Sink) Timing(name string, d time.Duration, tags ...string) {
+	s.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Close closes the Sink's socket.
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Sink) send(name, value, kind string, tags []string) {
+	s.conn.Write([]byte(s.format(name, value, kind, tags)))
+}
+
+func (s *Sink) format(name, value, kind string, tags []string) string {
+	var b strings.Builder
+	b.WriteString(s.opts.Prefix)
+	b.WriteString(name)
+	if !s.opts.DogStatsD {
+		for _, tag := range tags {
+			b.WriteByte('.')
+			b.WriteString(sanitize(tag[strings.IndexByte(tag, ':')+1:]))
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(kind)
+	if s.opts.DogStatsD && len(tags) > 0 {
+		b.WriteString("|#")
+		for i, tag := range tags {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(sanitize(tag))
+		}
+	}
+	return b.String()
+}
+
+// sanitize replaces the characters that delimit the parts of a StatsD line, which job names may contain.
+var sanitize = strings.NewReplacer("|", "_", ",", "_", "#", "_", "@", "_", "\n", "_").Replace
@@ -0,0 +1,41 @@
+package workstatsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gocraft/work"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	s := &Sink{opts: Options{Prefix: "myapp."}}
+	assert.Equal(t, "myapp.work.job.duration.export:12.5|ms", s.format("work.job.duration", "12.5", "ms", []string{"job:export"}))
+	assert.Equal(t, "myapp.work.fetch.error:1|c", s.format("work.fetch.error", "1", "c", nil))
+
+	s.opts.DogStatsD = true
+	assert.Equal(t, "myapp.work.job.failed:1|c|#job:a_b", s.format("work.job.failed", "1", "c", []string{"job:a|b"}))
+	assert.Equal(t, "myapp.work.fetch.error:1|c", s.format("work.fetch.error", "1", "c", nil))
+}
+
+func TestSink(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no UDP:", err)
+	}
+	defer server.Close()
+
+	var sink work.MetricsSink
+	s, err := New(server.LocalAddr().String(), Options{DogStatsD: true})
+	assert.NoError(t, err)
+	defer s.Close()
+	sink = s
+
+	sink.Timing("work.job.duration", 1500*time.Microsecond, "job:export")
+	buf := make([]byte, 512)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := server.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "work.job.duration:1.5|ms|#job:export", string(buf[:n]))
+}
@@ -0,0 +1,119 @@
+package work
+
+import (
+	"sync"
+)
+
+// JobClass tags a job type with the resource its jobs mostly wait on, so that a pool can run as many jobs of each
+// class at once as its host can take, see WorkerPoolOptions.CPUConcurrency and IOConcurrency.
+type JobClass uint8
+
+const (
+	// Unclassified jobs only count towards the pool's concurrency and CostBudget.
+	Unclassified JobClass = iota
+	// CPUBound jobs spend their time computing, so running more of them at once than there are cores doesn't help.
+	CPUBound
+	// IOBound jobs spend their time waiting on the network or disk, so many of them can run at once.
+	IOBound
+)
+
+// costBudget caps what the jobs a pool runs at once take up: their total cost, where each job type has a cost of its
+// own, see JobOptions.Cost, and the number of them of each JobClass. Jobs take both out of the budget together, so
+// that a worker never holds one while it waits on the other. A nil budget doesn't cap anything.
+type costBudget struct {
+	max      uint // of the total cost; 0 for no cap
+	classMax map[JobClass]uint
+
+	mtx       sync.Mutex
+	cond      *sync.Cond
+	used      uint
+	classUsed map[JobClass]uint
+}
+
+func newCostBudget(max uint, classMax map[JobClass]uint) *costBudget {
+	for class, n := range classMax {
+		if n == 0 {
+			delete(classMax, class)
+		}
+	}
+	if max == 0 && len(classMax) == 0 {
+		return nil
+	}
+	b := &costBudget{max: max, classMax: classMax, classUsed: make(map[JobClass]uint)}
+	b.cond = sync.NewCond(&b.mtx)
+	return b
+}
+
+// jobCost returns what a job of type jt costs, at least 1. Jobs of no known type cost 1.
+func jobCost(jt *jobType) uint {
+	if jt == nil || jt.Cost == 0 {
+		return 1
+	}
+	return jt.Cost
+}
+
+func jobClass(jt *jobType) JobClass {
+	if jt == nil {
+		return Unclassified
+	}
+	return jt.Class
+}
+
+// cost keeps jt's cost within the budget, since a job that costs more than all of it would otherwise never run. It
+// runs on its own instead.
+func (b *costBudget) cost(jt *jobType) uint {
+	cost := jobCost(jt)
+	if b.max > 0 && cost > b.max {
+		return b.max
+	}
+	return cost
+}
+
+// fitsLocked returns whether a job of type jt could start now.
+func (b *costBudget) fitsLocked(jt *jobType) bool {
+	if b.max > 0 && b.used+b.cost(jt) > b.max {
+		return false
+	}
+	if max, ok := b.classMax[jobClass(jt)]; ok && b.classUsed[jobClass(jt)] >= max {
+		return false
+	}
+	return true
+}
+
+// fits returns whether a job of type jt could start now. Workers check before fetching, so they leave out job types
+// they'd have to wait on.
+func (b *costBudget) fits(jt *jobType) bool {
+	if b == nil {
+		return true
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.fitsLocked(jt)
+}
+
+// acquire takes a job of type jt out of the budget, waiting for running jobs to give theirs back if need be. Another
+// worker can take what fits showed was left before this one fetches, so a fetched job may have to wait a little.
+func (b *costBudget) acquire(jt *jobType) {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	for !b.fitsLocked(jt) {
+		b.cond.Wait()
+	}
+	b.used += b.cost(jt)
+	b.classUsed[jobClass(jt)]++
+	b.mtx.Unlock()
+}
+
+// release gives back what acquire took for a job of type jt.
+func (b *costBudget) release(jt *jobType) {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	b.used -= b.cost(jt)
+	b.classUsed[jobClass(jt)]--
+	b.mtx.Unlock()
+	b.cond.Broadcast()
+}
@@ -0,0 +1,152 @@
+package work
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCostBudget(t *testing.T) {
+	costing := func(cost uint) *jobType { return &jobType{JobOptions: JobOptions{Cost: cost}} }
+
+	var unlimited *costBudget
+	assert.True(t, unlimited.fits(costing(1000)))
+	unlimited.acquire(costing(1000))
+	unlimited.release(costing(1000))
+	assert.Nil(t, newCostBudget(0, nil))
+	assert.Nil(t, newCostBudget(0, map[JobClass]uint{CPUBound: 0}))
+
+	b := newCostBudget(8, nil)
+	assert.True(t, b.fits(costing(8)))
+	b.acquire(costing(6))
+	assert.True(t, b.fits(costing(2)))
+	assert.True(t, b.fits(nil))
+	assert.False(t, b.fits(costing(3)))
+
+	// A job costing more than the budget runs once it has the budget to itself
+	assert.False(t, b.fits(costing(20)))
+	acquired := make(chan struct{})
+	go func() {
+		b.acquire(costing(20))
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired before the budget was released")
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.release(costing(6))
+	<-acquired
+	assert.False(t, b.fits(nil))
+	b.release(costing(20))
+	assert.True(t, b.fits(costing(8)))
+}
+
+func TestCostBudgetClasses(t *testing.T) {
+	cpu := &jobType{JobOptions: JobOptions{Class: CPUBound}}
+	io := &jobType{JobOptions: JobOptions{Class: IOBound}}
+
+	b := newCostBudget(0, map[JobClass]uint{CPUBound: 2})
+	b.acquire(cpu)
+	b.acquire(cpu)
+	assert.False(t, b.fits(cpu))
+	assert.True(t, b.fits(io))
+	assert.True(t, b.fits(nil))
+	b.release(cpu)
+	assert.True(t, b.fits(cpu))
+
+	// With a cost budget too, a job has to fit in both
+	b = newCostBudget(4, map[JobClass]uint{IOBound: 10})
+	b.acquire(&jobType{JobOptions: JobOptions{Cost: 4}})
+	assert.False(t, b.fits(io))
+}
+
+func TestWorkerPoolCostBudget(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	var mtx sync.Mutex
+	var running, maxRunning uint
+	track := func(cost uint) func(job *Job) error {
+		return func(job *Job) error {
+			mtx.Lock()
+			running += cost
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mtx.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mtx.Lock()
+			running -= cost
+			mtx.Unlock()
+			return nil
+		}
+	}
+
+	wp := NewWorkerPoolWithOptions(TestContext{}, 8, ns, pool, WorkerPoolOptions{CostBudget: 8})
+	wp.Job("export", track(6), Cost(6))
+	wp.Job("small", track(1))
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 3; i++ {
+		_, err := enqueuer.Enqueue("export", nil)
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 20; i++ {
+		_, err := enqueuer.Enqueue("small", nil)
+		assert.NoError(t, err)
+	}
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	// Eight workers, but never more than 8 in cost at once
+	assert.EqualValues(t, 23, wp.Stats().Processed)
+	assert.True(t, maxRunning <= 8, "cost of %d ran at once", maxRunning)
+}
+
+func TestWorkerPoolJobClasses(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	var mtx sync.Mutex
+	running := make(map[string]int)
+	maxRunning := make(map[string]int)
+	track := func(job *Job) error {
+		mtx.Lock()
+		running[job.Name]++
+		if running[job.Name] > maxRunning[job.Name] {
+			maxRunning[job.Name] = running[job.Name]
+		}
+		mtx.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mtx.Lock()
+		running[job.Name]--
+		mtx.Unlock()
+		return nil
+	}
+
+	wp := NewWorkerPoolWithOptions(TestContext{}, 10, ns, pool, WorkerPoolOptions{CPUConcurrency: 2, IOConcurrency: 6})
+	wp.Job("resize", track, Class(CPUBound))
+	wp.Job("fetch", track, Class(IOBound))
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 20; i++ {
+		_, err := enqueuer.Enqueue("resize", nil)
+		assert.NoError(t, err)
+		_, err = enqueuer.Enqueue("fetch", nil)
+		assert.NoError(t, err)
+	}
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.EqualValues(t, 40, wp.Stats().Processed)
+	assert.True(t, maxRunning["resize"] <= 2, "%v", maxRunning)
+	assert.True(t, maxRunning["fetch"] <= 6, "%v", maxRunning)
+	assert.True(t, maxRunning["fetch"] > 2, "%v", maxRunning)
+}
@@ -0,0 +1,102 @@
+package work
+
+import (
+	"sort"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// deadGroupsChunkSize is how many dead jobs DeadJobGroups reads at a time.
+const deadGroupsChunkSize = 1000
+
+// DeadJobGroup is the dead jobs with the same Fingerprint, ie duplicates of one another, eg the same failing email
+// enqueued many times.
+type DeadJobGroup struct {
+	Fingerprint string                 `json:"fingerprint"`
+	JobName     string                 `json:"job_name"`
+	Args        map[string]interface{} `json:"args"` // of the job that died last
+	Count       int64                  `json:"count"`
+	FirstDiedAt int64                  `json:"first_died_at"`
+	LastDiedAt  int64                  `json:"last_died_at"`
+	LastErr     string                 `json:"last_err"` // of the job that died last
+}
+
+// DeadJobGroups groups the dead jobs, including those in the dead queues of job types with
+// JobOptions.OwnFailureQueues, by their Fingerprint, most numerous first. Jobs without a fingerprint, ie periodic jobs
+// and jobs enqueued by earlier versions, are grouped by the one they would have been given, so they end up with their
+// duplicates. It reads the whole of the dead queues, a chunk at a time.
+func (c *Client) DeadJobGroups() ([]*DeadJobGroup, error) {
+	keys, err := c.failureQueueKeys(redisKeyDead(c.namespace), redisKeyDeadOf)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := c.readConn()
+	defer conn.Close()
+
+	groups := make(map[string]*DeadJobGroup)
+	last := make(map[string]*Job) // the job that died last in each group, whose Args are decoded once all are read
+	for _, key := range keys {
+		for start := 0; ; start += deadGroupsChunkSize {
+			values, err := redis.Values(conn.Do("ZRANGE", key, start, start+deadGroupsChunkSize-1, "WITHSCORES"))
+			if err != nil {
+				logError("client.dead_job_groups.zrange", err)
+				return nil, err
+			}
+			if len(values) == 0 {
+				break
+			}
+
+			var jobsWithScores []jobScore
+			if err := redis.ScanSlice(values, &jobsWithScores); err != nil {
+				logError("client.dead_job_groups.scan_slice", err)
+				return nil, err
+			}
+			for _, jws := range jobsWithScores {
+				job, err := newJobRawArgs(jws.JobBytes, nil, nil)
+				if err != nil {
+					logError("client.dead_job_groups.new_job", err)
+					continue
+				}
+				fingerprint := job.Fingerprint
+				if fingerprint == "" {
+					if err := job.decodeArgs(); err != nil {
+						logError("client.dead_job_groups.decode_args", err)
+						continue
+					}
+					fingerprint, _ = jobFingerprint(job.Name, job.Args)
+				}
+
+				g := groups[fingerprint]
+				if g == nil {
+					g = &DeadJobGroup{Fingerprint: fingerprint, JobName: job.Name, FirstDiedAt: jws.Score}
+					groups[fingerprint] = g
+				}
+				g.Count++
+				if jws.Score < g.FirstDiedAt {
+					g.FirstDiedAt = jws.Score
+				}
+				if jws.Score >= g.LastDiedAt {
+					g.LastDiedAt = jws.Score
+					g.LastErr = job.LastErr
+					last[fingerprint] = job
+				}
+			}
+		}
+	}
+
+	result := make([]*DeadJobGroup, 0, len(groups))
+	for fingerprint, g := range groups {
+		if job := last[fingerprint]; job.decodeArgs() == nil {
+			g.Args = job.Args
+		}
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].LastDiedAt > result[j].LastDiedAt
+	})
+	return result, nil
+}
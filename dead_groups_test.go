@@ -0,0 +1,51 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientDeadJobGroups(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	client := NewClient(ns, pool)
+	groups, err := client.DeadJobGroups()
+	assert.NoError(t, err)
+	assert.Empty(t, groups)
+
+	fingerprint, err := jobFingerprint("send_email", Q{"to": "a@example.com"})
+	assert.NoError(t, err)
+	addDead := func(name string, args Q, fingerprint, lastErr string, diedAt int64) {
+		job := &Job{Name: name, ID: makeIdentifier(), Args: args, Fingerprint: fingerprint, Fails: 3, LastErr: lastErr}
+		rawJSON, err := job.serialize()
+		assert.NoError(t, err)
+		conn := pool.Get()
+		defer conn.Close()
+		_, err = conn.Do("ZADD", redisKeyDead(ns), diedAt, rawJSON)
+		assert.NoError(t, err)
+	}
+	addDead("send_email", Q{"to": "a@example.com"}, fingerprint, "timeout", 100)
+	addDead("send_email", Q{"to": "a@example.com"}, fingerprint, "refused", 300)
+	// Enqueued before jobs had fingerprints, but a duplicate all the same
+	addDead("send_email", Q{"to": "a@example.com"}, "", "timeout", 200)
+	addDead("send_email", Q{"to": "b@example.com"}, "", "timeout", 400)
+
+	groups, err = client.DeadJobGroups()
+	assert.NoError(t, err)
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, &DeadJobGroup{
+			Fingerprint: fingerprint,
+			JobName:     "send_email",
+			Args:        map[string]interface{}{"to": "a@example.com"},
+			Count:       3,
+			FirstDiedAt: 100,
+			LastDiedAt:  300,
+			LastErr:     "refused",
+		}, groups[0])
+		assert.EqualValues(t, 1, groups[1].Count)
+		assert.Equal(t, map[string]interface{}{"to": "b@example.com"}, groups[1].Args)
+	}
+}
@@ -23,6 +23,10 @@ type deadPoolReaper struct {
 	reapPeriod  time.Duration
 	curJobTypes []string
 
+	redisTimeout time.Duration
+	errorHook    ErrorHook
+	logger       Logger
+
 	stopChan         chan struct{}
 	doneStoppingChan chan struct{}
 }
@@ -64,7 +68,7 @@ func (r *deadPoolReaper) loop() {
 
 			// Reap
 			if err := r.reap(); err != nil {
-				logError("dead_pool_reaper.reap", err)
+				reportError(r.logger, r.errorHook, "dead_pool_reaper.reap", err)
 			}
 		}
 	}
@@ -77,7 +81,7 @@ func (r *deadPoolReaper) reap() error {
 		return err
 	}
 
-	conn := r.pool.Get()
+	conn := getConn(r.pool, r.redisTimeout)
 	defer conn.Close()
 
 	workerPoolsKey := redisKeyWorkerPools(r.namespace)
@@ -118,7 +122,7 @@ func (r *deadPoolReaper) cleanStaleLockInfo(poolID string, jobTypes []string) er
 	}
 	scriptArgs = append(scriptArgs, poolID) // ARGV[1]
 
-	conn := r.pool.Get()
+	conn := getConn(r.pool, r.redisTimeout)
 	defer conn.Close()
 	if _, err := redisReapLocksScript.Do(conn, scriptArgs...); err != nil {
 		return err
@@ -130,15 +134,15 @@ func (r *deadPoolReaper) cleanStaleLockInfo(poolID string, jobTypes []string) er
 func (r *deadPoolReaper) requeueInProgressJobs(poolID string, jobTypes []string) error {
 	numKeys := len(jobTypes) * requeueKeysPerJob
 	redisRequeueScript := redis.NewScript(numKeys, redisLuaReenqueueJob)
-	var scriptArgs = make([]interface{}, 0, numKeys+1)
+	var scriptArgs = make([]interface{}, 0, numKeys+2)
 
 	for _, jobType := range jobTypes {
 		// pops from in progress, push into job queue and decrement the queue lock
 		scriptArgs = append(scriptArgs, redisKeyJobsInProgress(r.namespace, poolID, jobType), redisKeyJobs(r.namespace, jobType), redisKeyJobsLock(r.namespace, jobType), redisKeyJobsLockInfo(r.namespace, jobType)) // KEYS[1-4 * N]
 	}
-	scriptArgs = append(scriptArgs, poolID) // ARGV[1]
+	scriptArgs = append(scriptArgs, poolID, nowEpochSeconds()) // ARGV[1-2]
 
-	conn := r.pool.Get()
+	conn := getConn(r.pool, r.redisTimeout)
 	defer conn.Close()
 
 	// Keep moving jobs until all queues are empty
@@ -157,7 +161,7 @@ func (r *deadPoolReaper) requeueInProgressJobs(poolID string, jobTypes []string)
 }
 
 func (r *deadPoolReaper) findDeadPools() (map[string][]string, error) {
-	conn := r.pool.Get()
+	conn := getConn(r.pool, r.redisTimeout)
 	defer conn.Close()
 
 	workerPoolsKey := redisKeyWorkerPools(r.namespace)
@@ -1,11 +1,14 @@
 package work
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/gocraft/work/spec"
+	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -51,6 +54,47 @@ func TestEnqueue(t *testing.T) {
 	assert.EqualValues(t, 2, listSize(pool, redisKeyJobs(ns, "wat")))
 }
 
+func TestEnqueueBatch(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	enqueuer := NewEnqueuer(ns, pool)
+	enqueuer.SetTap(100)
+
+	jobs, err := enqueuer.EnqueueBatch("wat", []map[string]interface{}{{"i": 1}, {"i": 2}, {"i": 3}})
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 3)
+	assert.EqualValues(t, []string{"wat"}, knownJobs(pool, redisKeyKnownJobs(ns)))
+	assert.EqualValues(t, 3, listSize(pool, redisKeyJobs(ns, "wat")))
+
+	// Jobs come off the queue in the order they were given
+	j := jobOnQueue(pool, redisKeyJobs(ns, "wat"))
+	assert.EqualValues(t, 1, j.ArgInt64("i"))
+	assert.Equal(t, jobs[0].ID, j.ID)
+
+	entries, err := NewTap(ns, pool).Read(10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	// A refused job refuses the batch
+	errRefused := fmt.Errorf("refused")
+	enqueuer.AddHook(func(job *Job) error {
+		if job.ArgInt64("i") == 5 {
+			return errRefused
+		}
+		return nil
+	})
+	queued := listSize(pool, redisKeyJobs(ns, "wat"))
+	jobs, err = enqueuer.EnqueueBatch("wat", []map[string]interface{}{{"i": 4}, {"i": 5}})
+	assert.Equal(t, errRefused, err)
+	assert.Nil(t, jobs)
+	assert.EqualValues(t, queued, listSize(pool, redisKeyJobs(ns, "wat")))
+
+	jobs, err = enqueuer.EnqueueBatch("wat", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, jobs)
+}
+
 func TestEnqueueIn(t *testing.T) {
 	pool := newTestPool(":6379")
 	ns := "work"
@@ -98,6 +142,56 @@ func TestEnqueueIn(t *testing.T) {
 	assert.NoError(t, j.ArgError())
 }
 
+func TestEnqueueAt(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	enqueuer := NewEnqueuer(ns, pool)
+
+	runAt := time.Now().Unix() + 3600
+	job, err := enqueuer.EnqueueAt("wat", runAt, Q{"a": 1})
+	assert.NoError(t, err)
+	if assert.NotNil(t, job) {
+		assert.Equal(t, "wat", job.Name)
+		assert.EqualValues(t, runAt, job.RunAt)
+	}
+
+	assert.EqualValues(t, []string{"wat"}, knownJobs(pool, redisKeyKnownJobs(ns)))
+	score, j := jobOnZset(pool, redisKeyScheduled(ns))
+	assert.EqualValues(t, runAt, score)
+	assert.Equal(t, job.ID, j.ID)
+}
+
+func TestEnqueueFollowsSpec(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	assert.Equal(t, redisKeyJobs(ns, "wat"), spec.QueueKey(ns, "wat"))
+	assert.Equal(t, redisKeyKnownJobs(ns), spec.KnownJobsKey(ns))
+	assert.Equal(t, redisKeyScheduled(ns), spec.ScheduledKey(ns))
+	assert.Equal(t, redisKeyWake(ns), spec.WakeChannel(ns))
+
+	// What Enqueuer produces passes as a spec payload
+	_, err := NewEnqueuer(ns, pool).Enqueue("wat", Q{"a": 1})
+	assert.NoError(t, err)
+	conn := pool.Get()
+	defer conn.Close()
+	rawJSON, err := redis.Bytes(conn.Do("RPOP", spec.QueueKey(ns, "wat")))
+	assert.NoError(t, err)
+	assert.NoError(t, spec.Validate(rawJSON))
+
+	// And a spec payload decodes as a job
+	rawJSON, err = spec.Encode(&spec.Payload{Name: "wat", ID: spec.NewID(), EnqueuedAt: 1500000000, Args: Q{"a": 1}, ArgsVersion: 2})
+	assert.NoError(t, err)
+	job, err := newJob(rawJSON, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "wat", job.Name)
+	assert.EqualValues(t, 1500000000, job.EnqueuedAt)
+	assert.EqualValues(t, 1, job.ArgInt64("a"))
+	assert.EqualValues(t, 2, job.ArgsVersion)
+}
+
 func TestEnqueueUnique(t *testing.T) {
 	pool := newTestPool(":6379")
 	ns := "work"
@@ -354,3 +448,159 @@ func EnqueueUniqueInByKey(t *testing.T) {
 	assert.NoError(t, j.ArgError())
 	assert.True(t, j.Unique)
 }
+
+func TestEnqueueFingerprint(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	enqueuer := NewEnqueuer(ns, pool)
+
+	job1, err := enqueuer.Enqueue("wat", Q{"a": 1, "b": "cool"})
+	assert.NoError(t, err)
+	job2, err := enqueuer.Enqueue("wat", Q{"b": "cool", "a": 1})
+	assert.NoError(t, err)
+	job3, err := enqueuer.Enqueue("wat", Q{"a": 2, "b": "cool"})
+	assert.NoError(t, err)
+	job4, err := enqueuer.Enqueue("foo", Q{"a": 1, "b": "cool"})
+	assert.NoError(t, err)
+
+	assert.Len(t, job1.Fingerprint, 40)
+	assert.Equal(t, job1.Fingerprint, job2.Fingerprint)
+	assert.NotEqual(t, job1.Fingerprint, job3.Fingerprint)
+	assert.NotEqual(t, job1.Fingerprint, job4.Fingerprint)
+
+	// The fingerprint survives a retry, though the job is re-serialized on the way
+	cleanKeyspace(ns, pool)
+	_, err = enqueuer.Enqueue("wat", Q{"n": 12345678901234567, "b": "cool"})
+	assert.NoError(t, err)
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job("wat", func(job *Job) error { return Retry(0, "later") })
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	_, retried := jobOnZset(pool, redisKeyRetry(ns))
+	fingerprint, err := jobFingerprint("wat", Q{"b": "cool", "n": 12345678901234567})
+	assert.NoError(t, err)
+	assert.Equal(t, fingerprint, retried.Fingerprint)
+
+	re := newRequeuer(ns, pool, redisKeyRetry(ns), []string{"wat"})
+	re.start()
+	re.drain()
+	re.stop()
+	assert.Equal(t, fingerprint, jobOnQueue(pool, redisKeyJobs(ns, "wat")).Fingerprint)
+}
+
+func TestEnqueueHooks(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	enqueuer := NewEnqueuer(ns, pool)
+
+	errMaintenance := fmt.Errorf("down for maintenance")
+	var seen []string
+	enqueuer.AddHook(func(job *Job) error {
+		seen = append(seen, job.Name)
+		job.Args["tenant"] = "acme"
+		return nil
+	})
+	enqueuer.AddHook(func(job *Job) error {
+		if job.Name == "risky" {
+			return errMaintenance
+		}
+		return nil
+	})
+
+	job, err := enqueuer.Enqueue("wat", Q{"a": 1})
+	assert.NoError(t, err)
+	if assert.NotNil(t, job) {
+		fingerprint, _ := jobFingerprint("wat", Q{"a": 1, "tenant": "acme"})
+		assert.Equal(t, fingerprint, job.Fingerprint)
+	}
+	queued := getQueuedJob(ns, pool, "wat")
+	if assert.NotNil(t, queued) {
+		assert.Equal(t, "acme", queued.ArgString("tenant"))
+	}
+
+	job, err = enqueuer.Enqueue("risky", Q{})
+	assert.Equal(t, errMaintenance, err)
+	assert.Nil(t, job)
+	scheduled, err := enqueuer.EnqueueIn("risky", 300, Q{})
+	assert.Equal(t, errMaintenance, err)
+	assert.Nil(t, scheduled)
+	job, err = enqueuer.EnqueueUnique("risky", Q{})
+	assert.Equal(t, errMaintenance, err)
+	assert.Nil(t, job)
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, "risky")))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyScheduled(ns)))
+
+	assert.Equal(t, []string{"wat", "risky", "risky", "risky"}, seen)
+}
+
+func TestEnqueueContext(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	enqueuer := NewEnqueuer(ns, pool)
+
+	type ctxKey struct{}
+	enqueuer.AddHook(func(job *Job) error {
+		if traceparent, ok := job.Context().Value(ctxKey{}).(string); ok {
+			job.Trace = TraceCarrier{}
+			job.Trace.Set("traceparent", traceparent)
+		}
+		return nil
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	_, err := enqueuer.EnqueueContext(ctx, "wat", Q{"a": 1})
+	assert.NoError(t, err)
+	queued := getQueuedJob(ns, pool, "wat")
+	if assert.NotNil(t, queued) {
+		assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", queued.Trace.Get("traceparent"))
+	}
+
+	_, err = enqueuer.Enqueue("bob", Q{})
+	assert.NoError(t, err)
+	queued = getQueuedJob(ns, pool, "bob")
+	if assert.NotNil(t, queued) {
+		assert.Nil(t, queued.Trace)
+	}
+}
+
+func BenchmarkEnqueue(b *testing.B) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	enqueuer := NewEnqueuer(ns, pool)
+	args := Q{"user_id": 1234, "event": "click"}
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := enqueuer.Enqueue("wat", args); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := enqueuer.Enqueue("wat", args); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+	b.Run("batch", func(b *testing.B) {
+		argsList := make([]map[string]interface{}, 100)
+		for i := range argsList {
+			argsList[i] = args
+		}
+		for i := 0; i < b.N; i += len(argsList) {
+			if _, err := enqueuer.EnqueueBatch("wat", argsList); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	cleanKeyspace(ns, pool)
+}
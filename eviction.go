@@ -0,0 +1,88 @@
+package work
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// EvictionCheck is what a WorkerPool does on Start if Redis could evict its queues, see
+// WorkerPoolOptions.EvictionCheck.
+type EvictionCheck uint8
+
+const (
+	// EvictionWarn logs an *EvictionError and hands it to the ErrorHook, then starts anyway. It's the default.
+	EvictionWarn EvictionCheck = iota
+	// EvictionRefuse has Start panic with the *EvictionError, so that a misconfigured Redis fails the deploy rather
+	// than silently losing jobs.
+	EvictionRefuse
+	// EvictionIgnore skips the check, eg for a Redis that doesn't allow INFO.
+	EvictionIgnore
+)
+
+// EvictionError is the error for a Redis whose maxmemory-policy could evict the keys of job queues once it's out of
+// memory: the allkeys-* policies evict any key, and the package's queues have no TTL to keep them safe. Use
+// noeviction, or a volatile-* policy, which only ever evicts the keys KeysForNamespace lists as Volatile.
+type EvictionError struct {
+	Policy string
+}
+
+func (e *EvictionError) Error() string {
+	return fmt.Sprintf("work: Redis maxmemory-policy is %q, which can evict job queues and lose their jobs; use noeviction or a volatile-* policy", e.Policy)
+}
+
+// CheckEviction returns an *EvictionError if Redis could evict job queues, eg for a deploy script or health check to
+// catch it before any jobs are lost. The policy is read with INFO, since CONFIG is often disabled on hosted Redis.
+func (c *Client) CheckEviction() error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	err := checkEviction(conn)
+	if _, ok := err.(*EvictionError); err != nil && !ok {
+		logError("client.check_eviction", err)
+	}
+	return err
+}
+
+func checkEviction(conn redis.Conn) error {
+	info, err := redis.String(conn.Do("INFO", "memory"))
+	if err != nil {
+		return err
+	}
+	policy := infoField(info, "maxmemory_policy")
+	if strings.HasPrefix(policy, "allkeys-") {
+		return &EvictionError{Policy: policy}
+	}
+	return nil
+}
+
+// infoField returns the value of field in the reply of an INFO command, or "" if it isn't there.
+func infoField(info, field string) string {
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), field+":"); value != scanner.Text() {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// checkEviction runs the pool's EvictionCheck. An error reading the policy is only reported: Redis may just be down
+// for now, and the workers wait for it like they would anyway.
+func (wp *WorkerPool) checkEviction() {
+	if wp.evictionCheck == EvictionIgnore {
+		return
+	}
+	conn := getConn(wp.pool, wp.redisTimeout)
+	defer conn.Close()
+
+	err := checkEviction(conn)
+	if _, ok := err.(*EvictionError); ok && wp.evictionCheck == EvictionRefuse {
+		panic(err.Error())
+	}
+	if err != nil {
+		reportError(wp.logger, wp.errorHook, "worker_pool.check_eviction", err)
+	}
+}
@@ -0,0 +1,53 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckEviction(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	conn := pool.Get()
+	defer conn.Close()
+
+	// Put back whatever policy the server had, rather than assume it
+	reply, err := redis.Strings(conn.Do("CONFIG", "GET", "maxmemory-policy"))
+	if !assert.NoError(t, err) || !assert.Len(t, reply, 2) {
+		return
+	}
+	defer conn.Do("CONFIG", "SET", "maxmemory-policy", reply[1])
+
+	_, err = conn.Do("CONFIG", "SET", "maxmemory-policy", "allkeys-lru")
+	assert.NoError(t, err)
+	client := NewClient(ns, pool)
+	err = client.CheckEviction()
+	if assert.IsType(t, &EvictionError{}, err) {
+		assert.Equal(t, "allkeys-lru", err.(*EvictionError).Policy)
+	}
+
+	var reported []error
+	wp := NewWorkerPoolWithOptions(TestContext{}, 1, ns, pool, WorkerPoolOptions{
+		ErrorHook: func(key string, err error) { reported = append(reported, err) },
+	})
+	wp.Job("job1", func(job *Job) error { return nil })
+	wp.Start()
+	wp.Stop()
+	assert.Len(t, reported, 1)
+
+	refusing := NewWorkerPoolWithOptions(TestContext{}, 1, ns, pool, WorkerPoolOptions{EvictionCheck: EvictionRefuse})
+	assert.Panics(t, refusing.Start)
+
+	_, err = conn.Do("CONFIG", "SET", "maxmemory-policy", "volatile-lru")
+	assert.NoError(t, err)
+	assert.NoError(t, client.CheckEviction())
+}
+
+func TestInfoField(t *testing.T) {
+	info := "# Memory\r\nused_memory:1024\r\nmaxmemory_policy:noeviction\r\n"
+	assert.Equal(t, "noeviction", infoField(info, "maxmemory_policy"))
+	assert.Equal(t, "1024", infoField(info, "used_memory"))
+	assert.Equal(t, "", infoField(info, "maxmemory"))
+}
@@ -0,0 +1,118 @@
+package work
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Executor decides how a job type's jobs are run, see JobOptions.Executor. Execute runs job, usually by calling
+// handler, which runs the job type's handler with the context the middleware set up, and returns its error.
+// Middleware runs before Execute is called, in the worker's process.
+type Executor interface {
+	Execute(job *Job, handler func(*Job) error) error
+}
+
+// How much of a subprocess's stderr is kept for the error of a failed job.
+const subprocessMaxStderr = 4096
+
+// SubprocessExecutor runs each job in a process of its own, which isolates the worker from handlers that crash,
+// leak memory or call native code. The process gets the job, serialized as JSON, on its stdin; it succeeds by
+// exiting 0, and fails by exiting with any other status, with the end of its stderr as the job's error. The
+// registered handler isn't called by the worker: to run it in the subprocess, start the same binary and have it call
+// RunSubprocessJob with the handler, eg when a flag or an environment variable is set.
+type SubprocessExecutor struct {
+	Path    string        // The program to run, eg os.Args[0] to run the current binary again
+	Args    []string      // Its arguments, not including the program name
+	Env     []string      // Its environment. If nil, it inherits the worker's.
+	Timeout time.Duration // If set, processes that run longer than this are killed and their job fails
+}
+
+// Execute runs the subprocess for job.
+func (e *SubprocessExecutor) Execute(job *Job, handler func(*Job) error) error {
+	rawJSON, err := job.serialize()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	var stderr tailBuffer
+	cmd := exec.CommandContext(ctx, e.Path, e.Args...)
+	cmd.Env = e.Env
+	cmd.Stdin = bytes.NewReader(rawJSON)
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("subprocess killed after timeout of %v", e.Timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("subprocess failed: %v: %s", err, msg)
+		}
+		return fmt.Errorf("subprocess failed: %v", err)
+	}
+	return nil
+}
+
+// tailBuffer keeps the last subprocessMaxStderr bytes written to it.
+type tailBuffer struct {
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > subprocessMaxStderr {
+		b.buf = b.buf[len(b.buf)-subprocessMaxStderr:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
+
+// RunSubprocessJob runs handler on the job a SubprocessExecutor passes on stdin. It's meant to be called from the
+// main function of the subprocess, which should exit with a non-zero status if it returns an error, eg:
+//
+//	if os.Getenv("RUN_JOB") != "" {
+//	    if err := work.RunSubprocessJob(resizeImage); err != nil {
+//	        fmt.Fprintln(os.Stderr, err)
+//	        os.Exit(1)
+//	    }
+//	    os.Exit(0)
+//	}
+//
+// A panic in handler is returned as an error.
+func RunSubprocessJob(handler func(*Job) error) error {
+	return runSubprocessJob(os.Stdin, handler)
+}
+
+func runSubprocessJob(stdin io.Reader, handler func(*Job) error) (err error) {
+	rawJSON, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	job, err := newJob(rawJSON, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("%v", panicErr)
+		}
+	}()
+	return handlerResult(job, handler(job))
+}
@@ -0,0 +1,108 @@
+package work
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSubprocessHelper isn't a real test: it's the subprocess the SubprocessExecutor tests run.
+func TestSubprocessHelper(t *testing.T) {
+	if os.Getenv("WORK_TEST_SUBPROCESS") == "" {
+		return
+	}
+	err := RunSubprocessJob(func(job *Job) error {
+		switch job.ArgString("do") {
+		case "fail":
+			return fmt.Errorf("failed on purpose")
+		case "panic":
+			panic("panicked on purpose")
+		case "hang":
+			time.Sleep(time.Minute)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestSubprocessExecutor(t *testing.T) {
+	executor := &SubprocessExecutor{
+		Path:    os.Args[0],
+		Args:    []string{"-test.run=^TestSubprocessHelper$"},
+		Env:     append(os.Environ(), "WORK_TEST_SUBPROCESS=1"),
+		Timeout: 5 * time.Second,
+	}
+	notCalled := func(job *Job) error {
+		t.Error("handler called in the worker's process")
+		return nil
+	}
+	jobDoing := func(do string) *Job {
+		return &Job{Name: "wat", ID: makeIdentifier(), Args: Q{"do": do}}
+	}
+
+	assert.NoError(t, executor.Execute(jobDoing("succeed"), notCalled))
+
+	err := executor.Execute(jobDoing("fail"), notCalled)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasSuffix(err.Error(), "failed on purpose"), err.Error())
+	}
+	err = executor.Execute(jobDoing("panic"), notCalled)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasSuffix(err.Error(), "panicked on purpose"), err.Error())
+	}
+
+	executor.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	err = executor.Execute(jobDoing("hang"), notCalled)
+	assert.EqualError(t, err, "subprocess killed after timeout of 100ms")
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+type recordingExecutor struct {
+	executed []string
+}
+
+func (e *recordingExecutor) Execute(job *Job, handler func(*Job) error) error {
+	e.executed = append(e.executed, job.ID)
+	return handler(job)
+}
+
+func TestWorkerPoolExecutor(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	executor := &recordingExecutor{}
+	var handled []string
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job("wat", func(job *Job) error {
+		handled = append(handled, job.ID)
+		return nil
+	}, Execute(executor))
+
+	job, err := NewEnqueuer(ns, pool).Enqueue("wat", nil)
+	assert.NoError(t, err)
+
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.Equal(t, []string{job.ID}, executor.executed)
+	assert.Equal(t, []string{job.ID}, handled)
+}
+
+func TestTailBuffer(t *testing.T) {
+	var b tailBuffer
+	b.Write([]byte(strings.Repeat("a", subprocessMaxStderr)))
+	b.Write([]byte("end"))
+	assert.Len(t, b.String(), subprocessMaxStderr)
+	assert.True(t, strings.HasSuffix(b.String(), "aend"))
+}
@@ -0,0 +1,28 @@
+package work
+
+// Gate is consulted before the scheduled and retried jobs of a job type are moved onto its queue, eg to check that an
+// API the jobs call is healthy. While the gate is closed, the jobs stay in the scheduled and retry queues, set aside
+// once they're due so that they aren't looked at again however many pile up; once it opens again, they're promoted. That way jobs don't run, fail and pile up retries against a
+// dependency that's known to be down. Jobs enqueued to run right away aren't affected.
+//
+// Open is called about once a second by whichever pool or Maintainer runs the requeuers, so it should be cheap, eg
+// return the result of a health check done in the background.
+type Gate interface {
+	Open(jobName string) bool
+}
+
+// GateFunc adapts a function to a Gate.
+type GateFunc func(jobName string) bool
+
+// Open calls f.
+func (f GateFunc) Open(jobName string) bool {
+	return f(jobName)
+}
+
+// Gated sets JobOptions.Gate.
+func Gated(gate Gate) JobOption {
+	return func(o *JobOptions) error {
+		o.Gate = gate
+		return nil
+	}
+}
@@ -1,9 +1,11 @@
 package work
 
 import (
+	"encoding/json"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -24,6 +26,15 @@ type workerPoolHeartbeater struct {
 	pid          int
 	hostname     string
 	workerIDs    string
+	redisTimeout time.Duration
+	errorHook    ErrorHook
+	logger       Logger
+	disabled     *jobNameSet
+	quiet        *atomicFlag
+	stats        *poolStats
+
+	// WorkerPool.Quiet beats right away, so beats are serialized for the last one written to say the pool is quiet.
+	beatMtx sync.Mutex
 
 	stopChan         chan struct{}
 	doneStoppingChan chan struct{}
@@ -87,12 +98,23 @@ func (h *workerPoolHeartbeater) loop() {
 }
 
 func (h *workerPoolHeartbeater) heartbeat() {
-	conn := h.pool.Get()
+	h.beatMtx.Lock()
+	defer h.beatMtx.Unlock()
+
+	conn := getConn(h.pool, h.redisTimeout)
 	defer conn.Close()
 
 	workerPoolsKey := redisKeyWorkerPools(h.namespace)
 	heartbeatKey := redisKeyHeartbeat(h.namespace, h.workerPoolID)
 
+	var sampler []byte
+	if h.stats != nil {
+		var err error
+		if sampler, err = json.Marshal(h.stats.samplerSnapshot()); err != nil {
+			reportError(h.logger, h.errorHook, "heartbeat.sampler", err)
+		}
+	}
+
 	conn.Send("SADD", workerPoolsKey, h.workerPoolID)
 	conn.Send("HMSET", heartbeatKey,
 		"heartbeat_at", nowEpochSeconds(),
@@ -102,15 +124,19 @@ func (h *workerPoolHeartbeater) heartbeat() {
 		"worker_ids", h.workerIDs,
 		"host", h.hostname,
 		"pid", h.pid,
+		"disabled_job_names", strings.Join(h.disabled.sorted(), ","),
+		"quiet", h.quiet.isSet(),
+		"sampler", sampler,
+		"capabilities", supportedCapabilities(),
 	)
 
 	if err := conn.Flush(); err != nil {
-		logError("heartbeat", err)
+		reportError(h.logger, h.errorHook, "heartbeat", err)
 	}
 }
 
 func (h *workerPoolHeartbeater) removeHeartbeat() {
-	conn := h.pool.Get()
+	conn := getConn(h.pool, h.redisTimeout)
 	defer conn.Close()
 
 	workerPoolsKey := redisKeyWorkerPools(h.namespace)
@@ -120,6 +146,6 @@ func (h *workerPoolHeartbeater) removeHeartbeat() {
 	conn.Send("DEL", heartbeatKey)
 
 	if err := conn.Flush(); err != nil {
-		logError("remove_heartbeat", err)
+		reportError(h.logger, h.errorHook, "remove_heartbeat", err)
 	}
 }
@@ -22,6 +22,8 @@ func TestHeartbeater(t *testing.T) {
 	}
 
 	heart := newWorkerPoolHeartbeater(ns, pool, "abcd", jobTypes, 10, []string{"ccc", "bbb"})
+	heart.disabled = newJobNameSet()
+	heart.disabled.add("foo")
 	heart.start()
 
 	time.Sleep(20 * time.Millisecond)
@@ -34,6 +36,7 @@ func TestHeartbeater(t *testing.T) {
 	assert.Equal(t, "bar,foo", h["job_names"])
 	assert.Equal(t, "bbb,ccc", h["worker_ids"])
 	assert.Equal(t, "10", h["concurrency"])
+	assert.Equal(t, "foo", h["disabled_job_names"])
 
 	assert.True(t, h["pid"] != "")
 	assert.True(t, h["host"] != "")
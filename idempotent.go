@@ -0,0 +1,45 @@
+package work
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var errNoIdempotence = fmt.Errorf("work: Idempotent is only available to jobs run by a worker pool")
+
+// Idempotent runs fn, the logical operation named key, unless it already succeeded within ttl, eg because the job was
+// retried after a later step failed, or redelivered after its process died. Once fn succeeds, that's recorded in Redis
+// under key for ttl, so key must name the operation across all jobs, eg "charge:"+orderID, and ttl must outlast the
+// job's retries. If fn fails, nothing is recorded and its error is returned.
+//
+// Deliveries of the same job that run at the same time can both run fn: Idempotent skips operations that are done,
+// not ones that are in progress. If recording the success fails, that's logged and fn may run again on the next
+// delivery, but the job goes on.
+func (j *Job) Idempotent(key string, ttl time.Duration, fn func() error) error {
+	if j.checkpoints == nil {
+		return errNoIdempotence
+	}
+
+	conn := getConn(j.checkpoints.pool, j.checkpoints.redisTimeout)
+	done, err := redis.Bool(conn.Do("EXISTS", redisKeyIdempotent(j.checkpoints.namespace, key)))
+	conn.Close()
+	if err != nil {
+		return err
+	}
+	if done {
+		return nil
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	conn = getConn(j.checkpoints.pool, j.checkpoints.redisTimeout)
+	defer conn.Close()
+	if _, err := conn.Do("SET", redisKeyIdempotent(j.checkpoints.namespace, key), j.ID, "PX", int64(ttl/time.Millisecond)); err != nil {
+		logError("job.idempotent", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package work
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobIdempotent(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	charges, attempts := 0, 0
+	jobTypes := map[string]*jobType{
+		"charge": {
+			Name:       "charge",
+			JobOptions: JobOptions{Priority: 1, MaxFails: 3, Backoff: func(*Job) int64 { return 0 }},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				attempts++
+				err := job.Idempotent("charge:"+job.ArgString("order"), time.Minute, func() error {
+					charges++
+					if charges == 1 {
+						return fmt.Errorf("declined")
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				if attempts == 2 {
+					return fmt.Errorf("email failed")
+				}
+				return nil
+			},
+		},
+	}
+	_, err := NewEnqueuer(ns, pool).Enqueue("charge", Q{"order": "1"})
+	assert.NoError(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	requeuer := newRequeuer(ns, pool, redisKeyRetry(ns), []string{"charge"})
+	for i := 0; i < 3; i++ {
+		fetched, err := w.fetchJob()
+		assert.NoError(t, err)
+		w.processJob(fetched)
+		requeuer.processAll()
+	}
+
+	// The failed charge was run again, the one that succeeded wasn't
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 2, charges)
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyRetry(ns)))
+
+	conn := pool.Get()
+	defer conn.Close()
+	ttl, err := redis.Int64(conn.Do("PTTL", redisKeyIdempotent(ns, "charge:1")))
+	assert.NoError(t, err)
+	assert.True(t, ttl > 0 && ttl <= time.Minute.Milliseconds())
+
+	assert.Equal(t, errNoIdempotence, (&Job{}).Idempotent("x", time.Minute, func() error { return nil }))
+}
@@ -1,10 +1,15 @@
 package work
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
+	"time"
 )
 
 // Job represents a job.
@@ -17,38 +22,139 @@ type Job struct {
 	Unique     bool                   `json:"unique,omitempty"`
 	UniqueKey  string                 `json:"unique_key,omitempty"`
 
+	// ArgsVersion is the version of Args' layout. See WorkerPool.MigrateArgs.
+	ArgsVersion uint `json:"args_version,omitempty"`
+
+	// Fingerprint is a hash of the job's name and arguments, computed once when it's first enqueued and kept as is
+	// through retries and the dead queue, so it stays the same even though the job is re-serialized along the way.
+	// Jobs with the same fingerprint are duplicates of one another, see Client.DeadJobGroups. Periodic jobs,
+	// whose serialized form has to match across versions, and jobs enqueued by earlier versions don't have one.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
 	// Inputs when retrying
 	Fails    int64  `json:"fails,omitempty"` // number of times this job has failed
 	LastErr  string `json:"err,omitempty"`
 	FailedAt int64  `json:"failed_at,omitempty"`
 
-	rawJSON      []byte
+	// DeadRetention is set on dead jobs whose type has JobOptions.DeadRetention, in seconds.
+	DeadRetention int64 `json:"dead_retention,omitempty"`
+
+	// History is what happened to the job besides being enqueued and succeeding, oldest first: where and when it
+	// failed, when it was retried and so on. Only the last 20 events are kept.
+	History []JobEvent `json:"history,omitempty"`
+
+	// Annotations are notes handlers leave on the job with Annotate, eg how far they got, kept with it through retries
+	// and the dead queue.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Trace carries the trace context of whoever enqueued the job, eg W3C's traceparent, for tracing middleware to
+	// continue. The package only keeps it with the job; see TraceCarrier.
+	Trace TraceCarrier `json:"trace,omitempty"`
+
+	rawJSON      []byte          // the job as dequeued, or as enqueued for the jobs an Enqueuer returns
+	rawArgs      json.RawMessage // Args as enqueued, until they're decoded
 	dequeuedFrom []byte
 	inProgQueue  []byte
 	argError     error
 	observer     *observer
+	checkpoints  *checkpointStore
+	checkpointed bool // whether the job saved or loaded a checkpoint, which is deleted once it succeeds
+	result       interface{}
+	ctx          context.Context
+	followUps    []*Job
+	itemFailures map[int]error
+	attempt      int64 // counting from 1, set once a worker runs the job, since Fails goes up when an attempt fails
+}
+
+// jobFingerprint hashes the job name and the canonical JSON of args: encoding/json sorts map keys, so the same
+// arguments always hash the same.
+func jobFingerprint(jobName string, args map[string]interface{}) (string, error) {
+	h := sha1.New()
+	h.Write([]byte(jobName))
+	h.Write([]byte{0})
+	if err := json.NewEncoder(h).Encode(args); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 // Q is a shortcut to easily specify arguments for jobs when enqueueing them.
 // Example: e.Enqueue("send_email", work.Q{"addr": "test@example.com", "track": true})
 type Q map[string]interface{}
 
+// jobAlias has Job's fields but not its methods, so it can be embedded to override how Args are (un)marshalled.
+type jobAlias Job
+
+// jobWithRawArgs is a Job whose Args are left as JSON.
+type jobWithRawArgs struct {
+	*jobAlias
+	Args json.RawMessage `json:"args"`
+}
+
 func newJob(rawJSON, dequeuedFrom, inProgQueue []byte) (*Job, error) {
+	job, err := newJobRawArgs(rawJSON, dequeuedFrom, inProgQueue)
+	if err != nil {
+		return nil, err
+	}
+	if err := job.decodeArgs(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// newJobRawArgs is newJob without decoding the job's Args, which is most of the cost of decoding a job. There's no
+// buffer to reuse here: the job keeps what's unmarshalled, including rawJSON, for as long as its handler and
+// middleware hold on to it.
+func newJobRawArgs(rawJSON, dequeuedFrom, inProgQueue []byte) (*Job, error) {
 	var job Job
-	err := json.Unmarshal(rawJSON, &job)
+	aux := jobWithRawArgs{jobAlias: (*jobAlias)(&job)}
+	err := json.Unmarshal(rawJSON, &aux)
 	if err != nil {
 		return nil, err
 	}
+	job.rawArgs = aux.Args
 	job.rawJSON = rawJSON
 	job.dequeuedFrom = dequeuedFrom
 	job.inProgQueue = inProgQueue
 	return &job, nil
 }
 
+// decodeArgs fills in Args from the job's raw args, if that hasn't happened yet.
+func (j *Job) decodeArgs() error {
+	if j.rawArgs == nil {
+		return nil
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal(j.rawArgs, &args); err != nil {
+		return err
+	}
+	j.Args = args
+	j.rawArgs = nil
+	return nil
+}
+
 func (j *Job) serialize() ([]byte, error) {
+	if j.rawArgs != nil {
+		// Args were never decoded; pass them through untouched
+		return json.Marshal(&jobWithRawArgs{jobAlias: (*jobAlias)(j), Args: j.rawArgs})
+	}
 	return json.Marshal(j)
 }
 
+// RawArgs returns the job's arguments as JSON. For job types registered with JobOptions.RawArgs, Args is left nil and
+// this is the only way to get at the arguments, saving the cost of decoding them for handlers that parse them
+// themselves, eg into a struct. The returned slice must not be modified.
+func (j *Job) RawArgs() []byte {
+	if j.rawArgs != nil {
+		return j.rawArgs
+	}
+	rawArgs, err := json.Marshal(j.Args)
+	if err != nil {
+		return nil
+	}
+	return rawArgs
+}
+
 // setArg sets a single named argument on the job.
 func (j *Job) setArg(key string, val interface{}) {
 	if j.Args == nil {
@@ -63,6 +169,157 @@ func (j *Job) failed(err error) {
 	j.FailedAt = nowEpochSeconds()
 }
 
+// The kinds of JobEvent.
+const (
+	JobFailed   = "failed"   // A worker ran the job and it failed
+	JobRetried  = "retried"  // The job was moved from the retry queue back to its queue
+	JobOrphaned = "orphaned" // The job was put back on its queue because the pool running it died, so it runs again
+	JobRevived  = "revived"  // The dead job was put back on its queue with Client.RetryDeadJob or RetryAllDeadJobs
+)
+
+// jobHistoryMaxLen is the number of events kept in Job.History.
+const jobHistoryMaxLen = 20
+
+// JobEvent is a step in a job's life, as recorded in Job.History.
+type JobEvent struct {
+	Event   string `json:"event"`             // One of JobFailed, JobRetried, JobOrphaned or JobRevived
+	At      int64  `json:"at"`                // In epoch seconds
+	Host    string `json:"host,omitempty"`    // For JobFailed, the host of the worker
+	Pid     int    `json:"pid,omitempty"`     // For JobFailed, the process of the worker
+	Attempt int64  `json:"attempt,omitempty"` // For JobFailed, the job's Fails after the attempt
+	Err     string `json:"err,omitempty"`     // For JobFailed, the error
+	Stack   string `json:"stack,omitempty"`   // For JobFailed, where the handler panicked, if it did
+}
+
+// record adds e to the job's history, dropping the oldest events past jobHistoryMaxLen.
+func (j *Job) record(e JobEvent) {
+	j.History = append(j.History, e)
+	if len(j.History) > jobHistoryMaxLen {
+		j.History = j.History[len(j.History)-jobHistoryMaxLen:]
+	}
+}
+
+// ReportItemFailure records that the item at index idx of the job's list of items, its JobOptions.ItemsArg
+// argument, failed with err. If the handler goes on to succeed, the job is done except for the items that failed:
+// they're retried in a job of their own, which has the same arguments but only those items and counts as a retry of
+// this one, following its type's MaxFails and backoff. If the handler fails, the whole job is retried as usual.
+// Reporting the same item again replaces its error. ReportItemFailure isn't safe for concurrent use.
+func (j *Job) ReportItemFailure(idx int, err error) {
+	if j.itemFailures == nil {
+		j.itemFailures = make(map[int]error)
+	}
+	j.itemFailures[idx] = err
+}
+
+// itemsRetry returns the job retrying the items of j that failed, and their error.
+func (j *Job) itemsRetry(itemsArg string) (*Job, error) {
+	if itemsArg == "" {
+		return nil, fmt.Errorf("item failures reported, but the job type has no JobOptions.ItemsArg")
+	}
+	if err := j.decodeArgs(); err != nil {
+		return nil, err
+	}
+	items, ok := j.Args[itemsArg].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("item failures reported, but the %q argument isn't a list", itemsArg)
+	}
+
+	failed := make([]int, 0, len(j.itemFailures))
+	for idx := range j.itemFailures {
+		if idx < 0 || idx >= len(items) {
+			return nil, fmt.Errorf("item failure reported for item %d of %d", idx, len(items))
+		}
+		failed = append(failed, idx)
+	}
+	sort.Ints(failed)
+
+	retried := make([]interface{}, 0, len(failed))
+	for _, idx := range failed {
+		retried = append(retried, items[idx])
+	}
+	args := make(map[string]interface{}, len(j.Args))
+	for k, v := range j.Args {
+		args[k] = v
+	}
+	args[itemsArg] = retried
+
+	fingerprint, _ := jobFingerprint(j.Name, args)
+	retry := &Job{
+		Name:        j.Name,
+		ID:          makeIdentifier(),
+		EnqueuedAt:  j.EnqueuedAt,
+		Args:        args,
+		ArgsVersion: j.ArgsVersion,
+		Fingerprint: fingerprint,
+		Fails:       j.Fails,
+		History:     append([]JobEvent(nil), j.History...),
+		Annotations: j.annotationsCopy(),
+		Trace:       j.Trace,
+	}
+	first := failed[0]
+	return retry, fmt.Errorf("%d of %d items failed, eg item %d: %v", len(failed), len(items), first, j.itemFailures[first])
+}
+
+// Result returns the result the job's handler returned with Success, if any. Middleware can read it once next
+// returns.
+func (j *Job) Result() interface{} {
+	return j.result
+}
+
+// Annotate records value under key in the job's Annotations, or deletes key if value is "". Annotations are saved
+// with the job when it fails, so the next attempt can read them with Annotation, eg to resume from the last offset
+// processed rather than from the start. They're saved for retries, the dead queue and the retries of failed items,
+// but not once the job succeeds. Keep them small: they're stored and read with every copy of the job.
+// Annotate isn't safe for concurrent use.
+func (j *Job) Annotate(key, value string) {
+	if value == "" {
+		delete(j.Annotations, key)
+		return
+	}
+	if j.Annotations == nil {
+		j.Annotations = make(map[string]string)
+	}
+	j.Annotations[key] = value
+}
+
+// Annotation returns the annotation recorded under key with Annotate, by this attempt or an earlier one, or "".
+func (j *Job) Annotation(key string) string {
+	return j.Annotations[key]
+}
+
+func (j *Job) annotationsCopy() map[string]string {
+	if len(j.Annotations) == 0 {
+		return nil
+	}
+	annotations := make(map[string]string, len(j.Annotations))
+	for k, v := range j.Annotations {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// Age returns how long ago the job was first enqueued, which retries don't reset. See JobOptions.MaxAge.
+func (j *Job) Age() time.Duration {
+	return time.Duration(nowEpochSeconds()-j.EnqueuedAt) * time.Second
+}
+
+// Context returns the job's context, which is canceled once the worker pool running the job is stopped, or the job's
+// JobOptions.Timeout is up. Long-running handlers should pass it on to what they call, or check it between steps, to
+// give up cleanly rather than hold up the pool's shutdown. Jobs that aren't run by a worker pool get
+// context.Background().
+func (j *Job) Context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+	return j.ctx
+}
+
+// SetContext replaces the job's context for the middleware after the caller and the handler, eg with one carrying a
+// tracing span. ctx should derive from Context, or the job no longer notices that its pool is stopping.
+func (j *Job) SetContext(ctx context.Context) {
+	j.ctx = ctx
+}
+
 // Checkin will update the status of the executing job to the specified messages. This message is visible within the web UI. This is useful for indicating some sort of progress on very long running jobs. For instance, on a job that has to process a million records over the course of an hour, the job could call Checkin with the current job number every 10k jobs.
 func (j *Job) Checkin(msg string) {
 	if j.observer != nil {
@@ -0,0 +1,196 @@
+package work
+
+import (
+	"fmt"
+	"time"
+)
+
+// JobOption sets one of a job type's JobOptions, eg pool.Job("export", (*Context).Export, work.Priority(10),
+// work.MaxFails(1)). Options check their arguments when the job type is registered.
+type JobOption func(*JobOptions) error
+
+// Priority sets JobOptions.Priority, from 1 to 100000.
+func Priority(priority uint) JobOption {
+	return func(o *JobOptions) error {
+		if priority < 1 || priority > 100000 {
+			return fmt.Errorf("Priority(%d): must be between 1 and 100000", priority)
+		}
+		o.Priority = priority
+		return nil
+	}
+}
+
+// MaxFails sets JobOptions.MaxFails, at least 1.
+func MaxFails(maxFails uint) JobOption {
+	return func(o *JobOptions) error {
+		if maxFails < 1 {
+			return fmt.Errorf("MaxFails(%d): must be at least 1; use 1 to send failed jobs straight to the dead queue", maxFails)
+		}
+		o.MaxFails = maxFails
+		return nil
+	}
+}
+
+// SkipDead sets JobOptions.SkipDead.
+func SkipDead() JobOption {
+	return func(o *JobOptions) error {
+		o.SkipDead = true
+		return nil
+	}
+}
+
+// MaxConcurrency sets JobOptions.MaxConcurrency. 0 means no max.
+func MaxConcurrency(maxConcurrency uint) JobOption {
+	return func(o *JobOptions) error {
+		o.MaxConcurrency = maxConcurrency
+		return nil
+	}
+}
+
+// Backoff sets JobOptions.Backoff.
+func Backoff(backoff BackoffCalculator) JobOption {
+	return func(o *JobOptions) error {
+		if backoff == nil {
+			return fmt.Errorf("Backoff(nil): needs a BackoffCalculator; leave the option out for the default backoff")
+		}
+		o.Backoff = backoff
+		return nil
+	}
+}
+
+// BatchSize sets JobOptions.BatchSize, at least 1.
+func BatchSize(batchSize uint) JobOption {
+	return func(o *JobOptions) error {
+		if batchSize < 1 {
+			return fmt.Errorf("BatchSize(%d): must be at least 1", batchSize)
+		}
+		o.BatchSize = batchSize
+		return nil
+	}
+}
+
+// RawArgs sets JobOptions.RawArgs.
+func RawArgs() JobOption {
+	return func(o *JobOptions) error {
+		o.RawArgs = true
+		return nil
+	}
+}
+
+// DeadRetention sets JobOptions.DeadRetention.
+func DeadRetention(retention time.Duration) JobOption {
+	return func(o *JobOptions) error {
+		if retention <= 0 {
+			return fmt.Errorf("DeadRetention(%v): must be positive", retention)
+		}
+		o.DeadRetention = retention
+		return nil
+	}
+}
+
+// OwnFailureQueues sets JobOptions.OwnFailureQueues.
+func OwnFailureQueues() JobOption {
+	return func(o *JobOptions) error {
+		o.OwnFailureQueues = true
+		return nil
+	}
+}
+
+// ExpressRetries sets JobOptions.ExpressRetries.
+func ExpressRetries() JobOption {
+	return func(o *JobOptions) error {
+		o.ExpressRetries = true
+		return nil
+	}
+}
+
+// Execute sets JobOptions.Executor.
+func Execute(executor Executor) JobOption {
+	return func(o *JobOptions) error {
+		if executor == nil {
+			return fmt.Errorf("Execute(nil): needs an Executor; leave the option out to call the handler directly")
+		}
+		o.Executor = executor
+		return nil
+	}
+}
+
+// Items sets JobOptions.ItemsArg.
+func Items(arg string) JobOption {
+	return func(o *JobOptions) error {
+		if arg == "" {
+			return fmt.Errorf("Items(\"\"): needs the name of the argument holding the items")
+		}
+		o.ItemsArg = arg
+		return nil
+	}
+}
+
+// Cost sets JobOptions.Cost, at least 1.
+func Cost(cost uint) JobOption {
+	return func(o *JobOptions) error {
+		if cost < 1 {
+			return fmt.Errorf("Cost(%d): must be at least 1", cost)
+		}
+		o.Cost = cost
+		return nil
+	}
+}
+
+// Class sets JobOptions.Class.
+func Class(class JobClass) JobOption {
+	return func(o *JobOptions) error {
+		if class != CPUBound && class != IOBound {
+			return fmt.Errorf("Class(%d): must be CPUBound or IOBound", class)
+		}
+		o.Class = class
+		return nil
+	}
+}
+
+// MaxAge sets JobOptions.MaxAge.
+func MaxAge(age time.Duration) JobOption {
+	return func(o *JobOptions) error {
+		if age <= 0 {
+			return fmt.Errorf("MaxAge(%v): must be positive", age)
+		}
+		o.MaxAge = age
+		return nil
+	}
+}
+
+// Timeout sets JobOptions.Timeout.
+func Timeout(timeout time.Duration) JobOption {
+	return func(o *JobOptions) error {
+		if timeout <= 0 {
+			return fmt.Errorf("Timeout(%v): must be positive", timeout)
+		}
+		o.Timeout = timeout
+		return nil
+	}
+}
+
+// SLA sets JobOptions.SLA.
+func SLA(sla time.Duration) JobOption {
+	return func(o *JobOptions) error {
+		if sla <= 0 {
+			return fmt.Errorf("SLA(%v): must be positive", sla)
+		}
+		o.SLA = sla
+		return nil
+	}
+}
+
+// newJobOptions applies opts, checking that they make sense together.
+func newJobOptions(opts []JobOption) (JobOptions, error) {
+	var jobOpts JobOptions
+	for _, opt := range opts {
+		if err := opt(&jobOpts); err != nil {
+			return JobOptions{}, err
+		}
+	}
+	if jobOpts.SkipDead && jobOpts.DeadRetention > 0 {
+		return JobOptions{}, fmt.Errorf("DeadRetention has no effect with SkipDead, since jobs never go to the dead queue")
+	}
+	return jobOpts, nil
+}
@@ -0,0 +1,67 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobOptions(t *testing.T) {
+	pool := newTestPool(":6379")
+	wp := NewWorkerPool(TestContext{}, 1, "work", pool)
+	backoff := func(job *Job) int64 { return 1 }
+
+	wp.Job("wat", func(job *Job) error { return nil },
+		Priority(10), MaxFails(2), MaxConcurrency(3), Backoff(backoff), BatchSize(4), RawArgs(), DeadRetention(time.Hour), OwnFailureQueues(), ExpressRetries(), Items("emails"), Cost(8), Class(IOBound), MaxAge(time.Hour), Timeout(time.Minute), SLA(5*time.Minute))
+	jt := wp.jobTypes["wat"]
+	assert.EqualValues(t, 10, jt.Priority)
+	assert.EqualValues(t, 2, jt.MaxFails)
+	assert.EqualValues(t, 3, jt.MaxConcurrency)
+	assert.NotNil(t, jt.Backoff)
+	assert.EqualValues(t, 4, jt.BatchSize)
+	assert.True(t, jt.RawArgs)
+	assert.Equal(t, time.Hour, jt.DeadRetention)
+	assert.True(t, jt.OwnFailureQueues)
+	assert.True(t, jt.ExpressRetries)
+	assert.Equal(t, "emails", jt.ItemsArg)
+	assert.EqualValues(t, 8, jt.Cost)
+	assert.Equal(t, IOBound, jt.Class)
+	assert.Equal(t, time.Hour, jt.MaxAge)
+	assert.Equal(t, time.Minute, jt.Timeout)
+	assert.Equal(t, 5*time.Minute, jt.SLA)
+	assert.False(t, jt.SkipDead)
+
+	// Defaults still apply to what's left out
+	wp.Job("bob", func(job *Job) error { return nil }, SkipDead())
+	jt = wp.jobTypes["bob"]
+	assert.EqualValues(t, 1, jt.Priority)
+	assert.EqualValues(t, 4, jt.MaxFails)
+	assert.True(t, jt.SkipDead)
+}
+
+func TestJobOptionsInvalid(t *testing.T) {
+	pool := newTestPool(":6379")
+	wp := NewWorkerPool(TestContext{}, 1, "work", pool)
+	handler := func(job *Job) error { return nil }
+
+	assert.PanicsWithValue(t, `work: job "wat": Priority(0): must be between 1 and 100000`, func() {
+		wp.Job("wat", handler, Priority(0))
+	})
+	assert.PanicsWithValue(t, `work: job "wat": MaxFails(0): must be at least 1; use 1 to send failed jobs straight to the dead queue`, func() {
+		wp.Job("wat", handler, MaxFails(0))
+	})
+	assert.Panics(t, func() { wp.Job("wat", handler, Backoff(nil)) })
+	assert.Panics(t, func() { wp.Job("wat", handler, BatchSize(0)) })
+	assert.Panics(t, func() { wp.Job("wat", handler, DeadRetention(0)) })
+	assert.Panics(t, func() { wp.Job("wat", handler, Items("")) })
+	assert.Panics(t, func() { wp.Job("wat", handler, Cost(0)) })
+	assert.Panics(t, func() { wp.Job("wat", handler, Class(Unclassified)) })
+	assert.Panics(t, func() { wp.Job("wat", handler, MaxAge(0)) })
+	assert.Panics(t, func() { wp.Job("wat", handler, Timeout(0)) })
+	assert.Panics(t, func() { wp.Job("wat", handler, SLA(0)) })
+	assert.PanicsWithValue(t, `work: job "wat": DeadRetention has no effect with SkipDead, since jobs never go to the dead queue`, func() {
+		wp.Job("wat", handler, SkipDead(), DeadRetention(time.Hour))
+	})
+	assert.Empty(t, wp.jobTypes)
+}
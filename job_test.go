@@ -1,6 +1,7 @@
 package work
 
 import (
+	"fmt"
 	"math"
 	"testing"
 
@@ -254,3 +255,118 @@ func TestJobArgumentExtractionBadFloat(t *testing.T) {
 		j.argError = nil
 	}
 }
+
+func TestJobRawArgs(t *testing.T) {
+	rawJSON := []byte(`{"name":"foo","id":"1","t":1,"args":{"a":1,"b":"x"},"fails":2}`)
+
+	job, err := newJobRawArgs(rawJSON, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", job.Name)
+	assert.EqualValues(t, 2, job.Fails)
+	assert.Nil(t, job.Args)
+	assert.Equal(t, `{"a":1,"b":"x"}`, string(job.RawArgs()))
+
+	// Undecoded args survive a round trip, eg to the retry queue
+	serialized, err := job.serialize()
+	assert.NoError(t, err)
+	again, err := newJob(serialized, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 1.0, "b": "x"}, again.Args)
+
+	assert.NoError(t, job.decodeArgs())
+	assert.Equal(t, map[string]interface{}{"a": 1.0, "b": "x"}, job.Args)
+	assert.Equal(t, `{"a":1,"b":"x"}`, string(job.RawArgs()))
+
+	_, err = newJob([]byte(`{"name":"foo","args":[1]}`), nil, nil)
+	assert.Error(t, err)
+}
+
+func BenchmarkNewJob(b *testing.B) {
+	rawJSON := []byte(`{"name":"track_event","id":"b7a2d6b1c3e4f5a6b7c8d9e0","t":1600000000,"args":{"user_id":1234,"event":"click","props":{"x":1,"y":2}}}`)
+
+	b.Run("decoded", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			newJob(rawJSON, nil, nil)
+		}
+	})
+	b.Run("raw", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			newJobRawArgs(rawJSON, nil, nil)
+		}
+	})
+}
+
+func TestJobRecordHistory(t *testing.T) {
+	j := &Job{}
+	for i := 1; i <= jobHistoryMaxLen+5; i++ {
+		j.record(JobEvent{Event: JobFailed, Attempt: int64(i)})
+	}
+	assert.Len(t, j.History, jobHistoryMaxLen)
+	assert.EqualValues(t, 6, j.History[0].Attempt)
+	assert.EqualValues(t, jobHistoryMaxLen+5, j.History[jobHistoryMaxLen-1].Attempt)
+}
+
+func TestJobItemsRetry(t *testing.T) {
+	j := &Job{Name: "notify", ID: "1", Args: Q{"emails": []interface{}{"a", "b", "c", "d"}, "subject": "hi"}, Fails: 1, ArgsVersion: 2}
+	j.ReportItemFailure(3, fmt.Errorf("bounced"))
+	j.ReportItemFailure(1, fmt.Errorf("timeout"))
+
+	retry, err := j.itemsRetry("emails")
+	assert.EqualError(t, err, "2 of 4 items failed, eg item 1: timeout")
+	assert.Equal(t, map[string]interface{}{"emails": []interface{}{"b", "d"}, "subject": "hi"}, retry.Args)
+	assert.Equal(t, []interface{}{"a", "b", "c", "d"}, j.Args["emails"])
+	assert.NotEqual(t, j.ID, retry.ID)
+	assert.EqualValues(t, 1, retry.Fails)
+	assert.EqualValues(t, 2, retry.ArgsVersion)
+	assert.NotEmpty(t, retry.Fingerprint)
+
+	_, err = j.itemsRetry("")
+	assert.EqualError(t, err, "item failures reported, but the job type has no JobOptions.ItemsArg")
+	_, err = j.itemsRetry("subject")
+	assert.EqualError(t, err, `item failures reported, but the "subject" argument isn't a list`)
+	j.ReportItemFailure(4, fmt.Errorf("nope"))
+	_, err = j.itemsRetry("emails")
+	assert.EqualError(t, err, "item failure reported for item 4 of 4")
+}
+
+func TestJobAnnotate(t *testing.T) {
+	j := &Job{}
+	assert.Equal(t, "", j.Annotation("offset"))
+	j.Annotate("gone", "")
+	assert.Nil(t, j.Annotations)
+
+	j.Annotate("offset", "10")
+	j.Annotate("cursor", "abc")
+	j.Annotate("cursor", "")
+	assert.Equal(t, "10", j.Annotation("offset"))
+	assert.Equal(t, map[string]string{"offset": "10"}, j.Annotations)
+
+	// Item retries keep them, separately
+	j = &Job{Name: "wat", Args: Q{"items": []interface{}{"a", "b"}}}
+	j.Annotate("offset", "10")
+	j.ReportItemFailure(1, fmt.Errorf("bad"))
+	retry, _ := j.itemsRetry("items")
+	assert.Equal(t, map[string]string{"offset": "10"}, retry.Annotations)
+	retry.Annotate("offset", "20")
+	assert.Equal(t, "10", j.Annotation("offset"))
+}
+
+func TestJobTrace(t *testing.T) {
+	trace := TraceCarrier{}
+	assert.Equal(t, "", trace.Get("traceparent"))
+	trace.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.Equal(t, []string{"traceparent"}, trace.Keys())
+
+	// It's kept through serialization, and by item retries
+	j := &Job{Name: "wat", Args: Q{"items": []interface{}{"a", "b"}}, Trace: trace}
+	rawJSON, err := j.serialize()
+	assert.NoError(t, err)
+	j, err = newJob(rawJSON, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, trace, j.Trace)
+	j.ReportItemFailure(0, fmt.Errorf("bad"))
+	retry, _ := j.itemsRetry("items")
+	assert.Equal(t, trace, retry.Trace)
+}
@@ -0,0 +1,79 @@
+package work
+
+// NamespaceKeys are the Redis keys and channels a namespace uses, see KeysForNamespace. Keys and Patterns are in the
+// same glob syntax as Redis ACL key patterns, so they can be pasted into an ACL rule as ~<key>, and Channels as
+// &<channel>.
+type NamespaceKeys struct {
+	Keys     []string // keys with fixed names
+	Patterns []string // globs for the keys named after pools, workers, jobs or their arguments, eg "ns:checkpoint:*"
+	Volatile []string // those of Patterns whose keys are set with a TTL; all other keys must never be evicted
+	Channels []string // pub/sub channels
+}
+
+// KeysForNamespace returns all the keys and channels that the package touches in namespace for the job types
+// jobNames, eg to set up Redis ACLs for a fleet, to monitor the keys, or to check that an eviction policy will only
+// ever evict volatile keys. Job types that aren't listed still use the namespace-wide keys, eg the retry and dead
+// queues, but not the keys of their own.
+//
+// The list is only meant for operators: the package doesn't read it, so it doesn't guarantee that the keys exist.
+func KeysForNamespace(namespace string, jobNames []string) NamespaceKeys {
+	prefix := redisNamespacePrefix(namespace)
+	keys := NamespaceKeys{
+		Keys: []string{
+			redisKeyKnownJobs(namespace),
+			redisKeyRetry(namespace),
+			redisKeyRetryExpress(namespace),
+			redisKeyDead(namespace),
+			redisKeyOwnFailureQueues(namespace),
+			redisKeyScheduled(namespace),
+			redisKeyWorkerPools(namespace),
+			redisKeyLastPeriodicEnqueue(namespace),
+			redisKeyPeriodicLastRuns(namespace),
+			redisKeyAckFailures(namespace),
+			redisKeyStandby(namespace),
+			redisKeyConfig(namespace),
+			redisKeyTap(namespace),
+			redisKeyAuditLog(namespace),
+			redisKeyRestartTurns(namespace),
+			redisKeyShedCounts(namespace),
+		},
+		Patterns: []string{
+			redisKeyHeartbeat(namespace, "*"),
+			redisKeyWorkerObservation(namespace, "*"),
+			redisKeyLeader(namespace, "*"),
+			redisKeyCheckpoint(namespace, "*"),
+			redisKeyIdempotent(namespace, "*"),
+			redisKeySemaphore(namespace, "*"),
+			redisKeyMigratingZset(namespace, "*"),
+			prefix + "quota:*",
+		},
+		Volatile: []string{
+			redisKeyWorkerObservation(namespace, "*"),
+			redisKeyLeader(namespace, "*"),
+			redisKeyCheckpoint(namespace, "*"),
+			redisKeyIdempotent(namespace, "*"),
+			redisKeySemaphore(namespace, "*"),
+			prefix + "quota:*",
+		},
+		Channels: []string{
+			redisKeyWake(namespace),
+		},
+	}
+
+	for _, jobName := range jobNames {
+		keys.Keys = append(keys.Keys,
+			redisKeyJobs(namespace, jobName),
+			redisKeyJobsPaused(namespace, jobName),
+			redisKeyJobsLock(namespace, jobName),
+			redisKeyJobsLockInfo(namespace, jobName),
+			redisKeyJobsConcurrency(namespace, jobName),
+			redisKeyRetryOf(namespace, jobName),
+			redisKeyDeadOf(namespace, jobName),
+		)
+		unique := prefix + "unique:" + jobName + ":*"
+		keys.Keys = append(keys.Keys, redisKeyMigrating(namespace, jobName))
+		keys.Patterns = append(keys.Patterns, redisKeyJobsInProgress(namespace, "*", jobName), unique)
+		keys.Volatile = append(keys.Volatile, unique)
+	}
+	return keys
+}
@@ -0,0 +1,69 @@
+package work
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeysForNamespace(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	job2 := "job2"
+	cleanKeyspace(ns, pool)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue(job1, Q{"a": 1})
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueUnique(job1, Q{"a": 2})
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueIn(job2, 300, nil)
+	assert.NoError(t, err)
+
+	wp := NewWorkerPool(TestContext{}, 2, ns, pool)
+	wp.JobWithOptions(job1, JobOptions{MaxFails: 1, MaxConcurrency: 1}, func(job *Job) error {
+		return fmt.Errorf("sorry kid")
+	})
+	wp.Job(job2, func(job *Job) error { return nil })
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+	assert.NoError(t, NewClient(ns, pool).SetConfig(JobConfigKey(ConfigPaused, job2), "true"))
+
+	keys := KeysForNamespace(ns, []string{job1, job2})
+	assert.Contains(t, keys.Keys, "work:jobs:job1")
+	assert.Contains(t, keys.Patterns, "work:jobs:job1:*:inprogress")
+	assert.Contains(t, keys.Channels, "work:wake")
+	for _, pattern := range keys.Volatile {
+		assert.Contains(t, keys.Patterns, pattern)
+	}
+
+	// Every key the run left behind is listed
+	conn := pool.Get()
+	defer conn.Close()
+	found, err := redis.Strings(conn.Do("KEYS", ns+":*"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, found)
+	for _, key := range found {
+		assert.True(t, keys.covers(key), key)
+	}
+	assert.False(t, keys.covers("work:jobs:job3"))
+}
+
+func (k NamespaceKeys) covers(key string) bool {
+	for _, k := range k.Keys {
+		if k == key {
+			return true
+		}
+	}
+	for _, pattern := range k.Patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
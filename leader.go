@@ -0,0 +1,164 @@
+package work
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	leaderLeaseTTL      = 15 * time.Second
+	leaderRenewInterval = 5 * time.Second
+)
+
+// leaderElector holds a lease on a Redis key for as long as the pool is running, so that exactly one of the pools
+// competing for the key runs the singleton components. Losing the lease (eg because Redis was unreachable for longer
+// than leaseTTL) calls onDemoted; the pool can win it back later. Stopping releases the lease so another pool can take
+// over without waiting for it to expire.
+type leaderElector struct {
+	poolID        string
+	key           string
+	pool          *redis.Pool
+	leaseTTL      time.Duration
+	renewInterval time.Duration
+	redisTimeout  time.Duration
+	errorHook     ErrorHook
+	logger        Logger
+
+	onElected func()
+	onDemoted func()
+
+	isLeader    bool // only touched by the elector's goroutine
+	lastRenewed time.Time
+	leading     int32 // isLeader, for reading from other goroutines
+
+	renewScript   *redis.Script
+	releaseScript *redis.Script
+
+	stopChan         chan struct{}
+	doneStoppingChan chan struct{}
+}
+
+func newLeaderElector(key string, pool *redis.Pool, poolID string, onElected, onDemoted func()) *leaderElector {
+	return &leaderElector{
+		poolID:           poolID,
+		key:              key,
+		pool:             pool,
+		leaseTTL:         leaderLeaseTTL,
+		renewInterval:    leaderRenewInterval,
+		onElected:        onElected,
+		onDemoted:        onDemoted,
+		renewScript:      redis.NewScript(1, redisLuaRenewLease),
+		releaseScript:    redis.NewScript(1, redisLuaReleaseLease),
+		stopChan:         make(chan struct{}),
+		doneStoppingChan: make(chan struct{}),
+	}
+}
+
+// start campaigns right away, so a lone pool is leader by the time start returns.
+func (l *leaderElector) start() {
+	l.campaign()
+	go l.loop()
+}
+
+func (l *leaderElector) stop() {
+	l.stopChan <- struct{}{}
+	<-l.doneStoppingChan
+}
+
+func (l *leaderElector) isLeading() bool {
+	return atomic.LoadInt32(&l.leading) == 1
+}
+
+func (l *leaderElector) loop() {
+	ticker := time.NewTicker(l.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopChan:
+			if l.isLeader {
+				l.demote()
+				l.release()
+			}
+			l.doneStoppingChan <- struct{}{}
+			return
+		case <-ticker.C:
+			if l.isLeader {
+				l.renew()
+			} else {
+				l.campaign()
+			}
+		}
+	}
+}
+
+func (l *leaderElector) campaign() {
+	conn := getConn(l.pool, l.redisTimeout)
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", l.key, l.poolID, "NX", "PX", l.leaseTTL.Milliseconds()))
+	if err == redis.ErrNil {
+		return // someone else is leader
+	} else if err != nil {
+		reportError(l.logger, l.errorHook, "leader_elector.campaign", err)
+		return
+	}
+
+	l.isLeader = true
+	l.lastRenewed = time.Now()
+	atomic.StoreInt32(&l.leading, 1)
+	l.onElected()
+}
+
+func (l *leaderElector) renew() {
+	conn := getConn(l.pool, l.redisTimeout)
+	defer conn.Close()
+
+	renewed, err := redis.Bool(l.renewScript.Do(conn, l.key, l.poolID, l.leaseTTL.Milliseconds()))
+	if err != nil {
+		reportError(l.logger, l.errorHook, "leader_elector.renew", err)
+		// Our lease may still be good; only give up once it has certainly expired.
+		if time.Since(l.lastRenewed) >= l.leaseTTL {
+			l.demote()
+		}
+		return
+	}
+	if !renewed {
+		l.demote()
+		return
+	}
+	l.lastRenewed = time.Now()
+}
+
+func (l *leaderElector) demote() {
+	l.isLeader = false
+	atomic.StoreInt32(&l.leading, 0)
+	l.onDemoted()
+}
+
+func (l *leaderElector) release() {
+	conn := getConn(l.pool, l.redisTimeout)
+	defer conn.Close()
+
+	if _, err := l.releaseScript.Do(conn, l.key, l.poolID); err != nil {
+		reportError(l.logger, l.errorHook, "leader_elector.release", err)
+	}
+}
+
+// leaderGroup identifies the processes that compete for leadership: those with the same job types. Pools with
+// different job types can't stand in for each other, since eg a requeuer sends jobs it has no handler for to the dead
+// queue.
+func leaderGroup(jobNames []string) string {
+	sorted := append([]string(nil), jobNames...)
+	sort.Strings(sorted)
+
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(sorted, ",")))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
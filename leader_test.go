@@ -0,0 +1,133 @@
+package work
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderElectorHandover(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	key := redisKeyLeader(ns, "group")
+
+	var elected, demoted [2]int64
+	electors := make([]*leaderElector, 2)
+	for i := range electors {
+		i := i
+		electors[i] = newLeaderElector(key, pool, makeIdentifier(),
+			func() { atomic.AddInt64(&elected[i], 1) },
+			func() { atomic.AddInt64(&demoted[i], 1) })
+		electors[i].renewInterval = 10 * time.Millisecond
+		electors[i].start()
+	}
+
+	assert.True(t, electors[0].isLeading())
+	assert.False(t, electors[1].isLeading())
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, electors[1].isLeading())
+
+	// Stopping the leader hands over without waiting for the lease to expire
+	electors[0].stop()
+	assert.EqualValues(t, 1, atomic.LoadInt64(&demoted[0]))
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, electors[1].isLeading())
+	assert.EqualValues(t, 1, atomic.LoadInt64(&elected[1]))
+
+	electors[1].stop()
+	assert.EqualValues(t, 1, atomic.LoadInt64(&demoted[1]))
+	conn := pool.Get()
+	defer conn.Close()
+	exists, err := conn.Do("EXISTS", key)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, exists)
+}
+
+func TestLeaderElectorLosesLease(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	key := redisKeyLeader(ns, "group")
+
+	var demoted int64
+	l := newLeaderElector(key, pool, "1", func() {}, func() { atomic.AddInt64(&demoted, 1) })
+	l.renewInterval = 10 * time.Millisecond
+	l.start()
+	assert.True(t, l.isLeading())
+
+	// Another pool took over, eg after our lease expired while Redis was unreachable
+	conn := pool.Get()
+	_, err := conn.Do("SET", key, "2")
+	conn.Close()
+	assert.NoError(t, err)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, l.isLeading())
+	assert.EqualValues(t, 1, atomic.LoadInt64(&demoted))
+
+	// Stopping doesn't release a lease we don't hold
+	l.stop()
+	conn = pool.Get()
+	defer conn.Close()
+	owner, err := conn.Do("GET", key)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), owner)
+}
+
+func TestWorkerPoolLeaderElection(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	pools := make([]*WorkerPool, 3)
+	for i := range pools {
+		pools[i] = NewWorkerPoolWithOptions(TestContext{}, 1, ns, pool, WorkerPoolOptions{LeaderElection: true})
+		pools[i].Job("wat", func(job *Job) error { return nil })
+	}
+	// Pools with different job types elect their own leader
+	pools[2].Job("other", func(job *Job) error { return nil })
+
+	for _, wp := range pools {
+		wp.Start()
+	}
+	assert.True(t, pools[0].IsLeader())
+	assert.False(t, pools[1].IsLeader())
+	assert.True(t, pools[2].IsLeader())
+
+	for _, wp := range pools {
+		wp.Stop()
+	}
+	assert.False(t, pools[0].IsLeader())
+
+	// Without leader election, every started pool leads
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	assert.False(t, wp.IsLeader())
+	wp.Start()
+	assert.True(t, wp.IsLeader())
+	wp.Stop()
+}
+
+func TestWorkerPoolIsLeaderWhileStopping(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	wp := NewWorkerPoolWithOptions(TestContext{}, 1, ns, pool, WorkerPoolOptions{LeaderElection: true})
+	wp.Job("wat", func(job *Job) error { return nil })
+	wp.Start()
+
+	// Eg a health check asking from another goroutine; run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			wp.IsLeader()
+		}
+	}()
+	wp.Stop()
+	<-done
+	assert.False(t, wp.IsLeader())
+}
@@ -1,7 +1,250 @@
 package work
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorHook is called with each error encountered by a worker pool's background processes (fetching and
+// acknowledging jobs, heartbeats, requeuers, etc), in addition to the error being logged. key identifies the
+// operation that failed, eg "worker.fetch". Panics in job handlers are reported too, as "runJob.panic".
+type ErrorHook func(key string, err error)
+
+// LogLevel is how severe a log message is.
+type LogLevel int
+
+// Log levels, from least to most severe.
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarn:
+		return "WARN"
+	case LogError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// Logger receives the package's log messages. msg identifies what happened, eg "worker.fetch" for an error fetching
+// jobs, and keyvals are alternating keys and values with the details, eg "error", err. Loggers are called from many
+// goroutines at once.
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// LoggerFunc adapts a function to a Logger.
+type LoggerFunc func(level LogLevel, msg string, keyvals ...interface{})
+
+// Log calls f.
+func (f LoggerFunc) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	f(level, msg, keyvals...)
+}
+
+// NewWriterLogger returns a Logger that writes a line to w for each message at minLevel or above, eg
+// "ERROR: worker.fetch error=EOF".
+func NewWriterLogger(w io.Writer, minLevel LogLevel) Logger {
+	var mtx sync.Mutex
+	return LoggerFunc(func(level LogLevel, msg string, keyvals ...interface{}) {
+		if level < minLevel {
+			return
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s: %s", level, msg)
+		for i := 0; i < len(keyvals); i += 2 {
+			var v interface{} = "(missing)"
+			if i+1 < len(keyvals) {
+				v = keyvals[i+1]
+			}
+			fmt.Fprintf(&b, " %v=%v", keyvals[i], v)
+		}
+		b.WriteByte('\n')
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		io.WriteString(w, b.String())
+	})
+}
+
+var logger atomic.Value // of loggerBox
+
+// loggerBox lets atomic.Value hold Loggers of different types.
+type loggerBox struct {
+	Logger
+}
+
+func init() {
+	SetLogger(defaultLogger())
+}
+
+// SetLogger sends the package's log messages to l instead of slog's default logger, or on Go versions before 1.21,
+// standard error. Worker pools with a WorkerPoolOptions.Logger, and Maintainers with a MaintainerOptions.Logger, log
+// to theirs instead.
+func SetLogger(l Logger) {
+	logger.Store(loggerBox{l})
+}
+
+// loggerOr returns l, or the package's logger if l is nil.
+func loggerOr(l Logger) Logger {
+	if l == nil {
+		return logger.Load().(loggerBox).Logger
+	}
+	return l
+}
 
 func logError(key string, err error) {
-	fmt.Printf("ERROR: %s - %s\n", key, err.Error())
+	loggerOr(nil).Log(LogError, key, "error", err)
+}
+
+// reportError logs err to l, or the package's logger if l is nil, and then hands it to hook, if one is set.
+func reportError(l Logger, hook ErrorHook, key string, err error) {
+	loggerOr(l).Log(LogError, key, "error", err)
+	if hook != nil {
+		hook(key, err)
+	}
+}
+
+// SuppressedErrors is reported to the log and ErrorHook in place of the errors WorkerPoolOptions.ErrorsPerMinute held
+// back. It's reported under the same key as the errors, once the minute they occurred in is up.
+type SuppressedErrors struct {
+	JobName string // of the jobs the errors were about, if any
+	Count   int64
+	Last    error
+}
+
+func (e *SuppressedErrors) Error() string {
+	if e.JobName != "" {
+		return fmt.Sprintf("%d more errors for %s jobs in the last minute, the last: %v", e.Count, e.JobName, e.Last)
+	}
+	return fmt.Sprintf("%d more errors in the last minute, the last: %v", e.Count, e.Last)
+}
+
+// errorReporter reports a worker pool's errors like reportError, but with a perMinute cap on how many it reports of
+// each key and job type, so that a flood of them doesn't flood the logs too. The rest are counted and reported as
+// SuppressedErrors when the minute is up. With no cap, it's just reportError.
+type errorReporter struct {
+	namespace string
+	hook      ErrorHook
+	logger    Logger // nil for the package's
+	perMinute uint
+
+	windowEnd int64 // unix nanoseconds, so flush can tell without the lock whether there's anything to do
+
+	mtx    sync.Mutex
+	counts map[errorSource]*errorCount
+}
+
+type errorSource struct {
+	key     string
+	jobName string
+}
+
+type errorCount struct {
+	reported   uint
+	suppressed int64
+	last       error
+}
+
+func newErrorReporter(namespace string, hook ErrorHook, logger Logger, perMinute uint) *errorReporter {
+	return &errorReporter{namespace: namespace, hook: hook, logger: logger, perMinute: perMinute, counts: make(map[errorSource]*errorCount)}
+}
+
+// report reports err, which happened doing key for a job named jobName, or "" if it's not about a job.
+func (r *errorReporter) report(key, jobName string, err error) {
+	r.reportFor(key, jobName, nil, err)
+}
+
+// reportJob is report for errors about a particular job, which are logged with its ID and attempt too.
+func (r *errorReporter) reportJob(key string, job *Job, err error) {
+	r.reportFor(key, job.Name, job, err)
+}
+
+func (r *errorReporter) reportFor(key, jobName string, job *Job, err error) {
+	if r == nil {
+		logError(key, err)
+		return
+	}
+	if r.perMinute == 0 {
+		r.reportNow(key, jobName, job, err)
+		return
+	}
+	r.flush(time.Now(), false)
+
+	r.mtx.Lock()
+	src := errorSource{key: key, jobName: jobName}
+	c := r.counts[src]
+	if c == nil {
+		c = &errorCount{}
+		r.counts[src] = c
+	}
+	held := c.reported >= r.perMinute
+	if held {
+		c.suppressed++
+		c.last = err
+	} else {
+		c.reported++
+	}
+	r.mtx.Unlock()
+
+	if !held {
+		r.reportNow(key, jobName, job, err)
+	}
+}
+
+// reportNow is reportError, with the pool's namespace, the job type, if any, and the job's ID and attempt, if it's
+// about one, logged alongside err.
+func (r *errorReporter) reportNow(key, jobName string, job *Job, err error) {
+	keyvals := []interface{}{"error", err, "namespace", r.namespace}
+	if jobName != "" {
+		keyvals = append(keyvals, "job", jobName)
+	}
+	if job != nil {
+		attempt := job.attempt
+		if attempt == 0 {
+			attempt = job.Fails + 1
+		}
+		keyvals = append(keyvals, "id", job.ID, "attempt", attempt)
+	}
+	loggerOr(r.logger).Log(LogError, key, keyvals...)
+	if r.hook != nil {
+		r.hook(key, err)
+	}
+}
+
+// flush starts a new minute if the current one is up, reporting the errors that were held back in it. If all is set,
+// it does so regardless, eg since the pool is stopping.
+func (r *errorReporter) flush(now time.Time, all bool) {
+	if r == nil || r.perMinute == 0 || (!all && now.UnixNano() < atomic.LoadInt64(&r.windowEnd)) {
+		return
+	}
+
+	r.mtx.Lock()
+	if !all && now.UnixNano() < r.windowEnd {
+		// Another goroutine got here first
+		r.mtx.Unlock()
+		return
+	}
+	counts := r.counts
+	r.counts = make(map[errorSource]*errorCount)
+	atomic.StoreInt64(&r.windowEnd, now.Add(time.Minute).UnixNano())
+	r.mtx.Unlock()
+
+	for src, c := range counts {
+		if c.suppressed > 0 {
+			r.reportNow(src.key, src.jobName, nil, &SuppressedErrors{JobName: src.jobName, Count: c.suppressed, Last: c.last})
+		}
+	}
 }
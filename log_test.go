@@ -0,0 +1,119 @@
+package work
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorReporter(t *testing.T) {
+	var reported []string
+	hook := func(key string, err error) { reported = append(reported, key+": "+err.Error()) }
+
+	r := newErrorReporter("work", hook, nil, 2)
+	for i := 1; i <= 4; i++ {
+		r.report("runJob.panic", "wat", fmt.Errorf("wat %d", i))
+	}
+	r.report("runJob.panic", "bob", fmt.Errorf("bob 1"))
+	r.report("worker.fetch", "", fmt.Errorf("fetch 1"))
+	assert.Equal(t, []string{"runJob.panic: wat 1", "runJob.panic: wat 2", "runJob.panic: bob 1", "worker.fetch: fetch 1"}, reported)
+
+	// Nothing's reported for the held back errors until the minute is up
+	reported = nil
+	r.flush(time.Now(), false)
+	assert.Empty(t, reported)
+	r.flush(time.Now().Add(time.Minute), false)
+	assert.Equal(t, []string{"runJob.panic: 2 more errors for wat jobs in the last minute, the last: wat 4"}, reported)
+
+	// And the next minute starts afresh
+	reported = nil
+	r.report("runJob.panic", "wat", fmt.Errorf("wat 5"))
+	assert.Equal(t, []string{"runJob.panic: wat 5"}, reported)
+
+	// Without a cap, everything is reported
+	reported = nil
+	r = newErrorReporter("work", hook, nil, 0)
+	for i := 0; i < 10; i++ {
+		r.report("worker.fetch", "", fmt.Errorf("fetch"))
+	}
+	assert.Len(t, reported, 10)
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWriterLogger(&buf, LogWarn)
+	l.Log(LogInfo, "ignored")
+	l.Log(LogWarn, "worker.slow", "job", "wat", "took")
+	assert.Equal(t, "WARN: worker.slow job=wat took=(missing)\n", buf.String())
+
+	var logged []string
+	SetLogger(LoggerFunc(func(level LogLevel, msg string, keyvals ...interface{}) {
+		logged = append(logged, fmt.Sprint(level, " ", msg, keyvals))
+	}))
+	defer SetLogger(defaultLogger())
+	reportError(nil, nil, "worker.fetch", fmt.Errorf("EOF"))
+	assert.Equal(t, []string{"ERROR worker.fetch[error EOF]"}, logged)
+
+	// A pool's errors carry its namespace, and the job type they're about
+	logged = nil
+	r := newErrorReporter("work", nil, nil, 0)
+	r.report("runJob.panic", "wat", fmt.Errorf("dang"))
+	r.report("worker.fetch", "", fmt.Errorf("EOF"))
+	assert.Equal(t, []string{"ERROR runJob.panic[error dang namespace work job wat]", "ERROR worker.fetch[error EOF namespace work]"}, logged)
+
+	// and the job's ID and attempt, if they're about one
+	logged = nil
+	r.reportJob("runJob.panic", &Job{Name: "wat", ID: "abc", Fails: 2}, fmt.Errorf("dang"))
+	r.reportJob("worker.terminate_and_retry.serialize", &Job{Name: "wat", ID: "abc", Fails: 3, attempt: 3}, fmt.Errorf("dang"))
+	assert.Equal(t, []string{
+		"ERROR runJob.panic[error dang namespace work job wat id abc attempt 3]",
+		"ERROR worker.terminate_and_retry.serialize[error dang namespace work job wat id abc attempt 3]",
+	}, logged)
+
+	// A pool's own Logger gets its errors instead
+	logged = nil
+	buf.Reset()
+	reportError(NewWriterLogger(&buf, LogDebug), nil, "heartbeat", fmt.Errorf("EOF"))
+	r = newErrorReporter("work", nil, NewWriterLogger(&buf, LogDebug), 0)
+	r.report("worker.fetch", "", fmt.Errorf("EOF"))
+	assert.Empty(t, logged)
+	assert.Equal(t, "ERROR: heartbeat error=EOF\nERROR: worker.fetch error=EOF namespace=work\n", buf.String())
+}
+
+func TestWorkerPoolErrorsPerMinute(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	var mtx sync.Mutex
+	var reported []error
+	hook := func(key string, err error) {
+		if key == "runJob.panic" {
+			mtx.Lock()
+			reported = append(reported, err)
+			mtx.Unlock()
+		}
+	}
+	wp := NewWorkerPoolWithOptions(TestContext{}, 2, ns, pool, WorkerPoolOptions{ErrorHook: hook, ErrorsPerMinute: 1})
+	wp.Job("wat", func(job *Job) error { panic("dang") }, MaxFails(1), SkipDead())
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 5; i++ {
+		_, err := enqueuer.Enqueue("wat", nil)
+		assert.NoError(t, err)
+	}
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	// The first panic, and then how many more there were once the pool stops
+	if assert.Len(t, reported, 2) {
+		assert.Equal(t, "dang", reported[0].Error())
+		assert.Equal(t, &SuppressedErrors{JobName: "wat", Count: 4, Last: reported[1].(*SuppressedErrors).Last}, reported[1])
+		assert.EqualError(t, reported[1].(*SuppressedErrors).Last, "dang")
+	}
+}
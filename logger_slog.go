@@ -0,0 +1,37 @@
+//go:build go1.21
+// +build go1.21
+
+package work
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a Logger that logs to l, at the slog level matching each message's, with its keyvals as
+// attributes.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return LoggerFunc(func(level LogLevel, msg string, keyvals ...interface{}) {
+		l.Log(context.Background(), slogLevel(level), msg, keyvals...)
+	})
+}
+
+// defaultLogger logs to slog's default logger, looked up for each message so that a later slog.SetDefault applies
+// too. Unless the program sets it, that's standard error, from info level up.
+func defaultLogger() Logger {
+	return LoggerFunc(func(level LogLevel, msg string, keyvals ...interface{}) {
+		slog.Default().Log(context.Background(), slogLevel(level), msg, keyvals...)
+	})
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogDebug:
+		return slog.LevelDebug
+	case LogInfo:
+		return slog.LevelInfo
+	case LogWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
@@ -0,0 +1,30 @@
+//go:build go1.21
+// +build go1.21
+
+package work
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := NewSlogLogger(slog.New(handler))
+	l.Log(LogInfo, "ignored")
+	l.Log(LogWarn, "worker.slow", "job", "wat")
+	l.Log(LogError, "worker.fetch", "error", "EOF", "namespace", "work")
+	assert.Equal(t, "level=WARN msg=worker.slow job=wat\nlevel=ERROR msg=worker.fetch error=EOF namespace=work\n", buf.String())
+}
@@ -0,0 +1,11 @@
+//go:build !go1.21
+// +build !go1.21
+
+package work
+
+import "os"
+
+// defaultLogger logs to standard error, from info level up. Go 1.21 and later log to slog's default logger instead.
+func defaultLogger() Logger {
+	return NewWriterLogger(os.Stderr, LogInfo)
+}
@@ -0,0 +1,262 @@
+package work
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// maintenance bundles the background components that keep a namespace healthy but don't process jobs: requeuers for
+// the retry and scheduled queues, the dead pool reaper and the periodic enqueuer. Each start creates them afresh, so a
+// maintenance can be started again after it's stopped, eg when leadership comes back.
+type maintenance struct {
+	namespace    string
+	pool         *redis.Pool
+	jobNames     []string
+	periodicJobs []*periodicJob
+	redisTimeout time.Duration
+	errorHook    ErrorHook
+	logger       Logger
+	gates        map[string]Gate
+	alerts       []*alertState
+
+	// If set, the requeuers read the known job types from Redis as they go, rather than sticking to jobNames
+	readKnownJobs bool
+
+	retrier          *requeuer
+	scheduler        *requeuer
+	deadPoolReaper   *deadPoolReaper
+	periodicEnqueuer *periodicEnqueuer
+	queueMonitor     *queueMonitor
+}
+
+func newMaintenance(namespace string, pool *redis.Pool, jobNames []string, periodicJobs []*periodicJob) *maintenance {
+	return &maintenance{
+		namespace:    namespace,
+		pool:         pool,
+		jobNames:     jobNames,
+		periodicJobs: periodicJobs,
+	}
+}
+
+func (m *maintenance) start() {
+	m.retrier = newRequeuer(m.namespace, m.pool, redisKeyRetry(m.namespace), m.jobNames)
+	m.retrier.ownQueueKey = redisKeyRetryOf
+	m.retrier.expressKey = redisKeyRetryExpress(m.namespace)
+	m.scheduler = newRequeuer(m.namespace, m.pool, redisKeyScheduled(m.namespace), m.jobNames)
+	for _, r := range []*requeuer{m.retrier, m.scheduler} {
+		r.redisTimeout, r.errorHook, r.logger, r.gates = m.redisTimeout, m.errorHook, m.logger, m.gates
+		r.readKnownJobs = m.readKnownJobs
+	}
+	m.deadPoolReaper = newDeadPoolReaper(m.namespace, m.pool, m.jobNames)
+	m.deadPoolReaper.redisTimeout, m.deadPoolReaper.errorHook, m.deadPoolReaper.logger = m.redisTimeout, m.errorHook, m.logger
+	m.periodicEnqueuer = newPeriodicEnqueuer(m.namespace, m.pool, m.periodicJobs)
+	m.periodicEnqueuer.redisTimeout, m.periodicEnqueuer.errorHook, m.periodicEnqueuer.logger = m.redisTimeout, m.errorHook, m.logger
+
+	m.retrier.start()
+	m.scheduler.start()
+	m.deadPoolReaper.start()
+	m.periodicEnqueuer.start()
+	if len(m.alerts) > 0 {
+		m.queueMonitor = newQueueMonitor(m.namespace, m.pool, m.alerts)
+		m.queueMonitor.redisTimeout, m.queueMonitor.errorHook, m.queueMonitor.logger = m.redisTimeout, m.errorHook, m.logger
+		m.queueMonitor.start()
+	}
+}
+
+func (m *maintenance) stop() {
+	m.retrier.stop()
+	m.scheduler.stop()
+	m.deadPoolReaper.stop()
+	m.periodicEnqueuer.stop()
+	if m.queueMonitor != nil {
+		m.queueMonitor.stop()
+		m.queueMonitor = nil
+	}
+}
+
+// Maintainer runs the background processes that a WorkerPool normally runs alongside its workers: requeuers that move
+// due jobs from the retry and scheduled queues back onto their job queues, the reaper that requeues jobs left in
+// progress by dead pools, and the periodic enqueuer. It's for deployments that want these in a dedicated process;
+// start the job processing pools with WorkerPoolOptions.SkipMaintenance so they don't also run them.
+type Maintainer struct {
+	maintainerID   string
+	namespace      string
+	pool           *redis.Pool
+	jobNames       []string
+	periodicJobs   []*periodicJob
+	redisTimeout   time.Duration
+	errorHook      ErrorHook
+	logger         Logger
+	leaderElection bool
+	gates          map[string]Gate
+	alerts         []*alertState
+
+	mtx           sync.Mutex
+	started       bool
+	maintenance   *maintenance
+	leaderElector *leaderElector
+}
+
+// MaintainerOptions can be passed to NewMaintainerWithOptions.
+type MaintainerOptions struct {
+	RedisTimeout   time.Duration // If set, bounds each internal Redis command. Default is no timeout.
+	ErrorHook      ErrorHook     // If set, called with every error encountered while requeueing, reaping, etc.
+	Logger         Logger        // If set, errors are logged to it rather than the package's logger, see SetLogger.
+	LeaderElection bool          // If true, of all the maintainers and pools with the same job types, only the elected leader does any work. See WorkerPoolOptions.LeaderElection.
+}
+
+// NewMaintainer creates a Maintainer for the given namespace. jobNames are the job types the namespace's worker pools
+// handle: jobs of other types in the retry and scheduled queues are moved to the dead queue, as a WorkerPool would.
+// If jobNames is nil, the job types that have ever been registered or enqueued in the namespace are read from Redis
+// instead, as the Maintainer goes, so job types deployed after it started aren't taken for unknown ones.
+func NewMaintainer(namespace string, pool *redis.Pool, jobNames []string) *Maintainer {
+	return NewMaintainerWithOptions(namespace, pool, jobNames, MaintainerOptions{})
+}
+
+// NewMaintainerWithOptions creates a Maintainer as per NewMaintainer with the given options.
+func NewMaintainerWithOptions(namespace string, pool *redis.Pool, jobNames []string, opts MaintainerOptions) *Maintainer {
+	if pool == nil {
+		panic("NewMaintainer needs a non-nil *redis.Pool")
+	}
+
+	return &Maintainer{
+		maintainerID:   makeIdentifier(),
+		namespace:      namespace,
+		pool:           pool,
+		jobNames:       jobNames,
+		redisTimeout:   opts.RedisTimeout,
+		errorHook:      opts.ErrorHook,
+		logger:         opts.Logger,
+		leaderElection: opts.LeaderElection,
+	}
+}
+
+// PeriodicallyEnqueue will periodically enqueue jobName according to the cron-based spec. See
+// WorkerPool.PeriodicallyEnqueue.
+func (m *Maintainer) PeriodicallyEnqueue(spec string, jobName string, opts ...PeriodicOption) *Maintainer {
+	m.periodicJobs = append(m.periodicJobs, newPeriodicJob(spec, jobName, opts...))
+	return m
+}
+
+// PeriodicallyEnqueueWithArgs is PeriodicallyEnqueue for jobs that take arguments. See
+// WorkerPool.PeriodicallyEnqueueWithArgs.
+func (m *Maintainer) PeriodicallyEnqueueWithArgs(spec string, jobName string, args PeriodicArgs, opts ...PeriodicOption) *Maintainer {
+	pj := newPeriodicJob(spec, jobName, opts...)
+	pj.args = args
+	m.periodicJobs = append(m.periodicJobs, pj)
+	return m
+}
+
+// PeriodicallyEnqueueEvery will periodically enqueue jobName every interval. See
+// WorkerPool.PeriodicallyEnqueueEvery.
+func (m *Maintainer) PeriodicallyEnqueueEvery(interval time.Duration, jobName string, opts ...PeriodicOption) *Maintainer {
+	m.periodicJobs = append(m.periodicJobs, newIntervalPeriodicJob(interval, jobName, opts...))
+	return m
+}
+
+// Gate holds back the scheduled and retried jobs of jobName while gate is closed, like JobOptions.Gate does for
+// worker pools. Call it before Start.
+func (m *Maintainer) Gate(jobName string, gate Gate) *Maintainer {
+	if m.gates == nil {
+		m.gates = make(map[string]Gate)
+	}
+	m.gates[jobName] = gate
+	return m
+}
+
+// Alert calls hook when jobName's queue crosses the alert's threshold, and again when it's back under. See
+// WorkerPool.Alert. Call it before Start.
+func (m *Maintainer) Alert(alert Alert, hook AlertHook) *Maintainer {
+	if err := alert.validate(); err != nil {
+		panic(err.Error())
+	}
+	m.alerts = append(m.alerts, &alertState{Alert: alert, hook: hook})
+	return m
+}
+
+// Start starts the background processes. It returns an error if jobNames were to be read from Redis and couldn't be.
+func (m *Maintainer) Start() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.started {
+		return nil
+	}
+
+	jobNames := m.jobNames
+	if jobNames == nil {
+		var err error
+		if jobNames, err = m.knownJobs(); err != nil {
+			return err
+		}
+	}
+
+	m.maintenance = newMaintenance(m.namespace, m.pool, jobNames, m.periodicJobs)
+	m.maintenance.redisTimeout, m.maintenance.errorHook, m.maintenance.logger = m.redisTimeout, m.errorHook, m.logger
+	m.maintenance.gates, m.maintenance.alerts = m.gates, m.alerts
+	m.maintenance.readKnownJobs = m.jobNames == nil
+	if m.leaderElection {
+		m.leaderElector = newLeaderElector(redisKeyLeader(m.namespace, leaderGroup(jobNames)), m.pool, m.maintainerID, m.maintenance.start, m.maintenance.stop)
+		m.leaderElector.redisTimeout, m.leaderElector.errorHook, m.leaderElector.logger = m.redisTimeout, m.errorHook, m.logger
+		m.leaderElector.start()
+	} else {
+		m.maintenance.start()
+	}
+	m.started = true
+
+	return nil
+}
+
+// Stop stops the background processes.
+func (m *Maintainer) Stop() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if !m.started {
+		return
+	}
+	m.started = false
+
+	if m.leaderElector != nil {
+		m.leaderElector.stop()
+		m.leaderElector = nil
+	} else {
+		m.maintenance.stop()
+	}
+	m.maintenance = nil
+}
+
+// Drain requeues every job that's due in the retry and scheduled queues before returning. It does nothing unless the
+// Maintainer is started and, with leader election, is the leader.
+func (m *Maintainer) Drain() {
+	if !m.IsLeader() {
+		return
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.maintenance.retrier.drain()
+	m.maintenance.scheduler.drain()
+}
+
+// IsLeader returns whether the Maintainer is doing any work. That's always the case for a started Maintainer unless
+// MaintainerOptions.LeaderElection is set.
+func (m *Maintainer) IsLeader() bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.leaderElector != nil {
+		return m.leaderElector.isLeading()
+	}
+	return m.started
+}
+
+func (m *Maintainer) knownJobs() ([]string, error) {
+	conn := getConn(m.pool, m.redisTimeout)
+	defer conn.Close()
+
+	jobNames, err := redis.Strings(conn.Do("SMEMBERS", redisKeyKnownJobs(m.namespace)))
+	if err != nil {
+		reportError(m.logger, m.errorHook, "maintainer.known_jobs", err)
+		return nil, err
+	}
+	return jobNames, nil
+}
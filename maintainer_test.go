@@ -0,0 +1,89 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintainer(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.EnqueueIn(job1, -1, Q{"a": 1})
+	assert.NoError(t, err)
+
+	// A job type no pool handles goes to the dead queue
+	conn := pool.Get()
+	_, err = conn.Do("ZADD", redisKeyRetry(ns), nowEpochSeconds()-1, `{"name":"unknown","id":"1","t":1,"args":{}}`)
+	conn.Close()
+	assert.NoError(t, err)
+
+	// Job names are read from Redis; EnqueueIn made job1 known
+	m := NewMaintainer(ns, pool, nil)
+	assert.False(t, m.IsLeader())
+	assert.NoError(t, m.Start())
+	assert.True(t, m.IsLeader())
+	m.Drain()
+
+	// And kept up to date, so a job type first enqueued after Start isn't taken for an unknown one
+	_, err = enqueuer.EnqueueIn("job2", -1, Q{"b": 2})
+	assert.NoError(t, err)
+	m.Drain()
+	m.Stop()
+	assert.False(t, m.IsLeader())
+
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, job1)))
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "job2")))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyScheduled(ns)))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyRetry(ns)))
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyDead(ns)))
+}
+
+func TestMaintainerLeaderElection(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	opts := MaintainerOptions{LeaderElection: true}
+	m1 := NewMaintainerWithOptions(ns, pool, []string{"a", "b"}, opts)
+	m2 := NewMaintainerWithOptions(ns, pool, []string{"b", "a"}, opts)
+	assert.NoError(t, m1.Start())
+	assert.NoError(t, m2.Start())
+	assert.True(t, m1.IsLeader())
+	assert.False(t, m2.IsLeader())
+
+	// A pool with the same job types competes with them
+	wp := NewWorkerPoolWithOptions(TestContext{}, 1, ns, pool, WorkerPoolOptions{LeaderElection: true})
+	wp.Job("a", func(job *Job) error { return nil })
+	wp.Job("b", func(job *Job) error { return nil })
+	wp.Start()
+	assert.False(t, wp.IsLeader())
+
+	wp.Stop()
+	m2.Stop()
+	m1.Stop()
+}
+
+func TestWorkerPoolSkipMaintenance(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	_, err := NewEnqueuer(ns, pool).EnqueueIn(job1, -1, nil)
+	assert.NoError(t, err)
+
+	wp := NewWorkerPoolWithOptions(TestContext{}, 1, ns, pool, WorkerPoolOptions{SkipMaintenance: true})
+	wp.Job(job1, func(job *Job) error { return nil })
+	wp.Start()
+	assert.False(t, wp.IsLeader())
+	wp.Drain()
+	wp.Stop()
+
+	// Nothing moved the scheduled job onto its queue
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyScheduled(ns)))
+}
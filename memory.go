@@ -0,0 +1,104 @@
+package work
+
+import (
+	"sort"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// memoryUsageSamples is how many entries of a queue MEMORY USAGE looks at to estimate the size of the rest.
+const memoryUsageSamples = 100
+
+// MemoryUsage is an estimate of how much Redis memory a namespace uses, in bytes, see Client.MemoryUsage.
+type MemoryUsage struct {
+	Queues    map[string]int64 `json:"queues"`    // each job type's queue, by job name
+	Scheduled int64            `json:"scheduled"` // the scheduled queue
+	Retry     int64            `json:"retry"`     // the retry queues, express and of job types with OwnFailureQueues, included
+	Dead      int64            `json:"dead"`      // the dead queue and those of job types with OwnFailureQueues
+	Total     int64            `json:"total"`     // all of the above and the namespace's other fixed keys, see KeysForNamespace
+}
+
+// QueueMemoryUsage returns an estimate of the Redis memory the jobs waiting in jobName's queue use, in bytes, eg to
+// see which job types a Redis needs to be sized for. It's based on MEMORY USAGE, which samples the queue's entries
+// rather than reading them all, so it's cheap even for long queues.
+func (c *Client) QueueMemoryUsage(jobName string) (int64, error) {
+	conn := c.readConn()
+	defer conn.Close()
+
+	usage, err := memoryUsage(conn, []string{redisKeyJobs(c.namespace, jobName)})
+	if err != nil {
+		logError("client.queue_memory_usage", err)
+		return 0, err
+	}
+	return usage[0], nil
+}
+
+// MemoryUsage returns an estimate of the Redis memory the namespace uses, in bytes, broken down by queue. Like
+// QueueMemoryUsage, it samples the queues. The keys named after pools, workers and jobs, eg heartbeats and in-progress
+// queues, aren't counted, since finding them would take a scan of the whole keyspace.
+func (c *Client) MemoryUsage() (*MemoryUsage, error) {
+	conn := c.readConn()
+	defer conn.Close()
+
+	jobNames, err := redis.Strings(conn.Do("SMEMBERS", redisKeyKnownJobs(c.namespace)))
+	if err != nil {
+		logError("client.memory_usage.known_jobs", err)
+		return nil, err
+	}
+	sort.Strings(jobNames)
+	ownFailureQueues, err := redis.Strings(conn.Do("SMEMBERS", redisKeyOwnFailureQueues(c.namespace)))
+	if err != nil {
+		logError("client.memory_usage.own_failure_queues", err)
+		return nil, err
+	}
+
+	// KeysForNamespace lists each job type's queue among its keys, and the scheduled, retry and dead queues, but not
+	// the failure queues of job types whose own they are
+	keys := KeysForNamespace(c.namespace, jobNames).Keys
+	for _, jobName := range ownFailureQueues {
+		keys = append(keys, redisKeyRetryOf(c.namespace, jobName), redisKeyDeadOf(c.namespace, jobName))
+	}
+	usage, err := memoryUsage(conn, keys)
+	if err != nil {
+		logError("client.memory_usage", err)
+		return nil, err
+	}
+
+	byKey := make(map[string]int64, len(keys))
+	m := &MemoryUsage{Queues: make(map[string]int64, len(jobNames))}
+	for i, key := range keys {
+		byKey[key] = usage[i]
+		m.Total += usage[i]
+	}
+	for _, jobName := range jobNames {
+		m.Queues[jobName] = byKey[redisKeyJobs(c.namespace, jobName)]
+	}
+	m.Scheduled = byKey[redisKeyScheduled(c.namespace)]
+	m.Retry = byKey[redisKeyRetry(c.namespace)] + byKey[redisKeyRetryExpress(c.namespace)]
+	m.Dead = byKey[redisKeyDead(c.namespace)]
+	for _, jobName := range ownFailureQueues {
+		m.Retry += byKey[redisKeyRetryOf(c.namespace, jobName)]
+		m.Dead += byKey[redisKeyDeadOf(c.namespace, jobName)]
+	}
+	return m, nil
+}
+
+// memoryUsage returns the MEMORY USAGE of each of keys, 0 for those that don't exist.
+func memoryUsage(conn redis.Conn, keys []string) ([]int64, error) {
+	for _, key := range keys {
+		conn.Send("MEMORY", "USAGE", key, "SAMPLES", memoryUsageSamples)
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	usage := make([]int64, len(keys))
+	for i := range keys {
+		bytes, err := redis.Int64(conn.Receive())
+		if err != nil && err != redis.ErrNil {
+			return nil, err
+		}
+		usage[i] = bytes
+	}
+	return usage, nil
+}
@@ -0,0 +1,43 @@
+package work
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientMemoryUsage(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	client := NewClient(ns, pool)
+	usage, err := client.QueueMemoryUsage("wat")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, usage)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 50; i++ {
+		_, err := enqueuer.Enqueue("wat", Q{"body": strings.Repeat("x", 1000)})
+		assert.NoError(t, err)
+	}
+	_, err = enqueuer.Enqueue("ugh", nil)
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueIn("ugh", 300, nil)
+	assert.NoError(t, err)
+	insertDeadJob(ns, pool, "ugh", 12345, 12347)
+
+	wat, err := client.QueueMemoryUsage("wat")
+	assert.NoError(t, err)
+	assert.True(t, wat > 50*1000, "wat uses %d bytes", wat)
+
+	m, err := client.MemoryUsage()
+	assert.NoError(t, err)
+	assert.Equal(t, wat, m.Queues["wat"])
+	assert.True(t, m.Queues["ugh"] > 0 && m.Queues["ugh"] < wat)
+	assert.True(t, m.Scheduled > 0)
+	assert.True(t, m.Dead > 0)
+	assert.EqualValues(t, 0, m.Retry)
+	assert.True(t, m.Total > m.Queues["wat"]+m.Queues["ugh"]+m.Scheduled+m.Dead)
+}
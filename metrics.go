@@ -0,0 +1,33 @@
+package work
+
+import "time"
+
+// MetricsSink receives metrics about the jobs a worker pool fetches and runs, as they happen, for a metrics system
+// such as StatsD; see contrib/workstatsd. Set it with WorkerPoolOptions.Metrics. It's called from the pool's workers,
+// so it must be safe for concurrent use and shouldn't block.
+//
+// Metrics about a job are tagged "job:<name>". These are sent:
+//
+//	work.job.fetched     count   a job was fetched from its queue, not counting the rest of a batch
+//	work.job.duration    timing  how long a job took in middleware and its handler
+//	work.job.succeeded   count   a job ran successfully
+//	work.job.failed      count   a job failed, or had no handler
+//	work.job.retried     count   a failed job was sent to the retry queue
+//	work.job.dead        count   a failed job was sent to the dead queue
+//	work.job.discarded   count   a failed job was dropped
+//	work.job.shed        count   a job was dropped without running, since its type was shed
+//	work.fetch.error     count   fetching jobs failed, untagged
+type MetricsSink interface {
+	Count(name string, value int64, tags ...string)
+	Timing(name string, d time.Duration, tags ...string)
+}
+
+func jobTag(jobName string) string {
+	return "job:" + jobName
+}
+
+func (s *poolStats) count(name string, tags ...string) {
+	if s.sink != nil {
+		s.sink.Count(name, 1, tags...)
+	}
+}
@@ -0,0 +1,266 @@
+package work
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// migrateChunkSize is how many jobs MigrateNamespace moves at a time.
+const migrateChunkSize = 1000
+
+// MigrateNamespace moves every queued job of c's namespace into the namespace of to, which may be on another Redis,
+// eg to rename an app or to consolidate Redis instances. The unique keys of unique jobs are moved first, then the job
+// queues, the scheduled queue, and the retry and dead queues, one at a time, a chunk of jobs at a time, so producers
+// and workers can be switched over to the new namespace while it runs: start workers on the new namespace, switch the
+// producers over, stop the old workers, and call MigrateNamespace again to pick up whatever was enqueued or requeued
+// in the meantime. Jobs that are in progress aren't moved; they're back on the old queues once their pool's workers
+// stop or the pool is reaped. Moved unique jobs point at their moved unique keys. The number of jobs moved is
+// returned.
+//
+// No job is lost if MigrateNamespace fails partway, eg if either Redis goes down: each chunk of jobs is taken off its
+// queue in one step and held in a marker in the old namespace until it's on the new one, and a later call moves those
+// first. Requeuers can't move a job while it's held. A job from a job queue may be moved twice, though, if the call
+// fails after pushing the held jobs onto the new queue but before letting go of them.
+func (c *Client) MigrateNamespace(to *Client) (int64, error) {
+	if c.pool == to.pool && redisNamespacePrefix(c.namespace) == redisNamespacePrefix(to.namespace) {
+		return 0, fmt.Errorf("work: can't migrate namespace %q into itself", c.namespace)
+	}
+
+	conn := c.pool.Get()
+	defer conn.Close()
+	toConn := to.pool.Get()
+	defer toConn.Close()
+
+	jobNames, err := redis.Strings(conn.Do("SMEMBERS", redisKeyKnownJobs(c.namespace)))
+	if err != nil {
+		logError("client.migrate_namespace.known_jobs", err)
+		return 0, err
+	}
+	sort.Strings(jobNames)
+	ownFailureQueues, err := redis.Strings(conn.Do("SMEMBERS", redisKeyOwnFailureQueues(c.namespace)))
+	if err != nil {
+		logError("client.migrate_namespace.own_failure_queues", err)
+		return 0, err
+	}
+	sort.Strings(ownFailureQueues)
+
+	// The new namespace must know the job types before their jobs arrive, or its requeuer sends them to the dead queue
+	toConn.Send("MULTI")
+	for _, jobName := range jobNames {
+		toConn.Send("SADD", redisKeyKnownJobs(to.namespace), jobName)
+	}
+	for _, jobName := range ownFailureQueues {
+		toConn.Send("SADD", redisKeyOwnFailureQueues(to.namespace), jobName)
+	}
+	if _, err := toConn.Do("EXEC"); err != nil {
+		logError("client.migrate_namespace.sadd", err)
+		return 0, err
+	}
+
+	// Unique keys go before their jobs, so the jobs' workers in the new namespace find them
+	if err := migrateUniqueKeys(conn, toConn, c.namespace, to.namespace); err != nil {
+		logError("client.migrate_namespace.unique_keys", err)
+		return 0, err
+	}
+
+	m := &namespaceMigration{conn: conn, toConn: toConn, from: c.namespace, to: to.namespace}
+	var count int64
+	for _, jobName := range jobNames {
+		n, err := m.migrateList(redisKeyJobs(c.namespace, jobName), redisKeyMigrating(c.namespace, jobName), redisKeyJobs(to.namespace, jobName))
+		count += n
+		if err != nil {
+			logError("client.migrate_namespace.jobs", err)
+			return count, err
+		}
+	}
+
+	zsets := [][2]string{
+		{redisKeyScheduled(c.namespace), redisKeyScheduled(to.namespace)},
+		{redisKeyRetry(c.namespace), redisKeyRetry(to.namespace)},
+		{redisKeyRetryExpress(c.namespace), redisKeyRetryExpress(to.namespace)},
+		{redisKeyDead(c.namespace), redisKeyDead(to.namespace)},
+	}
+	for _, jobName := range ownFailureQueues {
+		zsets = append(zsets,
+			[2]string{redisKeyRetryOf(c.namespace, jobName), redisKeyRetryOf(to.namespace, jobName)},
+			[2]string{redisKeyDeadOf(c.namespace, jobName), redisKeyDeadOf(to.namespace, jobName)},
+		)
+	}
+	for _, keys := range zsets {
+		n, err := m.migrateZset(keys[0], redisKeyMigratingZset(c.namespace, keys[0]), keys[1])
+		count += n
+		if err != nil {
+			logError("client.migrate_namespace.zset", err)
+			return count, err
+		}
+	}
+
+	c.audit("migrate_namespace", map[string]interface{}{"namespace": to.namespace, "count": count})
+	return count, nil
+}
+
+// namespaceMigration moves jobs from one namespace to another, rewriting the unique keys of unique jobs.
+type namespaceMigration struct {
+	conn, toConn redis.Conn
+	from, to     string
+}
+
+// migrateList moves the jobs of the job queue from to the job queue to, oldest first, by way of the marker list
+// marker. The jobs keep their order, but are queued behind any that were already enqueued on to.
+func (m *namespaceMigration) migrateList(from, marker, to string) (int64, error) {
+	var count int64
+	for {
+		// Move the marker's jobs, which are left over from a failed call on the first round
+		rawJSONs, err := redis.ByteSlices(m.conn.Do("LRANGE", marker, 0, -1))
+		if err != nil {
+			return count, err
+		}
+		if len(rawJSONs) > 0 {
+			args := redis.Args{to}
+			for i := len(rawJSONs) - 1; i >= 0; i-- {
+				args = append(args, m.payload(rawJSONs[i]))
+			}
+			if _, err := m.toConn.Do("LPUSH", args...); err != nil {
+				return count, err
+			}
+			if _, err := m.conn.Do("DEL", marker); err != nil {
+				return count, err
+			}
+			count += int64(len(rawJSONs))
+		}
+
+		m.conn.Send("MULTI")
+		for i := 0; i < migrateChunkSize; i++ {
+			m.conn.Send("RPOPLPUSH", from, marker)
+		}
+		replies, err := redis.Values(m.conn.Do("EXEC"))
+		if err != nil {
+			return count, err
+		}
+		if replies[0] == nil {
+			return count, nil
+		}
+	}
+}
+
+// migrateZset moves the jobs of the zset from into the zset to, with their scores, by way of the marker zset marker.
+// Adding jobs to a zset twice is harmless, so unlike for lists, a failed call never moves a job twice.
+func (m *namespaceMigration) migrateZset(from, marker, to string) (int64, error) {
+	script := redis.NewScript(2, redisLuaZsetToMarker)
+	var count int64
+	for {
+		// Move the marker's jobs, which are left over from a failed call on the first round
+		values, err := redis.ByteSlices(m.conn.Do("ZRANGE", marker, 0, -1, "WITHSCORES"))
+		if err != nil {
+			return count, err
+		}
+		if len(values) > 0 {
+			args := redis.Args{to}
+			for i := 0; i < len(values); i += 2 {
+				args = append(args, values[i+1], m.payload(values[i]))
+			}
+			if _, err := m.toConn.Do("ZADD", args...); err != nil {
+				return count, err
+			}
+			if _, err := m.conn.Do("DEL", marker); err != nil {
+				return count, err
+			}
+			count += int64(len(values) / 2)
+		}
+
+		n, err := redis.Int64(script.Do(m.conn, from, marker, migrateChunkSize))
+		if err != nil {
+			return count, err
+		}
+		if n == 0 {
+			return count, nil
+		}
+	}
+}
+
+// payload returns rawJSON with the unique key of a unique job moved to the new namespace.
+func (m *namespaceMigration) payload(rawJSON []byte) []byte {
+	return migrateUniquePayload(rawJSON, m.from, m.to)
+}
+
+// migrateUniquePayload returns rawJSON, a job, with its unique key moved from namespace from to namespace to, if it
+// has one. Anything that isn't a unique job in from is returned as is.
+func migrateUniquePayload(rawJSON []byte, from, to string) []byte {
+	if !bytes.Contains(rawJSON, []byte(`"unique_key"`)) {
+		return rawJSON
+	}
+	job, err := newJobRawArgs(rawJSON, nil, nil)
+	if err != nil {
+		return rawJSON
+	}
+	fromPrefix := redisNamespacePrefix(from) + "unique:"
+	if !strings.HasPrefix(job.UniqueKey, fromPrefix) {
+		return rawJSON
+	}
+	job.UniqueKey = redisNamespacePrefix(to) + "unique:" + strings.TrimPrefix(job.UniqueKey, fromPrefix)
+	migrated, err := job.serialize()
+	if err != nil {
+		return rawJSON
+	}
+	return migrated
+}
+
+// migrateUniqueKeys copies the unique keys of namespace from to namespace to, with what's left of their TTLs, then
+// deletes them. The keys of unique jobs whose arguments can be updated hold the updated job, whose unique key is
+// moved too.
+func migrateUniqueKeys(conn, toConn redis.Conn, from, to string) error {
+	fromPrefix, toPrefix := redisNamespacePrefix(from), redisNamespacePrefix(to)
+	cursor := int64(0)
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", fromPrefix+"unique:*", "COUNT", migrateChunkSize))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			conn.Send("GET", key)
+			conn.Send("PTTL", key)
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		uniqueValues := make([][]byte, len(keys))
+		ttls := make([]int64, len(keys))
+		for i := range keys {
+			if uniqueValues[i], err = redis.Bytes(conn.Receive()); err != nil && err != redis.ErrNil {
+				return err
+			}
+			if ttls[i], err = redis.Int64(conn.Receive()); err != nil {
+				return err
+			}
+		}
+
+		for i, key := range keys {
+			if uniqueValues[i] == nil {
+				continue // gone since
+			}
+			args := redis.Args{toPrefix + strings.TrimPrefix(key, fromPrefix), migrateUniquePayload(uniqueValues[i], from, to)}
+			if ttls[i] > 0 {
+				args = append(args, "PX", ttls[i])
+			}
+			if _, err := toConn.Do("SET", args...); err != nil {
+				return err
+			}
+			if _, err := conn.Do("DEL", key); err != nil {
+				return err
+			}
+		}
+
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package work
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientMigrateNamespace(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns, renamed := "work", "work-renamed"
+	cleanKeyspace(ns, pool)
+	cleanKeyspace(renamed, pool)
+
+	client := NewClient(ns, pool)
+	_, err := client.MigrateNamespace(NewClient(ns+":", pool))
+	assert.Error(t, err)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for _, n := range []int{1, 2, 3} {
+		_, err := enqueuer.Enqueue("wat", Q{"n": n})
+		assert.NoError(t, err)
+	}
+	_, err = enqueuer.EnqueueIn("ugh", 300, nil)
+	assert.NoError(t, err)
+	insertDeadJob(ns, pool, "wat", 12345, 12347)
+
+	// A job left held by a migration that failed partway
+	conn := pool.Get()
+	_, err = conn.Do("RPOPLPUSH", redisKeyJobs(ns, "wat"), redisKeyMigrating(ns, "wat"))
+	assert.NoError(t, err)
+	conn.Close()
+
+	count, err := client.MigrateNamespace(NewClient(renamed, pool))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, count)
+
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, "wat")))
+	assert.EqualValues(t, 0, listSize(pool, redisKeyMigrating(ns, "wat")))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyScheduled(ns)))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyDead(ns)))
+
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyScheduled(renamed)))
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyDead(renamed)))
+	assert.ElementsMatch(t, []string{"ugh", "wat"}, knownJobs(pool, redisKeyKnownJobs(renamed)))
+	for _, n := range []float64{1, 2, 3} {
+		job := getQueuedJob(renamed, pool, "wat")
+		if assert.NotNil(t, job) {
+			assert.EqualValues(t, n, job.Args["n"])
+		}
+	}
+
+	// Running it again once producers have switched over moves only what's been left behind since
+	_, err = enqueuer.Enqueue("wat", Q{"n": 4})
+	assert.NoError(t, err)
+	count, err = client.MigrateNamespace(NewClient(renamed, pool))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	entries, _, err := client.AuditLog(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "migrate_namespace", entries[0].Action)
+	assert.Equal(t, renamed, entries[0].Details["namespace"])
+}
+
+func TestClientMigrateNamespaceUnique(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns, renamed := "work", "work-renamed"
+	cleanKeyspace(ns, pool)
+	cleanKeyspace(renamed, pool)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.EnqueueUnique("wat", Q{"a": 1})
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueUniqueInByKey("ugh", 300, Q{"b": 1}, Q{"key": "k"})
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueUniqueInByKey("ugh", 300, Q{"b": 2}, Q{"key": "k"}) // updates the arguments
+	assert.NoError(t, err)
+
+	// Scheduled jobs left held by a migration that failed partway
+	conn := pool.Get()
+	_, err = conn.Do("ZUNIONSTORE", redisKeyMigratingZset(ns, redisKeyScheduled(ns)), 1, redisKeyScheduled(ns))
+	assert.NoError(t, err)
+	_, err = conn.Do("DEL", redisKeyScheduled(ns))
+	assert.NoError(t, err)
+	conn.Close()
+
+	count, err := NewClient(ns, pool).MigrateNamespace(NewClient(renamed, pool))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyMigratingZset(ns, redisKeyScheduled(ns))))
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyScheduled(renamed)))
+
+	// The unique keys moved, and the jobs point at them
+	watKey, err := redisKeyUniqueJob(renamed, "wat", Q{"a": 1})
+	assert.NoError(t, err)
+	ughKey, err := redisKeyUniqueJob(renamed, "ugh", Q{"key": "k"})
+	assert.NoError(t, err)
+	for _, key := range []string{watKey, ughKey} {
+		assert.True(t, keyExists(pool, key))
+		assert.False(t, keyExists(pool, strings.Replace(key, renamed, ns, 1)))
+	}
+	job := getQueuedJob(renamed, pool, "wat")
+	if assert.NotNil(t, job) {
+		assert.Equal(t, watKey, job.UniqueKey)
+	}
+	_, job = jobOnZset(pool, redisKeyScheduled(renamed))
+	assert.Equal(t, ughKey, job.UniqueKey)
+
+	// Including the updated job held in the key of a job whose arguments can be updated
+	conn = pool.Get()
+	defer conn.Close()
+	rawJSON, err := redis.Bytes(conn.Do("GET", ughKey))
+	assert.NoError(t, err)
+	updated, err := newJob(rawJSON, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ughKey, updated.UniqueKey)
+	assert.EqualValues(t, 2, updated.ArgInt64("b"))
+}
@@ -0,0 +1,163 @@
+package work
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const monitorInterval = 15 * time.Second
+
+// Alert is a threshold on a job type's queue, see WorkerPool.Alert. At least one of MaxCount and MaxLatency must be
+// set; the alert fires when the queue crosses either.
+type Alert struct {
+	JobName    string
+	MaxCount   int64         // fires when more than this many jobs are waiting in the queue
+	MaxLatency time.Duration // fires when the next job in the queue has waited longer than this
+	For        time.Duration // if set, only fires once the queue has been over the threshold this long
+}
+
+// AlertEvent is what an AlertHook is called with: once when an alert fires, and again when it's resolved.
+type AlertEvent struct {
+	Alert   Alert
+	Firing  bool      // false when the queue is back under the threshold
+	Since   time.Time // when the queue went over the threshold
+	Count   int64     // of the jobs in the queue when the alert fired or was resolved
+	Latency time.Duration
+}
+
+// AlertHook is called with an alert's events. It's called from the monitor's goroutine, so it shouldn't block for
+// long; hand the event off to a goroutine of its own for anything slow, eg paging someone.
+type AlertHook func(AlertEvent)
+
+func (a Alert) validate() error {
+	if a.JobName == "" {
+		return fmt.Errorf("work: Alert needs a JobName")
+	}
+	if a.MaxCount <= 0 && a.MaxLatency <= 0 {
+		return fmt.Errorf("work: Alert for %q needs a MaxCount or MaxLatency", a.JobName)
+	}
+	return nil
+}
+
+// alertState is an alert being monitored.
+type alertState struct {
+	Alert
+	hook AlertHook
+
+	overSince time.Time // zero while the queue is under the threshold
+	firing    bool
+}
+
+func (s *alertState) over(count int64, latency time.Duration) bool {
+	return (s.MaxCount > 0 && count > s.MaxCount) || (s.MaxLatency > 0 && latency > s.MaxLatency)
+}
+
+// update takes in the queue's latest numbers, calling the hook if the alert fires or is resolved.
+func (s *alertState) update(count int64, latency time.Duration, now time.Time) {
+	if !s.over(count, latency) {
+		if s.firing {
+			s.hook(AlertEvent{Alert: s.Alert, Firing: false, Since: s.overSince, Count: count, Latency: latency})
+		}
+		s.overSince, s.firing = time.Time{}, false
+		return
+	}
+
+	if s.overSince.IsZero() {
+		s.overSince = now
+	}
+	if !s.firing && now.Sub(s.overSince) >= s.For {
+		s.firing = true
+		s.hook(AlertEvent{Alert: s.Alert, Firing: true, Since: s.overSince, Count: count, Latency: latency})
+	}
+}
+
+// queueMonitor checks the queues that have alerts on them every monitorInterval. It runs as part of maintenance, so
+// that with leader election an alert fires once rather than once per pool.
+type queueMonitor struct {
+	namespace string
+	pool      *redis.Pool
+	alerts    []*alertState
+	interval  time.Duration
+
+	redisTimeout time.Duration
+	errorHook    ErrorHook
+	logger       Logger
+
+	stopChan         chan struct{}
+	doneStoppingChan chan struct{}
+}
+
+func newQueueMonitor(namespace string, pool *redis.Pool, alerts []*alertState) *queueMonitor {
+	return &queueMonitor{
+		namespace:        namespace,
+		pool:             pool,
+		alerts:           alerts,
+		interval:         monitorInterval,
+		stopChan:         make(chan struct{}),
+		doneStoppingChan: make(chan struct{}),
+	}
+}
+
+func (m *queueMonitor) start() {
+	go m.loop()
+}
+
+func (m *queueMonitor) stop() {
+	m.stopChan <- struct{}{}
+	<-m.doneStoppingChan
+}
+
+func (m *queueMonitor) loop() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopChan:
+			m.doneStoppingChan <- struct{}{}
+			return
+		case <-ticker.C:
+			if err := m.check(time.Now()); err != nil {
+				reportError(m.logger, m.errorHook, "queue_monitor.check", err)
+			}
+		}
+	}
+}
+
+// check reads the length and latency of each queue with an alert, and updates the alerts.
+func (m *queueMonitor) check(now time.Time) error {
+	conn := getConn(m.pool, m.redisTimeout)
+	defer conn.Close()
+
+	for _, s := range m.alerts {
+		conn.Send("LLEN", redisKeyJobs(m.namespace, s.JobName))
+		conn.Send("LINDEX", redisKeyJobs(m.namespace, s.JobName), -1)
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+
+	for _, s := range m.alerts {
+		count, err := redis.Int64(conn.Receive())
+		if err != nil {
+			return err
+		}
+		rawJSON, err := redis.Bytes(conn.Receive())
+		if err != nil && err != redis.ErrNil {
+			return err
+		}
+
+		var latency time.Duration
+		if rawJSON != nil {
+			job, err := newJobRawArgs(rawJSON, nil, nil)
+			if err != nil {
+				return err
+			}
+			latency = now.Sub(time.Unix(job.EnqueuedAt, 0))
+		}
+		s.update(count, latency, now)
+	}
+	return nil
+}
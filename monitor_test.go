@@ -0,0 +1,117 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertValidate(t *testing.T) {
+	assert.Error(t, Alert{MaxCount: 10}.validate())
+	assert.Error(t, Alert{JobName: "wat"}.validate())
+	assert.NoError(t, Alert{JobName: "wat", MaxLatency: time.Hour}.validate())
+
+	wp := NewWorkerPool(TestContext{}, 1, "work", newTestPool(":6379"))
+	assert.PanicsWithValue(t, `work: Alert for "wat" needs a MaxCount or MaxLatency`, func() {
+		wp.Alert(Alert{JobName: "wat"}, func(AlertEvent) {})
+	})
+}
+
+func TestAlertState(t *testing.T) {
+	var events []AlertEvent
+	s := &alertState{
+		Alert: Alert{JobName: "wat", MaxCount: 100, MaxLatency: time.Hour, For: 5 * time.Minute},
+		hook:  func(e AlertEvent) { events = append(events, e) },
+	}
+
+	start := time.Unix(1500000000, 0)
+	s.update(50, time.Minute, start)
+	assert.Empty(t, events)
+
+	// Over, but not for long enough yet
+	s.update(150, time.Minute, start.Add(time.Minute))
+	s.update(50, 2*time.Hour, start.Add(3*time.Minute))
+	assert.Empty(t, events)
+
+	s.update(150, time.Minute, start.Add(6*time.Minute))
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, AlertEvent{Alert: s.Alert, Firing: true, Since: start.Add(time.Minute), Count: 150, Latency: time.Minute}, events[0])
+	}
+
+	// It only fires once, and is resolved once back under
+	s.update(200, time.Minute, start.Add(7*time.Minute))
+	s.update(10, time.Second, start.Add(8*time.Minute))
+	s.update(10, time.Second, start.Add(9*time.Minute))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, AlertEvent{Alert: s.Alert, Firing: false, Since: start.Add(time.Minute), Count: 10, Latency: time.Second}, events[1])
+	}
+
+	// Dipping under resets the clock
+	s.update(150, time.Minute, start.Add(10*time.Minute))
+	s.update(10, time.Minute, start.Add(11*time.Minute))
+	s.update(150, time.Minute, start.Add(12*time.Minute))
+	s.update(150, time.Minute, start.Add(16*time.Minute))
+	assert.Len(t, events, 2)
+}
+
+func TestQueueMonitor(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	events := make(chan AlertEvent, 10)
+	hook := func(e AlertEvent) { events <- e }
+	alerts := []*alertState{
+		{Alert: Alert{JobName: "wat", MaxCount: 2}, hook: hook},
+		{Alert: Alert{JobName: "bob", MaxLatency: time.Minute}, hook: hook},
+	}
+
+	setNowEpochSecondsMock(1500000000)
+	defer resetNowEpochSecondsMock()
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 3; i++ {
+		_, err := enqueuer.Enqueue("wat", nil)
+		assert.NoError(t, err)
+	}
+	_, err := enqueuer.Enqueue("bob", nil)
+	assert.NoError(t, err)
+
+	monitor := newQueueMonitor(ns, pool, alerts)
+	assert.NoError(t, monitor.check(time.Unix(1500000030, 0)))
+	if assert.Len(t, events, 1) {
+		e := <-events
+		assert.Equal(t, "wat", e.Alert.JobName)
+		assert.True(t, e.Firing)
+		assert.EqualValues(t, 3, e.Count)
+	}
+
+	assert.NoError(t, monitor.check(time.Unix(1500000090, 0)))
+	if assert.Len(t, events, 1) {
+		e := <-events
+		assert.Equal(t, "bob", e.Alert.JobName)
+		assert.Equal(t, 90*time.Second, e.Latency)
+	}
+
+	// Run as part of a pool's maintenance
+	cleanKeyspace(ns, pool)
+	resetNowEpochSecondsMock()
+	for i := 0; i < 3; i++ {
+		_, err := enqueuer.Enqueue("wat", nil)
+		assert.NoError(t, err)
+	}
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Alert(Alert{JobName: "wat", MaxCount: 2}, hook)
+	wp.Start()
+	wp.maintenance.queueMonitor.stop()
+	monitor = wp.maintenance.queueMonitor
+	monitor.interval = time.Millisecond
+	monitor.start()
+	select {
+	case e := <-events:
+		assert.Equal(t, "wat", e.Alert.JobName)
+	case <-time.After(time.Second):
+		t.Fatal("no alert")
+	}
+	wp.Stop()
+}
@@ -10,9 +10,12 @@ import (
 
 // An observer observes a single worker. Each worker has its own observer.
 type observer struct {
-	namespace string
-	workerID  string
-	pool      *redis.Pool
+	namespace    string
+	workerID     string
+	pool         *redis.Pool
+	redisTimeout time.Duration
+	errorHook    ErrorHook
+	logger       Logger
 
 	// nil: worker isn't doing anything that we know of
 	// not nil: the last started observation that we received on the channel.
@@ -139,7 +142,7 @@ func (o *observer) loop() {
 					o.process(obv)
 				default:
 					if err := o.writeStatus(o.currentStartedObservation); err != nil {
-						logError("observer.write", err)
+						reportError(o.logger, o.errorHook, "observer.write", err)
 					}
 					o.doneDrainingChan <- struct{}{}
 					break DRAIN_LOOP
@@ -148,7 +151,7 @@ func (o *observer) loop() {
 		case <-ticker:
 			if o.lastWrittenVersion != o.version {
 				if err := o.writeStatus(o.currentStartedObservation); err != nil {
-					logError("observer.write", err)
+					reportError(o.logger, o.errorHook, "observer.write", err)
 				}
 				o.lastWrittenVersion = o.version
 			}
@@ -168,7 +171,7 @@ func (o *observer) process(obv *observation) {
 			o.currentStartedObservation.checkin = obv.checkin
 			o.currentStartedObservation.checkinAt = obv.checkinAt
 		} else {
-			logError("observer.checkin_mismatch", fmt.Errorf("got checkin but mismatch on job ID or no job"))
+			reportError(o.logger, o.errorHook, "observer.checkin_mismatch", fmt.Errorf("got checkin but mismatch on job ID or no job"))
 		}
 	}
 	o.version++
@@ -176,14 +179,14 @@ func (o *observer) process(obv *observation) {
 	// If this is the version observation we got, just go ahead and write it.
 	if o.version == 1 {
 		if err := o.writeStatus(o.currentStartedObservation); err != nil {
-			logError("observer.first_write", err)
+			reportError(o.logger, o.errorHook, "observer.first_write", err)
 		}
 		o.lastWrittenVersion = o.version
 	}
 }
 
 func (o *observer) writeStatus(obv *observation) error {
-	conn := o.pool.Get()
+	conn := getConn(o.pool, o.redisTimeout)
 	defer conn.Close()
 
 	key := redisKeyWorkerObservation(o.namespace, o.workerID)
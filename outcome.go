@@ -0,0 +1,130 @@
+package work
+
+import (
+	"fmt"
+	"time"
+)
+
+// OutcomeKind says what becomes of a job once its handler returns an Outcome.
+type OutcomeKind int
+
+const (
+	OutcomeSuccess OutcomeKind = iota // The job is done
+	OutcomeRetry                      // The job is retried after Outcome.Delay, as long as it has fails left
+	OutcomeDead                       // The job goes to the dead queue right away, or is dropped if its type has SkipDead
+	OutcomeDiscard                    // The job is dropped, without being retried or sent to the dead queue
+)
+
+func (k OutcomeKind) String() string {
+	switch k {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeRetry:
+		return "retry"
+	case OutcomeDead:
+		return "dead"
+	case OutcomeDiscard:
+		return "discard"
+	}
+	return fmt.Sprintf("OutcomeKind(%d)", int(k))
+}
+
+// Outcome is what a handler can return instead of an error to say explicitly what should become of its job, eg
+// work.Retry(time.Minute, "rate limited") or work.Discard("account deleted"). Handlers can be declared to return an
+// Outcome, or return one as their error: an Outcome is an error, so it also passes through middleware, which sees
+// the failing kinds as the errors they are and Success as no error at all.
+//
+// A plain error is handled as before: the job is retried with its type's backoff while it has fails left, then sent
+// to the dead queue. Retries, deaths and discards count as failures, in the job's Fails and LastErr and in the pool's
+// stats.
+type Outcome struct {
+	Kind   OutcomeKind
+	Result interface{}   // For OutcomeSuccess, made available to middleware with Job.Result
+	Delay  time.Duration // For OutcomeRetry, replacing the job type's backoff. Rounded down to the second.
+	Reason string        // Why the job failed, for OutcomeRetry, OutcomeDead and OutcomeDiscard. Recorded as its LastErr.
+
+	// FollowUps are jobs to enqueue once the job succeeds, see Then.
+	FollowUps []*Job
+}
+
+// Success returns the Outcome of a job that is done, with an optional result for middleware.
+func Success(result interface{}) Outcome {
+	return Outcome{Kind: OutcomeSuccess, Result: result}
+}
+
+// Retry returns the Outcome of a job to retry after delay. Retrying counts as a failure, so a job that has no fails
+// left goes to the dead queue instead.
+func Retry(delay time.Duration, reason string) Outcome {
+	return Outcome{Kind: OutcomeRetry, Delay: delay, Reason: reason}
+}
+
+// Dead returns the Outcome of a job that can't succeed, which goes straight to the dead queue.
+func Dead(reason string) Outcome {
+	return Outcome{Kind: OutcomeDead, Reason: reason}
+}
+
+// Discard returns the Outcome of a job that's no longer needed, which is dropped without a trace.
+func Discard(reason string) Outcome {
+	return Outcome{Kind: OutcomeDiscard, Reason: reason}
+}
+
+// Then returns the outcome with jobs to enqueue once its job is done, eg
+//
+//	return work.Success(nil).Then(&work.Job{Name: "send_receipt", Args: work.Q{"order_id": orderID}})
+//
+// Only Name, Args and ArgsVersion of the jobs are used; the worker gives them an ID and the like. They're enqueued by
+// the same script that acknowledges the job, so they're enqueued exactly when the job is done: never for a job that
+// fails (even if a middleware turns its success into an error) and so will be retried, and never twice. Follow-ups
+// of outcomes other than OutcomeSuccess are ignored. Follow-ups can't be unique jobs.
+func (o Outcome) Then(jobs ...*Job) Outcome {
+	o.FollowUps = append(o.FollowUps[:len(o.FollowUps):len(o.FollowUps)], jobs...)
+	return o
+}
+
+func (o Outcome) Error() string {
+	if o.Reason == "" {
+		return o.Kind.String()
+	}
+	return o.Kind.String() + ": " + o.Reason
+}
+
+// handlerResult turns what a handler returned into the error middleware sees: nil for success, including a Success
+// outcome, whose result and follow-ups are kept on the job.
+func handlerResult(job *Job, err error) error {
+	if o, ok := err.(Outcome); ok && o.Kind == OutcomeSuccess {
+		job.result = o.Result
+		job.followUps = o.FollowUps
+		return nil
+	}
+	return err
+}
+
+// followUp is a job to enqueue when another is acknowledged.
+type followUp struct {
+	name    string
+	rawJSON []byte
+}
+
+// newFollowUps makes the follow-up jobs of job ready to enqueue.
+func newFollowUps(job *Job) ([]followUp, error) {
+	followUps := make([]followUp, 0, len(job.followUps))
+	for _, f := range job.followUps {
+		if f.Unique {
+			return nil, fmt.Errorf("follow-up %q: can't be unique", f.Name)
+		}
+		fingerprint, _ := jobFingerprint(f.Name, f.Args)
+		rawJSON, err := (&Job{
+			Name:        f.Name,
+			ID:          makeIdentifier(),
+			EnqueuedAt:  nowEpochSeconds(),
+			Args:        f.Args,
+			ArgsVersion: f.ArgsVersion,
+			Fingerprint: fingerprint,
+		}).serialize()
+		if err != nil {
+			return nil, fmt.Errorf("follow-up %q: %v", f.Name, err)
+		}
+		followUps = append(followUps, followUp{name: f.Name, rawJSON: rawJSON})
+	}
+	return followUps, nil
+}
@@ -0,0 +1,165 @@
+package work
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutcomes(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	tMock := nowEpochSeconds() - 10
+	setNowEpochSecondsMock(tMock)
+	defer resetNowEpochSecondsMock()
+
+	var result interface{}
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Middleware(func(job *Job, next NextMiddlewareFunc) error {
+		err := next()
+		if job.Name == "succeed" {
+			result = job.Result()
+		}
+		return err
+	})
+	opts := JobOptions{MaxFails: 5}
+	wp.JobWithOptions("succeed", opts, func(job *Job) Outcome { return Success(42) })
+	wp.JobWithOptions("retry", opts, func(job *Job) Outcome { return Retry(time.Minute, "busy") })
+	wp.JobWithOptions("die", opts, func(job *Job) error { return Dead("bad input") })
+	wp.JobWithOptions("discard", opts, func(job *Job) error { return fmt.Errorf("wrapped: %w", Discard("gone")) })
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for _, jobName := range []string{"succeed", "retry", "die", "discard"} {
+		_, err := enqueuer.Enqueue(jobName, nil)
+		assert.NoError(t, err)
+	}
+
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.Equal(t, 42, result)
+
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyRetry(ns)))
+	score, job := jobOnZset(pool, redisKeyRetry(ns))
+	assert.Equal(t, tMock+60, score)
+	assert.Equal(t, "retry", job.Name)
+	assert.Equal(t, "retry: busy", job.LastErr)
+	assert.EqualValues(t, 1, job.Fails)
+
+	// Dead right away, even with fails left
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyDead(ns)))
+	_, job = jobOnZset(pool, redisKeyDead(ns))
+	assert.Equal(t, "die", job.Name)
+	assert.Equal(t, "dead: bad input", job.LastErr)
+
+	stats := wp.Stats()
+	assert.EqualValues(t, 4, stats.Processed)
+	assert.EqualValues(t, 3, stats.Failed)
+	assert.EqualValues(t, 1, stats.Retried)
+	assert.EqualValues(t, 1, stats.Died)
+	assert.EqualValues(t, 1, stats.Discarded)
+}
+
+func TestOutcomeRetryOutOfFails(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	jobTypes := map[string]*jobType{"wat": {Name: "wat", JobOptions: JobOptions{Priority: 1, MaxFails: 2}}}
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+
+	job := &Job{Name: "wat", ID: makeIdentifier(), Fails: 2}
+	fate := w.jobFate(jobTypes["wat"], job, Retry(time.Second, "again"))
+	assert.Equal(t, redisKeyDead(ns), fate.zsetKey)
+}
+
+func TestOutcomeHandlerTypes(t *testing.T) {
+	ctxType := reflect.TypeOf(tstCtx{})
+	assert.True(t, isValidHandlerType(ctxType, reflect.ValueOf(func(job *Job) Outcome { return Success(nil) })))
+	assert.True(t, isValidHandlerType(ctxType, reflect.ValueOf(func(c *tstCtx, job *Job) Outcome { return Success(nil) })))
+	assert.False(t, isValidHandlerType(ctxType, reflect.ValueOf(func(job *Job) *Outcome { return nil })))
+
+	assert.Equal(t, "success", Success(nil).Error())
+	assert.Equal(t, "discard: gone", Discard("gone").Error())
+	assert.Equal(t, "OutcomeKind(9)", OutcomeKind(9).String())
+}
+
+func TestOutcomeThen(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	receipt := &Job{Name: "send_receipt", Args: Q{"order_id": 7}, ArgsVersion: 2}
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Middleware(func(job *Job, next NextMiddlewareFunc) error {
+		err := next()
+		if job.Name == "vetoed" {
+			return fmt.Errorf("vetoed")
+		}
+		return err
+	})
+	opts := JobOptions{MaxFails: 5}
+	wp.JobWithOptions("order", opts, func(job *Job) Outcome { return Success(nil).Then(receipt) })
+	wp.JobWithOptions("vetoed", opts, func(job *Job) Outcome { return Success(nil).Then(receipt) })
+	wp.JobWithOptions("retry", opts, func(job *Job) Outcome { return Retry(time.Minute, "busy").Then(receipt) })
+	wp.JobWithOptions("unique", opts, func(job *Job) Outcome { return Success(nil).Then(&Job{Name: "x", Unique: true}) })
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for _, jobName := range []string{"order", "vetoed", "retry", "unique"} {
+		_, err := enqueuer.Enqueue(jobName, nil)
+		assert.NoError(t, err)
+	}
+
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	// Only the job that succeeded had its follow-up enqueued
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "send_receipt")))
+	job := jobOnQueue(pool, redisKeyJobs(ns, "send_receipt"))
+	assert.Equal(t, "send_receipt", job.Name)
+	assert.NotEmpty(t, job.ID)
+	assert.EqualValues(t, 7, job.ArgInt64("order_id"))
+	assert.EqualValues(t, 2, job.ArgsVersion)
+	assert.NotEmpty(t, job.Fingerprint)
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, "x")))
+
+	assert.EqualValues(t, 3, zsetSize(pool, redisKeyRetry(ns)))
+	knownJobs, err := redis.Strings(pool.Get().Do("SMEMBERS", redisKeyKnownJobs(ns)))
+	assert.NoError(t, err)
+	assert.Contains(t, knownJobs, "send_receipt")
+}
+
+func TestOutcomeThenAckedOnce(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	jobTypes := map[string]*jobType{"order": {Name: "order", JobOptions: JobOptions{Priority: 1, MaxFails: 2}}}
+	_, err := NewEnqueuer(ns, pool).Enqueue("order", nil)
+	assert.NoError(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	job, err := w.fetchJob()
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	job.followUps = []*Job{{Name: "a"}, {Name: "b"}}
+	followUps, err := newFollowUps(job)
+	assert.NoError(t, err)
+	fate := terminateOp{followUps: followUps}
+
+	// Acking again, eg because the first ack's reply was lost, doesn't enqueue the follow-ups twice
+	assert.NoError(t, w.ack([]*Job{job, job}, []terminateOp{fate, fate}))
+	assert.NoError(t, w.ack([]*Job{job}, []terminateOp{fate}))
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "a")))
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "b")))
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobsInProgress(ns, "1", "order")))
+}
@@ -3,6 +3,8 @@ package work
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -12,6 +14,7 @@ import (
 const (
 	periodicEnqueuerSleep   = 2 * time.Minute
 	periodicEnqueuerHorizon = 4 * time.Minute
+	periodicCatchUpMaxRuns  = 100
 )
 
 type periodicEnqueuer struct {
@@ -19,6 +22,10 @@ type periodicEnqueuer struct {
 	pool                  *redis.Pool
 	periodicJobs          []*periodicJob
 	scheduledPeriodicJobs []*scheduledPeriodicJob
+	redisTimeout          time.Duration
+	errorHook             ErrorHook
+	logger                Logger
+	lastRunsScript        *redis.Script
 	stopChan              chan struct{}
 	doneStoppingChan      chan struct{}
 }
@@ -27,6 +34,132 @@ type periodicJob struct {
 	jobName  string
 	spec     string
 	schedule cron.Schedule
+	args     PeriodicArgs
+	catchUp  CatchUpPolicy
+	location *time.Location // set by InLocation
+}
+
+// CatchUpPolicy says what to do about the runs of a periodic job that were missed because no pool was up to enqueue
+// them. Missed runs are found from the last run that was enqueued, which is kept in Redis for each periodic job.
+type CatchUpPolicy int
+
+const (
+	CatchUpSkip CatchUpPolicy = iota // Missed runs are skipped; the job next runs at its next scheduled time
+	CatchUpOnce                      // The most recent missed run is enqueued right away
+	CatchUpAll                       // Every missed run (up to the last 100) is enqueued right away, oldest first
+)
+
+// InLocation makes the periodic job's spec follow the wall clock of loc, eg America/New_York, whose daylight saving
+// time changes move the job's runs with them. Without it, specs are in the process's local time zone unless they start
+// with CRON_TZ=<location>, so pools in different time zones would disagree about when the job runs.
+func InLocation(loc *time.Location) PeriodicOption {
+	return func(pj *periodicJob) {
+		pj.location = loc
+		if s, ok := pj.schedule.(*cron.SpecSchedule); ok {
+			s.Location = loc
+		}
+	}
+}
+
+// PeriodicOption configures a periodic job registered with PeriodicallyEnqueue.
+type PeriodicOption func(*periodicJob)
+
+// CatchUp sets the periodic job's CatchUpPolicy, which is CatchUpSkip by default.
+func CatchUp(policy CatchUpPolicy) PeriodicOption {
+	return func(pj *periodicJob) {
+		pj.catchUp = policy
+	}
+}
+
+// PeriodicArgs builds the arguments of a periodic job from the time it's scheduled for. Every pool enqueues its own
+// copy of each periodic job, and the copies are only deduplicated if they're identical, so the arguments must only
+// depend on scheduledAt, not eg on the current time or the host. scheduledAt is in UTC, or in the job's location if
+// it was registered with InLocation.
+type PeriodicArgs func(scheduledAt time.Time) map[string]interface{}
+
+// PeriodicArgsTemplate returns PeriodicArgs that copy args, replacing placeholders in their string values with the
+// time the job is scheduled for, in UTC or the job's location: {date} becomes eg "2016-07-12", {time} eg
+// "2016-07-12T21:37:33Z" and {unix} the epoch seconds. Other values, including maps and slices, are copied as they are.
+func PeriodicArgsTemplate(args map[string]interface{}) PeriodicArgs {
+	return func(scheduledAt time.Time) map[string]interface{} {
+		r := strings.NewReplacer(
+			"{date}", scheduledAt.Format("2006-01-02"),
+			"{time}", scheduledAt.Format(time.RFC3339),
+			"{unix}", strconv.FormatInt(scheduledAt.Unix(), 10),
+		)
+		built := make(map[string]interface{}, len(args))
+		for k, v := range args {
+			if s, ok := v.(string); ok {
+				v = r.Replace(s)
+			}
+			built[k] = v
+		}
+		return built
+	}
+}
+
+// newPeriodicJob parses spec, panicking if it's invalid. See WorkerPool.PeriodicallyEnqueue.
+func newPeriodicJob(spec string, jobName string, opts ...PeriodicOption) *periodicJob {
+	p := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+	schedule, err := p.Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	pj := &periodicJob{jobName: jobName, spec: spec, schedule: schedule}
+	for _, opt := range opts {
+		opt(pj)
+	}
+	return pj
+}
+
+// newIntervalPeriodicJob panics unless interval is a whole number of seconds. See WorkerPool.PeriodicallyEnqueueEvery.
+func newIntervalPeriodicJob(interval time.Duration, jobName string, opts ...PeriodicOption) *periodicJob {
+	if interval < time.Second || interval%time.Second != 0 {
+		panic(fmt.Sprintf("work: periodic job %q: interval %v must be a whole number of seconds", jobName, interval))
+	}
+
+	schedule := intervalSchedule{seconds: int64(interval / time.Second)}
+	pj := &periodicJob{jobName: jobName, spec: "@every " + interval.String(), schedule: schedule}
+	for _, opt := range opts {
+		opt(pj)
+	}
+	return pj
+}
+
+// intervalSchedule runs at the multiples of an interval since the Unix epoch, rather than an interval after whenever
+// it's asked like cron's @every, so runs don't drift however late the enqueuer wakes up, and every pool agrees on them,
+// which is what lets their copies of each run be deduplicated.
+type intervalSchedule struct {
+	seconds int64
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return time.Unix((t.Unix()/s.seconds+1)*s.seconds, 0)
+}
+
+// lastRunField is pj's field in the hash of last runs.
+func (pj *periodicJob) lastRunField() string {
+	return pj.jobName + ":" + pj.spec
+}
+
+// missedRuns returns the runs of pj after lastRun and up to now that its catch-up policy says to enqueue.
+func (pj *periodicJob) missedRuns(lastRun int64, now time.Time) []int64 {
+	if pj.catchUp == CatchUpSkip {
+		return nil
+	}
+	var missed []int64
+	for t := pj.schedule.Next(time.Unix(lastRun, 0)); !t.After(now); t = pj.schedule.Next(t) {
+		missed = append(missed, t.Unix())
+		if len(missed) > periodicCatchUpMaxRuns {
+			missed = missed[1:]
+		}
+	}
+	if pj.catchUp == CatchUpOnce && len(missed) > 1 {
+		missed = missed[len(missed)-1:]
+	}
+	return missed
 }
 
 type scheduledPeriodicJob struct {
@@ -40,6 +173,7 @@ func newPeriodicEnqueuer(namespace string, pool *redis.Pool, periodicJobs []*per
 		namespace:        namespace,
 		pool:             pool,
 		periodicJobs:     periodicJobs,
+		lastRunsScript:   redis.NewScript(1, redisLuaSetPeriodicLastRuns),
 		stopChan:         make(chan struct{}),
 		doneStoppingChan: make(chan struct{}),
 	}
@@ -62,7 +196,7 @@ func (pe *periodicEnqueuer) loop() {
 	if pe.shouldEnqueue() {
 		err := pe.enqueue()
 		if err != nil {
-			logError("periodic_enqueuer.loop.enqueue", err)
+			reportError(pe.logger, pe.errorHook, "periodic_enqueuer.loop.enqueue", err)
 		}
 	}
 
@@ -76,7 +210,7 @@ func (pe *periodicEnqueuer) loop() {
 			if pe.shouldEnqueue() {
 				err := pe.enqueue()
 				if err != nil {
-					logError("periodic_enqueuer.loop.enqueue", err)
+					reportError(pe.logger, pe.errorHook, "periodic_enqueuer.loop.enqueue", err)
 				}
 			}
 		}
@@ -88,49 +222,85 @@ func (pe *periodicEnqueuer) enqueue() error {
 	nowTime := time.Unix(now, 0)
 	horizon := nowTime.Add(periodicEnqueuerHorizon)
 
-	conn := pe.pool.Get()
+	conn := getConn(pe.pool, pe.redisTimeout)
 	defer conn.Close()
 
-	for _, pj := range pe.periodicJobs {
-		for t := pj.schedule.Next(nowTime); t.Before(horizon); t = pj.schedule.Next(t) {
-			epoch := t.Unix()
-			id := makeUniquePeriodicID(pj.jobName, pj.spec, epoch)
-
-			job := &Job{
-				Name: pj.jobName,
-				ID:   id,
+	lastRuns, err := redis.Int64Map(conn.Do("HGETALL", redisKeyPeriodicLastRuns(pe.namespace)))
+	if err != nil {
+		return err
+	}
 
-				// This is technically wrong, but this lets the bytes be identical for the same periodic job instance. If we don't do this, we'd need to use a different approach -- probably giving each periodic job its own history of the past 100 periodic jobs, and only scheduling a job if it's not in the history.
-				EnqueuedAt: epoch,
-				Args:       nil,
+	lastRunArgs := []interface{}{redisKeyPeriodicLastRuns(pe.namespace)}
+	for _, pj := range pe.periodicJobs {
+		if lastRun, ok := lastRuns[pj.lastRunField()]; ok {
+			for _, epoch := range pj.missedRuns(lastRun, nowTime) {
+				if err := pe.schedule(conn, pj, epoch); err != nil {
+					return err
+				}
 			}
+		}
 
-			rawJSON, err := job.serialize()
-			if err != nil {
+		var lastEpoch int64
+		for t := pj.schedule.Next(nowTime); t.Before(horizon); t = pj.schedule.Next(t) {
+			lastEpoch = t.Unix()
+			if err := pe.schedule(conn, pj, lastEpoch); err != nil {
 				return err
 			}
+		}
+		if lastEpoch > 0 {
+			lastRunArgs = append(lastRunArgs, pj.lastRunField(), lastEpoch)
+		}
+	}
 
-			_, err = conn.Do("ZADD", redisKeyScheduled(pe.namespace), epoch, rawJSON)
-			if err != nil {
-				return err
-			}
+	if len(lastRunArgs) > 1 {
+		if _, err := pe.lastRunsScript.Do(conn, lastRunArgs...); err != nil {
+			return err
+		}
+	}
+
+	_, err = conn.Do("SET", redisKeyLastPeriodicEnqueue(pe.namespace), now)
+
+	return err
+}
+
+// schedule adds the run of pj at epoch to the scheduled queue.
+func (pe *periodicEnqueuer) schedule(conn redis.Conn, pj *periodicJob, epoch int64) error {
+	id := makeUniquePeriodicID(pj.jobName, pj.spec, epoch)
+
+	job := &Job{
+		Name: pj.jobName,
+		ID:   id,
+
+		// This is technically wrong, but this lets the bytes be identical for the same periodic job instance. If we don't do this, we'd need to use a different approach -- probably giving each periodic job its own history of the past 100 periodic jobs, and only scheduling a job if it's not in the history.
+		EnqueuedAt: epoch,
+		Args:       nil,
+	}
+	if pj.args != nil {
+		scheduledAt := time.Unix(epoch, 0).UTC()
+		if pj.location != nil {
+			scheduledAt = scheduledAt.In(pj.location)
 		}
+		job.Args = pj.args(scheduledAt)
 	}
 
-	_, err := conn.Do("SET", redisKeyLastPeriodicEnqueue(pe.namespace), now)
+	rawJSON, err := job.serialize()
+	if err != nil {
+		return err
+	}
 
+	_, err = conn.Do("ZADD", redisKeyScheduled(pe.namespace), epoch, rawJSON)
 	return err
 }
 
 func (pe *periodicEnqueuer) shouldEnqueue() bool {
-	conn := pe.pool.Get()
+	conn := getConn(pe.pool, pe.redisTimeout)
 	defer conn.Close()
 
 	lastEnqueue, err := redis.Int64(conn.Do("GET", redisKeyLastPeriodicEnqueue(pe.namespace)))
 	if err == redis.ErrNil {
 		return true
 	} else if err != nil {
-		logError("periodic_enqueuer.should_enqueue", err)
+		reportError(pe.logger, pe.errorHook, "periodic_enqueuer.should_enqueue", err)
 		return true
 	}
 
@@ -118,3 +118,152 @@ func appendPeriodicJob(pjs []*periodicJob, spec, jobName string) []*periodicJob
 	pj := &periodicJob{jobName: jobName, spec: spec, schedule: sched}
 	return append(pjs, pj)
 }
+
+func TestPeriodicEnqueuerArgs(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	pj := newPeriodicJob("0 0 * * * *", "report") // Every hour
+	pj.args = PeriodicArgsTemplate(Q{"day": "{date}", "at": "{time}", "epoch": "{unix}", "kind": "hourly", "n": 3})
+
+	setNowEpochSecondsMock(1468360700) // 2016-07-12T21:58:20Z
+	defer resetNowEpochSecondsMock()
+
+	pe := newPeriodicEnqueuer(ns, pool, []*periodicJob{pj})
+	assert.NoError(t, pe.enqueue())
+
+	// Enqueueing again, eg from another pool, builds the same job
+	assert.NoError(t, pe.enqueue())
+
+	scheduledJobs, count, err := NewClient(ns, pool).ScheduledJobs(1)
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, count) {
+		assert.Equal(t, map[string]interface{}{
+			"day":   "2016-07-12",
+			"at":    "2016-07-12T22:00:00Z",
+			"epoch": "1468360800",
+			"kind":  "hourly",
+			"n":     float64(3),
+		}, scheduledJobs[0].Args)
+	}
+}
+
+func TestPeriodicEnqueuerCatchUp(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+
+	setNowEpochSecondsMock(1468360700) // 2016-07-12T21:58:20Z
+	defer resetNowEpochSecondsMock()
+
+	for _, tc := range []struct {
+		policy CatchUpPolicy
+		missed []int64
+	}{
+		{CatchUpSkip, nil},
+		{CatchUpOnce, []int64{1468357200}},
+		{CatchUpAll, []int64{1468350000, 1468353600, 1468357200}},
+	} {
+		cleanKeyspace(ns, pool)
+		pj := newPeriodicJob("0 0 * * * *", "report", CatchUp(tc.policy)) // Every hour
+
+		// The last run enqueued was at 19:00, so the ones at 20:00 and 21:00 were missed
+		conn := pool.Get()
+		_, err := conn.Do("HSET", redisKeyPeriodicLastRuns(ns), pj.lastRunField(), 1468350000-3600)
+		assert.NoError(t, err)
+		conn.Close()
+
+		pe := newPeriodicEnqueuer(ns, pool, []*periodicJob{pj})
+		assert.NoError(t, pe.enqueue())
+		assert.NoError(t, pe.enqueue())
+
+		scheduledJobs, _, err := NewClient(ns, pool).ScheduledJobs(1)
+		assert.NoError(t, err)
+		var runs []int64
+		for _, j := range scheduledJobs {
+			runs = append(runs, j.RunAt)
+		}
+		assert.Equal(t, append(tc.missed, 1468360800), runs, tc.policy)
+
+		// The run at 22:00 is recorded, so nothing is missed next time
+		lastRuns, err := redis.Int64Map(pool.Get().Do("HGETALL", redisKeyPeriodicLastRuns(ns)))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int64{pj.lastRunField(): 1468360800}, lastRuns)
+		assert.Empty(t, pj.missedRuns(1468360800, time.Unix(1468360800, 0)))
+	}
+}
+
+func TestPeriodicLastRunsOnlyMoveForward(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	conn := pool.Get()
+	defer conn.Close()
+	script := redis.NewScript(1, redisLuaSetPeriodicLastRuns)
+	_, err := script.Do(conn, redisKeyPeriodicLastRuns(ns), "a", 20, "b", 20)
+	assert.NoError(t, err)
+	_, err = script.Do(conn, redisKeyPeriodicLastRuns(ns), "a", 10, "b", 30)
+	assert.NoError(t, err)
+
+	lastRuns, err := redis.Int64Map(conn.Do("HGETALL", redisKeyPeriodicLastRuns(ns)))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 20, "b": 30}, lastRuns)
+}
+
+func TestPeriodicJobInLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+
+	// 9am in New York is 14:00 UTC in winter and 13:00 UTC once daylight saving time starts on March 13th, 2016
+	pj := newPeriodicJob("0 0 9 * * *", "open_market", InLocation(newYork))
+	first := pj.schedule.Next(time.Date(2016, 3, 11, 15, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2016, 3, 12, 14, 0, 0, 0, time.UTC), first.UTC())
+	assert.Equal(t, time.Date(2016, 3, 13, 13, 0, 0, 0, time.UTC), pj.schedule.Next(first).UTC())
+
+	// Arguments are built on the job's wall clock
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+	pj.args = PeriodicArgsTemplate(Q{"day": "{date}", "at": "{time}"})
+
+	setNowEpochSecondsMock(time.Date(2016, 3, 13, 12, 58, 0, 0, time.UTC).Unix())
+	defer resetNowEpochSecondsMock()
+	assert.NoError(t, newPeriodicEnqueuer(ns, pool, []*periodicJob{pj}).enqueue())
+
+	scheduledJobs, _, err := NewClient(ns, pool).ScheduledJobs(1)
+	assert.NoError(t, err)
+	if assert.Len(t, scheduledJobs, 1) {
+		assert.Equal(t, map[string]interface{}{"day": "2016-03-13", "at": "2016-03-13T09:00:00-04:00"}, scheduledJobs[0].Args)
+	}
+}
+
+func TestPeriodicJobEvery(t *testing.T) {
+	pj := newIntervalPeriodicJob(5*time.Second, "heartbeat")
+	assert.Equal(t, "@every 5s", pj.spec)
+
+	// Runs fall on multiples of the interval, however late they're asked for
+	assert.Equal(t, int64(1468360705), pj.schedule.Next(time.Unix(1468360700, 0)).Unix())
+	assert.Equal(t, int64(1468360705), pj.schedule.Next(time.Unix(1468360703, 999)).Unix())
+	assert.Equal(t, int64(1468360710), pj.schedule.Next(time.Unix(1468360705, 0)).Unix())
+
+	assert.Panics(t, func() { newIntervalPeriodicJob(500*time.Millisecond, "heartbeat") })
+	assert.Panics(t, func() { newIntervalPeriodicJob(1500*time.Millisecond, "heartbeat") })
+
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	setNowEpochSecondsMock(1468360700)
+	defer resetNowEpochSecondsMock()
+
+	pe := newPeriodicEnqueuer(ns, pool, []*periodicJob{pj})
+	assert.NoError(t, pe.enqueue())
+	assert.NoError(t, pe.enqueue())
+
+	// Every run before the horizon is scheduled once: the horizon itself, 4 minutes from now, falls on a run too, which
+	// is left for the next enqueue
+	assert.EqualValues(t, periodicEnqueuerHorizon/(5*time.Second)-1, zsetSize(pool, redisKeyScheduled(ns)))
+}
@@ -11,6 +11,7 @@ type prioritySampler struct {
 
 type sampleItem struct {
 	priority uint
+	jobName  string
 
 	// payload:
 	redisJobs               string
@@ -21,9 +22,10 @@ type sampleItem struct {
 	redisJobsMaxConcurrency string
 }
 
-func (s *prioritySampler) add(priority uint, redisJobs, redisJobsInProg, redisJobsPaused, redisJobsLock, redisJobsLockInfo, redisJobsMaxConcurrency string) {
+func (s *prioritySampler) add(priority uint, jobName, redisJobs, redisJobsInProg, redisJobsPaused, redisJobsLock, redisJobsLockInfo, redisJobsMaxConcurrency string) {
 	sample := sampleItem{
 		priority:                priority,
+		jobName:                 jobName,
 		redisJobs:               redisJobs,
 		redisJobsInProg:         redisJobsInProg,
 		redisJobsPaused:         redisJobsPaused,
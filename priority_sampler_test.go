@@ -10,9 +10,9 @@ import (
 func TestPrioritySampler(t *testing.T) {
 	ps := prioritySampler{}
 
-	ps.add(5, "jobs.5", "jobsinprog.5", "jobspaused.5", "jobslock.5", "jobslockinfo.5", "jobsconcurrency.5")
-	ps.add(2, "jobs.2a", "jobsinprog.2a", "jobspaused.2a", "jobslock.2a", "jobslockinfo.2a", "jobsconcurrency.2a")
-	ps.add(1, "jobs.1b", "jobsinprog.1b", "jobspaused.1b", "jobslock.1b", "jobslockinfo.1b", "jobsconcurrency.1b")
+	ps.add(5, "5", "jobs.5", "jobsinprog.5", "jobspaused.5", "jobslock.5", "jobslockinfo.5", "jobsconcurrency.5")
+	ps.add(2, "2a", "jobs.2a", "jobsinprog.2a", "jobspaused.2a", "jobslock.2a", "jobslockinfo.2a", "jobsconcurrency.2a")
+	ps.add(1, "1b", "jobs.1b", "jobsinprog.1b", "jobspaused.1b", "jobslock.1b", "jobslockinfo.1b", "jobsconcurrency.1b")
 
 	var c5 = 0
 	var c2 = 0
@@ -44,6 +44,7 @@ func BenchmarkPrioritySampler(b *testing.B) {
 	ps := prioritySampler{}
 	for i := 0; i < 200; i++ {
 		ps.add(uint(i)+1,
+			fmt.Sprint(i),
 			"jobs."+fmt.Sprint(i),
 			"jobsinprog."+fmt.Sprint(i),
 			"jobspaused."+fmt.Sprint(i),
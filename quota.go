@@ -0,0 +1,74 @@
+package work
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// QuotaLimit caps how many jobs a tenant may enqueue in any Window, see Enqueuer.AddQuota.
+type QuotaLimit struct {
+	Window time.Duration // eg time.Minute or 24 * time.Hour; rounded down to whole seconds
+	Max    int64
+}
+
+// QuotaExceededError is returned by an enqueue that would take a tenant over one of its quota limits.
+type QuotaExceededError struct {
+	Tenant string
+	Limit  QuotaLimit
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("work: tenant %q is over its quota of %d jobs per %v", e.Tenant, e.Limit.Max, e.Limit.Window)
+}
+
+// AddQuota limits how many jobs each tenant may enqueue, eg to keep one customer of a multi-tenant platform from
+// flooding the shared queues. tenant returns the tenant a job is enqueued for, eg from one of its Args, or "" for jobs
+// that don't count against any quota. An enqueue that would take the tenant over any of limits fails with a
+// *QuotaExceededError, and doesn't count against the others.
+//
+// The limits apply to every enqueuer of the namespace with the same quota, since the counts are kept in Redis. They
+// roll: the count of the previous window is weighted by how much of it falls within Window of now, which tracks a
+// true sliding window closely without keeping a timestamp per job. AddQuota adds an enqueue hook, see AddHook, so a
+// job that's counted and then refused by a later hook or fails to be enqueued still counts.
+func (e *Enqueuer) AddQuota(tenant func(job *Job) string, limits ...QuotaLimit) {
+	for _, limit := range limits {
+		if limit.Window < time.Second {
+			panic("work: AddQuota needs limits with a Window of at least a second")
+		}
+	}
+
+	script := redis.NewScript(2*len(limits), redisLuaTakeQuota)
+	e.AddHook(func(job *Job) error {
+		t := tenant(job)
+		if t == "" || len(limits) == 0 {
+			return nil
+		}
+
+		now := nowEpochSeconds()
+		args := make([]interface{}, 0, 5*len(limits))
+		for _, limit := range limits {
+			window := int64(limit.Window / time.Second)
+			bucket := now / window
+			args = append(args, redisKeyQuota(e.Namespace, t, window, bucket), redisKeyQuota(e.Namespace, t, window, bucket-1))
+		}
+		for _, limit := range limits {
+			window := int64(limit.Window / time.Second)
+			args = append(args, limit.Max, window, now%window)
+		}
+
+		conn := e.Pool.Get()
+		defer conn.Close()
+
+		exceeded, err := redis.Int(script.Do(conn, args...))
+		if err != nil {
+			logError("enqueuer.quota", err)
+			return err
+		}
+		if exceeded > 0 {
+			return &QuotaExceededError{Tenant: t, Limit: limits[exceeded-1]}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,51 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueQuota(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	setNowEpochSecondsMock(1425263400) // on a minute
+	defer resetNowEpochSecondsMock()
+
+	perMinute := QuotaLimit{Window: time.Minute, Max: 3}
+	perDay := QuotaLimit{Window: 24 * time.Hour, Max: 5}
+	enqueuer := NewEnqueuer(ns, pool)
+	enqueuer.AddQuota(func(job *Job) string { return job.ArgString("tenant") }, perMinute, perDay)
+
+	for i := 0; i < 3; i++ {
+		_, err := enqueuer.Enqueue("wat", Q{"tenant": "acme"})
+		assert.NoError(t, err)
+	}
+	_, err := enqueuer.Enqueue("wat", Q{"tenant": "acme"})
+	assert.Equal(t, &QuotaExceededError{Tenant: "acme", Limit: perMinute}, err)
+
+	// Other tenants and jobs without one aren't held back
+	_, err = enqueuer.Enqueue("wat", Q{"tenant": "globex"})
+	assert.NoError(t, err)
+	_, err = enqueuer.Enqueue("wat", nil)
+	assert.NoError(t, err)
+
+	// Halfway through the next minute, half of the last minute's jobs still count
+	setNowEpochSecondsMock(1425263490)
+	for i := 0; i < 2; i++ {
+		_, err := enqueuer.Enqueue("wat", Q{"tenant": "acme"})
+		assert.NoError(t, err)
+	}
+	_, err = enqueuer.Enqueue("wat", Q{"tenant": "acme"})
+	assert.Equal(t, &QuotaExceededError{Tenant: "acme", Limit: perMinute}, err)
+
+	// The day's limit holds once the minute has rolled past
+	setNowEpochSecondsMock(1425263600)
+	_, err = enqueuer.Enqueue("wat", Q{"tenant": "acme"})
+	assert.Equal(t, &QuotaExceededError{Tenant: "acme", Limit: perDay}, err)
+
+	assert.EqualValues(t, 7, listSize(pool, redisKeyJobs(ns, "wat")))
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 )
 
 func redisNamespacePrefix(namespace string) string {
@@ -40,6 +42,25 @@ func redisKeyDead(namespace string) string {
 	return redisNamespacePrefix(namespace) + "dead"
 }
 
+// The retry and dead queues of a job type with JobOptions.OwnFailureQueues
+func redisKeyRetryOf(namespace, jobName string) string {
+	return redisKeyRetry(namespace) + ":" + jobName
+}
+
+func redisKeyDeadOf(namespace, jobName string) string {
+	return redisKeyDead(namespace) + ":" + jobName
+}
+
+// The retry queue of the job types with JobOptions.ExpressRetries
+func redisKeyRetryExpress(namespace string) string {
+	return redisNamespacePrefix(namespace) + "retry_express"
+}
+
+// The set of job types with JobOptions.OwnFailureQueues
+func redisKeyOwnFailureQueues(namespace string) string {
+	return redisNamespacePrefix(namespace) + "own_failure_queues"
+}
+
 func redisKeyScheduled(namespace string) string {
 	return redisNamespacePrefix(namespace) + "scheduled"
 }
@@ -72,8 +93,16 @@ func redisKeyJobsConcurrency(namespace, jobName string) string {
 	return redisKeyJobs(namespace, jobName) + ":max_concurrency"
 }
 
+// bufPool holds buffers for building unique job keys, which are copied out before the buffer is returned. Decoding
+// jobs doesn't use it: what a decode allocates is the job's fields and Args, which the job keeps.
+var bufPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
 func redisKeyUniqueJob(namespace, jobName string, args map[string]interface{}) (string, error) {
-	var buf bytes.Buffer
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufPool.Put(buf)
 
 	buf.WriteString(redisNamespacePrefix(namespace))
 	buf.WriteString("unique:")
@@ -81,7 +110,7 @@ func redisKeyUniqueJob(namespace, jobName string, args map[string]interface{}) (
 	buf.WriteRune(':')
 
 	if args != nil {
-		err := json.NewEncoder(&buf).Encode(args)
+		err := json.NewEncoder(buf).Encode(args)
 		if err != nil {
 			return "", err
 		}
@@ -94,15 +123,82 @@ func redisKeyLastPeriodicEnqueue(namespace string) string {
 	return redisNamespacePrefix(namespace) + "last_periodic_enqueue"
 }
 
+func redisKeyPeriodicLastRuns(namespace string) string {
+	return redisNamespacePrefix(namespace) + "periodic_last_runs"
+}
+
+func redisKeyAckFailures(namespace string) string {
+	return redisNamespacePrefix(namespace) + "ack_failures"
+}
+
+func redisKeyStandby(namespace string) string {
+	return redisNamespacePrefix(namespace) + "standby"
+}
+
+func redisKeyLeader(namespace, group string) string {
+	return redisNamespacePrefix(namespace) + "leader:" + group
+}
+
+func redisKeyConfig(namespace string) string {
+	return redisNamespacePrefix(namespace) + "config"
+}
+
+func redisKeyTap(namespace string) string {
+	return redisNamespacePrefix(namespace) + "tap"
+}
+
+func redisKeyAuditLog(namespace string) string {
+	return redisNamespacePrefix(namespace) + "audit_log"
+}
+
+// The pub/sub channel on which enqueuers announce the job names they enqueue, see Enqueuer.SetWakeWorkers
+func redisKeyWake(namespace string) string {
+	return redisNamespacePrefix(namespace) + "wake"
+}
+
+func redisKeyRestartTurns(namespace string) string {
+	return redisNamespacePrefix(namespace) + "restart_turns"
+}
+
+func redisKeyShedCounts(namespace string) string {
+	return redisNamespacePrefix(namespace) + "shed_counts"
+}
+
+func redisKeyCheckpoint(namespace, jobID string) string {
+	return redisNamespacePrefix(namespace) + "checkpoint:" + jobID
+}
+
+func redisKeySemaphore(namespace, name string) string {
+	return redisNamespacePrefix(namespace) + "semaphore:" + name
+}
+
+func redisKeyIdempotent(namespace, key string) string {
+	return redisNamespacePrefix(namespace) + "done:" + key
+}
+
+// The counter of the jobs a tenant enqueued in one window of an enqueue quota, see Enqueuer.AddQuota
+func redisKeyQuota(namespace, tenant string, window, bucket int64) string {
+	return fmt.Sprintf("%squota:%s:%d:%d", redisNamespacePrefix(namespace), tenant, window, bucket)
+}
+
+// The list that holds a job type's jobs while Client.MigrateNamespace moves them out of its queue
+func redisKeyMigrating(namespace, jobName string) string {
+	return redisNamespacePrefix(namespace) + "migrating:" + jobName
+}
+
+// The marker zset that MigrateNamespace holds the jobs of zsetKey, eg work:retry, in while it moves them
+func redisKeyMigratingZset(namespace, zsetKey string) string {
+	return redisNamespacePrefix(namespace) + "migrating_zset:" + strings.TrimPrefix(zsetKey, redisNamespacePrefix(namespace))
+}
+
 // Used to fetch the next job to run
 //
-// KEYS[1] = the 1st job queue we want to try, eg, "work:jobs:emails"
-// KEYS[2] = the 1st job queue's in prog queue, eg, "work:jobs:emails:97c84119d13cb54119a38743:inprogress"
-// KEYS[3] = the 2nd job queue...
-// KEYS[4] = the 2nd job queue's in prog queue...
+// KEYS[1] = the standby flag. Nothing is fetched while it's set.
+// KEYS[2] = the 1st job queue we want to try, eg, "work:jobs:emails"
+// KEYS[3] = the 1st job queue's in prog queue, eg, "work:jobs:emails:97c84119d13cb54119a38743:inprogress"
+// KEYS[4-7] = the 1st job queue's pause, lock, lock info and max concurrency keys
+// KEYS[8] = the 2nd job queue...
 // ...
-// KEYS[N] = the last job queue...
-// KEYS[N+1] = the last job queue's in prog queue...
 // ARGV[1] = job queue's workerPoolID
 var redisLuaFetchJob = fmt.Sprintf(`
 local function acquireLock(lockKey, lockInfoKey, workerPoolID)
@@ -135,7 +231,11 @@ local res, jobQueue, inProgQueue, pauseKey, lockKey, maxConcurrency, workerPoolI
 local keylen = #KEYS
 workerPoolID = ARGV[1]
 
-for i=1,keylen,%d do
+if redis.call('get', KEYS[1]) then
+  return nil
+end
+
+for i=2,keylen,%d do
   jobQueue = KEYS[i]
   inProgQueue = KEYS[i+1]
   pauseKey = KEYS[i+2]
@@ -153,6 +253,36 @@ for i=1,keylen,%d do
 end
 return nil`, fetchKeysPerJobType)
 
+// Lua functions for the scripts that add to a job's History. recordJobEvent adds an event to a decoded job. So as not
+// to re-encode jobs that have no history yet, recordRawJobEvent adds the history to their JSON as is; jobs that have
+// one have already been through cjson on their way back from the retry or dead queue.
+var redisLuaJobEventFuncs = fmt.Sprintf(`
+local function recordJobEvent(j, event, at)
+  if not j['history'] then
+    j['history'] = {}
+  end
+  table.insert(j['history'], {event = event, at = tonumber(at)})
+  if #j['history'] > %d then
+    table.remove(j['history'], 1)
+  end
+end
+
+local function recordRawJobEvent(raw, event, at)
+  if #raw < 3 or string.sub(raw, 1, 1) ~= '{' or string.sub(raw, -1) ~= '}' then
+    return raw
+  end
+  if not string.find(raw, '"history":[', 1, true) then
+    return string.sub(raw, 1, -2) .. ',"history":[{"event":"' .. event .. '","at":' .. at .. '}]}'
+  end
+  local ok, j = pcall(cjson.decode, raw)
+  if not ok then
+    return raw
+  end
+  recordJobEvent(j, event, at)
+  return cjson.encode(j)
+end
+`, jobHistoryMaxLen)
+
 // Used by the reaper to re-enqueue jobs that were in progress
 //
 // KEYS[1] = the 1st job's in progress queue
@@ -163,7 +293,8 @@ return nil`, fetchKeysPerJobType)
 // KEYS[N] = the last job's in progress queue
 // KEYS[N+1] = the last job's job queue
 // ARGV[1] = workerPoolID for job queue
-var redisLuaReenqueueJob = fmt.Sprintf(`
+// ARGV[2] = the current time in epoch seconds, for the jobs' history
+var redisLuaReenqueueJob = redisLuaJobEventFuncs + fmt.Sprintf(`
 local function releaseLock(lockKey, lockInfoKey, workerPoolID)
   redis.call('decr', lockKey)
   redis.call('hincrby', lockInfoKey, workerPoolID, -1)
@@ -178,14 +309,291 @@ for i=1,keylen,%d do
   jobQueue = KEYS[i+1]
   lockKey = KEYS[i+2]
   lockInfoKey = KEYS[i+3]
-  res = redis.call('rpoplpush', inProgQueue, jobQueue)
+  res = redis.call('rpop', inProgQueue)
   if res then
+    redis.call('lpush', jobQueue, recordRawJobEvent(res, 'orphaned', ARGV[2]))
     releaseLock(lockKey, lockInfoKey, workerPoolID)
     return {res, inProgQueue, jobQueue}
   end
 end
 return nil`, requeueKeysPerJob)
 
+// Used by workers to acknowledge the jobs they're done with. Safe to run more than once for the same job: a job's lock
+// is only released, the job only moved along and its follow-ups only enqueued if it was still in its in progress queue.
+//
+// KEYS[1] = the 1st job's in progress queue
+// KEYS[2] = the 1st job's lock
+// KEYS[3] = the 1st job's lock info hash
+// KEYS[4] = the retry or dead zset to add the 1st job to, if any
+// KEYS[5] = the 2nd job's in progress queue
+// ...
+// ARGV[1] = workerPoolID
+// ARGV[2] = the known jobs set
+// ARGV[3] = job queue prefix, eg, "work:jobs:"
+// ARGV[4] = the 1st job as it was fetched
+// ARGV[5] = score of the 1st job in KEYS[4]
+// ARGV[6] = the 1st job to add to KEYS[4], or an empty string to add nothing
+// ARGV[7] = once it's added, delete the jobs in KEYS[4] with a score below this, or 0 to delete none
+// ARGV[8] = and keep only this many of the most recent jobs in KEYS[4], or 0 to keep them all
+// ARGV[9] = the number of follow-ups of the 1st job, N
+// ARGV[10] = the name of its 1st follow-up
+// ARGV[11] = its 1st follow-up
+// ...
+// ARGV[10+2N] = the 2nd job as it was fetched
+// ...
+var redisLuaAckJobs = fmt.Sprintf(`
+local keylen = #KEYS
+local workerPoolID = ARGV[1]
+local acked = 0
+local a = 4
+
+for i=1,keylen,%d do
+  local followUps = tonumber(ARGV[a+5])
+  if redis.call('lrem', KEYS[i], 1, ARGV[a]) > 0 then
+    redis.call('decr', KEYS[i+1])
+    redis.call('hincrby', KEYS[i+2], workerPoolID, -1)
+    if ARGV[a+2] ~= '' then
+      redis.call('zadd', KEYS[i+3], ARGV[a+1], ARGV[a+2])
+      if ARGV[a+3] ~= '0' then
+        redis.call('zremrangebyscore', KEYS[i+3], '-inf', '(' .. ARGV[a+3])
+      end
+      local maxKept = tonumber(ARGV[a+4])
+      if maxKept > 0 then
+        redis.call('zremrangebyrank', KEYS[i+3], 0, -maxKept-1)
+      end
+    end
+    for f=a+%d,a+%d+2*followUps-1,2 do
+      redis.call('lpush', ARGV[3] .. ARGV[f], ARGV[f+1])
+      redis.call('sadd', ARGV[2], ARGV[f])
+    end
+    acked = acked + 1
+  end
+  a = a + %d + 2*followUps
+end
+return acked`, ackKeysPerJob, ackArgsPerJob, ackArgsPerJob, ackArgsPerJob)
+
+// Used to fetch more jobs of a batched job type once the first one is fetched. Stops early if the queue runs dry, is
+// paused, or the job type's max concurrency is reached.
+//
+// KEYS[1] = the job queue, eg, "work:jobs:emails"
+// KEYS[2] = the job queue's in prog queue
+// KEYS[3] = the job queue's pause key
+// KEYS[4] = the job's lock
+// KEYS[5] = the job's lock info hash
+// KEYS[6] = the job's max concurrency key
+// ARGV[1] = workerPoolID
+// ARGV[2] = the max number of jobs to fetch
+var redisLuaFetchJobBatch = `
+local jobs = {}
+if redis.call('get', KEYS[3]) then
+  return jobs
+end
+
+local maxConcurrency = tonumber(redis.call('get', KEYS[6]))
+local activeJobs, res
+for i=1,tonumber(ARGV[2]) do
+  if maxConcurrency and maxConcurrency > 0 then
+    activeJobs = tonumber(redis.call('get', KEYS[4]))
+    if activeJobs and activeJobs >= maxConcurrency then
+      break
+    end
+  end
+  res = redis.call('rpoplpush', KEYS[1], KEYS[2])
+  if not res then
+    break
+  end
+  redis.call('incr', KEYS[4])
+  redis.call('hincrby', KEYS[5], ARGV[1], 1)
+  jobs[i] = res
+end
+return jobs
+`
+
+// Used by enqueuers to count a job against a tenant's enqueue quotas, unless that would exceed one of them. Each limit
+// has a counter for the current window and one for the previous; the previous one's count is weighted by how much of
+// it still overlaps the rolling window.
+//
+// KEYS[1] = the 1st limit's counter for the current window
+// KEYS[2] = the 1st limit's counter for the previous window
+// KEYS[3] = the 2nd limit's counter for the current window...
+// ARGV[1] = the 1st limit's max number of jobs
+// ARGV[2] = the 1st limit's window, in seconds
+// ARGV[3] = how far into the current window it is, in seconds
+// ARGV[4] = the 2nd limit's max number of jobs...
+// Returns 0 if the job was counted, or else the 1-based index of the limit it would exceed.
+var redisLuaTakeQuota = `
+local n = #KEYS / 2
+for i = 1, n do
+  local curr = tonumber(redis.call('get', KEYS[2*i-1]) or '0')
+  local prev = tonumber(redis.call('get', KEYS[2*i]) or '0')
+  local window = tonumber(ARGV[3*i-1])
+  local elapsed = tonumber(ARGV[3*i])
+  if curr + prev * (window - elapsed) / window >= tonumber(ARGV[3*i-2]) then
+    return i
+  end
+end
+for i = 1, n do
+  redis.call('incr', KEYS[2*i-1])
+  redis.call('expire', KEYS[2*i-1], 2 * tonumber(ARGV[3*i-1]))
+end
+return 0
+`
+
+// Used by config watchers to drop a pause from the config once its pause key has expired, see Client.PauseFor. A pause
+// set again in the meantime is left alone.
+//
+// KEYS[1] = the config hash
+// KEYS[2] = the job type's pause key
+// ARGV[1] = the job type's pause field in the config
+// ARGV[2] = the field's value when the config was read
+var redisLuaLapsePause = `
+if redis.call('exists', KEYS[2]) == 0 and redis.call('hget', KEYS[1], ARGV[1]) == ARGV[2] then
+  redis.call('hdel', KEYS[1], ARGV[1])
+  return 1
+end
+return 0
+`
+
+// Used by pools to take or renew their turn in a rolling restart. Turns that have expired are dropped first.
+//
+// KEYS[1] = the restart turns zset
+// ARGV[1] = workerPoolID
+// ARGV[2] = the current time in epoch milliseconds
+// ARGV[3] = the max number of turns at once
+// ARGV[4] = when the turn expires, in epoch milliseconds
+var redisLuaTakeRestartTurn = `
+redis.call('zremrangebyscore', KEYS[1], '-inf', ARGV[2])
+if redis.call('zscore', KEYS[1], ARGV[1]) or redis.call('zcard', KEYS[1]) < tonumber(ARGV[3]) then
+  redis.call('zadd', KEYS[1], ARGV[4], ARGV[1])
+  return 1
+end
+return 0
+`
+
+// Used by periodic enqueuers to record the last run they enqueued of each periodic job. Records only move forward, so
+// an enqueuer running late can't make runs that already happened look missed.
+//
+// KEYS[1] = the periodic last runs hash
+// ARGV[1] = the 1st periodic job's field
+// ARGV[2] = the 1st periodic job's last run, in epoch seconds
+// ARGV[3] = the 2nd periodic job's field
+// ...
+var redisLuaSetPeriodicLastRuns = `
+for i=1,#ARGV,2 do
+  local last = tonumber(redis.call('hget', KEYS[1], ARGV[i]))
+  if not last or last < tonumber(ARGV[i+1]) then
+    redis.call('hset', KEYS[1], ARGV[i], ARGV[i+1])
+  end
+end
+return nil
+`
+
+// Used by MigrateNamespace to take the first jobs off a zset in one step, holding them in a marker zset while they're
+// moved.
+//
+// KEYS[1] = the zset, eg work:retry
+// KEYS[2] = the marker zset
+// ARGV[1] = how many jobs to take
+// Returns how many jobs were taken.
+var redisLuaZsetToMarker = `
+local res = redis.call('zrange', KEYS[1], 0, tonumber(ARGV[1]) - 1, 'WITHSCORES')
+for i = 1, #res, 2 do
+  redis.call('zadd', KEYS[2], res[i + 1], res[i])
+end
+if #res > 0 then
+  redis.call('zremrangebyrank', KEYS[1], 0, #res / 2 - 1)
+end
+return #res / 2
+`
+
+// Used by Client.WaitForEmpty to check that no jobs of a type are left to process, a page of a shared retry queue at a
+// time. Jobs are counted as in progress by their lock, which fetching increments and acknowledging decrements.
+//
+// KEYS[1] = the job queue
+// KEYS[2] = the job's lock
+// KEYS[3] = the job's own retry queue
+// KEYS[4] = the shared retry queue to look for jobs of the type in, eg work:retry
+// ARGV[1] = the job's name
+// ARGV[2] = where in KEYS[4] to look from
+// ARGV[3] = a job of the type found in KEYS[4] before, or empty; if it's still there, there's no need to look further
+// Returns {0, the job found in KEYS[4], if any} if there are jobs left, {1} if there are none, or {2, ARGV[2] for the
+// next page} if there may be more in KEYS[4].
+var redisLuaIsQueueEmpty = fmt.Sprintf(`
+if redis.call('llen', KEYS[1]) > 0 or (tonumber(redis.call('get', KEYS[2])) or 0) > 0 or redis.call('zcard', KEYS[3]) > 0 then
+  return {0}
+end
+if ARGV[3] ~= '' and redis.call('zscore', KEYS[4], ARGV[3]) then
+  return {0, ARGV[3]}
+end
+local start = tonumber(ARGV[2])
+local jobs = redis.call('zrange', KEYS[4], start, start + %d - 1)
+for _, job in ipairs(jobs) do
+  if cjson.decode(job)['name'] == ARGV[1] then
+    return {0, job}
+  end
+end
+if #jobs < %d then
+  return {1}
+end
+return {2, start + %d}`, requeueScanSize, requeueScanSize, requeueScanSize)
+
+// Used by the leader to extend its lease, if it still holds it
+//
+// KEYS[1] = the leader key
+// ARGV[1] = workerPoolID of the leader
+// ARGV[2] = lease TTL in milliseconds
+var redisLuaRenewLease = `
+if redis.call('get', KEYS[1]) == ARGV[1] then
+  redis.call('pexpire', KEYS[1], ARGV[2])
+  return 1
+end
+return 0
+`
+
+// Used by the leader to give up its lease, if it still holds it
+//
+// KEYS[1] = the leader key
+// ARGV[1] = workerPoolID of the leader
+var redisLuaReleaseLease = `
+if redis.call('get', KEYS[1]) == ARGV[1] then
+  return redis.call('del', KEYS[1])
+end
+return 0
+`
+
+// Used by handlers to take a slot of a semaphore, if one is free. Holders whose lease ran out, eg because their
+// process died, are dropped first.
+//
+// KEYS[1] = the semaphore's zset of holders, scored by when their lease runs out
+// ARGV[1] = the holder's token
+// ARGV[2] = the semaphore's limit
+// ARGV[3] = now, in milliseconds
+// ARGV[4] = lease TTL in milliseconds
+var redisLuaAcquireSemaphore = `
+redis.call('zremrangebyscore', KEYS[1], '-inf', ARGV[3])
+if redis.call('zcard', KEYS[1]) >= tonumber(ARGV[2]) then
+  return 0
+end
+redis.call('zadd', KEYS[1], tonumber(ARGV[3]) + tonumber(ARGV[4]), ARGV[1])
+redis.call('pexpire', KEYS[1], ARGV[4])
+return 1
+`
+
+// Used by handlers to extend the lease on their slot of a semaphore, if they still hold it
+//
+// KEYS[1] = the semaphore's zset of holders
+// ARGV[1] = the holder's token
+// ARGV[2] = now, in milliseconds
+// ARGV[3] = lease TTL in milliseconds
+var redisLuaRenewSemaphore = `
+if not redis.call('zscore', KEYS[1], ARGV[1]) then
+  return 0
+end
+redis.call('zadd', KEYS[1], tonumber(ARGV[2]) + tonumber(ARGV[3]), ARGV[1])
+redis.call('pexpire', KEYS[1], ARGV[3])
+return 1
+`
+
 // Used by the reaper to clean up stale locks
 //
 // KEYS[1] = the 1st job's lock
@@ -223,27 +631,73 @@ return nil
 // KEYS[3...] = known job queues, eg ["work:jobs:create_watch", "work:jobs:send_email", ...]
 // ARGV[1] = jobs prefix, eg, "work:jobs:". We'll take that and append the job name from the JSON object in order to queue up a job
 // ARGV[2] = current time in epoch seconds
-var redisLuaZremLpushCmd = `
-local res, j, queue
-res = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[2], 'LIMIT', 0, 1)
-if #res > 0 then
-  j = cjson.decode(res[1])
-  redis.call('zrem', KEYS[1], res[1])
-  queue = ARGV[1] .. j['name']
-  for _,v in pairs(KEYS) do
-    if v == queue then
-      j['t'] = tonumber(ARGV[2])
-      redis.call('lpush', queue, cjson.encode(j))
-      return 'ok'
+// ARGV[3...] = names of the job types whose jobs are held back, see Gate
+// Due jobs that are held back are moved out of the way, to their score plus heldScoreOffset, so that they're only
+// looked at once while their gate stays closed; redisLuaReleaseHeld moves them back.
+// Returns nil once there are no more due jobs, or else 'ok' (a job was requeued), 'dead' (a job of an unknown type was
+// put on the dead queue) or 'held' (the due jobs looked at were all held back, and moved out of the way).
+var redisLuaZremLpushCmd = redisLuaJobEventFuncs + fmt.Sprintf(`
+local held = {}
+for i = 3, #ARGV do
+  held[ARGV[i]] = true
+end
+local res = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[2], 'WITHSCORES', 'LIMIT', 0, %d)
+if #res == 0 then
+  return nil
+end
+for i = 1, #res, 2 do
+  local raw = res[i]
+  local j = cjson.decode(raw)
+  if held[j['name']] then
+    redis.call('zadd', KEYS[1], tonumber(res[i + 1]) + %d, raw)
+  else
+    redis.call('zrem', KEYS[1], raw)
+    local queue = ARGV[1] .. j['name']
+    for _,v in pairs(KEYS) do
+      if v == queue then
+        j['t'] = tonumber(ARGV[2])
+        if j['history'] then -- only failed jobs, not scheduled ones, have history
+          recordJobEvent(j, 'retried', ARGV[2])
+        end
+        redis.call('lpush', queue, cjson.encode(j))
+        return 'ok'
+      end
     end
+    j['err'] = 'unknown job when requeueing'
+    j['failed_at'] = tonumber(ARGV[2])
+    redis.call('zadd', KEYS[2], ARGV[2], cjson.encode(j))
+    return 'dead' -- put on dead queue
   end
-  j['err'] = 'unknown job when requeueing'
-  j['failed_at'] = tonumber(ARGV[2])
-  redis.call('zadd', KEYS[2], ARGV[2], cjson.encode(j))
-  return 'dead' -- put on dead queue
 end
-return nil
-`
+return 'held'
+`, requeueScanSize, heldScoreOffset)
+
+// Used by requeuers to move the jobs redisLuaZremLpushCmd held back to their own scores once their gate is open again.
+//
+// KEYS[1] = zset of jobs (retry or scheduled), eg work:retry
+// ARGV[1] = how many held back jobs to pass over, since they're still held back; 0 on the first call
+// ARGV[2...] = names of the job types whose jobs are still held back
+// Returns nil once done, or else ARGV[1] for the next call.
+var redisLuaReleaseHeld = fmt.Sprintf(`
+local offset = tonumber(ARGV[1])
+local held = {}
+for i = 2, #ARGV do
+  held[ARGV[i]] = true
+end
+local res = redis.call('zrangebyscore', KEYS[1], %d, '+inf', 'WITHSCORES', 'LIMIT', offset, %d)
+for i = 1, #res, 2 do
+  local j = cjson.decode(res[i])
+  if held[j['name']] then
+    offset = offset + 1
+  else
+    redis.call('zadd', KEYS[1], tonumber(res[i + 1]) - %d, res[i])
+  end
+end
+if #res < %d then
+  return nil
+end
+return offset
+`, heldScoreOffset, requeueScanSize, heldScoreOffset, 2*requeueScanSize)
 
 // KEYS[1] = zset of (dead|scheduled|retry), eg, work:dead
 // ARGV[1] = died at. The z rank of the job.
@@ -275,7 +729,7 @@ return {deletedCount, jobBytes}
 // ARGV[3] = died at. The z rank of the job.
 // ARGV[4] = job ID to requeue
 // Returns: number of jobs requeued (typically 1 or 0)
-var redisLuaRequeueSingleDeadCmd = `
+var redisLuaRequeueSingleDeadCmd = redisLuaJobEventFuncs + `
 local jobs, i, j, queue, found, requeuedCount
 jobs = redis.call('zrangebyscore', KEYS[1], ARGV[3], ARGV[3])
 local jobCount = #jobs
@@ -292,6 +746,7 @@ for i=1,jobCount do
         j['fails'] = nil
         j['failed_at'] = nil
         j['err'] = nil
+        recordJobEvent(j, 'revived', ARGV[2])
         redis.call('lpush', queue, cjson.encode(j))
         requeuedCount = requeuedCount + 1
         found = true
@@ -314,7 +769,7 @@ return requeuedCount
 // ARGV[2] = current time in epoch seconds
 // ARGV[3] = max number of jobs to requeue
 // Returns: number of jobs requeued
-var redisLuaRequeueAllDeadCmd = `
+var redisLuaRequeueAllDeadCmd = redisLuaJobEventFuncs + `
 local jobs, i, j, queue, found, requeuedCount
 jobs = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[2], 'LIMIT', 0, ARGV[3])
 local jobCount = #jobs
@@ -330,6 +785,7 @@ for i=1,jobCount do
       j['fails'] = nil
       j['failed_at'] = nil
       j['err'] = nil
+      recordJobEvent(j, 'revived', ARGV[2])
       redis.call('lpush', queue, cjson.encode(j))
       requeuedCount = requeuedCount + 1
       found = true
@@ -345,6 +801,35 @@ end
 return requeuedCount
 `
 
+// Used by replicated enqueuers to keep a standby's queue to the most recently mirrored jobs. The unique keys of the
+// jobs trimmed are deleted, so they don't refuse enqueues once the standby is promoted.
+//
+// KEYS[1] = job queue (a list) or the scheduled queue (a zset), eg work:jobs:send_email
+// ARGV[1] = how many jobs to keep
+// ARGV[2] = 'zset' if KEYS[1] is the scheduled queue, whose jobs that are due soonest are trimmed
+var redisLuaTrimMirrored = `
+local keep = tonumber(ARGV[1])
+local trimmed
+if ARGV[2] == 'zset' then
+  local over = redis.call('zcard', KEYS[1]) - keep
+  if over <= 0 then
+    return 0
+  end
+  trimmed = redis.call('zrange', KEYS[1], 0, over - 1)
+  redis.call('zremrangebyrank', KEYS[1], 0, over - 1)
+else
+  trimmed = redis.call('lrange', KEYS[1], keep, -1)
+  redis.call('ltrim', KEYS[1], 0, keep - 1)
+end
+for _, raw in ipairs(trimmed) do
+  local j = cjson.decode(raw)
+  if j['unique_key'] then
+    redis.call('del', j['unique_key'])
+  end
+end
+return #trimmed
+`
+
 // KEYS[1] = job queue to push onto
 // KEYS[2] = Unique job's key. Test for existence and set if we push.
 // ARGV[1] = job
@@ -373,3 +858,53 @@ else
 end
 return 'dup'
 `
+
+// Used by worker pools to delete dead jobs that are past their retention: their own, for jobs whose type has
+// JobOptions.DeadRetention, or else the namespace's. The number of jobs looked at per call is capped, and the next
+// call picks up where this one left off, so that jobs kept for longer than the rest, eg payments kept for a year,
+// don't stand in the way of the jobs behind them. The cursor is the score the call left off at, and how many of the
+// jobs with that score it kept, which come first at that score.
+//
+// KEYS[1] = dead queue
+// ARGV[1] = now, in epoch seconds
+// ARGV[2] = the namespace's retention in seconds, or 0 to keep dead jobs without a retention of their own forever
+// ARGV[3] = only jobs that died before this, in epoch seconds, can be past their retention
+// ARGV[4] = max number of jobs to look at
+// ARGV[5] = the cursor's score, or -inf to start over
+// ARGV[6] = the number of jobs kept at the cursor's score
+// Returns {the number of jobs deleted, the next cursor's score, the next cursor's number of jobs}. Once all the jobs
+// were looked at, the next cursor starts over.
+var redisLuaTrimDead = `
+local now = tonumber(ARGV[1])
+local defaultRetention = tonumber(ARGV[2])
+local maxScanned = tonumber(ARGV[4])
+local cursor = ARGV[5]
+local kept = tonumber(ARGV[6])
+local scanned = 0
+local deleted = 0
+while scanned < maxScanned do
+  local res = redis.call('zrangebyscore', KEYS[1], cursor, '(' .. ARGV[3], 'WITHSCORES', 'LIMIT', kept, math.min(100, maxScanned - scanned))
+  if #res == 0 then
+    return {deleted, '-inf', 0}
+  end
+  for i = 1, #res, 2 do
+    local ok, j = pcall(cjson.decode, res[i])
+    local retention = defaultRetention
+    if ok and type(j['dead_retention']) == 'number' then
+      retention = j['dead_retention']
+    end
+    if res[i+1] ~= cursor then
+      cursor = res[i+1]
+      kept = 0
+    end
+    if ok and retention > 0 and tonumber(res[i+1]) + retention <= now then
+      redis.call('zrem', KEYS[1], res[i])
+      deleted = deleted + 1
+    else
+      kept = kept + 1
+    end
+    scanned = scanned + 1
+  end
+end
+return {deleted, cursor, kept}
+`
@@ -0,0 +1,121 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrRedisTimeout is wrapped by the errors returned from internal Redis commands that didn't complete within the
+// configured WorkerPoolOptions.RedisTimeout. Use errors.Is(err, ErrRedisTimeout) in an ErrorHook to detect them.
+var ErrRedisTimeout = errors.New("redis command timed out")
+
+// timeoutConn bounds every command it runs by timeout, overriding the read timeout the connection was dialed with.
+// Pipelined commands are bounded too: the writes of Send and Flush, and the reads of Receive.
+type timeoutConn struct {
+	redis.Conn
+	timeout time.Duration
+
+	// Set once a write timed out. The connection is left to the write, which closes it once it returns, and every
+	// later call gets this error.
+	stalled error
+}
+
+// getConn gets a connection from pool. If timeout is positive, both waiting for the connection and each command run
+// on it are bounded by timeout so that a hung Redis can't block the caller indefinitely.
+func getConn(pool *redis.Pool, timeout time.Duration) redis.Conn {
+	if timeout <= 0 {
+		return pool.Get()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// On error, GetContext returns a conn that returns the error from every command.
+	conn, _ := pool.GetContext(ctx)
+	return &timeoutConn{Conn: conn, timeout: timeout}
+}
+
+func (c *timeoutConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if c.stalled != nil {
+		return nil, c.stalled
+	}
+	reply, err := redis.DoWithTimeout(c.Conn, c.timeout, commandName, args...)
+	return reply, wrapRedisTimeout(commandName, err)
+}
+
+func (c *timeoutConn) Send(commandName string, args ...interface{}) error {
+	return c.write(commandName, func() error { return c.Conn.Send(commandName, args...) })
+}
+
+func (c *timeoutConn) Flush() error {
+	return c.write("flush", c.Conn.Flush)
+}
+
+func (c *timeoutConn) Receive() (interface{}, error) {
+	if c.stalled != nil {
+		return nil, c.stalled
+	}
+	reply, err := redis.ReceiveWithTimeout(c.Conn, c.timeout)
+	return reply, wrapRedisTimeout("receive", err)
+}
+
+func (c *timeoutConn) Err() error {
+	if c.stalled != nil {
+		return c.stalled
+	}
+	return c.Conn.Err()
+}
+
+func (c *timeoutConn) Close() error {
+	if c.stalled != nil {
+		return nil
+	}
+	return c.Conn.Close()
+}
+
+// write runs f, which writes to the connection, bounded by timeout. Send only writes once its buffer is full, but
+// Flush always does, and a Redis that stopped reading blocks it for as long as the connection's write timeout, if it
+// was dialed with one. Redigo has no write deadline per call, so if the timeout is up first, f is left running and
+// the connection is closed once it returns.
+func (c *timeoutConn) write(commandName string, f func() error) error {
+	if c.stalled != nil {
+		return c.stalled
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return wrapRedisTimeout(commandName, err)
+	case <-timer.C:
+		c.stalled = fmt.Errorf("%w: %s: write stalled", ErrRedisTimeout, commandName)
+		go func() {
+			<-done
+			c.Conn.Close()
+		}()
+		return c.stalled
+	}
+}
+
+// wrapRedisTimeout wraps err with ErrRedisTimeout if it was caused by a timeout. Other errors are returned unchanged
+// so that callers can still compare against redis.ErrNil and friends.
+func wrapRedisTimeout(commandName string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var netErr net.Error
+	if err == context.DeadlineExceeded || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %s: %v", ErrRedisTimeout, commandName, err)
+	}
+
+	return err
+}
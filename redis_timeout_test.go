@@ -0,0 +1,115 @@
+package work
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetConnTimeout(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	conn := getConn(pool, 50*time.Millisecond)
+	defer conn.Close()
+
+	// Normal commands and their errors pass through untouched
+	_, err := redis.String(conn.Do("GET", ns+":nothing"))
+	assert.Equal(t, redis.ErrNil, err)
+
+	// A command that blocks server side longer than the timeout errors out
+	start := time.Now()
+	_, err = conn.Do("BLPOP", ns+":nothing", 2)
+	assert.True(t, errors.Is(err, ErrRedisTimeout))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestGetConnTimeoutPoolExhausted(t *testing.T) {
+	pool := newTestPool(":6379")
+	pool.MaxActive = 1
+	pool.Wait = true
+
+	held := pool.Get()
+	defer held.Close()
+
+	conn := getConn(pool, 20*time.Millisecond)
+	defer conn.Close()
+
+	_, err := conn.Do("PING")
+	assert.True(t, errors.Is(err, ErrRedisTimeout))
+}
+
+func TestGetConnTimeoutFlush(t *testing.T) {
+	// A Redis that accepts connections but never reads from them, so writes stall once the socket buffers are full
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			accepted <- c
+		}
+	}()
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", ln.Addr().String()) }}
+	defer pool.Close()
+
+	conn := getConn(pool, 50*time.Millisecond)
+	defer conn.Close()
+
+	start := time.Now()
+	big := make([]byte, 1<<20)
+	var sendErr error
+	for i := 0; i < 64 && sendErr == nil; i++ {
+		sendErr = conn.Send("SET", "big", big)
+	}
+	if sendErr == nil {
+		sendErr = conn.Flush()
+	}
+	assert.True(t, errors.Is(sendErr, ErrRedisTimeout))
+	assert.True(t, time.Since(start) < time.Second)
+
+	// The connection is given up on
+	_, err = conn.Do("PING")
+	assert.True(t, errors.Is(err, ErrRedisTimeout))
+	assert.True(t, errors.Is(conn.Err(), ErrRedisTimeout))
+	(<-accepted).Close()
+}
+
+func TestGetConnNoTimeout(t *testing.T) {
+	pool := newTestPool(":6379")
+
+	conn := getConn(pool, 0)
+	defer conn.Close()
+
+	_, ok := conn.(*timeoutConn)
+	assert.False(t, ok)
+}
+
+func TestWorkerPoolErrorHook(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	deleteQueue(pool, ns, job1)
+	deleteRetryAndDead(pool, ns)
+
+	var keys []string
+	wp := NewWorkerPoolWithOptions(TestContext{}, 1, ns, pool, WorkerPoolOptions{
+		ErrorHook: func(key string, err error) {
+			keys = append(keys, key)
+		},
+	})
+	w := wp.workers[0]
+
+	// Acknowledging a job that can't be serialized for the retry queue goes through the hook
+	job := &Job{Name: job1, ID: "1", Args: Q{"bad": make(chan int)}}
+	terminateAndRetry(w, &jobType{Name: job1}, job)
+
+	assert.Equal(t, []string{"worker.terminate_and_retry.serialize"}, keys)
+}
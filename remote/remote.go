@@ -0,0 +1,106 @@
+// Package remote runs jobs in a handler service over RPC, so that handlers can be written in other languages while
+// work, in Go, fetches, retries and acknowledges the jobs. The contract is the JobHandler service in handler.proto:
+// generate a gRPC client for it, adapt it to Client, and register job types with an Executor:
+//
+//	type grpcClient struct{ c pb.JobHandlerClient }
+//
+//	func (g grpcClient) Handle(ctx context.Context, job *remote.Job) (*remote.Outcome, error) {
+//	    out, err := g.c.Handle(ctx, &pb.Job{Name: job.Name, Id: job.ID, ArgsJson: job.ArgsJSON, ...})
+//	    if err != nil {
+//	        return nil, err
+//	    }
+//	    return &remote.Outcome{Kind: remote.OutcomeKind(out.Kind), Reason: out.Reason, RetryDelaySeconds: out.RetryDelaySeconds}, nil
+//	}
+//
+//	pool.Job("render_pdf", func(*work.Job) error { return nil }, work.Execute(&remote.Executor{
+//	    Client:  grpcClient{pb.NewJobHandlerClient(conn)},
+//	    Timeout: time.Minute,
+//	}))
+//
+// The handler registered with the pool isn't called; middleware still runs in the worker.
+package remote
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gocraft/work"
+)
+
+// Job is the Job message of handler.proto.
+type Job struct {
+	Name        string
+	ID          string
+	EnqueuedAt  int64
+	ArgsJSON    []byte
+	Fails       int64
+	LastErr     string
+	Fingerprint string
+}
+
+// OutcomeKind is the Outcome.Kind enum of handler.proto.
+type OutcomeKind int32
+
+const (
+	OutcomeSuccess OutcomeKind = 0
+	OutcomeRetry   OutcomeKind = 1
+	OutcomeDead    OutcomeKind = 2
+	OutcomeDiscard OutcomeKind = 3
+)
+
+// Outcome is the Outcome message of handler.proto.
+type Outcome struct {
+	Kind              OutcomeKind
+	Reason            string
+	RetryDelaySeconds int64
+}
+
+// Client calls the JobHandler service's Handle method, typically through a generated gRPC client.
+type Client interface {
+	Handle(ctx context.Context, job *Job) (*Outcome, error)
+}
+
+// Executor is a work.Executor that forwards jobs to a JobHandler service.
+type Executor struct {
+	Client  Client
+	Timeout time.Duration // If set, bounds each call; a call that times out fails the job
+}
+
+// Execute sends job to the service and turns its response into the job's work.Outcome.
+func (e *Executor) Execute(job *work.Job, handler func(*work.Job) error) error {
+	ctx := context.Background()
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	out, err := e.Client.Handle(ctx, &Job{
+		Name:        job.Name,
+		ID:          job.ID,
+		EnqueuedAt:  job.EnqueuedAt,
+		ArgsJSON:    job.RawArgs(),
+		Fails:       job.Fails,
+		LastErr:     job.LastErr,
+		Fingerprint: job.Fingerprint,
+	})
+	if err != nil {
+		return fmt.Errorf("remote handler: %v", err)
+	}
+	if out == nil {
+		return fmt.Errorf("remote handler: no outcome")
+	}
+
+	switch out.Kind {
+	case OutcomeSuccess:
+		return nil
+	case OutcomeRetry:
+		return work.Retry(time.Duration(out.RetryDelaySeconds)*time.Second, out.Reason)
+	case OutcomeDead:
+		return work.Dead(out.Reason)
+	case OutcomeDiscard:
+		return work.Discard(out.Reason)
+	}
+	return fmt.Errorf("remote handler: unknown outcome kind %d", out.Kind)
+}
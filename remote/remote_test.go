@@ -0,0 +1,56 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gocraft/work"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClient struct {
+	out   *Outcome
+	err   error
+	got   *Job
+	delay time.Duration
+}
+
+func (c *fakeClient) Handle(ctx context.Context, job *Job) (*Outcome, error) {
+	c.got = job
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return c.out, c.err
+}
+
+func TestExecutor(t *testing.T) {
+	client := &fakeClient{out: &Outcome{Kind: OutcomeSuccess}}
+	executor := &Executor{Client: client}
+	job := &work.Job{Name: "render_pdf", ID: "1", Args: map[string]interface{}{"page": 3}, Fails: 1, Fingerprint: "abc"}
+	handler := func(*work.Job) error {
+		t.Error("handler called")
+		return nil
+	}
+
+	assert.NoError(t, executor.Execute(job, handler))
+	assert.Equal(t, &Job{Name: "render_pdf", ID: "1", ArgsJSON: []byte(`{"page":3}`), Fails: 1, Fingerprint: "abc"}, client.got)
+
+	client.out = &Outcome{Kind: OutcomeRetry, Reason: "busy", RetryDelaySeconds: 30}
+	assert.Equal(t, work.Retry(30*time.Second, "busy"), executor.Execute(job, handler))
+	client.out = &Outcome{Kind: OutcomeDead, Reason: "bad pdf"}
+	assert.Equal(t, work.Dead("bad pdf"), executor.Execute(job, handler))
+	client.out = &Outcome{Kind: OutcomeDiscard, Reason: "gone"}
+	assert.Equal(t, work.Discard("gone"), executor.Execute(job, handler))
+	client.out = &Outcome{Kind: 9}
+	assert.EqualError(t, executor.Execute(job, handler), "remote handler: unknown outcome kind 9")
+
+	client.err = fmt.Errorf("unavailable")
+	assert.EqualError(t, executor.Execute(job, handler), "remote handler: unavailable")
+
+	client.err, client.delay, executor.Timeout = nil, time.Second, 10*time.Millisecond
+	assert.EqualError(t, executor.Execute(job, handler), "remote handler: context deadline exceeded")
+}
@@ -0,0 +1,83 @@
+package work
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// replayChunkSize is how many dead jobs ReplayDeadJobsTo reads and copies at a time.
+const replayChunkSize = 1000
+
+// ReplayDeadJobsTo copies the dead jobs into the job queues of another namespace, eg a staging environment pointed at
+// the same Redis, so that a fix can be tried against the payloads that actually failed before retrying them here. The
+// copies are enqueued as if new, with their failures cleared, but keep their IDs so they can be matched up with the
+// originals. Copies of unique jobs aren't unique: their unique keys belong to this namespace, and a worker running the
+// copy would clear them here. The dead jobs themselves are left as they are. The number of jobs copied is returned.
+//
+// Jobs are copied a chunk at a time rather than in one script, since the two namespaces may live on different nodes
+// of a Redis Cluster.
+func (c *Client) ReplayDeadJobsTo(namespace string) (int64, error) {
+	if redisNamespacePrefix(namespace) == redisNamespacePrefix(c.namespace) {
+		return 0, fmt.Errorf("work: can't replay dead jobs into their own namespace %q", c.namespace)
+	}
+
+	deadKeys, err := c.failureQueueKeys(redisKeyDead(c.namespace), redisKeyDeadOf)
+	if err != nil {
+		return 0, err
+	}
+
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	var count int64
+	for _, deadKey := range deadKeys {
+		for start := 0; ; start += replayChunkSize {
+			rawJSONs, err := redis.ByteSlices(conn.Do("ZRANGE", deadKey, start, start+replayChunkSize-1))
+			if err != nil {
+				logError("client.replay_dead_jobs.zrange", err)
+				return count, err
+			}
+			if len(rawJSONs) == 0 {
+				break
+			}
+
+			now := nowEpochSeconds()
+			jobNames := make(map[string]bool)
+			conn.Send("MULTI")
+			for _, rawJSON := range rawJSONs {
+				job, err := newJobRawArgs(rawJSON, nil, nil)
+				if err != nil {
+					logError("client.replay_dead_jobs.new_job", err)
+					continue
+				}
+				job.EnqueuedAt = now
+				job.Fails = 0
+				job.LastErr = ""
+				job.FailedAt = 0
+				job.DeadRetention = 0
+				job.Unique = false
+				job.UniqueKey = ""
+				replayJSON, err := job.serialize()
+				if err != nil {
+					logError("client.replay_dead_jobs.serialize", err)
+					continue
+				}
+				conn.Send("LPUSH", redisKeyJobs(namespace, job.Name), replayJSON)
+				jobNames[job.Name] = true
+			}
+			for jobName := range jobNames {
+				conn.Send("SADD", redisKeyKnownJobs(namespace), jobName)
+			}
+			replies, err := redis.Values(conn.Do("EXEC"))
+			if err != nil {
+				logError("client.replay_dead_jobs.exec", err)
+				return count, err
+			}
+			count += int64(len(replies) - len(jobNames))
+		}
+	}
+
+	c.audit("replay_dead_jobs", map[string]interface{}{"namespace": namespace, "count": count})
+	return count, nil
+}
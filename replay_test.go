@@ -0,0 +1,90 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientReplayDeadJobsTo(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns, staging := "work", "work-staging"
+	cleanKeyspace(ns, pool)
+	cleanKeyspace(staging, pool)
+
+	client := NewClient(ns, pool)
+	_, err := client.ReplayDeadJobsTo(ns + ":")
+	assert.Error(t, err)
+
+	count, err := client.ReplayDeadJobsTo(staging)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, count)
+
+	job1 := insertDeadJob(ns, pool, "wat", 12345, 12347)
+	insertDeadJob(ns, pool, "wat", 12345, 12348)
+	insertDeadJob(ns, pool, "ugh", 12345, 12349)
+
+	count, err = client.ReplayDeadJobsTo(staging)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, count)
+
+	// The dead jobs are left alone
+	assert.EqualValues(t, 3, zsetSize(pool, redisKeyDead(ns)))
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, "wat")))
+
+	// And copied afresh into the other namespace
+	assert.EqualValues(t, 2, listSize(pool, redisKeyJobs(staging, "wat")))
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(staging, "ugh")))
+	assert.ElementsMatch(t, []string{"ugh", "wat"}, knownJobs(pool, redisKeyKnownJobs(staging)))
+
+	replayed := getQueuedJob(staging, pool, "wat")
+	assert.Equal(t, job1.ID, replayed.ID)
+	assert.EqualValues(t, 0, replayed.Fails)
+	assert.Equal(t, "", replayed.LastErr)
+	assert.EqualValues(t, 0, replayed.FailedAt)
+	assert.True(t, replayed.EnqueuedAt > 12345)
+
+	entries, _, err := client.AuditLog(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "replay_dead_jobs", entries[0].Action)
+	assert.Equal(t, staging, entries[0].Details["namespace"])
+}
+
+func TestClientReplayDeadJobsToUnique(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns, staging := "work", "work-staging"
+	cleanKeyspace(ns, pool)
+	cleanKeyspace(staging, pool)
+
+	// A unique job that died with its unique key still set
+	enqueuer := NewEnqueuer(ns, pool)
+	job, err := enqueuer.EnqueueUnique("wat", Q{"a": 1})
+	assert.NoError(t, err)
+	uniqueKey, err := redisKeyUniqueJob(ns, "wat", Q{"a": 1})
+	assert.NoError(t, err)
+	job.Fails = 3
+	job.LastErr = "sorry"
+	job.FailedAt = 12347
+	rawJSON, _ := job.serialize()
+	conn := pool.Get()
+	_, err = conn.Do("ZADD", redisKeyDead(ns), 12347, rawJSON)
+	assert.NoError(t, err)
+	conn.Close()
+
+	client := NewClient(ns, pool)
+	count, err := client.ReplayDeadJobsTo(staging)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	replayed := getQueuedJob(staging, pool, "wat")
+	assert.False(t, replayed.Unique)
+	assert.Equal(t, "", replayed.UniqueKey)
+
+	// Running the copy leaves this namespace's unique key alone
+	wp := NewWorkerPool(TestContext{}, 1, staging, pool)
+	wp.Job("wat", func(job *Job) error { return nil })
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+	assert.True(t, keyExists(pool, uniqueKey))
+}
@@ -0,0 +1,259 @@
+package work
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ReplicatedEnqueuer enqueues jobs on a primary Redis and mirrors them, asynchronously, to a standby Redis, so that
+// queued work survives losing the primary's region. Enqueue errors only ever come from the primary; mirroring happens
+// in the background and its progress is reported by Stats. Jobs are mirrored byte for byte as the primary got them,
+// unique jobs along with their unique keys, so hooks only run once, on the primary.
+//
+// Worker pools should run against both Redis servers, with the standby's marked by Client.SetStandby so they don't
+// process the mirrored jobs. To fail over, clear the standby flag on the standby (and set it on the old primary, if
+// it's reachable). Processing is at least once across a failover: jobs that already ran on the primary but are still
+// mirrored run again, up to ReplicatedEnqueuerOptions.MaxMirroredJobs per job type.
+type ReplicatedEnqueuer struct {
+	// Updated atomically; kept first for 64-bit alignment on 32-bit platforms
+	pending  int64
+	mirrored int64
+	dropped  int64
+	failed   int64
+	lagNanos int64
+
+	*Enqueuer // the primary
+
+	standby         *redis.Pool
+	maxMirroredJobs int64
+	errorHook       ErrorHook
+	trimScript      *redis.Script
+
+	mirrorChan chan *mirrorOp
+	doneChan   chan struct{}
+	stopOnce   sync.Once
+}
+
+// ReplicatedEnqueuerOptions can be passed to NewReplicatedEnqueuer.
+type ReplicatedEnqueuerOptions struct {
+	BufferSize      int       // Jobs waiting to be mirrored beyond this many are dropped, rather than slowing down enqueues. Default is 10000.
+	MaxMirroredJobs int64     // If set, the standby's job queues are trimmed to this many of the most recently mirrored jobs per job type, and its scheduled queue to this many in all.
+	ErrorHook       ErrorHook // If set, called with every error encountered while mirroring.
+}
+
+// ReplicationStats describes how a ReplicatedEnqueuer's mirroring is keeping up.
+type ReplicationStats struct {
+	Pending  int64         `json:"pending"`  // Jobs enqueued on the primary and waiting to be mirrored
+	Mirrored int64         `json:"mirrored"` // Jobs mirrored to the standby
+	Dropped  int64         `json:"dropped"`  // Jobs not mirrored because the buffer was full
+	Failed   int64         `json:"failed"`   // Jobs not mirrored because writing to the standby failed
+	Lag      time.Duration `json:"lag"`      // How long after being enqueued on the primary the last mirrored job reached the standby
+}
+
+type mirrorOp struct {
+	jobName     string
+	rawJSONs    [][]byte
+	runAt       int64  // set for scheduled jobs
+	uniqueKey   string // set for unique jobs
+	uniqueValue []byte
+	enqueuedAt  time.Time
+}
+
+// NewReplicatedEnqueuer creates a ReplicatedEnqueuer that enqueues to primary and mirrors to standby. Call Stop when
+// done with it to flush the jobs waiting to be mirrored.
+func NewReplicatedEnqueuer(namespace string, primary, standby *redis.Pool) *ReplicatedEnqueuer {
+	return NewReplicatedEnqueuerWithOptions(namespace, primary, standby, ReplicatedEnqueuerOptions{})
+}
+
+// NewReplicatedEnqueuerWithOptions creates a ReplicatedEnqueuer with the given options.
+func NewReplicatedEnqueuerWithOptions(namespace string, primary, standby *redis.Pool, opts ReplicatedEnqueuerOptions) *ReplicatedEnqueuer {
+	if standby == nil {
+		panic("NewReplicatedEnqueuer needs a non-nil standby *redis.Pool")
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 10000
+	}
+
+	e := &ReplicatedEnqueuer{
+		Enqueuer:        NewEnqueuer(namespace, primary),
+		standby:         standby,
+		maxMirroredJobs: opts.MaxMirroredJobs,
+		errorHook:       opts.ErrorHook,
+		trimScript:      redis.NewScript(1, redisLuaTrimMirrored),
+		mirrorChan:      make(chan *mirrorOp, opts.BufferSize),
+		doneChan:        make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+// Enqueue enqueues a job like Enqueuer.Enqueue and mirrors it.
+func (e *ReplicatedEnqueuer) Enqueue(jobName string, args map[string]interface{}) (*Job, error) {
+	return e.EnqueueContext(context.Background(), jobName, args)
+}
+
+// EnqueueContext enqueues a job like Enqueuer.EnqueueContext and mirrors it.
+func (e *ReplicatedEnqueuer) EnqueueContext(ctx context.Context, jobName string, args map[string]interface{}) (*Job, error) {
+	job, err := e.Enqueuer.EnqueueContext(ctx, jobName, args)
+	if job != nil {
+		e.mirror(&mirrorOp{jobName: jobName, rawJSONs: [][]byte{job.rawJSON}})
+	}
+	return job, err
+}
+
+// EnqueueBatch enqueues jobs like Enqueuer.EnqueueBatch and mirrors them, as a batch too.
+func (e *ReplicatedEnqueuer) EnqueueBatch(jobName string, argsList []map[string]interface{}) ([]*Job, error) {
+	jobs, err := e.Enqueuer.EnqueueBatch(jobName, argsList)
+	if jobs != nil {
+		rawJSONs := make([][]byte, len(jobs))
+		for i, job := range jobs {
+			rawJSONs[i] = job.rawJSON
+		}
+		e.mirror(&mirrorOp{jobName: jobName, rawJSONs: rawJSONs})
+	}
+	return jobs, err
+}
+
+// EnqueueIn enqueues a job like Enqueuer.EnqueueIn and mirrors it.
+func (e *ReplicatedEnqueuer) EnqueueIn(jobName string, secondsFromNow int64, args map[string]interface{}) (*ScheduledJob, error) {
+	return e.EnqueueAt(jobName, nowEpochSeconds()+secondsFromNow, args)
+}
+
+// EnqueueAt enqueues a job like Enqueuer.EnqueueAt and mirrors it.
+func (e *ReplicatedEnqueuer) EnqueueAt(jobName string, runAt int64, args map[string]interface{}) (*ScheduledJob, error) {
+	scheduledJob, err := e.Enqueuer.EnqueueAt(jobName, runAt, args)
+	if scheduledJob != nil {
+		e.mirror(&mirrorOp{jobName: jobName, rawJSONs: [][]byte{scheduledJob.rawJSON}, runAt: runAt})
+	}
+	return scheduledJob, err
+}
+
+// EnqueueUnique enqueues a job like Enqueuer.EnqueueUnique and mirrors it if it was enqueued.
+func (e *ReplicatedEnqueuer) EnqueueUnique(jobName string, args map[string]interface{}) (*Job, error) {
+	return e.EnqueueUniqueByKey(jobName, args, nil)
+}
+
+// EnqueueUniqueIn enqueues a job like Enqueuer.EnqueueUniqueIn and mirrors it if it was enqueued.
+func (e *ReplicatedEnqueuer) EnqueueUniqueIn(jobName string, secondsFromNow int64, args map[string]interface{}) (*ScheduledJob, error) {
+	return e.EnqueueUniqueInByKey(jobName, secondsFromNow, args, nil)
+}
+
+// EnqueueUniqueByKey enqueues a job like Enqueuer.EnqueueUniqueByKey and mirrors it if it was enqueued.
+func (e *ReplicatedEnqueuer) EnqueueUniqueByKey(jobName string, args map[string]interface{}, keyMap map[string]interface{}) (*Job, error) {
+	job, err := e.Enqueuer.EnqueueUniqueByKey(jobName, args, keyMap)
+	if job != nil {
+		e.mirror(uniqueMirrorOp(job, keyMap, 0))
+	}
+	return job, err
+}
+
+// EnqueueUniqueInByKey enqueues a job like Enqueuer.EnqueueUniqueInByKey and mirrors it if it was enqueued.
+func (e *ReplicatedEnqueuer) EnqueueUniqueInByKey(jobName string, secondsFromNow int64, args map[string]interface{}, keyMap map[string]interface{}) (*ScheduledJob, error) {
+	scheduledJob, err := e.Enqueuer.EnqueueUniqueInByKey(jobName, secondsFromNow, args, keyMap)
+	if scheduledJob != nil {
+		e.mirror(uniqueMirrorOp(scheduledJob.Job, keyMap, scheduledJob.RunAt))
+	}
+	return scheduledJob, err
+}
+
+// uniqueMirrorOp mirrors a unique job along with its unique key, set to what the primary's enqueue set it to. The
+// primary already decided the job is unique, so the key is set whether or not the standby has it.
+func uniqueMirrorOp(job *Job, keyMap map[string]interface{}, runAt int64) *mirrorOp {
+	op := &mirrorOp{jobName: job.Name, rawJSONs: [][]byte{job.rawJSON}, runAt: runAt, uniqueKey: job.UniqueKey}
+	if keyMap == nil {
+		op.uniqueValue = []byte("1")
+	} else {
+		op.uniqueValue = job.rawJSON
+	}
+	return op
+}
+
+// Stats returns a snapshot of how mirroring is keeping up.
+func (e *ReplicatedEnqueuer) Stats() ReplicationStats {
+	return ReplicationStats{
+		Pending:  atomic.LoadInt64(&e.pending),
+		Mirrored: atomic.LoadInt64(&e.mirrored),
+		Dropped:  atomic.LoadInt64(&e.dropped),
+		Failed:   atomic.LoadInt64(&e.failed),
+		Lag:      time.Duration(atomic.LoadInt64(&e.lagNanos)),
+	}
+}
+
+// Stop mirrors the jobs that are waiting to be mirrored and stops mirroring. Don't enqueue once Stop is called.
+func (e *ReplicatedEnqueuer) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.mirrorChan)
+	})
+	<-e.doneChan
+}
+
+func (e *ReplicatedEnqueuer) mirror(op *mirrorOp) {
+	op.enqueuedAt = time.Now()
+	atomic.AddInt64(&e.pending, 1)
+	select {
+	case e.mirrorChan <- op:
+	default:
+		atomic.AddInt64(&e.pending, -1)
+		atomic.AddInt64(&e.dropped, 1)
+	}
+}
+
+func (e *ReplicatedEnqueuer) loop() {
+	for op := range e.mirrorChan {
+		err := e.copy(op)
+		if err == nil && e.maxMirroredJobs > 0 {
+			err = e.trim(op)
+		}
+
+		atomic.AddInt64(&e.pending, -1)
+		if err != nil {
+			reportError(nil, e.errorHook, "replicated_enqueuer.mirror", err)
+			atomic.AddInt64(&e.failed, 1)
+			continue
+		}
+		atomic.AddInt64(&e.mirrored, 1)
+		atomic.StoreInt64(&e.lagNanos, int64(time.Since(op.enqueuedAt)))
+	}
+	close(e.doneChan)
+}
+
+// copy writes op's jobs to the standby as they were enqueued on the primary, byte for byte, so they keep their IDs,
+// enqueue times and whatever the hooks set, eg their Trace.
+func (e *ReplicatedEnqueuer) copy(op *mirrorOp) error {
+	conn := e.standby.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	for _, rawJSON := range op.rawJSONs {
+		if op.runAt > 0 {
+			conn.Send("ZADD", redisKeyScheduled(e.Namespace), op.runAt, rawJSON)
+		} else {
+			conn.Send("LPUSH", redisKeyJobs(e.Namespace, op.jobName), rawJSON)
+		}
+	}
+	if op.uniqueKey != "" {
+		conn.Send("SET", op.uniqueKey, op.uniqueValue, "EX", 86400)
+	}
+	conn.Send("SADD", redisKeyKnownJobs(e.Namespace), op.jobName)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// trim keeps the standby's queue that op's jobs went to to the most recent maxMirroredJobs jobs: per job type for job
+// queues, and in all for the scheduled queue.
+func (e *ReplicatedEnqueuer) trim(op *mirrorOp) error {
+	conn := e.standby.Get()
+	defer conn.Close()
+
+	var err error
+	if op.runAt > 0 {
+		_, err = e.trimScript.Do(conn, redisKeyScheduled(e.Namespace), e.maxMirroredJobs, "zset")
+	} else {
+		_, err = e.trimScript.Do(conn, redisKeyJobs(e.Namespace, op.jobName), e.maxMirroredJobs, "list")
+	}
+	return err
+}
@@ -0,0 +1,188 @@
+package work
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicatedEnqueuer(t *testing.T) {
+	primary := newTestPool(":6379")
+	standby := newTestPoolDB(":6379", 1)
+	ns := "work"
+	cleanKeyspace(ns, primary)
+	cleanKeyspace(ns, standby)
+
+	e := NewReplicatedEnqueuer(ns, primary, standby)
+	for i := 0; i < 3; i++ {
+		_, err := e.Enqueue("wat", Q{"i": i})
+		assert.NoError(t, err)
+	}
+	_, err := e.EnqueueBatch("wat", []map[string]interface{}{{"i": 3}, {"i": 4}})
+	assert.NoError(t, err)
+	_, err = e.EnqueueIn("wat", 300, Q{"later": true})
+	assert.NoError(t, err)
+	job, err := e.EnqueueUnique("once", Q{"a": 1})
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+	job, err = e.EnqueueUnique("once", Q{"a": 1})
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+	e.Stop()
+
+	for _, pool := range []*redis.Pool{primary, standby} {
+		assert.EqualValues(t, 5, listSize(pool, redisKeyJobs(ns, "wat")))
+		assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "once")))
+		assert.EqualValues(t, 1, zsetSize(pool, redisKeyScheduled(ns)))
+	}
+
+	// The standby has the same jobs, byte for byte, and the unique job's key
+	for _, key := range []string{redisKeyJobs(ns, "wat"), redisKeyJobs(ns, "once")} {
+		assert.Equal(t, listRange(primary, key), listRange(standby, key))
+	}
+	uniqueKey, err := redisKeyUniqueJob(ns, "once", Q{"a": 1})
+	assert.NoError(t, err)
+	assert.True(t, keyExists(standby, uniqueKey))
+
+	stats := e.Stats()
+	assert.EqualValues(t, 6, stats.Mirrored)
+	assert.EqualValues(t, 0, stats.Pending)
+	assert.EqualValues(t, 0, stats.Dropped)
+	assert.EqualValues(t, 0, stats.Failed)
+	assert.True(t, stats.Lag > 0)
+}
+
+func TestReplicatedEnqueuerTrace(t *testing.T) {
+	primary := newTestPool(":6379")
+	standby := newTestPoolDB(":6379", 1)
+	ns := "work"
+	cleanKeyspace(ns, primary)
+	cleanKeyspace(ns, standby)
+
+	e := NewReplicatedEnqueuer(ns, primary, standby)
+	e.AddHook(func(job *Job) error {
+		job.Trace = TraceCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+		return nil
+	})
+	_, err := e.EnqueueContext(context.Background(), "wat", Q{"a": 1})
+	assert.NoError(t, err)
+	e.Stop()
+
+	// The copy carries the trace context the hook set on the primary's job
+	job := getQueuedJob(ns, standby, "wat")
+	if assert.NotNil(t, job) {
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", job.Trace.Get("traceparent"))
+	}
+}
+
+func TestReplicatedEnqueuerMaxMirroredJobs(t *testing.T) {
+	primary := newTestPool(":6379")
+	standby := newTestPoolDB(":6379", 1)
+	ns := "work"
+	cleanKeyspace(ns, primary)
+	cleanKeyspace(ns, standby)
+
+	e := NewReplicatedEnqueuerWithOptions(ns, primary, standby, ReplicatedEnqueuerOptions{MaxMirroredJobs: 2})
+	for i := 0; i < 5; i++ {
+		_, err := e.Enqueue("wat", Q{"i": i})
+		assert.NoError(t, err)
+	}
+	e.Stop()
+
+	assert.EqualValues(t, 5, listSize(primary, redisKeyJobs(ns, "wat")))
+	assert.EqualValues(t, 2, listSize(standby, redisKeyJobs(ns, "wat")))
+	assert.EqualValues(t, 3, jobOnQueue(standby, redisKeyJobs(ns, "wat")).ArgInt64("i"))
+}
+
+func TestReplicatedEnqueuerMaxMirroredJobsUnique(t *testing.T) {
+	primary := newTestPool(":6379")
+	standby := newTestPoolDB(":6379", 1)
+	ns := "work"
+	cleanKeyspace(ns, primary)
+	cleanKeyspace(ns, standby)
+
+	e := NewReplicatedEnqueuerWithOptions(ns, primary, standby, ReplicatedEnqueuerOptions{MaxMirroredJobs: 1})
+	for i := 0; i < 3; i++ {
+		_, err := e.EnqueueUniqueIn("wat", int64(100+i), Q{"i": i})
+		assert.NoError(t, err)
+	}
+	e.Stop()
+
+	// The scheduled queue is trimmed too, and the trimmed jobs' unique keys go with them
+	assert.EqualValues(t, 3, zsetSize(primary, redisKeyScheduled(ns)))
+	assert.EqualValues(t, 1, zsetSize(standby, redisKeyScheduled(ns)))
+	for i := 0; i < 3; i++ {
+		uniqueKey, err := redisKeyUniqueJob(ns, "wat", Q{"i": i})
+		assert.NoError(t, err)
+		assert.True(t, keyExists(primary, uniqueKey))
+		assert.Equal(t, i == 2, keyExists(standby, uniqueKey))
+	}
+}
+
+func TestWorkerStandby(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	var ran int
+	jobTypes := map[string]*jobType{
+		job1: {
+			Name:       job1,
+			JobOptions: JobOptions{Priority: 1},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				ran++
+				return nil
+			},
+		},
+	}
+
+	_, err := NewEnqueuer(ns, pool).Enqueue(job1, nil)
+	assert.NoError(t, err)
+
+	client := NewClient(ns, pool)
+	assert.NoError(t, client.SetStandby(true))
+	standby, err := client.IsStandby()
+	assert.NoError(t, err)
+	assert.True(t, standby)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.start()
+	w.drain()
+	assert.Equal(t, 0, ran)
+
+	assert.NoError(t, client.SetStandby(false))
+	standby, err = client.IsStandby()
+	assert.NoError(t, err)
+	assert.False(t, standby)
+	w.drain()
+	w.stop()
+	assert.Equal(t, 1, ran)
+}
+
+func newTestPoolDB(addr string, db int) *redis.Pool {
+	return &redis.Pool{
+		MaxActive:   10,
+		MaxIdle:     10,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr, redis.DialDatabase(db))
+		},
+		Wait: true,
+	}
+}
+
+func listRange(pool *redis.Pool, key string) []string {
+	conn := pool.Get()
+	defer conn.Close()
+
+	vals, err := redis.Strings(conn.Do("LRANGE", key, 0, -1))
+	if err != nil {
+		panic("could not get list: " + err.Error())
+	}
+	return vals
+}
@@ -8,11 +8,36 @@ import (
 )
 
 type requeuer struct {
-	namespace string
-	pool      *redis.Pool
+	namespace    string
+	pool         *redis.Pool
+	redisTimeout time.Duration
+	errorHook    ErrorHook
+	logger       Logger
 
 	redisRequeueScript *redis.Script
-	redisRequeueArgs   []interface{}
+	redisRequeueArgs   []interface{} // KEYS and ARGV[1]; the rest are added on each call
+
+	// Job types whose jobs are only requeued while their gate is open
+	gates map[string]Gate
+
+	// If set, jobs are also requeued from the queues this returns for the job types with JobOptions.OwnFailureQueues
+	ownQueueKey         func(namespace, jobName string) string
+	jobNames            map[string]bool
+	redisOwnQueueScript *redis.Script
+
+	// If set, jobs are requeued from this queue first, before the requeuer's own, see JobOptions.ExpressRetries
+	expressKey string
+
+	// The job types held back last round, and whether there was a round yet: jobs whose gate has opened since are
+	// released
+	lastHeld map[string]struct{}
+	hadRound bool
+
+	redisReleaseHeldScript *redis.Script
+
+	// If set, the known job types are read from Redis on each round, so jobs of types enqueued since the requeuer
+	// started aren't taken for unknown ones and killed. Maintainers without job names set it.
+	readKnownJobs bool
 
 	stopChan         chan struct{}
 	doneStoppingChan chan struct{}
@@ -21,22 +46,22 @@ type requeuer struct {
 	doneDrainingChan chan struct{}
 }
 
-func newRequeuer(namespace string, pool *redis.Pool, requeueKey string, jobNames []string) *requeuer {
-	args := make([]interface{}, 0, len(jobNames)+2+2)
-	args = append(args, requeueKey)              // KEY[1]
-	args = append(args, redisKeyDead(namespace)) // KEY[2]
-	for _, jobName := range jobNames {
-		args = append(args, redisKeyJobs(namespace, jobName)) // KEY[3, 4, ...]
-	}
-	args = append(args, redisKeyJobsPrefix(namespace)) // ARGV[1]
-	args = append(args, 0)                             // ARGV[2] -- NOTE: We're going to change this one on every call
+// requeueScanSize is how many due jobs each call of the requeue script looks at, at most, to find one that isn't held
+// back.
+const requeueScanSize = 100
 
-	return &requeuer{
+// heldScoreOffset is added to the scores of the due jobs that a closed gate holds back, which keeps them in the
+// scheduled and retry queues but out of the range of due jobs, so they aren't looked at again every round. It's about
+// 35,000 years, and small enough for the scores to stay exact.
+const heldScoreOffset int64 = 1 << 40
+
+func newRequeuer(namespace string, pool *redis.Pool, requeueKey string, jobNames []string) *requeuer {
+	r := &requeuer{
 		namespace: namespace,
 		pool:      pool,
 
-		redisRequeueScript: redis.NewScript(len(jobNames)+2, redisLuaZremLpushCmd),
-		redisRequeueArgs:   args,
+		redisOwnQueueScript:    redis.NewScript(3, redisLuaZremLpushCmd),
+		redisReleaseHeldScript: redis.NewScript(1, redisLuaReleaseHeld),
 
 		stopChan:         make(chan struct{}),
 		doneStoppingChan: make(chan struct{}),
@@ -44,6 +69,52 @@ func newRequeuer(namespace string, pool *redis.Pool, requeueKey string, jobNames
 		drainChan:        make(chan struct{}),
 		doneDrainingChan: make(chan struct{}),
 	}
+	r.setJobNames(requeueKey, jobNames)
+	return r
+}
+
+// setJobNames sets the job types whose jobs are requeued from requeueKey; jobs of other types go to the dead queue.
+func (r *requeuer) setJobNames(requeueKey string, jobNames []string) {
+	args := make([]interface{}, 0, len(jobNames)+2+1)
+	args = append(args, requeueKey)                // KEY[1]
+	args = append(args, redisKeyDead(r.namespace)) // KEY[2]
+	for _, jobName := range jobNames {
+		args = append(args, redisKeyJobs(r.namespace, jobName)) // KEY[3, 4, ...]
+	}
+	args = append(args, redisKeyJobsPrefix(r.namespace)) // ARGV[1]
+
+	names := make(map[string]bool, len(jobNames))
+	for _, jobName := range jobNames {
+		names[jobName] = true
+	}
+
+	r.redisRequeueScript = redis.NewScript(len(jobNames)+2, redisLuaZremLpushCmd)
+	r.redisRequeueArgs = args
+	r.jobNames = names
+}
+
+// refreshJobNames reads the known job types from Redis and requeues the jobs of any new ones from now on. On an error,
+// it reports it and returns false, and the round should be skipped rather than kill jobs of types it can't see.
+func (r *requeuer) refreshJobNames() bool {
+	conn := getConn(r.pool, r.redisTimeout)
+	defer conn.Close()
+
+	jobNames, err := redis.Strings(conn.Do("SMEMBERS", redisKeyKnownJobs(r.namespace)))
+	if err != nil {
+		reportError(r.logger, r.errorHook, "requeuer.known_jobs", err)
+		return false
+	}
+
+	changed := len(jobNames) != len(r.jobNames)
+	for _, jobName := range jobNames {
+		if !r.jobNames[jobName] {
+			changed = true
+		}
+	}
+	if changed {
+		r.setJobNames(r.redisRequeueArgs[0].(string), jobNames)
+	}
+	return true
 }
 
 func (r *requeuer) start() {
@@ -73,38 +144,153 @@ func (r *requeuer) loop() {
 			r.doneStoppingChan <- struct{}{}
 			return
 		case <-r.drainChan:
-			for r.process() {
-			}
+			r.processAll()
 			r.doneDrainingChan <- struct{}{}
 		case <-ticker:
-			for r.process() {
+			r.processAll()
+		}
+	}
+}
+
+// processAll requeues every due job, except those of job types whose gate is closed.
+func (r *requeuer) processAll() {
+	if r.readKnownJobs && !r.refreshJobNames() {
+		return
+	}
+	held := r.heldJobNames()
+	if r.opened(held) {
+		if r.expressKey != "" {
+			r.releaseHeld(r.expressKey, held)
+		}
+		r.releaseHeld(r.redisRequeueArgs[0].(string), held)
+	}
+	r.lastHeld, r.hadRound = held, true
+
+	if r.expressKey != "" {
+		args := append([]interface{}{r.expressKey}, r.redisRequeueArgs[1:]...)
+		r.processQueue(r.redisRequeueScript, args, held)
+	}
+	r.processQueue(r.redisRequeueScript, r.redisRequeueArgs, held)
+	if r.ownQueueKey == nil {
+		return
+	}
+
+	for _, jobName := range r.ownQueueJobNames() {
+		if _, closed := held[jobName]; closed {
+			continue
+		}
+		args := []interface{}{
+			r.ownQueueKey(r.namespace, jobName),  // KEY[1]
+			redisKeyDeadOf(r.namespace, jobName), // KEY[2]
+			redisKeyJobs(r.namespace, jobName),   // KEY[3]
+			redisKeyJobsPrefix(r.namespace),      // ARGV[1]
+		}
+		r.processQueue(r.redisOwnQueueScript, args, nil)
+	}
+}
+
+// heldJobNames returns the job types whose gate is closed right now.
+func (r *requeuer) heldJobNames() map[string]struct{} {
+	var held map[string]struct{}
+	for jobName, gate := range r.gates {
+		if !gate.Open(jobName) {
+			if held == nil {
+				held = make(map[string]struct{})
 			}
+			held[jobName] = struct{}{}
+		}
+	}
+	return held
+}
+
+// opened returns whether the jobs held back in earlier rounds may have to be released, since a gate has opened. That's
+// also the case on the first round, for jobs held back by a requeuer that's gone.
+func (r *requeuer) opened(held map[string]struct{}) bool {
+	if !r.hadRound {
+		return true
+	}
+	for jobName := range r.lastHeld {
+		if _, closed := held[jobName]; !closed {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseHeld moves the jobs held back in key whose job types aren't in held back to their own scores.
+func (r *requeuer) releaseHeld(key string, held map[string]struct{}) {
+	conn := getConn(r.pool, r.redisTimeout)
+	defer conn.Close()
+
+	args := make([]interface{}, 0, 2+len(held))
+	args = append(args, key, 0) // KEY[1], ARGV[1]
+	for jobName := range held {
+		args = append(args, jobName) // ARGV[2...]
+	}
+	for {
+		offset, err := redis.Int64(r.redisReleaseHeldScript.Do(conn, args...))
+		if err == redis.ErrNil {
+			return
+		} else if err != nil {
+			reportError(r.logger, r.errorHook, "requeuer.release_held", err)
+			return
 		}
+		args[1] = offset
 	}
 }
 
-func (r *requeuer) process() bool {
-	conn := r.pool.Get()
+// processQueue requeues the due jobs of one queue. keysAndPrefix are the script's KEYS and ARGV[1].
+func (r *requeuer) processQueue(script *redis.Script, keysAndPrefix []interface{}, held map[string]struct{}) {
+	args := make([]interface{}, 0, len(keysAndPrefix)+1+len(held))
+	args = append(args, keysAndPrefix...)
+	args = append(args, 0) // ARGV[2], set on each call
+	for jobName := range held {
+		args = append(args, jobName) // ARGV[3...]
+	}
+
+	for {
+		args[len(keysAndPrefix)] = nowEpochSeconds()
+		if !r.process(script, args) {
+			return
+		}
+	}
+}
+
+// ownQueueJobNames returns the job types the requeuer knows that have queues of their own.
+func (r *requeuer) ownQueueJobNames() []string {
+	conn := getConn(r.pool, r.redisTimeout)
 	defer conn.Close()
 
-	r.redisRequeueArgs[len(r.redisRequeueArgs)-1] = nowEpochSeconds()
+	jobNames, err := redis.Strings(conn.Do("SMEMBERS", redisKeyOwnFailureQueues(r.namespace)))
+	if err != nil {
+		reportError(r.logger, r.errorHook, "requeuer.own_queues", err)
+		return nil
+	}
 
-	res, err := redis.String(r.redisRequeueScript.Do(conn, r.redisRequeueArgs...))
+	known := jobNames[:0]
+	for _, jobName := range jobNames {
+		if r.jobNames[jobName] {
+			known = append(known, jobName)
+		}
+	}
+	return known
+}
+
+// process requeues the next due job, returning whether there may be more to do.
+func (r *requeuer) process(script *redis.Script, args []interface{}) bool {
+	conn := getConn(r.pool, r.redisTimeout)
+	defer conn.Close()
+
+	res, err := redis.String(script.Do(conn, args...))
 	if err == redis.ErrNil {
 		return false
 	} else if err != nil {
-		logError("requeuer.process", err)
+		reportError(r.logger, r.errorHook, "requeuer.process", err)
 		return false
 	}
 
-	if res == "" {
-		return false
-	} else if res == "dead" {
-		logError("requeuer.process.dead", fmt.Errorf("no job name"))
-		return true
-	} else if res == "ok" {
-		return true
+	if res == "dead" {
+		reportError(r.logger, r.errorHook, "requeuer.process.dead", fmt.Errorf("no job name"))
 	}
-
-	return false
+	return true
 }
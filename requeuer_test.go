@@ -1,8 +1,10 @@
 package work
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -79,3 +81,151 @@ func TestRequeueUnknown(t *testing.T) {
 	assert.Equal(t, nowish, job.FailedAt)
 	assert.Equal(t, "unknown job when requeueing", job.LastErr)
 }
+
+func TestRequeueOwnFailureQueues(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	past := nowEpochSeconds() - 10
+	for _, jobName := range []string{"wat", "foo"} {
+		job := &Job{Name: jobName, ID: makeIdentifier(), Fails: 1}
+		rawJSON, _ := job.serialize()
+		zadd(pool, redisKeyRetryOf(ns, jobName), past, rawJSON)
+	}
+
+	conn := pool.Get()
+	_, err := conn.Do("SADD", redisKeyOwnFailureQueues(ns), "wat", "foo")
+	conn.Close()
+	assert.NoError(t, err)
+
+	re := newRequeuer(ns, pool, redisKeyRetry(ns), []string{"wat"})
+	re.ownQueueKey = redisKeyRetryOf
+	re.start()
+	re.drain()
+	re.stop()
+
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "wat")))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyRetryOf(ns, "wat")))
+
+	// The requeuer doesn't know foo, so leaves its queue to a requeuer that does
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyRetryOf(ns, "foo")))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyDead(ns)))
+}
+
+func TestRequeueExpressRetries(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	// A backlog of bulk retries that fell due before the express one
+	past := nowEpochSeconds() - 10
+	for i := 0; i < 3; i++ {
+		job := &Job{Name: "wat", ID: makeIdentifier(), Fails: 1}
+		rawJSON, _ := job.serialize()
+		zadd(pool, redisKeyRetry(ns), past-1, rawJSON)
+	}
+	express := &Job{Name: "wat", ID: makeIdentifier(), Fails: 1}
+	rawJSON, _ := express.serialize()
+	zadd(pool, redisKeyRetryExpress(ns), past, rawJSON)
+
+	m := newMaintenance(ns, pool, []string{"wat"}, nil)
+	m.start()
+	m.retrier.drain()
+	m.stop()
+
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyRetryExpress(ns)))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyRetry(ns)))
+	assert.EqualValues(t, 4, listSize(pool, redisKeyJobs(ns, "wat")))
+
+	// The express job is requeued first, so it's fetched first
+	first := getQueuedJob(ns, pool, "wat")
+	if assert.NotNil(t, first) {
+		assert.Equal(t, express.ID, first.ID)
+	}
+}
+
+func TestWorkerExpressRetries(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job("checkout", func(job *Job) error { return fmt.Errorf("payment provider down") }, ExpressRetries(), OwnFailureQueues())
+	_, err := NewEnqueuer(ns, pool).Enqueue("checkout", nil)
+	assert.NoError(t, err)
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyRetryExpress(ns)))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyRetryOf(ns, "checkout")))
+
+	jobs, count, err := NewClient(ns, pool).RetryJobs(1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, "checkout", jobs[0].Name)
+	}
+}
+
+func TestRequeueGate(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	// More held back jobs than the script looks at in one call, ahead of one that isn't held back
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < requeueScanSize+50; i++ {
+		_, err := enqueuer.EnqueueIn("billing", -20, nil)
+		assert.NoError(t, err)
+	}
+	_, err := enqueuer.EnqueueIn("email", -10, nil)
+	assert.NoError(t, err)
+
+	healthy := false
+	re := newRequeuer(ns, pool, redisKeyScheduled(ns), []string{"billing", "email"})
+	re.gates = map[string]Gate{"billing": GateFunc(func(jobName string) bool { return healthy })}
+	re.start()
+	re.drain()
+
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, "billing")))
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "email")))
+	assert.EqualValues(t, requeueScanSize+50, zsetSize(pool, redisKeyScheduled(ns)))
+
+	// The held back jobs are set aside, so later rounds don't look at them, but keep their run times for the client
+	conn := pool.Get()
+	due, err := redis.Int64(conn.Do("ZCOUNT", redisKeyScheduled(ns), "-inf", nowEpochSeconds()))
+	conn.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, due)
+	jobs, _, err := NewClient(ns, pool).ScheduledJobs(1)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, jobs) {
+		assert.True(t, jobs[0].RunAt <= nowEpochSeconds()-20)
+		assert.NoError(t, NewClient(ns, pool).DeleteScheduledJob(jobs[0].RunAt, jobs[0].ID))
+	}
+	re.drain()
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, "billing")))
+
+	// Promotion resumes once the gate opens
+	healthy = true
+	re.drain()
+	re.stop()
+
+	assert.EqualValues(t, requeueScanSize+49, listSize(pool, redisKeyJobs(ns, "billing")))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyScheduled(ns)))
+}
+
+func TestWorkerPoolGate(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job("billing", func(job *Job) error { return nil }, Gated(GateFunc(func(jobName string) bool { return false })))
+	assert.Len(t, wp.gates(), 1)
+
+	m := NewMaintainer(ns, pool, []string{"billing"}).Gate("billing", GateFunc(func(jobName string) bool { return false }))
+	assert.Len(t, m.gates, 1)
+}
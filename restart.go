@@ -0,0 +1,154 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	restartTurnTTL       = 60 * time.Second
+	restartRenewInterval = 10 * time.Second
+	restartPollInterval  = 1 * time.Second
+	restartDefaultGrace  = 30 * time.Second
+	restartIdlePoll      = 10 * time.Millisecond
+)
+
+// RestartOptions are the options of WorkerPool.QuietInTurn.
+type RestartOptions struct {
+	MaxQuiet int           // How many pools of the namespace can take their turn at once. Defaults to 1.
+	Grace    time.Duration // How long a pool's turn lasts once it's stopped, for its replacement to start. Defaults to 30 seconds.
+}
+
+// QuietInTurn quiets the pool as part of a rolling restart of a fleet, so that no more than opts.MaxQuiet pools of
+// the namespace are quiet (or stopped and not yet replaced) at once. It waits for the pool's turn, calls Quiet, and
+// returns once the jobs the pool was running are done, leaving the caller to Stop it. The turn is held until
+// opts.Grace after Stop, or a minute after the process dies without stopping.
+//
+// If ctx is done before the pool's turn comes, the pool is left running as it was. If it's done while jobs are still
+// running, the pool stays quiet and keeps its turn. Either way ctx's error is returned. A pool that isn't started
+// has nothing to quiet, and gets an error right away.
+func (wp *WorkerPool) QuietInTurn(ctx context.Context, opts RestartOptions) error {
+	if !wp.started {
+		return errors.New("work: QuietInTurn needs a started pool")
+	}
+	if opts.MaxQuiet < 1 {
+		opts.MaxQuiet = 1
+	}
+	if opts.Grace <= 0 {
+		opts.Grace = restartDefaultGrace
+	}
+
+	turn := newRestartTurn(wp.namespace, wp.pool, wp.workerPoolID, opts.MaxQuiet)
+	turn.redisTimeout, turn.errorHook, turn.logger = wp.redisTimeout, wp.errorHook, wp.logger
+	for !turn.take() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(turn.pollInterval):
+		}
+	}
+	turn.start()
+	wp.restartTurn, wp.restartGrace = turn, opts.Grace
+
+	wp.Quiet()
+	// Rather than Drain, which can't be given up on, wait for the workers to be done with the jobs they were running
+	ticker := time.NewTicker(restartIdlePoll)
+	defer ticker.Stop()
+	for !wp.idle() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// restartTurn holds a pool's place among the pools of a namespace taking their turn to restart. Places are members of
+// a sorted set, scored with the time at which they expire, and kept by renewing them until the pool stops.
+type restartTurn struct {
+	namespace     string
+	pool          *redis.Pool
+	poolID        string
+	maxQuiet      int
+	ttl           time.Duration
+	renewInterval time.Duration
+	pollInterval  time.Duration
+	redisTimeout  time.Duration
+	errorHook     ErrorHook
+	logger        Logger
+
+	takeScript *redis.Script
+
+	stopChan         chan time.Duration
+	doneStoppingChan chan struct{}
+}
+
+func newRestartTurn(namespace string, pool *redis.Pool, poolID string, maxQuiet int) *restartTurn {
+	return &restartTurn{
+		namespace:        namespace,
+		pool:             pool,
+		poolID:           poolID,
+		maxQuiet:         maxQuiet,
+		ttl:              restartTurnTTL,
+		renewInterval:    restartRenewInterval,
+		pollInterval:     restartPollInterval,
+		takeScript:       redis.NewScript(1, redisLuaTakeRestartTurn),
+		stopChan:         make(chan time.Duration),
+		doneStoppingChan: make(chan struct{}),
+	}
+}
+
+func (t *restartTurn) start() {
+	go t.loop()
+}
+
+// stop keeps the turn for grace more, then lets it expire.
+func (t *restartTurn) stop(grace time.Duration) {
+	t.stopChan <- grace
+	<-t.doneStoppingChan
+}
+
+func (t *restartTurn) loop() {
+	ticker := time.NewTicker(t.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case grace := <-t.stopChan:
+			t.expireIn(grace)
+			t.doneStoppingChan <- struct{}{}
+			return
+		case <-ticker.C:
+			t.take()
+		}
+	}
+}
+
+// take takes the turn, or renews it if it's already the pool's. It returns false if MaxQuiet other pools have theirs.
+// Errors count as not getting the turn, since a pool that can't reach Redis can't tell.
+func (t *restartTurn) take() bool {
+	conn := getConn(t.pool, t.redisTimeout)
+	defer conn.Close()
+
+	now := time.Now()
+	taken, err := redis.Bool(t.takeScript.Do(conn, redisKeyRestartTurns(t.namespace), t.poolID, now.UnixNano()/int64(time.Millisecond), t.maxQuiet, now.Add(t.ttl).UnixNano()/int64(time.Millisecond)))
+	if err != nil {
+		reportError(t.logger, t.errorHook, "restart_turn.take", err)
+		return false
+	}
+	return taken
+}
+
+func (t *restartTurn) expireIn(d time.Duration) {
+	conn := getConn(t.pool, t.redisTimeout)
+	defer conn.Close()
+
+	expiresAt := time.Now().Add(d).UnixNano() / int64(time.Millisecond)
+	if _, err := conn.Do("ZADD", redisKeyRestartTurns(t.namespace), "XX", expiresAt, t.poolID); err != nil {
+		reportError(t.logger, t.errorHook, "restart_turn.expire", err)
+	}
+}
@@ -0,0 +1,92 @@
+package work
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartTurn(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	t1 := newRestartTurn(ns, pool, "1", 2)
+	t2 := newRestartTurn(ns, pool, "2", 2)
+	t3 := newRestartTurn(ns, pool, "3", 2)
+	assert.True(t, t1.take())
+	assert.True(t, t2.take())
+	assert.False(t, t3.take())
+
+	// Renewing a turn doesn't need a free place
+	assert.True(t, t1.take())
+
+	// Neither do expired turns take one
+	t1.expireIn(0)
+	assert.True(t, t3.take())
+	assert.False(t, t1.take())
+	assert.EqualValues(t, 2, zsetSize(pool, redisKeyRestartTurns(ns)))
+}
+
+func TestWorkerPoolQuietInTurn(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	newPool := func() *WorkerPool {
+		wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+		wp.Job("wat", func(job *Job) error { return nil })
+		wp.Start()
+		return wp
+	}
+	wp1, wp2 := newPool(), newPool()
+	opts := RestartOptions{MaxQuiet: 1, Grace: 10 * time.Millisecond}
+
+	assert.NoError(t, wp1.QuietInTurn(context.Background(), opts))
+	assert.True(t, wp1.IsQuiet())
+
+	// wp2 has to wait for wp1 to stop
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, wp2.QuietInTurn(ctx, opts))
+	assert.False(t, wp2.IsQuiet())
+
+	wp1.Stop()
+	assert.NoError(t, wp2.QuietInTurn(context.Background(), opts))
+	assert.True(t, wp2.IsQuiet())
+	wp2.Stop()
+}
+
+func TestWorkerPoolQuietInTurnRunningJobs(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	assert.Error(t, wp.QuietInTurn(context.Background(), RestartOptions{}))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	wp.Job("wat", func(job *Job) error {
+		close(started)
+		<-release
+		return nil
+	})
+	wp.Start()
+	_, err := NewEnqueuer(ns, pool).Enqueue("wat", nil)
+	assert.NoError(t, err)
+	<-started
+
+	// Given up on while the job is running, the pool stays quiet
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, wp.QuietInTurn(ctx, RestartOptions{Grace: 10 * time.Millisecond}))
+	assert.True(t, wp.IsQuiet())
+	assert.False(t, wp.idle())
+
+	// and stops as usual once the job is done
+	close(release)
+	wp.Stop()
+	assert.True(t, wp.idle())
+}
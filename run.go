@@ -3,20 +3,22 @@ package work
 import (
 	"fmt"
 	"reflect"
+	"runtime/debug"
 )
 
 // returns an error if the job fails, or there's a panic, or we couldn't reflect correctly.
 // if we return an error, it signals we want the job to be retried.
 func runJob(job *Job, ctxType reflect.Type, middleware []*middlewareHandler, jt *jobType) (returnCtx reflect.Value, returnError error) {
 	returnCtx = reflect.New(ctxType)
-	currentMiddleware := 0
-	maxMiddleware := len(middleware)
-
-	var next NextMiddlewareFunc
-	next = func() error {
-		if currentMiddleware < maxMiddleware {
-			mw := middleware[currentMiddleware]
-			currentMiddleware++
+
+	// The next of each middleware runs the rest of the chain, every time it's called
+	var runFrom func(i int) error
+	runFrom = func(i int) error {
+		if i < len(middleware) {
+			mw := middleware[i]
+			var next NextMiddlewareFunc = func() error {
+				return runFrom(i + 1)
+			}
 			if mw.IsGeneric {
 				return mw.GenericMiddlewareHandler(job, next)
 			}
@@ -27,28 +29,61 @@ func runJob(job *Job, ctxType reflect.Type, middleware []*middlewareHandler, jt
 			}
 			return x.(error)
 		}
-		if jt.IsGeneric {
-			return jt.GenericHandler(job)
+		if jt.Executor != nil {
+			return handlerResult(job, jt.Executor.Execute(job, func(job *Job) error {
+				return callHandler(job, returnCtx, jt)
+			}))
 		}
-		res := jt.DynamicHandler.Call([]reflect.Value{returnCtx, reflect.ValueOf(job)})
-		x := res[0].Interface()
-		if x == nil {
-			return nil
-		}
-		return x.(error)
+		return handlerResult(job, callHandler(job, returnCtx, jt))
 	}
 
 	defer func() {
 		if panicErr := recover(); panicErr != nil {
-			// err turns out to be interface{}, of actual type "runtime.errorCString"
-			// Luckily, the err sprints nicely via fmt.
-			errorishError := fmt.Errorf("%v", panicErr)
-			logError("runJob.panic", errorishError)
-			returnError = errorishError
+			// A panic with an error keeps it, for errors.Is and errors.As; anything else sprints nicely via fmt
+			err, ok := panicErr.(error)
+			if !ok {
+				err = fmt.Errorf("%v", panicErr)
+			}
+			returnError = jobPanic{err, debug.Stack()}
 		}
 	}()
 
-	returnError = next()
+	returnError = runFrom(0)
 
 	return
 }
+
+// jobPanic is the error runJob returns when the job panics, so that the worker can report it and record where it
+// panicked.
+type jobPanic struct {
+	error
+	stack []byte
+}
+
+// Unwrap returns the error the job panicked with, or one made from the value it panicked with.
+func (p jobPanic) Unwrap() error {
+	return p.error
+}
+
+// jobPanicStackMaxLen caps the stack trace kept in a failed job's history, which is kept for its last 20 events.
+const jobPanicStackMaxLen = 4096
+
+// trimmedStack returns the panic's stack trace, cut short if it's long: the frames that matter are at the top.
+func (p jobPanic) trimmedStack() string {
+	if len(p.stack) > jobPanicStackMaxLen {
+		return string(p.stack[:jobPanicStackMaxLen]) + "..."
+	}
+	return string(p.stack)
+}
+
+func callHandler(job *Job, ctx reflect.Value, jt *jobType) error {
+	if jt.IsGeneric {
+		return jt.GenericHandler(job)
+	}
+	res := jt.DynamicHandler.Call([]reflect.Value{ctx, reflect.ValueOf(job)})
+	x := res[0].Interface()
+	if x == nil {
+		return nil
+	}
+	return x.(error)
+}
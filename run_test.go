@@ -1,9 +1,12 @@
 package work
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -140,6 +143,13 @@ func TestRunHandlerPanic(t *testing.T) {
 	_, err := runJob(job, tstCtxType, middleware, jt)
 	assert.Error(t, err)
 	assert.Equal(t, "dayam", err.Error())
+
+	// Where it panicked is kept for the job's history
+	if p, ok := err.(jobPanic); assert.True(t, ok) {
+		assert.Contains(t, p.trimmedStack(), "TestRunHandlerPanic")
+		p.stack = make([]byte, 2*jobPanicStackMaxLen)
+		assert.Len(t, p.trimmedStack(), jobPanicStackMaxLen+3)
+	}
 }
 
 func TestRunMiddlewarePanic(t *testing.T) {
@@ -169,3 +179,100 @@ func TestRunMiddlewarePanic(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "dayam", err.Error())
 }
+
+func TestRunMiddlewareOrder(t *testing.T) {
+	outer := func(c *tstCtx, j *Job, next NextMiddlewareFunc) error {
+		c.record("<outer")
+		err := next()
+		c.record("outer>")
+		return err
+	}
+	retry := func(c *tstCtx, j *Job, next NextMiddlewareFunc) error {
+		if err := next(); err == nil {
+			return nil
+		}
+		c.record("retry")
+		return next()
+	}
+	inner := func(c *tstCtx, j *Job, next NextMiddlewareFunc) error {
+		c.record("<inner")
+		err := next()
+		c.record("inner>")
+		return err
+	}
+	var calls int
+	h1 := func(c *tstCtx, j *Job) error {
+		calls++
+		c.record("h1")
+		if calls == 1 {
+			return fmt.Errorf("h1_err")
+		}
+		return nil
+	}
+
+	middleware := []*middlewareHandler{
+		{DynamicMiddleware: reflect.ValueOf(outer)},
+		{DynamicMiddleware: reflect.ValueOf(retry)},
+		{DynamicMiddleware: reflect.ValueOf(inner)},
+	}
+	jt := &jobType{
+		Name:           "foo",
+		DynamicHandler: reflect.ValueOf(h1),
+	}
+
+	v, err := runJob(&Job{Name: "foo"}, tstCtxType, middleware, jt)
+	assert.NoError(t, err)
+	// Calling next again runs the rest of the chain again
+	assert.Equal(t, "<outer<innerh1inner>retry<innerh1inner>outer>", v.Interface().(*tstCtx).String())
+}
+
+func TestRunErrorWrapping(t *testing.T) {
+	errDown := errors.New("service down")
+	wrap := func(j *Job, next NextMiddlewareFunc) error {
+		if err := next(); err != nil {
+			return fmt.Errorf("job %s: %w", j.Name, err)
+		}
+		return nil
+	}
+	middleware := []*middlewareHandler{
+		{IsGeneric: true, GenericMiddlewareHandler: wrap},
+	}
+
+	// Errors wrapped by middleware can still be told apart, outcomes included
+	jt := &jobType{Name: "foo", IsGeneric: true, GenericHandler: func(j *Job) error { return errDown }}
+	_, err := runJob(&Job{Name: "foo"}, tstCtxType, middleware, jt)
+	assert.True(t, errors.Is(err, errDown))
+	assert.Equal(t, "job foo: service down", err.Error())
+
+	var outcome Outcome
+	jt = &jobType{Name: "foo", IsGeneric: true, GenericHandler: func(j *Job) error { return Retry(time.Minute, "busy") }}
+	_, err = runJob(&Job{Name: "foo"}, tstCtxType, middleware, jt)
+	if assert.True(t, errors.As(err, &outcome)) {
+		assert.Equal(t, OutcomeRetry, outcome.Kind)
+		assert.Equal(t, time.Minute, outcome.Delay)
+	}
+
+	// So can what a handler panicked with
+	jt = &jobType{Name: "foo", IsGeneric: true, GenericHandler: func(j *Job) error { panic(errDown) }}
+	_, err = runJob(&Job{Name: "foo"}, tstCtxType, middleware, jt)
+	assert.True(t, errors.Is(err, errDown))
+	assert.True(t, errors.As(err, new(jobPanic)))
+	assert.Equal(t, "service down", err.Error())
+}
+
+func TestRunMiddlewareContext(t *testing.T) {
+	type key struct{}
+	mw := func(j *Job, next NextMiddlewareFunc) error {
+		j.SetContext(context.WithValue(j.Context(), key{}, "span"))
+		return next()
+	}
+	var got interface{}
+	jt := &jobType{Name: "foo", IsGeneric: true, GenericHandler: func(j *Job) error {
+		got = j.Context().Value(key{})
+		return nil
+	}}
+
+	_, err := runJob(&Job{Name: "foo"}, tstCtxType, []*middlewareHandler{{IsGeneric: true, GenericMiddlewareHandler: mw}}, jt)
+	assert.NoError(t, err)
+	assert.Equal(t, "span", got)
+}
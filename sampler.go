@@ -0,0 +1,69 @@
+package work
+
+import (
+	"math/rand"
+)
+
+// QueueState is what a Sampler is told about a job type's queue.
+type QueueState struct {
+	JobName  string
+	Priority uint // the job type's priority, or its override in the live config
+}
+
+// Sampler decides the order in which a worker's fetch tries the queues of its job types: the job comes from the first
+// queue that has one the pool can run. Sample is called before every fetch with all of the pool's job types and
+// returns them in the order to try. It may reorder queues in place and return it; job types it leaves out aren't
+// fetched from this time. Job types that are disabled, rate limited or in their empty queue cooldown are left out of
+// the fetch regardless.
+//
+// A pool's workers share its Sampler, so Sample must be safe to call from several goroutines at once. It's on the
+// fetch path, so it should be quick and not block, eg on a Redis command of its own.
+type Sampler interface {
+	Sample(queues []QueueState) []QueueState
+}
+
+// WeightedSampler is the default Sampler. It orders queues by lottery, weighted by priority, so that each job type is
+// tried first in proportion to its priority's share of them all. Custom samplers can use it to break ties, or for the
+// job types they don't care to order themselves.
+type WeightedSampler struct{}
+
+// Sample implements Sampler.
+func (WeightedSampler) Sample(queues []QueueState) []QueueState {
+	var sumRemaining uint
+	for _, q := range queues {
+		sumRemaining += q.Priority
+	}
+	// Same algorithm as prioritySampler.sample: each draw moves the queue it lands on to the front of the rest
+	for next := 0; next < len(queues)-1 && sumRemaining > 0; next++ {
+		rn := uint(rand.Uint32()) % sumRemaining
+		prevSum := uint(0)
+		for i := len(queues) - 1; i >= next; i-- {
+			if rn < queues[i].Priority+prevSum {
+				queues[i], queues[next] = queues[next], queues[i]
+				sumRemaining -= queues[next].Priority
+				break
+			}
+			prevSum += queues[i].Priority
+		}
+	}
+	return queues
+}
+
+// sampleOrder returns the job types in the order the worker's next fetch should try them.
+func (w *worker) sampleOrder() []sampleItem {
+	if w.customSampler == nil {
+		return w.sampler.sample()
+	}
+
+	w.queueStates = w.queueStates[:0]
+	for _, s := range w.sampler.samples {
+		w.queueStates = append(w.queueStates, QueueState{JobName: s.jobName, Priority: s.priority})
+	}
+	w.ordered = w.ordered[:0]
+	for _, q := range w.customSampler.Sample(w.queueStates) {
+		if i, ok := w.sampleIndex[q.JobName]; ok {
+			w.ordered = append(w.ordered, w.sampler.samples[i])
+		}
+	}
+	return w.ordered
+}
@@ -0,0 +1,76 @@
+package work
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedSampler(t *testing.T) {
+	var first = make(map[string]int)
+	var lastIsLowest int
+	total := 8000
+	for i := 0; i < total; i++ {
+		queues := WeightedSampler{}.Sample([]QueueState{{"1b", 1}, {"5", 5}, {"2a", 2}})
+		assert.Len(t, queues, 3)
+		first[queues[0].JobName]++
+		if queues[2].JobName == "1b" {
+			lastIsLowest++
+		}
+	}
+
+	// Each is first in proportion to its priority, give or take; probability is a thing
+	for name, priority := range map[string]int{"1b": 1, "2a": 2, "5": 5} {
+		expected := total * priority / 8
+		assert.InDelta(t, expected, first[name], float64(expected)/5, "%s: %v", name, first)
+	}
+	assert.True(t, float64(lastIsLowest) > float64(total)*0.50)
+
+	assert.Empty(t, WeightedSampler{}.Sample(nil))
+	assert.Equal(t, []QueueState{{"a", 0}, {"b", 0}}, WeightedSampler{}.Sample([]QueueState{{"a", 0}, {"b", 0}}))
+}
+
+// byNameSampler tries queues in order of job name, leaving out the ones named in skip.
+type byNameSampler struct {
+	skip string
+}
+
+func (s byNameSampler) Sample(queues []QueueState) []QueueState {
+	sort.Slice(queues, func(i, j int) bool { return queues[i].JobName < queues[j].JobName })
+	ordered := queues[:0]
+	for _, q := range queues {
+		if q.JobName != s.skip {
+			ordered = append(ordered, q)
+		}
+	}
+	return ordered
+}
+
+func TestWorkerPoolSampler(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for _, name := range []string{"c", "b", "a", "c", "b", "a", "skipped"} {
+		_, err := enqueuer.Enqueue(name, nil)
+		assert.NoError(t, err)
+	}
+
+	var ran []string
+	wp := NewWorkerPoolWithOptions(TestContext{}, 1, ns, pool, WorkerPoolOptions{Sampler: byNameSampler{skip: "skipped"}})
+	for _, name := range []string{"a", "b", "c", "skipped"} {
+		// Left to the WeightedSampler, "skipped" would nearly always be tried first
+		wp.Job(name, func(job *Job) error {
+			ran = append(ran, job.Name)
+			return nil
+		}, Priority(map[string]uint{"a": 1, "b": 1, "c": 1, "skipped": 100}[name]))
+	}
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.Equal(t, []string{"a", "a", "b", "b", "c", "c"}, ran)
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "skipped")))
+}
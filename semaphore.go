@@ -0,0 +1,125 @@
+package work
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	semaphoreLeaseTTL      = 15 * time.Second
+	semaphoreRenewInterval = 5 * time.Second
+	semaphorePollInterval  = 100 * time.Millisecond
+)
+
+// Semaphore caps how many holders, across every process using the namespace, use a resource at once, eg a fragile
+// database that jobs of many types call. Unlike JobOptions.MaxConcurrency, which caps the jobs of one type, any handler
+// can hold a slot, for just the part of its work that needs the resource. Get one with WorkerPool.Semaphore.
+//
+// Slots are leases that are renewed while held, so the slots of a process that dies are freed within 15 seconds.
+type Semaphore struct {
+	name         string
+	limit        int
+	key          string
+	pool         *redis.Pool
+	redisTimeout time.Duration
+	errorHook    ErrorHook
+	logger       Logger
+
+	acquireScript *redis.Script
+	renewScript   *redis.Script
+}
+
+// Semaphore returns the semaphore named name, which lets at most limit holders in at once. Every process must use the
+// same limit for a name.
+func (wp *WorkerPool) Semaphore(name string, limit int) *Semaphore {
+	return &Semaphore{
+		name:          name,
+		limit:         limit,
+		key:           redisKeySemaphore(wp.namespace, name),
+		pool:          wp.pool,
+		redisTimeout:  wp.redisTimeout,
+		errorHook:     wp.errorHook,
+		logger:        wp.logger,
+		acquireScript: redis.NewScript(1, redisLuaAcquireSemaphore),
+		renewScript:   redis.NewScript(1, redisLuaRenewSemaphore),
+	}
+}
+
+// Acquire blocks until it holds a slot of the semaphore, or ctx is done, in which case it returns ctx's error. Call
+// release once done with the resource, eg with defer; it's safe to call more than once.
+func (s *Semaphore) Acquire(ctx context.Context) (release func(), err error) {
+	token := makeIdentifier()
+	for {
+		acquired, err := s.tryAcquire(token)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(semaphorePollInterval):
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go s.renew(token, stop, done)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			s.release(token)
+		})
+	}, nil
+}
+
+func (s *Semaphore) tryAcquire(token string) (bool, error) {
+	conn := getConn(s.pool, s.redisTimeout)
+	defer conn.Close()
+
+	return redis.Bool(s.acquireScript.Do(conn, s.key, token, s.limit, time.Now().UnixNano()/int64(time.Millisecond), semaphoreLeaseTTL.Milliseconds()))
+}
+
+// renew extends the lease on the slot until stop is closed.
+func (s *Semaphore) renew(token string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(semaphoreRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			conn := getConn(s.pool, s.redisTimeout)
+			renewed, err := redis.Bool(s.renewScript.Do(conn, s.key, token, time.Now().UnixNano()/int64(time.Millisecond), semaphoreLeaseTTL.Milliseconds()))
+			conn.Close()
+			if err != nil {
+				reportError(s.logger, s.errorHook, "semaphore.renew", err)
+			} else if !renewed {
+				// The lease ran out, eg while Redis was unreachable, and the slot may have gone to someone else
+				reportError(s.logger, s.errorHook, "semaphore.renew", fmt.Errorf("lost the lease on semaphore %s", s.name))
+				return
+			}
+		}
+	}
+}
+
+func (s *Semaphore) release(token string) {
+	conn := getConn(s.pool, s.redisTimeout)
+	defer conn.Close()
+
+	if _, err := conn.Do("ZREM", s.key, token); err != nil {
+		reportError(s.logger, s.errorHook, "semaphore.release", err)
+	}
+}
@@ -0,0 +1,56 @@
+package work
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphore(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	sem := wp.Semaphore("db", 2)
+
+	release1, err := sem.Acquire(context.Background())
+	assert.NoError(t, err)
+	release2, err := wp.Semaphore("db", 2).Acquire(context.Background())
+	assert.NoError(t, err)
+
+	// Both slots are taken
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = sem.Acquire(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// A waiter gets the slot once it's released
+	acquired := make(chan func())
+	go func() {
+		release, err := sem.Acquire(context.Background())
+		assert.NoError(t, err)
+		acquired <- release
+	}()
+	release1()
+	release1()
+	release3 := <-acquired
+	release2()
+	release3()
+
+	conn := pool.Get()
+	defer conn.Close()
+	n, err := redis.Int64(conn.Do("ZCARD", redisKeySemaphore(ns, "db")))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+
+	// The slots of holders whose lease ran out are freed
+	_, err = conn.Do("ZADD", redisKeySemaphore(ns, "db"), 1, "dead1", 1, "dead2")
+	assert.NoError(t, err)
+	release, err := sem.Acquire(context.Background())
+	assert.NoError(t, err)
+	release()
+}
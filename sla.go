@@ -0,0 +1,99 @@
+package work
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SLABreach is what an SLAHook is called with for a job that completed later than its type's JobOptions.SLA.
+type SLABreach struct {
+	JobName string
+	JobID   string
+	SLA     time.Duration
+	Took    time.Duration // from when the job was first enqueued until it completed, to the second
+}
+
+// SLAHook is called with each SLA breach, see WorkerPoolOptions.SLAHook. It's called from the worker that ran the job,
+// before the job is acknowledged, so it shouldn't block for long.
+type SLAHook func(SLABreach)
+
+// SLAStats counts a pool's completed jobs of one type by whether they met the type's JobOptions.SLA.
+type SLAStats struct {
+	SLA      time.Duration `json:"sla"`
+	Met      int64         `json:"met"`
+	Breached int64         `json:"breached"`
+}
+
+// Compliance is the fraction of the completed jobs that met the SLA, or 1 if none completed yet.
+func (s SLAStats) Compliance() float64 {
+	if s.Met+s.Breached == 0 {
+		return 1
+	}
+	return float64(s.Met) / float64(s.Met+s.Breached)
+}
+
+type slaCounts struct {
+	sla      int64 // nanoseconds
+	met      int64
+	breached int64
+}
+
+// checkSLA counts job, which just completed successfully, against its type's SLA, if it has one, calling the pool's
+// SLAHook if it breached it. Jobs scheduled to run later count from when they were enqueued too.
+func (w *worker) checkSLA(jt *jobType, job *Job) {
+	if jt == nil || jt.SLA <= 0 {
+		return
+	}
+	took := job.Age()
+	breached := took > jt.SLA
+	w.stats.slaChecked(job.Name, jt.SLA, breached)
+	if breached && w.slaHook != nil {
+		w.slaHook(SLABreach{JobName: job.Name, JobID: job.ID, SLA: jt.SLA, Took: took})
+	}
+}
+
+// slaChecked records whether a jobName job met its SLA.
+func (s *poolStats) slaChecked(jobName string, sla time.Duration, breached bool) {
+	if s == nil {
+		return
+	}
+
+	s.slaMtx.RLock()
+	c := s.sla[jobName]
+	s.slaMtx.RUnlock()
+	if c == nil {
+		s.slaMtx.Lock()
+		if c = s.sla[jobName]; c == nil {
+			if s.sla == nil {
+				s.sla = make(map[string]*slaCounts)
+			}
+			c = &slaCounts{}
+			s.sla[jobName] = c
+		}
+		s.slaMtx.Unlock()
+	}
+
+	atomic.StoreInt64(&c.sla, int64(sla))
+	if breached {
+		atomic.AddInt64(&c.breached, 1)
+	} else {
+		atomic.AddInt64(&c.met, 1)
+	}
+}
+
+func (s *poolStats) slaSnapshot() map[string]SLAStats {
+	s.slaMtx.RLock()
+	defer s.slaMtx.RUnlock()
+	if len(s.sla) == 0 {
+		return nil
+	}
+	stats := make(map[string]SLAStats, len(s.sla))
+	for jobName, c := range s.sla {
+		stats[jobName] = SLAStats{
+			SLA:      time.Duration(atomic.LoadInt64(&c.sla)),
+			Met:      atomic.LoadInt64(&c.met),
+			Breached: atomic.LoadInt64(&c.breached),
+		}
+	}
+	return stats
+}
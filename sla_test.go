@@ -0,0 +1,30 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerCheckSLA(t *testing.T) {
+	jt := &jobType{Name: "export", JobOptions: JobOptions{SLA: time.Minute}}
+	var breaches []SLABreach
+	w := newWorker("work", "1", nil, tstCtxType, nil, map[string]*jobType{"export": jt}, nil)
+	w.stats = &poolStats{}
+	w.slaHook = func(b SLABreach) { breaches = append(breaches, b) }
+
+	setNowEpochSecondsMock(1468360700)
+	defer resetNowEpochSecondsMock()
+
+	w.checkSLA(jt, &Job{Name: "export", ID: "a", EnqueuedAt: 1468360700 - 30})
+	w.checkSLA(jt, &Job{Name: "export", ID: "b", EnqueuedAt: 1468360700 - 60})
+	w.checkSLA(jt, &Job{Name: "export", ID: "c", EnqueuedAt: 1468360700 - 90})
+	w.checkSLA(&jobType{Name: "wat"}, &Job{Name: "wat", EnqueuedAt: 0})
+
+	assert.Equal(t, []SLABreach{{JobName: "export", JobID: "c", SLA: time.Minute, Took: 90 * time.Second}}, breaches)
+	stats := w.stats.snapshot().SLA
+	assert.Equal(t, map[string]SLAStats{"export": {SLA: time.Minute, Met: 2, Breached: 1}}, stats)
+	assert.InDelta(t, 2.0/3, stats["export"].Compliance(), 0.001)
+	assert.Equal(t, 1.0, SLAStats{}.Compliance())
+}
@@ -0,0 +1,34 @@
+// Reference producer for work's job payload format, see the spec package.
+
+const crypto = require("crypto");
+
+function namespacePrefix(namespace) {
+  return namespace === "" || namespace.endsWith(":") ? namespace : namespace + ":";
+}
+
+function encode(jobName, args = null, argsVersion = 0) {
+  const payload = {
+    name: jobName,
+    id: crypto.randomBytes(12).toString("hex"),
+    t: Math.floor(Date.now() / 1000),
+    args: args,
+  };
+  if (argsVersion) {
+    payload.args_version = argsVersion;
+  }
+  return JSON.stringify(payload);
+}
+
+// Enqueues a job with a node-redis v4 client.
+async function enqueue(redis, namespace, jobName, args = null) {
+  const prefix = namespacePrefix(namespace);
+  const payload = encode(jobName, args);
+  await redis
+    .multi()
+    .lPush(prefix + "jobs:" + jobName, payload)
+    .sAdd(prefix + "known_jobs", jobName)
+    .exec();
+  return payload;
+}
+
+module.exports = { encode, enqueue };
@@ -0,0 +1,32 @@
+"""Reference producer for work's job payload format, see the spec package."""
+
+import json
+import os
+import time
+
+
+def namespace_prefix(namespace):
+    return namespace if namespace.endswith(":") or not namespace else namespace + ":"
+
+
+def encode(job_name, args=None, args_version=0):
+    payload = {
+        "name": job_name,
+        "id": os.urandom(12).hex(),
+        "t": int(time.time()),
+        "args": args,
+    }
+    if args_version:
+        payload["args_version"] = args_version
+    return json.dumps(payload)
+
+
+def enqueue(redis, namespace, job_name, args=None):
+    """Enqueues a job with a redis-py client."""
+    prefix = namespace_prefix(namespace)
+    payload = encode(job_name, args)
+    pipe = redis.pipeline()
+    pipe.lpush(prefix + "jobs:" + job_name, payload)
+    pipe.sadd(prefix + "known_jobs", job_name)
+    pipe.execute()
+    return payload
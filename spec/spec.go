@@ -0,0 +1,173 @@
+// Package spec describes the format of the jobs that work's worker pools consume, so that producers in other
+// languages can enqueue jobs for Go workers, and checks payloads against it.
+//
+// To enqueue a job named jobName in a namespace, a producer:
+//
+//  1. Encodes the job as a JSON object, the payload, with these fields:
+//     - "name": jobName, a non-empty string
+//     - "id": a unique, non-empty string; work uses 24 random hex digits
+//     - "t": when the job was enqueued, in epoch seconds
+//     - "args": the job's arguments, a JSON object, or null for none
+//     - "args_version", optionally: the version of the arguments' layout, a non-negative integer, see
+//     WorkerPool.MigrateArgs
+//     - "fingerprint", optionally: a hash of the name and args that work's Enqueuer adds; others can leave it out
+//     - "trace", optionally: the trace context of the producer as an object of string values, eg W3C's
+//     "traceparent" and "tracestate", for workers' tracing middleware to continue, see work.TraceCarrier
+//  2. LPUSHes the payload onto the list QueueKey(namespace, jobName).
+//  3. SADDs jobName to the set KnownJobsKey(namespace), so the web UI and the requeuers know about the job type.
+//
+// To schedule a job instead, step 2 is a ZADD of the payload to ScheduledKey(namespace) with the epoch second it's due
+// at as its score. Optionally, producers can PUBLISH jobName to WakeChannel(namespace) after the LPUSH, so pools with
+// a WorkerPoolOptions.EmptyQueueCooldown fetch it without waiting out the cooldown, like Enqueuer.SetWakeWorkers.
+//
+// The other fields of a job, eg "fails", "err" and "history", belong to the workers. Producers must leave them out;
+// Validate rejects payloads that have them. Numbers in args are decoded as float64 on the Go side, so integers beyond
+// 2^53 should be sent as strings.
+//
+// The examples directory has reference producers in Python and Node.js.
+package spec
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Payload is a job as a producer enqueues it.
+type Payload struct {
+	Name        string                 `json:"name"`
+	ID          string                 `json:"id"`
+	EnqueuedAt  int64                  `json:"t"`
+	Args        map[string]interface{} `json:"args"`
+	ArgsVersion uint                   `json:"args_version,omitempty"`
+}
+
+// NewPayload returns a payload for a job named jobName with args, with a new ID and enqueued now.
+func NewPayload(jobName string, args map[string]interface{}) *Payload {
+	return &Payload{
+		Name:       jobName,
+		ID:         NewID(),
+		EnqueuedAt: time.Now().Unix(),
+		Args:       args,
+	}
+}
+
+// NewID returns a new job ID in the form work uses, 24 random hex digits.
+func NewID() string {
+	b := make([]byte, 12)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+// Encode returns p as JSON, ready to be enqueued, or an error if it isn't valid.
+func Encode(p *Payload) ([]byte, error) {
+	rawJSON, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(rawJSON); err != nil {
+		return nil, err
+	}
+	return rawJSON, nil
+}
+
+// ValidationError is the error for a payload that doesn't follow the spec.
+type ValidationError struct {
+	Field  string // the offending field, or "" if the payload as a whole is the problem
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return "spec: invalid payload: " + e.Reason
+	}
+	return fmt.Sprintf("spec: invalid payload: %q %s", e.Field, e.Reason)
+}
+
+// workerFields are the fields of a job that only workers set.
+var workerFields = []string{
+	"unique", "unique_key", "fails", "err", "failed_at", "dead_retention", "history", "annotations",
+}
+
+// Validate returns a *ValidationError if rawJSON isn't a payload a producer may enqueue, eg to check the output of a
+// producer in another language in its tests, or to vet payloads at a boundary.
+func Validate(rawJSON []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rawJSON, &fields); err != nil || fields == nil {
+		return &ValidationError{Reason: "not a JSON object"}
+	}
+
+	for _, field := range []string{"name", "id"} {
+		var s string
+		if err := json.Unmarshal(fields[field], &s); err != nil || s == "" {
+			return &ValidationError{Field: field, Reason: "must be a non-empty string"}
+		}
+	}
+
+	var t float64
+	if err := json.Unmarshal(fields["t"], &t); err != nil || t <= 0 || t != math.Trunc(t) {
+		return &ValidationError{Field: "t", Reason: "must be a positive integer of epoch seconds"}
+	}
+
+	if raw, ok := fields["args"]; !ok {
+		return &ValidationError{Field: "args", Reason: "is missing; use null for no arguments"}
+	} else if string(raw) != "null" {
+		var args map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return &ValidationError{Field: "args", Reason: "must be a JSON object or null"}
+		}
+	}
+
+	if raw, ok := fields["args_version"]; ok {
+		var v float64
+		if err := json.Unmarshal(raw, &v); err != nil || v < 0 || v != math.Trunc(v) {
+			return &ValidationError{Field: "args_version", Reason: "must be a non-negative integer"}
+		}
+	}
+
+	if raw, ok := fields["trace"]; ok {
+		var trace map[string]string
+		if err := json.Unmarshal(raw, &trace); err != nil || trace == nil {
+			return &ValidationError{Field: "trace", Reason: "must be a JSON object of strings"}
+		}
+	}
+
+	for _, field := range workerFields {
+		if _, ok := fields[field]; ok {
+			return &ValidationError{Field: field, Reason: "is set by workers, not producers"}
+		}
+	}
+	return nil
+}
+
+func namespacePrefix(namespace string) string {
+	if l := len(namespace); l > 0 && namespace[l-1] != ':' {
+		return namespace + ":"
+	}
+	return namespace
+}
+
+// QueueKey returns the key of the Redis list that jobs named jobName are LPUSHed onto.
+func QueueKey(namespace, jobName string) string {
+	return namespacePrefix(namespace) + "jobs:" + jobName
+}
+
+// KnownJobsKey returns the key of the Redis set of the namespace's job names.
+func KnownJobsKey(namespace string) string {
+	return namespacePrefix(namespace) + "known_jobs"
+}
+
+// ScheduledKey returns the key of the Redis sorted set of scheduled jobs, scored by when they're due.
+func ScheduledKey(namespace string) string {
+	return namespacePrefix(namespace) + "scheduled"
+}
+
+// WakeChannel returns the Redis pub/sub channel that announces enqueued jobs by name.
+func WakeChannel(namespace string) string {
+	return namespacePrefix(namespace) + "wake"
+}
@@ -0,0 +1,57 @@
+package spec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	p := NewPayload("send_email", map[string]interface{}{"to": "ada@example.com"})
+	assert.Len(t, p.ID, 24)
+
+	rawJSON, err := Encode(p)
+	assert.NoError(t, err)
+	assert.NoError(t, Validate(rawJSON))
+
+	_, err = Encode(&Payload{ID: NewID(), EnqueuedAt: 1})
+	assert.Equal(t, &ValidationError{Field: "name", Reason: "must be a non-empty string"}, err)
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{
+		`{"name":"wat","id":"abc","t":1500000000,"args":null}`,
+		`{"name":"wat","id":"abc","t":1500000000,"args":{"a":1},"args_version":2,"fingerprint":"f00"}`,
+		`{"name":"wat","id":"abc","t":1500000000,"args":null,"trace":{"traceparent":"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}}`,
+	}
+	for _, rawJSON := range valid {
+		assert.NoError(t, Validate([]byte(rawJSON)), rawJSON)
+	}
+
+	invalid := map[string]string{
+		`[]`: "",
+		`{"id":"abc","t":1500000000,"args":null}`:                                "name",
+		`{"name":"wat","id":"","t":1500000000,"args":null}`:                      "id",
+		`{"name":"wat","id":"abc","t":"now","args":null}`:                        "t",
+		`{"name":"wat","id":"abc","t":1500000000.5,"args":null}`:                 "t",
+		`{"name":"wat","id":"abc","t":1500000000}`:                               "args",
+		`{"name":"wat","id":"abc","t":1500000000,"args":[1]}`:                    "args",
+		`{"name":"wat","id":"abc","t":1500000000,"args":null,"args_version":-1}`: "args_version",
+		`{"name":"wat","id":"abc","t":1500000000,"args":null,"fails":1}`:         "fails",
+		`{"name":"wat","id":"abc","t":1500000000,"args":null,"trace":{"a":1}}`:   "trace",
+	}
+	for rawJSON, field := range invalid {
+		err := Validate([]byte(rawJSON))
+		if assert.IsType(t, &ValidationError{}, err, rawJSON) {
+			assert.Equal(t, field, err.(*ValidationError).Field, rawJSON)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	assert.Equal(t, "work:jobs:wat", QueueKey("work", "wat"))
+	assert.Equal(t, "work:jobs:wat", QueueKey("work:", "wat"))
+	assert.Equal(t, "work:known_jobs", KnownJobsKey("work"))
+	assert.Equal(t, "work:scheduled", ScheduledKey("work"))
+	assert.Equal(t, "work:wake", WakeChannel("work"))
+}
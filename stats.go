@@ -0,0 +1,218 @@
+package work
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerPoolStats is an in-memory snapshot of what a worker pool's workers have done since the pool was created.
+// It doesn't touch Redis, so it's cheap enough to serve from a health check endpoint.
+type WorkerPoolStats struct {
+	Processed      int64         `json:"processed"`        // Jobs that ran to completion, successfully or not
+	Failed         int64         `json:"failed"`           // Jobs whose handler returned an error or panicked, plus stray jobs with no handler
+	InFlight       int64         `json:"in_flight"`        // Jobs currently running
+	FetchErrors    int64         `json:"fetch_errors"`     // Errors encountered while fetching jobs from Redis
+	Retried        int64         `json:"retried"`          // Failed jobs sent to the retry queue
+	Died           int64         `json:"died"`             // Failed jobs sent to the dead queue
+	Discarded      int64         `json:"discarded"`        // Failed jobs dropped, with a Discard outcome or because their type has SkipDead
+	Shed           int64         `json:"shed"`             // Jobs dropped without running because their type was shed, see ConfigShed
+	AvgHandlerTime time.Duration `json:"avg_handler_time"` // Average time spent in middleware and handlers per processed job
+
+	// Sampler has the priority sampler's decisions by job type, so the service ratios priorities produce can be
+	// compared to the ratios they were meant to produce. Job types the sampler hasn't considered yet are left out.
+	Sampler map[string]SamplerStats `json:"sampler,omitempty"`
+
+	// SLA has the completed jobs of each job type with JobOptions.SLA, by whether they met it.
+	SLA map[string]SLAStats `json:"sla,omitempty"`
+}
+
+// SamplerStats counts the priority sampler's decisions for one job type.
+type SamplerStats struct {
+	Priority uint  `json:"priority"` // The job type's priority when last sampled
+	Picks    int64 `json:"picks"`    // Fetches for which the job type was ranked first, whether or not its queue had a job
+	Fetched  int64 `json:"fetched"`  // Fetches that got a job from the job type's queue, not counting the rest of a batch
+}
+
+// poolStats holds the counters behind WorkerPoolStats. They are shared by all of a pool's workers and updated
+// atomically. A nil *poolStats is valid and records nothing.
+type poolStats struct {
+	processed    int64
+	failed       int64
+	inFlight     int64
+	fetchErrors  int64
+	retried      int64
+	died         int64
+	discarded    int64
+	shed         int64
+	handled      int64 // processed jobs that had a handler, for averaging handlerNanos
+	handlerNanos int64
+
+	samplerMtx sync.RWMutex
+	sampler    map[string]*samplerCounts
+
+	slaMtx sync.RWMutex
+	sla    map[string]*slaCounts
+
+	sink MetricsSink // see WorkerPoolOptions.Metrics
+}
+
+type samplerCounts struct {
+	priority uint64
+	picks    int64
+	fetched  int64
+}
+
+func (s *poolStats) jobStarted() {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.inFlight, 1)
+}
+
+func (s *poolStats) jobFetched(jobName string) {
+	if s == nil {
+		return
+	}
+	s.count("work.job.fetched", jobTag(jobName))
+}
+
+func (s *poolStats) jobDone(jobName string, elapsed time.Duration, failed bool) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.inFlight, -1)
+	atomic.AddInt64(&s.processed, 1)
+	atomic.AddInt64(&s.handled, 1)
+	atomic.AddInt64(&s.handlerNanos, int64(elapsed))
+	if failed {
+		atomic.AddInt64(&s.failed, 1)
+	}
+
+	if s.sink != nil {
+		s.sink.Timing("work.job.duration", elapsed, jobTag(jobName))
+		if failed {
+			s.count("work.job.failed", jobTag(jobName))
+		} else {
+			s.count("work.job.succeeded", jobTag(jobName))
+		}
+	}
+}
+
+func (s *poolStats) jobStray(jobName string) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.processed, 1)
+	atomic.AddInt64(&s.failed, 1)
+	s.count("work.job.failed", jobTag(jobName))
+}
+
+func (s *poolStats) jobShed(jobName string) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.shed, 1)
+	s.count("work.job.shed", jobTag(jobName))
+}
+
+func (s *poolStats) fetchError() {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.fetchErrors, 1)
+	s.count("work.fetch.error")
+}
+
+// jobOutcome records where a failed jobName job went.
+func (s *poolStats) jobOutcome(jobName string, kind OutcomeKind) {
+	if s == nil {
+		return
+	}
+	switch kind {
+	case OutcomeRetry:
+		atomic.AddInt64(&s.retried, 1)
+		s.count("work.job.retried", jobTag(jobName))
+	case OutcomeDead:
+		atomic.AddInt64(&s.died, 1)
+		s.count("work.job.dead", jobTag(jobName))
+	case OutcomeDiscard:
+		atomic.AddInt64(&s.discarded, 1)
+		s.count("work.job.discarded", jobTag(jobName))
+	}
+}
+
+// samplerCounts returns the counts of jobName, creating them the first time.
+func (s *poolStats) samplerCounts(jobName string) *samplerCounts {
+	s.samplerMtx.RLock()
+	c := s.sampler[jobName]
+	s.samplerMtx.RUnlock()
+	if c != nil {
+		return c
+	}
+
+	s.samplerMtx.Lock()
+	defer s.samplerMtx.Unlock()
+	if c = s.sampler[jobName]; c == nil {
+		if s.sampler == nil {
+			s.sampler = make(map[string]*samplerCounts)
+		}
+		c = &samplerCounts{}
+		s.sampler[jobName] = c
+	}
+	return c
+}
+
+// samplerPicked records that the sampler ranked jobName first for a fetch.
+func (s *poolStats) samplerPicked(jobName string, priority uint) {
+	if s == nil {
+		return
+	}
+	c := s.samplerCounts(jobName)
+	atomic.StoreUint64(&c.priority, uint64(priority))
+	atomic.AddInt64(&c.picks, 1)
+}
+
+// samplerFetched records that a fetch got a job from jobName's queue.
+func (s *poolStats) samplerFetched(jobName string) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.samplerCounts(jobName).fetched, 1)
+}
+
+func (s *poolStats) samplerSnapshot() map[string]SamplerStats {
+	s.samplerMtx.RLock()
+	defer s.samplerMtx.RUnlock()
+	if len(s.sampler) == 0 {
+		return nil
+	}
+	stats := make(map[string]SamplerStats, len(s.sampler))
+	for jobName, c := range s.sampler {
+		stats[jobName] = SamplerStats{
+			Priority: uint(atomic.LoadUint64(&c.priority)),
+			Picks:    atomic.LoadInt64(&c.picks),
+			Fetched:  atomic.LoadInt64(&c.fetched),
+		}
+	}
+	return stats
+}
+
+func (s *poolStats) snapshot() WorkerPoolStats {
+	stats := WorkerPoolStats{
+		Processed:   atomic.LoadInt64(&s.processed),
+		Failed:      atomic.LoadInt64(&s.failed),
+		InFlight:    atomic.LoadInt64(&s.inFlight),
+		FetchErrors: atomic.LoadInt64(&s.fetchErrors),
+		Retried:     atomic.LoadInt64(&s.retried),
+		Died:        atomic.LoadInt64(&s.died),
+		Discarded:   atomic.LoadInt64(&s.discarded),
+		Shed:        atomic.LoadInt64(&s.shed),
+		Sampler:     s.samplerSnapshot(),
+		SLA:         s.slaSnapshot(),
+	}
+	if handled := atomic.LoadInt64(&s.handled); handled > 0 {
+		stats.AvgHandlerTime = time.Duration(atomic.LoadInt64(&s.handlerNanos) / handled)
+	}
+	return stats
+}
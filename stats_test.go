@@ -0,0 +1,100 @@
+package work
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerPoolStats(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	wp := NewWorkerPool(TestContext{}, 2, ns, pool)
+	wp.Job(job1, func(job *Job) error {
+		time.Sleep(2 * time.Millisecond)
+		if job.ArgBool("fail") {
+			return fmt.Errorf("sorry kid")
+		}
+		return nil
+	})
+
+	assert.Equal(t, WorkerPoolStats{}, wp.Stats())
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 3; i++ {
+		_, err := enqueuer.Enqueue(job1, Q{"fail": i == 0})
+		assert.NoError(t, err)
+	}
+
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	stats := wp.Stats()
+	assert.EqualValues(t, 3, stats.Processed)
+	assert.EqualValues(t, 1, stats.Failed)
+	assert.EqualValues(t, 0, stats.InFlight)
+	assert.EqualValues(t, 0, stats.FetchErrors)
+	assert.EqualValues(t, 1, stats.Retried)
+	assert.True(t, stats.AvgHandlerTime >= 2*time.Millisecond)
+	assert.EqualValues(t, 1, stats.Sampler[job1].Priority)
+	assert.EqualValues(t, 3, stats.Sampler[job1].Fetched)
+	assert.True(t, stats.Sampler[job1].Picks >= 3)
+}
+
+func TestPoolStatsNil(t *testing.T) {
+	var s *poolStats
+	s.jobStarted()
+	s.jobFetched("foo")
+	s.jobDone("foo", time.Second, true)
+	s.jobStray("foo")
+	s.jobShed("foo")
+	s.fetchError()
+	s.samplerPicked("foo", 1)
+	s.samplerFetched("foo")
+	s.jobOutcome("foo", OutcomeRetry)
+}
+
+type testMetricsSink struct {
+	mtx  sync.Mutex
+	sent []string
+}
+
+func (s *testMetricsSink) Count(name string, value int64, tags ...string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.sent = append(s.sent, fmt.Sprint(name, " ", value, " ", tags))
+}
+
+func (s *testMetricsSink) Timing(name string, d time.Duration, tags ...string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.sent = append(s.sent, fmt.Sprint(name, " ", d, " ", tags))
+}
+
+func TestPoolStatsMetrics(t *testing.T) {
+	sink := &testMetricsSink{}
+	s := &poolStats{sink: sink}
+	s.jobFetched("export")
+	s.jobStarted()
+	s.jobDone("export", time.Second, false)
+	s.jobDone("export", 2*time.Second, true)
+	s.jobOutcome("export", OutcomeDead)
+	s.fetchError()
+
+	assert.Equal(t, []string{
+		"work.job.fetched 1 [job:export]",
+		"work.job.duration 1s [job:export]",
+		"work.job.succeeded 1 [job:export]",
+		"work.job.duration 2s [job:export]",
+		"work.job.failed 1 [job:export]",
+		"work.job.dead 1 [job:export]",
+		"work.fetch.error 1 []",
+	}, sink.sent)
+}
@@ -0,0 +1,120 @@
+package work
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// SetTap makes the enqueuer copy every job it enqueues from now on to the namespace's tap: a Redis stream capped to
+// roughly maxLen entries that a Tap can read without taking the jobs off their queues. It's meant for analytics and
+// auditing pipelines that want to watch job traffic without affecting processing. A maxLen of 0 turns the tap off.
+//
+// Copying a job to the tap is best effort: an error doing so is logged, but doesn't fail the enqueue.
+func (e *Enqueuer) SetTap(maxLen int64) {
+	e.mtx.Lock()
+	e.tapMaxLen = maxLen
+	e.mtx.Unlock()
+}
+
+// TapEntry is a job copied to the tap when it was enqueued.
+type TapEntry struct {
+	ID    string `json:"id"`     // Stream entry ID, which starts with the millisecond it was enqueued at
+	RunAt int64  `json:"run_at"` // When the job was scheduled to run, or 0 if it was enqueued to run right away
+	*Job
+}
+
+// Tap reads the jobs that enqueuers with SetTap copied to a namespace's tap, oldest first. Reading doesn't remove
+// anything, from the tap or from the queues, so any number of taps can read at once. A Tap isn't safe for concurrent
+// use.
+type Tap struct {
+	namespace string
+	pool      *redis.Pool
+	lastID    string
+}
+
+// NewTap creates a Tap that starts at the oldest job still in the namespace's tap.
+func NewTap(namespace string, pool *redis.Pool) *Tap {
+	return NewTapFrom(namespace, pool, "0")
+}
+
+// NewTapFrom creates a Tap that starts after the entry with the given ID, eg one saved from LastID to resume reading
+// where a previous Tap left off. "$" starts with the next job enqueued.
+func NewTapFrom(namespace string, pool *redis.Pool, lastID string) *Tap {
+	return &Tap{
+		namespace: namespace,
+		pool:      pool,
+		lastID:    lastID,
+	}
+}
+
+// Read returns up to count of the next jobs in the tap. If there are none, it waits up to block for one to be
+// enqueued, or returns right away if block is 0.
+func (t *Tap) Read(count int, block time.Duration) ([]*TapEntry, error) {
+	conn := t.pool.Get()
+	defer conn.Close()
+
+	args := []interface{}{"COUNT", count}
+	if block > 0 {
+		args = append(args, "BLOCK", block.Milliseconds())
+	}
+	args = append(args, "STREAMS", redisKeyTap(t.namespace), t.lastID)
+
+	streams, err := redis.Values(conn.Do("XREAD", args...))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(streams) != 1 {
+		return nil, fmt.Errorf("tap: need 1 stream back, got %d", len(streams))
+	}
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil || len(stream) != 2 {
+		return nil, fmt.Errorf("tap: bad stream reply")
+	}
+	messages, err := redis.Values(stream[1], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*TapEntry, 0, len(messages))
+	for _, m := range messages {
+		entry, err := newTapEntry(m)
+		if err != nil {
+			return entries, err
+		}
+		t.lastID = entry.ID
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// LastID returns the ID of the last entry read, from which NewTapFrom can resume.
+func (t *Tap) LastID() string {
+	return t.lastID
+}
+
+func newTapEntry(message interface{}) (*TapEntry, error) {
+	parts, err := redis.Values(message, nil)
+	if err != nil || len(parts) != 2 {
+		return nil, fmt.Errorf("tap: bad entry")
+	}
+	id, err := redis.String(parts[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := redis.StringMap(parts[1], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := newJob([]byte(fields["job"]), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	runAt, _ := strconv.ParseInt(fields["run_at"], 10, 64)
+	return &TapEntry{ID: id, RunAt: runAt, Job: job}, nil
+}
@@ -0,0 +1,83 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTap(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue(job1, Q{"i": 0})
+	assert.NoError(t, err)
+
+	enqueuer.SetTap(100)
+	_, err = enqueuer.Enqueue(job1, Q{"i": 1})
+	assert.NoError(t, err)
+	scheduled, err := enqueuer.EnqueueIn(job1, 60, Q{"i": 2})
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueUnique(job1, Q{"i": 3})
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueUnique(job1, Q{"i": 3}) // not enqueued, so not tapped either
+	assert.NoError(t, err)
+
+	tap := NewTap(ns, pool)
+	entries, err := tap.Read(10, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.EqualValues(t, 1, entries[0].ArgInt64("i"))
+		assert.EqualValues(t, 0, entries[0].RunAt)
+		assert.EqualValues(t, 2, entries[1].ArgInt64("i"))
+		assert.Equal(t, scheduled.RunAt, entries[1].RunAt)
+		assert.True(t, entries[2].Unique)
+		assert.Equal(t, entries[2].ID, tap.LastID())
+	}
+
+	// Reading didn't take anything off the queues
+	assert.EqualValues(t, 3, listSize(pool, redisKeyJobs(ns, job1)))
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyScheduled(ns)))
+
+	entries, err = tap.Read(10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	// A new tap can resume from where this one left off
+	_, err = enqueuer.Enqueue(job1, Q{"i": 4})
+	assert.NoError(t, err)
+	entries, err = NewTapFrom(ns, pool, tap.LastID()).Read(10, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.EqualValues(t, 4, entries[0].ArgInt64("i"))
+	}
+}
+
+func TestTapBlockingRead(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	enqueuer.SetTap(100)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		enqueuer.Enqueue(job1, Q{"i": 1})
+	}()
+
+	entries, err := NewTapFrom(ns, pool, "$").Read(10, time.Second)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.EqualValues(t, 1, entries[0].ArgInt64("i"))
+	}
+
+	entries, err = NewTapFrom(ns, pool, "$").Read(10, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
@@ -0,0 +1,31 @@
+package work
+
+// TraceCarrier holds a trace context as string fields, eg "traceparent" and "tracestate". Its methods match
+// OpenTelemetry's propagation.TextMapCarrier, so a propagator can inject into and extract from a job's Trace without
+// this package depending on OpenTelemetry. The contrib/workotel module has an enqueue hook that injects the context of
+// EnqueueContext's ctx and middleware that continues it around the job, for the handler to see in its Context:
+//
+//	enqueuer.AddHook(workotel.EnqueueHook(workotel.Options{}))
+//	pool.Middleware(workotel.Middleware(workotel.Options{}))
+//
+// The trace context stays with the job through retries, so each attempt's span has the enqueuer's as its parent.
+type TraceCarrier map[string]string
+
+// Get returns the value of key, or "" if it isn't set.
+func (c TraceCarrier) Get(key string) string {
+	return c[key]
+}
+
+// Set sets key to value.
+func (c TraceCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys returns the keys that are set.
+func (c TraceCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
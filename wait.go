@@ -0,0 +1,88 @@
+package work
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const waitForEmptyInterval = 100 * time.Millisecond
+
+// WaitForEmpty blocks until no jobs named jobName are left to process: none in its queue, none being processed by a
+// worker pool and none waiting to be retried. It's meant for deployment scripts and tests that need a queue to be
+// fully processed before going on. Scheduled jobs and dead jobs aren't waited for. If ctx is done first, its error is
+// returned.
+//
+// Each check looks at the queue, the jobs in progress and the retry queues together. The namespace's retry queues are
+// shared with other job types, so they're looked through a page at a time, and only once the queue is empty and
+// nothing is in progress. The job of jobName found there is remembered, and later checks only look further once it's
+// gone, so waiting on a large retry queue stays cheap.
+func (c *Client) WaitForEmpty(ctx context.Context, jobName string) error {
+	script := redis.NewScript(4, redisLuaIsQueueEmpty)
+	retryKeys := []string{redisKeyRetry(c.namespace), redisKeyRetryExpress(c.namespace)}
+	found := make([][]byte, len(retryKeys)) // the jobs of jobName found in each retry queue, if any
+
+	for {
+		empty, err := c.isQueueEmpty(script, jobName, retryKeys, found)
+		if err != nil {
+			logError("client.wait_for_empty", err)
+			return err
+		}
+		if empty {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitForEmptyInterval):
+		}
+	}
+}
+
+// isQueueEmpty checks retryKeys a page at a time for jobs of jobName, starting with the ones found there last time.
+func (c *Client) isQueueEmpty(script *redis.Script, jobName string, retryKeys []string, found [][]byte) (bool, error) {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	for i, retryKey := range retryKeys {
+		var offset int64
+		for {
+			values, err := redis.Values(script.Do(conn,
+				redisKeyJobs(c.namespace, jobName),
+				redisKeyJobsLock(c.namespace, jobName),
+				redisKeyRetryOf(c.namespace, jobName),
+				retryKey,
+				jobName,
+				offset,
+				found[i],
+			))
+			if err != nil {
+				return false, err
+			}
+			var status int64
+			var rest []interface{}
+			if rest, err = redis.Scan(values, &status); err != nil {
+				return false, err
+			}
+
+			switch status {
+			case 0:
+				found[i] = nil
+				if len(rest) > 0 {
+					found[i], err = redis.Bytes(rest[0], nil)
+				}
+				return false, err
+			case 2:
+				if offset, err = redis.Int64(rest[0], nil); err != nil {
+					return false, err
+				}
+				continue
+			}
+			found[i] = nil
+			break
+		}
+	}
+	return true, nil
+}
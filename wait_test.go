@@ -0,0 +1,76 @@
+package work
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientWaitForEmpty(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	client := NewClient(ns, pool)
+	assert.NoError(t, client.WaitForEmpty(context.Background(), "wat"))
+
+	waitBriefly := func(jobName string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return client.WaitForEmpty(ctx, jobName)
+	}
+
+	// A job waiting to be retried counts, but not one of another type whose name starts the same
+	conn := pool.Get()
+	defer conn.Close()
+	for _, name := range []string{"wat", "wat_else"} {
+		rawJSON, err := (&Job{Name: name, ID: makeIdentifier()}).serialize()
+		assert.NoError(t, err)
+		_, err = conn.Do("ZADD", redisKeyRetry(ns), nowEpochSeconds()+3600, rawJSON)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, context.DeadlineExceeded, waitBriefly("wat"))
+	assert.NoError(t, waitBriefly("wat_"))
+	cleanKeyspace(ns, pool)
+
+	// Jobs are matched on their name, whatever order a producer wrote their fields in, past the first page
+	for i := 0; i < requeueScanSize+10; i++ {
+		rawJSON, err := (&Job{Name: "other", ID: makeIdentifier()}).serialize()
+		assert.NoError(t, err)
+		_, err = conn.Do("ZADD", redisKeyRetryExpress(ns), nowEpochSeconds()+60, rawJSON)
+		assert.NoError(t, err)
+	}
+	_, err := conn.Do("ZADD", redisKeyRetryExpress(ns), nowEpochSeconds()+3600, `{"id":"1","t":1,"args":null,"name":"wat"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, context.DeadlineExceeded, waitBriefly("wat"))
+	assert.NoError(t, waitBriefly("other_"))
+	cleanKeyspace(ns, pool)
+
+	// So do queued jobs and jobs in progress
+	processed := 0
+	release := make(chan struct{})
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job("wat", func(job *Job) error {
+		<-release
+		processed++
+		return nil
+	})
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 3; i++ {
+		_, err := enqueuer.Enqueue("wat", nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, context.DeadlineExceeded, waitBriefly("wat"))
+
+	wp.Start()
+	defer wp.Stop()
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 2, listSize(pool, redisKeyJobs(ns, "wat"))) // the other one is in progress
+	assert.Equal(t, context.DeadlineExceeded, waitBriefly("wat"))
+
+	close(release)
+	assert.NoError(t, client.WaitForEmpty(context.Background(), "wat"))
+	assert.Equal(t, 3, processed)
+}
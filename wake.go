@@ -0,0 +1,132 @@
+package work
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const wakeListenerRetryDelay = 1 * time.Second
+
+// SetWakeWorkers makes the enqueuer announce the name of every job it enqueues from now on to run right away, so that
+// worker pools with WorkerPoolOptions.EmptyQueueCooldown check that queue again without waiting out the cooldown.
+// Idle workers of those pools also wake up rather than finish their sleep. It costs a PUBLISH per enqueue, which on
+// Redis Cluster goes to every node.
+//
+// Announcing a job is best effort: an error doing so is logged, but doesn't fail the enqueue.
+func (e *Enqueuer) SetWakeWorkers(wake bool) {
+	e.mtx.Lock()
+	e.wakeWorkers = wake
+	e.mtx.Unlock()
+}
+
+// wakeListener subscribes to the job names enqueuers announce and passes those of the pool's job types on to its
+// workers. It holds a connection of its own for as long as it runs.
+type wakeListener struct {
+	namespace string
+	pool      *redis.Pool
+	jobTypes  map[string]*jobType
+	workers   []*worker
+	errorHook ErrorHook
+	logger    Logger
+
+	mtx     sync.Mutex
+	psc     *redis.PubSubConn // while subscribed
+	stopped bool
+
+	stopChan         chan struct{}
+	doneStoppingChan chan struct{}
+}
+
+func newWakeListener(namespace string, pool *redis.Pool, jobTypes map[string]*jobType, workers []*worker) *wakeListener {
+	return &wakeListener{
+		namespace:        namespace,
+		pool:             pool,
+		jobTypes:         jobTypes,
+		workers:          workers,
+		stopChan:         make(chan struct{}),
+		doneStoppingChan: make(chan struct{}),
+	}
+}
+
+func (wl *wakeListener) start() {
+	go wl.loop()
+}
+
+// stop unsubscribes, which ends a listen in progress, or ends the wait to subscribe again.
+func (wl *wakeListener) stop() {
+	wl.mtx.Lock()
+	wl.stopped = true
+	if wl.psc != nil {
+		if err := wl.psc.Unsubscribe(); err != nil {
+			// Closing the connection ends the listen just the same
+			wl.psc.Close()
+		}
+	}
+	wl.mtx.Unlock()
+	close(wl.stopChan)
+	<-wl.doneStoppingChan
+}
+
+func (wl *wakeListener) loop() {
+	for wl.listen() {
+		select {
+		case <-wl.stopChan:
+			wl.doneStoppingChan <- struct{}{}
+			return
+		case <-time.After(wakeListenerRetryDelay):
+		}
+	}
+	wl.doneStoppingChan <- struct{}{}
+}
+
+// listen subscribes and passes on announcements until the listener is stopped, when it returns false, or the
+// subscription fails, when it returns true to have loop subscribe again. The connection is deliberately left without
+// the pool's redis timeout, since it blocks until something is enqueued.
+func (wl *wakeListener) listen() bool {
+	psc := redis.PubSubConn{Conn: wl.pool.Get()}
+	defer psc.Close()
+
+	wl.mtx.Lock()
+	if wl.stopped {
+		wl.mtx.Unlock()
+		return false
+	}
+	if err := psc.Subscribe(redisKeyWake(wl.namespace)); err != nil {
+		wl.mtx.Unlock()
+		reportError(wl.logger, wl.errorHook, "wake_listener.subscribe", err)
+		return true
+	}
+	wl.psc = &psc
+	wl.mtx.Unlock()
+
+	defer func() {
+		wl.mtx.Lock()
+		wl.psc = nil
+		wl.mtx.Unlock()
+	}()
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			if _, ok := wl.jobTypes[string(v.Data)]; ok {
+				for _, w := range wl.workers {
+					w.wakeUp(string(v.Data))
+				}
+			}
+		case redis.Subscription:
+			if v.Kind == "unsubscribe" && v.Count == 0 {
+				return false
+			}
+		case error:
+			wl.mtx.Lock()
+			stopped := wl.stopped
+			wl.mtx.Unlock()
+			if !stopped {
+				reportError(wl.logger, wl.errorHook, "wake_listener.receive", v)
+			}
+			return !stopped
+		}
+	}
+}
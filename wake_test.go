@@ -0,0 +1,78 @@
+package work
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerEmptyQueueCooldown(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	jobTypes := map[string]*jobType{
+		"busy": {Name: "busy", JobOptions: JobOptions{Priority: 1}},
+		"idle": {Name: "idle", JobOptions: JobOptions{Priority: 1}},
+	}
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.emptyQueueCooldown = time.Hour
+
+	job, err := w.fetchJob()
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+	assert.True(t, w.recentlyEmpty("busy", time.Now()))
+	assert.True(t, w.recentlyEmpty("idle", time.Now()))
+
+	// Skipped until the cooldown ends, even though there's a job now
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err = enqueuer.Enqueue("busy", nil)
+	assert.NoError(t, err)
+	job, err = w.fetchJob()
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+
+	// Once the cooldown is over, it's fetched again
+	assert.False(t, w.recentlyEmpty("busy", time.Now().Add(time.Hour)))
+
+	job, err = w.fetchJob()
+	assert.NoError(t, err)
+	if assert.NotNil(t, job) {
+		assert.Equal(t, "busy", job.Name)
+	}
+	assert.False(t, w.recentlyEmpty("busy", time.Now()))
+}
+
+func TestWorkerPoolWakeWorkers(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	var handled int64
+	wp := NewWorkerPoolWithOptions(TestContext{}, 2, ns, pool, WorkerPoolOptions{EmptyQueueCooldown: time.Hour})
+	wp.Job("wat", func(job *Job) error {
+		atomic.AddInt64(&handled, 1)
+		return nil
+	})
+	wp.Start()
+	defer wp.Stop()
+
+	// Let the workers find the queue empty, and the wake listener subscribe
+	time.Sleep(50 * time.Millisecond)
+
+	_, err := NewEnqueuer(ns, pool).Enqueue("wat", nil)
+	assert.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.EqualValues(t, 0, atomic.LoadInt64(&handled))
+
+	enqueuer := NewEnqueuer(ns, pool)
+	enqueuer.SetWakeWorkers(true)
+	_, err = enqueuer.Enqueue("wat", nil)
+	assert.NoError(t, err)
+	for i := 0; i < 100 && atomic.LoadInt64(&handled) < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt64(&handled))
+}
@@ -0,0 +1,105 @@
+package work
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Stats is a snapshot of a namespace's queues and workers, see Client.Stats.
+type Stats struct {
+	At          int64                  `json:"at"`           // When the snapshot was taken, in epoch seconds
+	Queues      []*Queue               `json:"queues"`       // As returned by Client.Queues
+	WorkerPools []*WorkerPoolHeartbeat `json:"worker_pools"` // As returned by Client.WorkerPoolHeartbeats
+	Workers     int                    `json:"workers"`      // Workers of all pools
+	BusyWorkers int                    `json:"busy_workers"` // Workers processing a job
+	Scheduled   int64                  `json:"scheduled"`    // Jobs in the scheduled queue
+	Retry       int64                  `json:"retry"`        // Jobs waiting to be retried, including those in job types' own retry queues
+	Dead        int64                  `json:"dead"`         // Dead jobs, including those in job types' own dead queues
+}
+
+// Stats returns a snapshot of the namespace's queues and workers, in one value that's easy to render or export.
+func (c *Client) Stats() (*Stats, error) {
+	stats := &Stats{At: nowEpochSeconds()}
+
+	var err error
+	if stats.Queues, err = c.Queues(); err != nil {
+		return nil, err
+	}
+	if stats.WorkerPools, err = c.WorkerPoolHeartbeats(); err != nil {
+		return nil, err
+	}
+	observations, err := c.WorkerObservations()
+	if err != nil {
+		return nil, err
+	}
+	stats.Workers = len(observations)
+	for _, o := range observations {
+		if o.IsBusy {
+			stats.BusyWorkers++
+		}
+	}
+
+	retryKeys, err := c.failureQueueKeys(redisKeyRetry(c.namespace), redisKeyRetryOf)
+	if err != nil {
+		return nil, err
+	}
+	deadKeys, err := c.failureQueueKeys(redisKeyDead(c.namespace), redisKeyDeadOf)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := c.readConn()
+	defer conn.Close()
+
+	if stats.Scheduled, err = redis.Int64(conn.Do("ZCARD", redisKeyScheduled(c.namespace))); err != nil {
+		logError("client.stats.scheduled", err)
+		return nil, err
+	}
+	if stats.Retry, err = zcardAll(conn, retryKeys); err != nil {
+		logError("client.stats.retry", err)
+		return nil, err
+	}
+	if stats.Dead, err = zcardAll(conn, deadKeys); err != nil {
+		logError("client.stats.dead", err)
+		return nil, err
+	}
+	return stats, nil
+}
+
+// zcardAll returns the total number of members in keys.
+func zcardAll(conn redis.Conn, keys []string) (int64, error) {
+	var total int64
+	for _, key := range keys {
+		n, err := redis.Int64(conn.Do("ZCARD", key))
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Watch calls fn with a Stats snapshot right away and then every interval until ctx is done, eg to drive a live
+// dashboard or terminal UI. A snapshot that fails, eg while Redis is down, is logged and skipped rather than ending
+// the watch. Watch returns ctx's error.
+func (c *Client) Watch(ctx context.Context, interval time.Duration, fn func(Stats)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := c.Stats()
+		if err != nil {
+			logError("client.watch", err)
+		} else {
+			fn(*stats)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package work
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientStats(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue("wat", nil)
+	assert.NoError(t, err)
+	_, err = enqueuer.EnqueueIn("wat", 300, nil)
+	assert.NoError(t, err)
+	insertDeadJob(ns, pool, "wat", 12345, 12347)
+
+	stats, err := NewClient(ns, pool).Stats()
+	assert.NoError(t, err)
+	if assert.Len(t, stats.Queues, 1) {
+		assert.EqualValues(t, 1, stats.Queues[0].Count)
+	}
+	assert.EqualValues(t, 1, stats.Scheduled)
+	assert.EqualValues(t, 0, stats.Retry)
+	assert.EqualValues(t, 1, stats.Dead)
+	assert.Equal(t, 0, stats.Workers)
+}
+
+func TestClientWatch(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var snapshots []Stats
+	err := NewClient(ns, pool).Watch(ctx, time.Millisecond, func(stats Stats) {
+		snapshots = append(snapshots, stats)
+		if len(snapshots) == 3 {
+			cancel()
+		}
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, snapshots, 3)
+}
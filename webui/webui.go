@@ -59,6 +59,7 @@ func NewServer(namespace string, pool *redis.Pool, hostPort string) *Server {
 	router.Post("/retry_dead_job/:died_at:\\d.*/:job_id", (*context).retryDeadJob)
 	router.Post("/delete_all_dead_jobs", (*context).deleteAllDeadJobs)
 	router.Post("/retry_all_dead_jobs", (*context).retryAllDeadJobs)
+	router.Get("/audit_log", (*context).auditLog)
 
 	//
 	// Build the HTML page:
@@ -76,6 +77,12 @@ func NewServer(namespace string, pool *redis.Pool, hostPort string) *Server {
 	return server
 }
 
+// UseReplica has the server read its listings and stats from a replica of its Redis, see work.Client.WithReplica.
+// Call it before Start.
+func (w *Server) UseReplica(replica *redis.Pool) {
+	w.client = w.client.WithReplica(replica)
+}
+
 // Start starts the server listening for requests on the hostPort specified in NewServer.
 func (w *Server) Start() {
 	w.wg.Add(1)
@@ -188,7 +195,7 @@ func (c *context) deleteDeadJob(rw web.ResponseWriter, r *web.Request) {
 		return
 	}
 
-	err = c.client.DeleteDeadJob(diedAt, r.PathParams["job_id"])
+	err = c.actingClient(r).DeleteDeadJob(diedAt, r.PathParams["job_id"])
 
 	render(rw, map[string]string{"status": "ok"}, err)
 }
@@ -200,21 +207,47 @@ func (c *context) retryDeadJob(rw web.ResponseWriter, r *web.Request) {
 		return
 	}
 
-	err = c.client.RetryDeadJob(diedAt, r.PathParams["job_id"])
+	err = c.actingClient(r).RetryDeadJob(diedAt, r.PathParams["job_id"])
 
 	render(rw, map[string]string{"status": "ok"}, err)
 }
 
 func (c *context) deleteAllDeadJobs(rw web.ResponseWriter, r *web.Request) {
-	err := c.client.DeleteAllDeadJobs()
+	err := c.actingClient(r).DeleteAllDeadJobs()
 	render(rw, map[string]string{"status": "ok"}, err)
 }
 
 func (c *context) retryAllDeadJobs(rw web.ResponseWriter, r *web.Request) {
-	err := c.client.RetryAllDeadJobs()
+	err := c.actingClient(r).RetryAllDeadJobs()
 	render(rw, map[string]string{"status": "ok"}, err)
 }
 
+func (c *context) auditLog(rw web.ResponseWriter, r *web.Request) {
+	page, err := parsePage(r)
+	if err != nil {
+		renderError(rw, err)
+		return
+	}
+
+	entries, count, err := c.client.AuditLog(page)
+	response := struct {
+		Count   int64              `json:"count"`
+		Entries []*work.AuditEntry `json:"entries"`
+	}{Count: count, Entries: entries}
+
+	render(rw, response, err)
+}
+
+// actingClient returns a client that records who made the request in the audit log: the user a proxy in front of the
+// UI authenticated, if it passes one on in X-Forwarded-User, or else the remote address.
+func (c *context) actingClient(r *web.Request) *work.Client {
+	actor := r.Header.Get("X-Forwarded-User")
+	if actor == "" {
+		actor = r.RemoteAddr
+	}
+	return c.client.WithActor("webui:" + actor)
+}
+
 func render(rw web.ResponseWriter, jsonable interface{}, err error) {
 	if err != nil {
 		renderError(rw, err)
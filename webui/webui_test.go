@@ -447,6 +447,40 @@ func TestWebUIDeadJobsDeleteRetryAll(t *testing.T) {
 	assert.EqualValues(t, 0, res.Count)
 }
 
+func TestWebUIAuditLog(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "testwork"
+	cleanKeyspace(ns, pool)
+
+	s := NewServer(ns, pool, ":6666")
+
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("POST", "/delete_all_dead_jobs", nil)
+	request.Header.Set("X-Forwarded-User", "ada")
+	s.router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/audit_log", nil)
+	s.router.ServeHTTP(recorder, request)
+	assert.Equal(t, 200, recorder.Code)
+	var res struct {
+		Count   int64 `json:"count"`
+		Entries []struct {
+			Action string `json:"action"`
+			Actor  string `json:"actor"`
+		} `json:"entries"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &res)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, 1, res.Count)
+	if assert.Len(t, res.Entries, 1) {
+		assert.Equal(t, "delete_all_dead_jobs", res.Entries[0].Action)
+		assert.Equal(t, "webui:ada", res.Entries[0].Actor)
+	}
+}
+
 func TestWebUIAssets(t *testing.T) {
 	pool := newTestPool(":6379")
 	ns := "testwork"
@@ -0,0 +1,245 @@
+// Package workbench measures the throughput and latency of gocraft/work against a Redis server. It's meant both for
+// sizing deployments (how many jobs per second can N workers get through on this Redis?) and for catching performance
+// regressions in work itself: results are plain values that can be saved and compared between runs with Compare.
+//
+// Every benchmark deletes all keys in the configured namespace before it runs, so point it at a namespace (or better,
+// a Redis) that holds nothing you care about.
+package workbench
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Config describes a benchmark run.
+type Config struct {
+	Pool        *redis.Pool
+	Namespace   string        // Defaults to "workbench". All keys in it are deleted.
+	Jobs        int           // Number of jobs to enqueue. Defaults to 10000.
+	JobTypes    int           // Number of job types the jobs are spread across. Defaults to 1.
+	Concurrency uint          // Number of workers. Defaults to 10.
+	HandlerTime time.Duration // How long each handler sleeps for. Defaults to 0, which measures work's own overhead.
+	BatchSize   uint          // JobOptions.BatchSize for every job type. Defaults to 0, no batching.
+}
+
+// Result is the outcome of a benchmark run.
+type Result struct {
+	Name    string        `json:"name"`
+	Ops     int           `json:"ops"`
+	Elapsed time.Duration `json:"elapsed"`
+	Rate    float64       `json:"rate"` // Ops per second
+
+	// Latency percentiles, only set by EndToEndLatency
+	P50 time.Duration `json:"p50,omitempty"`
+	P90 time.Duration `json:"p90,omitempty"`
+	P99 time.Duration `json:"p99,omitempty"`
+	Max time.Duration `json:"max,omitempty"`
+}
+
+func (r Result) String() string {
+	s := fmt.Sprintf("%s: %d ops in %v (%.0f ops/sec)", r.Name, r.Ops, r.Elapsed, r.Rate)
+	if r.Max > 0 {
+		s += fmt.Sprintf(", latency p50=%v p90=%v p99=%v max=%v", r.P50, r.P90, r.P99, r.Max)
+	}
+	return s
+}
+
+// EnqueueRate measures how fast a single Enqueuer can enqueue cfg.Jobs jobs.
+func EnqueueRate(cfg Config) (Result, error) {
+	cfg = cfg.withDefaults()
+	if err := cleanKeyspace(cfg); err != nil {
+		return Result{}, err
+	}
+
+	start := time.Now()
+	if err := enqueueJobs(cfg, nil); err != nil {
+		return Result{}, err
+	}
+	return newResult("enqueue", cfg.Jobs, time.Since(start)), nil
+}
+
+// FetchRate enqueues cfg.Jobs jobs up front, then measures how fast a worker pool with cfg.Concurrency workers can
+// fetch, run and acknowledge all of them.
+func FetchRate(cfg Config) (Result, error) {
+	cfg = cfg.withDefaults()
+	if err := cleanKeyspace(cfg); err != nil {
+		return Result{}, err
+	}
+	if err := enqueueJobs(cfg, nil); err != nil {
+		return Result{}, err
+	}
+
+	var ran int64
+	wp := newWorkerPool(cfg, func(job *work.Job) error {
+		atomic.AddInt64(&ran, 1)
+		return nil
+	})
+
+	start := time.Now()
+	wp.Start()
+	wp.Drain()
+	elapsed := time.Since(start)
+	wp.Stop()
+
+	if int(ran) != cfg.Jobs {
+		return Result{}, fmt.Errorf("workbench: ran %d of %d jobs", ran, cfg.Jobs)
+	}
+	return newResult("fetch", cfg.Jobs, elapsed), nil
+}
+
+// EndToEndLatency measures the time from enqueueing each job to its handler starting, while cfg.Jobs jobs are enqueued
+// as fast as possible into a running pool with cfg.Concurrency workers.
+func EndToEndLatency(cfg Config) (Result, error) {
+	cfg = cfg.withDefaults()
+	if err := cleanKeyspace(cfg); err != nil {
+		return Result{}, err
+	}
+
+	latencies := make([]time.Duration, cfg.Jobs)
+	var ran int64
+	wp := newWorkerPool(cfg, func(job *work.Job) error {
+		latency := time.Duration(time.Now().UnixNano()/1000-job.ArgInt64("enqueued_at_us")) * time.Microsecond
+		if i := atomic.AddInt64(&ran, 1) - 1; int(i) < len(latencies) {
+			latencies[i] = latency
+		}
+		return job.ArgError()
+	})
+
+	start := time.Now()
+	wp.Start()
+	err := enqueueJobs(cfg, func(q work.Q) { q["enqueued_at_us"] = time.Now().UnixNano() / 1000 })
+	if err != nil {
+		wp.Stop()
+		return Result{}, err
+	}
+	wp.Drain()
+	elapsed := time.Since(start)
+	wp.Stop()
+
+	if int(ran) != cfg.Jobs {
+		return Result{}, fmt.Errorf("workbench: ran %d of %d jobs", ran, cfg.Jobs)
+	}
+
+	r := newResult("end_to_end", cfg.Jobs, elapsed)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.P50 = percentile(latencies, 0.50)
+	r.P90 = percentile(latencies, 0.90)
+	r.P99 = percentile(latencies, 0.99)
+	r.Max = latencies[len(latencies)-1]
+	return r, nil
+}
+
+// Compare returns an error describing every benchmark in current whose rate dropped, or whose p99 latency rose, by more
+// than tolerance (eg 0.2 for 20%) relative to the benchmark of the same name in baseline. Benchmarks missing from
+// either side are ignored.
+func Compare(baseline, current []Result, tolerance float64) error {
+	byName := make(map[string]Result, len(baseline))
+	for _, r := range baseline {
+		byName[r.Name] = r
+	}
+
+	var regressions []string
+	for _, cur := range current {
+		base, ok := byName[cur.Name]
+		if !ok {
+			continue
+		}
+		if base.Rate > 0 && cur.Rate < base.Rate*(1-tolerance) {
+			regressions = append(regressions, fmt.Sprintf("%s: rate %.0f ops/sec, baseline %.0f", cur.Name, cur.Rate, base.Rate))
+		}
+		if base.P99 > 0 && float64(cur.P99) > float64(base.P99)*(1+tolerance) {
+			regressions = append(regressions, fmt.Sprintf("%s: p99 latency %v, baseline %v", cur.Name, cur.P99, base.P99))
+		}
+	}
+
+	if len(regressions) > 0 {
+		return fmt.Errorf("workbench: performance regressed: %v", regressions)
+	}
+	return nil
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.Namespace == "" {
+		cfg.Namespace = "workbench"
+	}
+	if cfg.Jobs <= 0 {
+		cfg.Jobs = 10000
+	}
+	if cfg.JobTypes <= 0 {
+		cfg.JobTypes = 1
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = 10
+	}
+	return cfg
+}
+
+func (cfg Config) jobName(i int) string {
+	return fmt.Sprintf("job%d", i%cfg.JobTypes)
+}
+
+func newWorkerPool(cfg Config, fn func(job *work.Job) error) *work.WorkerPool {
+	handler := fn
+	if cfg.HandlerTime > 0 {
+		handler = func(job *work.Job) error {
+			time.Sleep(cfg.HandlerTime)
+			return fn(job)
+		}
+	}
+
+	wp := work.NewWorkerPool(struct{}{}, cfg.Concurrency, cfg.Namespace, cfg.Pool)
+	for i := 0; i < cfg.JobTypes; i++ {
+		wp.JobWithOptions(cfg.jobName(i), work.JobOptions{Priority: 1, MaxFails: 1, SkipDead: true, BatchSize: cfg.BatchSize}, handler)
+	}
+	return wp
+}
+
+func enqueueJobs(cfg Config, setArgs func(q work.Q)) error {
+	enqueuer := work.NewEnqueuer(cfg.Namespace, cfg.Pool)
+	for i := 0; i < cfg.Jobs; i++ {
+		q := work.Q{"i": i}
+		if setArgs != nil {
+			setArgs(q)
+		}
+		if _, err := enqueuer.Enqueue(cfg.jobName(i), q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cleanKeyspace(cfg Config) error {
+	conn := cfg.Pool.Get()
+	defer conn.Close()
+
+	keys, err := redis.Strings(conn.Do("KEYS", cfg.Namespace+":*"))
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := conn.Do("DEL", k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newResult(name string, ops int, elapsed time.Duration) Result {
+	return Result{
+		Name:    name,
+		Ops:     ops,
+		Elapsed: elapsed,
+		Rate:    float64(ops) / elapsed.Seconds(),
+	}
+}
+
+// percentile returns the p'th percentile of sorted, which must not be empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
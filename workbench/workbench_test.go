@@ -0,0 +1,89 @@
+package workbench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBenchmarks(t *testing.T) {
+	// Not "work..."; the work package tests clean out every key starting with "work"
+	cfg := Config{Pool: newTestPool(":6379"), Namespace: "bench", Jobs: 200, JobTypes: 3, Concurrency: 4}
+
+	r, err := EnqueueRate(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "enqueue", r.Name)
+	assert.Equal(t, 200, r.Ops)
+	assert.True(t, r.Rate > 0)
+
+	r, err = FetchRate(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "fetch", r.Name)
+	assert.Equal(t, 200, r.Ops)
+
+	cfg.BatchSize = 10
+	r, err = EndToEndLatency(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "end_to_end", r.Name)
+	assert.True(t, r.P50 <= r.P90 && r.P90 <= r.P99 && r.P99 <= r.Max)
+	assert.True(t, r.Max > 0)
+}
+
+func TestCompare(t *testing.T) {
+	baseline := []Result{
+		{Name: "fetch", Rate: 1000},
+		{Name: "end_to_end", Rate: 1000, P99: 10 * time.Millisecond},
+	}
+
+	assert.NoError(t, Compare(baseline, []Result{
+		{Name: "fetch", Rate: 900},
+		{Name: "end_to_end", Rate: 1100, P99: 11 * time.Millisecond},
+		{Name: "enqueue", Rate: 1},
+	}, 0.2))
+
+	err := Compare(baseline, []Result{
+		{Name: "fetch", Rate: 700},
+		{Name: "end_to_end", Rate: 1000, P99: 20 * time.Millisecond},
+	}, 0.2)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "fetch: rate 700 ops/sec, baseline 1000")
+		assert.Contains(t, err.Error(), "end_to_end: p99 latency 20ms, baseline 10ms")
+	}
+}
+
+func BenchmarkEnqueue(b *testing.B) {
+	benchmark(b, EnqueueRate)
+}
+
+func BenchmarkFetch(b *testing.B) {
+	benchmark(b, FetchRate)
+}
+
+func BenchmarkEndToEnd(b *testing.B) {
+	benchmark(b, EndToEndLatency)
+}
+
+func benchmark(b *testing.B, fn func(Config) (Result, error)) {
+	cfg := Config{Pool: newTestPool(":6379"), Namespace: "bench", Jobs: 1000}
+	for i := 0; i < b.N; i++ {
+		r, err := fn(cfg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportMetric(r.Rate, "ops/sec")
+	}
+}
+
+func newTestPool(addr string) *redis.Pool {
+	return &redis.Pool{
+		MaxActive:   20,
+		MaxIdle:     20,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+		Wait: true,
+	}
+}
@@ -0,0 +1,205 @@
+// Package workchaos injects faults into the Redis connections of a gocraft/work worker pool under test, so that
+// applications can check that their handlers are idempotent and that jobs survive what goes wrong in production:
+// fetches and acknowledgements that fail, a slow Redis, and worker pools that die mid-job.
+//
+//	chaos := workchaos.New(redisPool, workchaos.Config{FetchFailureRate: 0.1, AckFailureRate: 0.1, MaxLatency: 5 * time.Millisecond})
+//	pool := work.NewWorkerPool(Context{}, 10, "my_app_namespace", chaos.Pool())
+//	...
+//	chaos.Kill() // the pool's Redis commands fail from now on, as if its process died
+//
+// Fetches and acknowledgements are told apart from other commands by the keys of the scripts work runs for them, so
+// they follow work's key layout, see KeysForNamespace.
+package workchaos
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrInjected is the error of every command that fails because of an injected fault.
+var ErrInjected = errors.New("workchaos: injected failure")
+
+// Config is which faults to inject, and how often.
+type Config struct {
+	FetchFailureRate   float64       // Fraction of job fetches that fail, from 0 to 1
+	AckFailureRate     float64       // Fraction of job acknowledgements that fail, so jobs stay in progress and are acknowledged again or reaped
+	CommandFailureRate float64       // Fraction of all other commands that fail, eg heartbeats and requeues
+	MaxLatency         time.Duration // Each command is delayed by a random duration up to this
+	Seed               int64         // Seeds the random choices, so a failing run can be repeated. Defaults to the current time.
+}
+
+// Stats counts the faults injected so far.
+type Stats struct {
+	FetchFailures   int64
+	AckFailures     int64
+	CommandFailures int64
+	Killed          int64 // Commands that failed because of Kill
+}
+
+// Chaos hands out connections to a Redis that inject the faults of its Config.
+type Chaos struct {
+	cfg    Config
+	pool   *redis.Pool
+	killed int32
+
+	mtx  sync.Mutex
+	rand *rand.Rand
+
+	stats Stats
+}
+
+// New returns a Chaos whose connections come from pool.
+func New(pool *redis.Pool, cfg Config) *Chaos {
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	return &Chaos{
+		cfg:  cfg,
+		pool: pool,
+		rand: rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+// Pool returns a pool of connections that inject faults, to pass to the worker pool under test. Each call returns a new
+// pool; they all share the Chaos's faults and Stats.
+func (c *Chaos) Pool() *redis.Pool {
+	return &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return &conn{Conn: c.pool.Get(), chaos: c}, nil
+		},
+	}
+}
+
+// Kill has every command fail from now on, as if the process using the connections died: its heartbeats stop, its
+// jobs in progress are left where they are until another pool's reaper requeues them, and nothing it does reaches
+// Redis. Stopping the pool afterwards cleans up its goroutines without undoing any of that. The commands redigo
+// manages its connections with still don't fail, and aren't counted as Killed.
+func (c *Chaos) Kill() {
+	atomic.StoreInt32(&c.killed, 1)
+}
+
+// Stats returns the faults injected so far.
+func (c *Chaos) Stats() Stats {
+	return Stats{
+		FetchFailures:   atomic.LoadInt64(&c.stats.FetchFailures),
+		AckFailures:     atomic.LoadInt64(&c.stats.AckFailures),
+		CommandFailures: atomic.LoadInt64(&c.stats.CommandFailures),
+		Killed:          atomic.LoadInt64(&c.stats.Killed),
+	}
+}
+
+// float64 returns a random number in [0, 1).
+func (c *Chaos) float64() float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.rand.Float64()
+}
+
+// fault returns the error to inject into commandName, if any, after delaying it.
+func (c *Chaos) fault(commandName string, args []interface{}) error {
+	k := kind(commandName, args)
+	if k == exempt {
+		return nil
+	}
+	if atomic.LoadInt32(&c.killed) == 1 {
+		atomic.AddInt64(&c.stats.Killed, 1)
+		return ErrInjected
+	}
+	if c.cfg.MaxLatency > 0 {
+		time.Sleep(time.Duration(c.float64() * float64(c.cfg.MaxLatency)))
+	}
+
+	switch k {
+	case fetch:
+		if c.float64() < c.cfg.FetchFailureRate {
+			atomic.AddInt64(&c.stats.FetchFailures, 1)
+			return ErrInjected
+		}
+	case ack:
+		if c.float64() < c.cfg.AckFailureRate {
+			atomic.AddInt64(&c.stats.AckFailures, 1)
+			return ErrInjected
+		}
+	case other:
+		if c.float64() < c.cfg.CommandFailureRate {
+			atomic.AddInt64(&c.stats.CommandFailures, 1)
+			return ErrInjected
+		}
+	}
+	return nil
+}
+
+type commandKind int
+
+const (
+	other commandKind = iota
+	fetch
+	ack
+	exempt // commands redigo needs to manage its connections, which never fail
+)
+
+// kind tells what a command does for work by the keys it's run with. Fetches are scripts whose first key is the
+// namespace's standby flag, or whose second is an in-progress queue (fetching a batch); acknowledgements are scripts
+// whose first key is an in-progress queue.
+func kind(commandName string, args []interface{}) commandKind {
+	switch strings.ToUpper(commandName) {
+	case "", "PING", "DISCARD", "UNWATCH":
+		return exempt
+	case "EVAL", "EVALSHA":
+	default:
+		return other
+	}
+
+	key := func(i int) string {
+		if len(args) > i+2 {
+			if s, ok := args[i+2].(string); ok {
+				return s
+			}
+		}
+		return ""
+	}
+	switch {
+	case strings.HasSuffix(key(0), ":standby") || strings.HasSuffix(key(1), ":inprogress"):
+		return fetch
+	case strings.HasSuffix(key(0), ":inprogress"):
+		return ack
+	}
+	return other
+}
+
+// conn injects its Chaos's faults into the commands it runs.
+type conn struct {
+	redis.Conn
+	chaos *Chaos
+}
+
+func (c *conn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.chaos.fault(commandName, args); err != nil {
+		return nil, err
+	}
+	return c.Conn.Do(commandName, args...)
+}
+
+func (c *conn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.chaos.fault(commandName, args); err != nil {
+		return nil, err
+	}
+	return redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c *conn) Send(commandName string, args ...interface{}) error {
+	if err := c.chaos.fault(commandName, args); err != nil {
+		return err
+	}
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c *conn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
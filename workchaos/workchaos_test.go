@@ -0,0 +1,83 @@
+package workchaos
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKind(t *testing.T) {
+	assert.Equal(t, fetch, kind("EVALSHA", []interface{}{"sha", 7, "work:standby", "work:jobs:a"}))
+	assert.Equal(t, fetch, kind("EVALSHA", []interface{}{"sha", 6, "work:jobs:a", "work:jobs:a:1234:inprogress"}))
+	assert.Equal(t, ack, kind("evalsha", []interface{}{"sha", 4, "work:jobs:a:1234:inprogress", "work:jobs:a:lock"}))
+	assert.Equal(t, other, kind("EVALSHA", []interface{}{"sha", 1, "work:retry"}))
+	assert.Equal(t, other, kind("HSET", []interface{}{"work:worker_pools:1234", "heartbeat_at", 1}))
+	assert.Equal(t, exempt, kind("PING", nil))
+}
+
+func TestChaosPool(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "workchaos"
+	cleanKeyspace(pool, ns)
+
+	enqueuer := work.NewEnqueuer(ns, pool)
+	for i := 0; i < 20; i++ {
+		_, err := enqueuer.Enqueue("wat", work.Q{"i": i})
+		assert.NoError(t, err)
+	}
+
+	chaos := New(pool, Config{FetchFailureRate: 0.5, MaxLatency: time.Millisecond, Seed: 1})
+	var mtx sync.Mutex
+	seen := map[int64]bool{}
+	wp := work.NewWorkerPool(struct{}{}, 2, ns, chaos.Pool())
+	wp.Job("wat", func(job *work.Job) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		seen[job.ArgInt64("i")] = true
+		return nil
+	})
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.Len(t, seen, 20)
+	assert.True(t, chaos.Stats().FetchFailures > 0)
+
+	chaos.Kill()
+	conn := chaos.Pool().Get()
+	_, err := conn.Do("GET", "foo")
+	conn.Close()
+	assert.Equal(t, ErrInjected, err)
+	assert.EqualValues(t, 1, chaos.Stats().Killed)
+}
+
+func newTestPool(addr string) *redis.Pool {
+	return &redis.Pool{
+		MaxActive:   10,
+		MaxIdle:     10,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+		Wait: true,
+	}
+}
+
+func cleanKeyspace(pool *redis.Pool, namespace string) {
+	conn := pool.Get()
+	defer conn.Close()
+
+	keys, err := redis.Strings(conn.Do("KEYS", namespace+"*"))
+	if err != nil {
+		panic("could not get keys: " + err.Error())
+	}
+	for _, k := range keys {
+		if _, err := conn.Do("DEL", k); err != nil {
+			panic("could not del: " + err.Error())
+		}
+	}
+}
@@ -1,28 +1,74 @@
 package work
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"os"
 	"reflect"
+	"runtime/pprof"
+	"sync/atomic"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
-const fetchKeysPerJobType = 6
+const (
+	fetchKeysPerJobType = 6
+	ackKeysPerJob       = 4
+	ackArgsPerJob       = 6 // plus 2 per follow-up
+	wakeChanSize        = 16
+)
 
 type worker struct {
 	workerID      string
 	poolID        string
+	hostname      string
+	pid           int
 	namespace     string
 	pool          *redis.Pool
 	jobTypes      map[string]*jobType
 	sleepBackoffs []int64
 	middleware    []*middlewareHandler
 	contextType   reflect.Type
-
-	redisFetchScript *redis.Script
-	sampler          prioritySampler
+	redisTimeout  time.Duration
+	errors        *errorReporter
+	stats         *poolStats
+	disabled      *jobNameSet
+	quiet         *atomicFlag
+	config        *liveConfig
+	inProgress    *inProgressLimit
+	costs         *costBudget
+	checkpoints   *checkpointStore
+	ctx           context.Context // canceled once the pool is stopping; nil for workers that aren't part of a pool
+
+	pprofLabels        bool
+	slaHook            SLAHook
+	deadJobRetention   time.Duration
+	maxDeadJobs        int
+	emptyQueueCooldown time.Duration
+	wakeChan           chan string
+	startDelay         time.Duration // before the first fetch, see WorkerPoolOptions.Warmup and StartStagger
+
+	busy int32 // 1 while the loop fetches and runs jobs, set before it checks whether the pool is quiet; atomic
+
+	// only touched by the worker's loop:
+	unacked    []*pendingAck
+	emptyUntil map[string]time.Time // job types whose queue was found empty, and until when to skip them
+	considered []string             // job types the last fetch looked at, in order
+
+	fetchArgs             []interface{}
+	redisFetchScript      *redis.Script
+	redisFetchBatchScript *redis.Script
+	redisAckScript        *redis.Script
+	sampler               prioritySampler
+	priorityVersion       uint64 // of the config's priority overrides the sampler is weighed with
+	customSampler         Sampler
+	sampleIndex           map[string]int // of each job type in sampler.samples, for customSampler's order
+	queueStates           []QueueState
+	ordered               []sampleItem
 	*observer
 
 	stopChan         chan struct{}
@@ -40,15 +86,30 @@ func newWorker(namespace string, poolID string, pool *redis.Pool, contextType re
 		sleepBackoffs = sleepBackoffsInMilliseconds
 	}
 
+	hostname, err := os.Hostname()
+	if err != nil {
+		logError("worker.hostname", err)
+		hostname = "hostname_errored"
+	}
+
 	w := &worker{
 		workerID:      workerID,
 		poolID:        poolID,
+		hostname:      hostname,
+		pid:           os.Getpid(),
 		namespace:     namespace,
 		pool:          pool,
 		contextType:   contextType,
 		sleepBackoffs: sleepBackoffs,
 
-		observer: ob,
+		redisFetchBatchScript: redis.NewScript(fetchKeysPerJobType, redisLuaFetchJobBatch),
+		redisAckScript:        redis.NewScript(-1, redisLuaAckJobs),
+
+		observer:    ob,
+		checkpoints: &checkpointStore{namespace: namespace, pool: pool},
+
+		wakeChan:   make(chan string, wakeChanSize),
+		emptyUntil: make(map[string]time.Time),
 
 		stopChan:         make(chan struct{}),
 		doneStoppingChan: make(chan struct{}),
@@ -68,6 +129,7 @@ func (w *worker) updateMiddlewareAndJobTypes(middleware []*middlewareHandler, jo
 	sampler := prioritySampler{}
 	for _, jt := range jobTypes {
 		sampler.add(jt.Priority,
+			jt.Name,
 			redisKeyJobs(w.namespace, jt.Name),
 			redisKeyJobsInProgress(w.namespace, w.poolID, jt.Name),
 			redisKeyJobsPaused(w.namespace, jt.Name),
@@ -76,8 +138,14 @@ func (w *worker) updateMiddlewareAndJobTypes(middleware []*middlewareHandler, jo
 			redisKeyJobsConcurrency(w.namespace, jt.Name))
 	}
 	w.sampler = sampler
+	w.sampleIndex = make(map[string]int, len(sampler.samples))
+	for i, s := range sampler.samples {
+		w.sampleIndex[s.jobName] = i
+	}
+	w.priorityVersion = 0 // so the config's priority overrides are applied to the new sampler
 	w.jobTypes = jobTypes
-	w.redisFetchScript = redis.NewScript(len(jobTypes)*fetchKeysPerJobType, redisLuaFetchJob)
+	// The number of keys varies from fetch to fetch since disabled job types are left out, so it's passed on each call.
+	w.redisFetchScript = redis.NewScript(-1, redisLuaFetchJob)
 }
 
 func (w *worker) start() {
@@ -100,29 +168,63 @@ func (w *worker) drain() {
 
 var sleepBackoffsInMilliseconds = []int64{0, 10, 100, 1000, 5000}
 
+// inProgressLimitWait is how long a worker held up by WorkerPoolOptions.MaxInProgress waits before checking again.
+const inProgressLimitWait = 10 * time.Millisecond
+
 func (w *worker) loop() {
 	var drained bool
 	var consequtiveNoJobs int64
 
-	// Begin immediately. We'll change the duration on each tick with a timer.Reset()
-	timer := time.NewTimer(0)
+	// Begin once the start delay is up, usually immediately. We'll change the duration on each tick with a timer.Reset()
+	timer := time.NewTimer(w.startDelay)
 	defer timer.Stop()
+	startAt := time.Now().Add(w.startDelay)
 
 	for {
 		select {
 		case <-w.stopChan:
+			// Give any outstanding acks one last chance; whatever's still stuck is requeued by the dead pool reaper
+			for _, pa := range w.unacked {
+				pa.retryAt = time.Time{}
+			}
+			w.reconcileAcks()
 			w.doneStoppingChan <- struct{}{}
 			return
 		case <-w.drainChan:
 			drained = true
-			timer.Reset(0)
+			timer.Reset(time.Until(startAt)) // ie right away, unless still warming up
+		case jobName := <-w.wakeChan:
+			if _, ok := w.emptyUntil[jobName]; ok {
+				delete(w.emptyUntil, jobName)
+				consequtiveNoJobs = 0
+				timer.Reset(0)
+			}
 		case <-timer.C:
+			w.errors.flush(time.Now(), false)
+			w.reconcileAcks()
+			if w.inProgress.take(1) == 0 {
+				// Held up by jobs that are running or waiting to be acknowledged, whether this worker's or others'
+				timer.Reset(inProgressLimitWait)
+				continue
+			}
+			atomic.StoreInt32(&w.busy, 1)
 			job, err := w.fetchJob()
+			if job == nil {
+				w.inProgress.release(1)
+			}
 			if err != nil {
-				logError("worker.fetch", err)
+				w.errors.report("worker.fetch", "", err)
+				w.stats.fetchError()
 				timer.Reset(10 * time.Millisecond)
 			} else if job != nil {
-				w.processJob(job)
+				jt := w.jobTypes[job.Name]
+				w.costs.acquire(jt)
+				if jt != nil && jt.BatchSize > 1 {
+					w.processBatch(job, jt)
+				} else {
+					w.processJob(job)
+				}
+				w.costs.release(jt)
 				consequtiveNoJobs = 0
 				timer.Reset(0)
 			} else {
@@ -131,32 +233,70 @@ func (w *worker) loop() {
 					drained = false
 				}
 				consequtiveNoJobs++
-				idx := consequtiveNoJobs
-				if idx >= int64(len(w.sleepBackoffs)) {
-					idx = int64(len(w.sleepBackoffs)) - 1
-				}
-				timer.Reset(time.Duration(w.sleepBackoffs[idx]) * time.Millisecond)
+				timer.Reset(w.idleSleep(consequtiveNoJobs))
 			}
+			atomic.StoreInt32(&w.busy, 0)
 		}
 	}
 }
 
+// idleSleep is how long to wait after n fetches in a row found no job: the sleep backoff for n, less up to a fifth of it
+// at random. The workers of a pool go idle together, and would otherwise keep polling Redis together.
+func (w *worker) idleSleep(n int64) time.Duration {
+	if n >= int64(len(w.sleepBackoffs)) {
+		n = int64(len(w.sleepBackoffs)) - 1
+	}
+	sleep := w.sleepBackoffs[n] * int64(time.Millisecond)
+	if sleep >= 5 {
+		sleep -= rand.Int63n(sleep / 5)
+	}
+	return time.Duration(sleep)
+}
+
 func (w *worker) fetchJob() (*Job, error) {
+	if w.quiet.isSet() {
+		return nil, nil
+	}
+	if w.config.prioritiesVersion() != w.priorityVersion {
+		w.applyPriorities()
+	}
 	// resort queues
 	// NOTE: we could optimize this to only resort every second, or something.
-	w.sampler.sample()
-	numKeys := len(w.sampler.samples) * fetchKeysPerJobType
-	var scriptArgs = make([]interface{}, 0, numKeys+1)
-
-	for _, s := range w.sampler.samples {
-		scriptArgs = append(scriptArgs, s.redisJobs, s.redisJobsInProg, s.redisJobsPaused, s.redisJobsLock, s.redisJobsLockInfo, s.redisJobsMaxConcurrency) // KEYS[1-6 * N]
+	samples := w.sampleOrder()
+	numKeys := len(samples) * fetchKeysPerJobType
+	// The args are the same from one fetch to the next, modulo order, so the slice is reused to save an allocation per poll
+	if cap(w.fetchArgs) < numKeys+3 {
+		w.fetchArgs = make([]interface{}, 1, numKeys+3)
+	}
+	scriptArgs := append(w.fetchArgs[:1], redisKeyStandby(w.namespace)) // KEYS[1]
+	w.considered = w.considered[:0]
+
+	now := time.Now()
+	for _, s := range samples {
+		if w.disabled.has(s.jobName) || !w.config.rateLimiter(s.jobName).ready(now) || w.recentlyEmpty(s.jobName, now) ||
+			!w.costs.fits(w.jobTypes[s.jobName]) {
+			continue
+		}
+		if len(scriptArgs) == 2 {
+			w.stats.samplerPicked(s.jobName, s.priority)
+		}
+		if w.emptyQueueCooldown > 0 {
+			w.considered = append(w.considered, s.jobName)
+		}
+		scriptArgs = append(scriptArgs, s.redisJobs, s.redisJobsInProg, s.redisJobsPaused, s.redisJobsLock, s.redisJobsLockInfo, s.redisJobsMaxConcurrency) // KEYS[2-7 * N]
+	}
+	if len(scriptArgs) == 2 {
+		// Every job type is disabled, rate limited, recently empty or over the pool's budgets; nothing to fetch.
+		return nil, nil
 	}
+	scriptArgs[0] = len(scriptArgs) - 1       // number of keys
 	scriptArgs = append(scriptArgs, w.poolID) // ARGV[1]
-	conn := w.pool.Get()
+	conn := getConn(w.pool, w.redisTimeout)
 	defer conn.Close()
 
 	values, err := redis.Values(w.redisFetchScript.Do(conn, scriptArgs...))
 	if err == redis.ErrNil {
+		w.markEmpty("", now)
 		return nil, nil
 	} else if err != nil {
 		return nil, err
@@ -181,15 +321,161 @@ func (w *worker) fetchJob() (*Job, error) {
 		return nil, fmt.Errorf("response in prog not bytes")
 	}
 
-	job, err := newJob(rawJSON, dequeuedFrom, inProgQueue)
+	job, err := newJobRawArgs(rawJSON, dequeuedFrom, inProgQueue)
 	if err != nil {
 		return nil, err
 	}
+	w.config.rateLimiter(job.Name).take(1)
+	w.stats.samplerFetched(job.Name)
+	w.stats.jobFetched(job.Name)
+	w.markEmpty(job.Name, now)
 
 	return job, nil
 }
 
+// applyPriorities weighs the sampler with the config's priority overrides, and the job types' own priorities for the
+// rest.
+func (w *worker) applyPriorities() {
+	priorities, version := w.config.priorityOverrides()
+	w.sampler.sum = 0
+	for i := range w.sampler.samples {
+		s := &w.sampler.samples[i]
+		if priority, ok := priorities[s.jobName]; ok {
+			s.priority = priority
+		} else {
+			s.priority = w.jobTypes[s.jobName].Priority
+		}
+		w.sampler.sum += s.priority
+	}
+	w.priorityVersion = version
+}
+
+// recentlyEmpty returns whether jobName's queue was found empty less than the cooldown ago.
+func (w *worker) recentlyEmpty(jobName string, now time.Time) bool {
+	if w.emptyQueueCooldown == 0 {
+		return false
+	}
+	until, ok := w.emptyUntil[jobName]
+	if ok && !now.Before(until) {
+		delete(w.emptyUntil, jobName)
+		return false
+	}
+	return ok
+}
+
+// markEmpty starts the cooldown of the job types the last fetch looked at before reaching fetchedName's queue, or of
+// all of them if fetchedName is "". The fetch script passes over queues that are paused or at their max concurrency as
+// well as empty ones, so those are skipped too, for no longer than the cooldown.
+func (w *worker) markEmpty(fetchedName string, now time.Time) {
+	for _, jobName := range w.considered {
+		if jobName == fetchedName {
+			return
+		}
+		w.emptyUntil[jobName] = now.Add(w.emptyQueueCooldown)
+	}
+}
+
+// wakeUp has the worker check jobName's queue again, without waiting for its cooldown to end. It doesn't block; if
+// too many wake ups are pending, this one is dropped and the cooldown runs its course.
+func (w *worker) wakeUp(jobName string) {
+	select {
+	case w.wakeChan <- jobName:
+	default:
+	}
+}
+
+// fetchBatch fetches up to n more jobs from the queue that job was fetched from.
+func (w *worker) fetchBatch(job *Job, n uint) ([]*Job, error) {
+	conn := getConn(w.pool, w.redisTimeout)
+	defer conn.Close()
+
+	rawJSONs, err := redis.ByteSlices(w.redisFetchBatchScript.Do(conn,
+		job.dequeuedFrom,
+		job.inProgQueue,
+		redisKeyJobsPaused(w.namespace, job.Name),
+		redisKeyJobsLock(w.namespace, job.Name),
+		redisKeyJobsLockInfo(w.namespace, job.Name),
+		redisKeyJobsConcurrency(w.namespace, job.Name),
+		w.poolID,
+		n,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0, len(rawJSONs))
+	for _, rawJSON := range rawJSONs {
+		j, err := newJobRawArgs(rawJSON, job.dequeuedFrom, job.inProgQueue)
+		if err != nil {
+			// Leave it in progress rather than lose it; the reaper requeues it once this pool is gone.
+			w.errors.report("worker.fetch_batch.new_job", job.Name, err)
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
 func (w *worker) processJob(job *Job) {
+	job, fate := w.execute(job)
+	w.removeJobFromInProgress(job, fate)
+}
+
+// processBatch runs job along with up to jt.BatchSize-1 more jobs of its type, then acknowledges them all at once.
+func (w *worker) processBatch(job *Job, jt *jobType) {
+	jobs := []*Job{job}
+	limiter := w.config.rateLimiter(job.Name)
+	if n := w.inProgress.take(limiter.available(jt.BatchSize-1, time.Now())); n > 0 {
+		more, err := w.fetchBatch(job, n)
+		if err != nil {
+			w.errors.report("worker.fetch_batch", job.Name, err)
+			w.stats.fetchError()
+		}
+		w.inProgress.release(int(n) - len(more))
+		limiter.take(len(more))
+		jobs = append(jobs, more...)
+	}
+
+	fates := make([]terminateOp, len(jobs))
+	for i, j := range jobs {
+		jobs[i], fates[i] = w.execute(j)
+	}
+	w.removeJobsFromInProgress(jobs, fates)
+}
+
+// run runs job through the middleware to its handler, with pprof labels if the pool has
+// WorkerPoolOptions.PprofLabels.
+func (w *worker) run(job *Job, jt *jobType) (err error) {
+	if !w.pprofLabels {
+		_, err = runJob(job, w.contextType, w.middleware, jt)
+		return err
+	}
+	pprof.Do(job.Context(), pprof.Labels("job", job.Name, "job_id", job.ID), func(ctx context.Context) {
+		job.ctx = ctx
+		_, err = runJob(job, w.contextType, w.middleware, jt)
+	})
+	return err
+}
+
+// setJobContext gives job its context, see Job.Context, returning the function that releases it once the job is done.
+func (w *worker) setJobContext(job *Job, jt *jobType) context.CancelFunc {
+	ctx := w.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if jt.Timeout <= 0 {
+		job.ctx = ctx
+		return func() {}
+	}
+	var cancel context.CancelFunc
+	job.ctx, cancel = context.WithTimeout(ctx, jt.Timeout)
+	return cancel
+}
+
+// execute runs job and decides its fate. The job returned is the one to acknowledge, which for unique jobs can differ
+// from the one passed in.
+func (w *worker) execute(job *Job) (*Job, terminateOp) {
 	if job.Unique {
 		updatedJob := w.getAndDeleteUniqueJob(job)
 		// This is to support the old way of doing it, where we used the job off the queue and just deleted the unique key
@@ -199,24 +485,90 @@ func (w *worker) processJob(job *Job) {
 			job = updatedJob
 		}
 	}
+	job.attempt = job.Fails + 1
+	if w.config.isShed(job.Name) {
+		w.shed(job)
+		return job, terminateOnly
+	}
 	var runErr error
 	jt := w.jobTypes[job.Name]
 	if jt == nil {
 		runErr = fmt.Errorf("stray job: no handler")
-		logError("process_job.stray", runErr)
+		w.errors.reportJob("process_job.stray", job, runErr)
+		w.stats.jobStray(job.Name)
 	} else {
+		if !jt.RawArgs {
+			runErr = job.decodeArgs()
+		}
 		w.observeStarted(job.Name, job.ID, job.Args)
 		job.observer = w.observer // for Checkin
-		_, runErr = runJob(job, w.contextType, w.middleware, jt)
+		job.checkpoints = w.checkpoints
+		cancel := w.setJobContext(job, jt)
+		w.stats.jobStarted()
+		startedAt := time.Now()
+		if runErr == nil {
+			runErr = jt.migrateArgs(job)
+		}
+		if runErr == nil {
+			runErr = w.run(job, jt)
+			if errors.As(runErr, new(jobPanic)) {
+				w.errors.reportJob("runJob.panic", job, runErr)
+			}
+		}
+		cancel()
+		w.stats.jobDone(job.Name, time.Since(startedAt), runErr != nil)
 		w.observeDone(job.Name, job.ID, runErr)
 	}
 
 	fate := terminateOnly
+	if runErr == nil {
+		if err := w.checkpoints.clear(job); err != nil {
+			// It expires in time
+			w.errors.reportJob("worker.clear_checkpoint", job, err)
+		}
+	}
+	if runErr == nil && len(job.followUps) > 0 {
+		fate.followUps, runErr = newFollowUps(job)
+	}
+	if runErr == nil && len(job.itemFailures) > 0 {
+		// The job is done, and the retry of its failed items goes wherever a retry of it would have
+		var retry *Job
+		if retry, runErr = job.itemsRetry(jt.ItemsArg); retry != nil {
+			w.fail(retry, runErr)
+			followUps := fate.followUps
+			fate = w.jobFate(jt, retry, runErr)
+			fate.followUps = followUps
+			return job, fate
+		}
+	}
 	if runErr != nil {
-		job.failed(runErr)
-		fate = w.jobFate(jt, job)
+		w.fail(job, runErr)
+		fate = w.jobFate(jt, job, runErr)
+	} else {
+		w.checkSLA(jt, job)
+	}
+	return job, fate
+}
+
+// shed counts a job that's dropped without running, since its type is shed.
+func (w *worker) shed(job *Job) {
+	w.stats.jobShed(job.Name)
+	conn := getConn(w.pool, w.redisTimeout)
+	defer conn.Close()
+	if _, err := conn.Do("HINCRBY", redisKeyShedCounts(w.namespace), job.Name, 1); err != nil {
+		w.errors.reportJob("worker.shed", job, err)
 	}
-	w.removeJobFromInProgress(job, fate)
+}
+
+// fail records that an attempt at running job failed with err, on the job and in its history.
+func (w *worker) fail(job *Job, err error) {
+	job.failed(err)
+	e := JobEvent{Event: JobFailed, At: job.FailedAt, Host: w.hostname, Pid: w.pid, Attempt: job.Fails, Err: job.LastErr}
+	var p jobPanic
+	if errors.As(err, &p) {
+		e.Stack = p.trimmedStack()
+	}
+	job.record(e)
 }
 
 func (w *worker) getAndDeleteUniqueJob(job *Job) *Job {
@@ -226,25 +578,29 @@ func (w *worker) getAndDeleteUniqueJob(job *Job) *Job {
 	if job.UniqueKey != "" {
 		uniqueKey = job.UniqueKey
 	} else { // For jobs put in queue prior to this change. In the future this can be deleted as there will always be a UniqueKey
+		if err = job.decodeArgs(); err != nil {
+			w.errors.reportJob("worker.delete_unique_job.args", job, err)
+			return nil
+		}
 		uniqueKey, err = redisKeyUniqueJob(w.namespace, job.Name, job.Args)
 		if err != nil {
-			logError("worker.delete_unique_job.key", err)
+			w.errors.reportJob("worker.delete_unique_job.key", job, err)
 			return nil
 		}
 	}
 
-	conn := w.pool.Get()
+	conn := getConn(w.pool, w.redisTimeout)
 	defer conn.Close()
 
 	rawJSON, err := redis.Bytes(conn.Do("GET", uniqueKey))
 	if err != nil {
-		logError("worker.delete_unique_job.get", err)
+		w.errors.reportJob("worker.delete_unique_job.get", job, err)
 		return nil
 	}
 
 	_, err = conn.Do("DEL", uniqueKey)
 	if err != nil {
-		logError("worker.delete_unique_job.del", err)
+		w.errors.reportJob("worker.delete_unique_job.del", job, err)
 		return nil
 	}
 
@@ -255,69 +611,233 @@ func (w *worker) getAndDeleteUniqueJob(job *Job) *Job {
 	}
 
 	// The job pulled off the queue was just a placeholder with no args, so replace it
-	jobWithArgs, err := newJob(rawJSON, job.dequeuedFrom, job.inProgQueue)
+	jobWithArgs, err := newJobRawArgs(rawJSON, job.dequeuedFrom, job.inProgQueue)
 	if err != nil {
-		logError("worker.delete_unique_job.updated_job", err)
+		w.errors.reportJob("worker.delete_unique_job.updated_job", job, err)
 		return nil
 	}
 
 	return jobWithArgs
 }
 
+// removeJobFromInProgress acknowledges job: it's removed from its in-progress queue, its concurrency lock is released
+// and fate decides where it goes next. If that fails, eg because of a network blip, the ack is retried from the
+// worker's loop until it goes through.
 func (w *worker) removeJobFromInProgress(job *Job, fate terminateOp) {
-	conn := w.pool.Get()
+	w.removeJobsFromInProgress([]*Job{job}, []terminateOp{fate})
+}
+
+// removeJobsFromInProgress is removeJobFromInProgress for many jobs in one round trip.
+func (w *worker) removeJobsFromInProgress(jobs []*Job, fates []terminateOp) {
+	if err := w.ack(jobs, fates); err != nil {
+		w.errors.report("worker.remove_job_from_in_progress.lrem", jobs[0].Name, err)
+		retryAt := time.Now().Add(ackRetryDelay)
+		for i, job := range jobs {
+			w.unacked = append(w.unacked, &pendingAck{
+				job:      job,
+				fate:     fates[i],
+				failures: 1,
+				retryAt:  retryAt,
+			})
+		}
+		return
+	}
+	w.inProgress.release(len(jobs))
+}
+
+func (w *worker) ack(jobs []*Job, fates []terminateOp) error {
+	numKeys := len(jobs) * ackKeysPerJob
+	var scriptArgs = make([]interface{}, 0, 1+numKeys+3+len(jobs)*ackArgsPerJob)
+	scriptArgs = append(scriptArgs, numKeys)
+	for i, job := range jobs {
+		scriptArgs = append(scriptArgs, job.inProgQueue, redisKeyJobsLock(w.namespace, job.Name), redisKeyJobsLockInfo(w.namespace, job.Name), fates[i].zsetKey) // KEYS[1-4 * N]
+	}
+	scriptArgs = append(scriptArgs, w.poolID, redisKeyKnownJobs(w.namespace), redisKeyJobsPrefix(w.namespace)) // ARGV[1-3]
+	for i, job := range jobs {
+		scriptArgs = append(scriptArgs, job.rawJSON, fates[i].score, fates[i].rawJSON, fates[i].trimBefore, fates[i].maxKept, len(fates[i].followUps)) // ARGV[4-9 * N]
+		for _, f := range fates[i].followUps {
+			scriptArgs = append(scriptArgs, f.name, f.rawJSON)
+		}
+	}
+
+	conn := getConn(w.pool, w.redisTimeout)
+	defer conn.Close()
+
+	_, err := w.redisAckScript.Do(conn, scriptArgs...)
+	return err
+}
+
+// pendingAck is a job that finished running but couldn't be removed from its in-progress queue yet.
+type pendingAck struct {
+	job      *Job
+	fate     terminateOp
+	failures int64
+	retryAt  time.Time
+}
+
+const (
+	ackRetryDelay    = 1 * time.Second
+	ackRetryMaxDelay = 1 * time.Minute
+)
+
+// reconcileAcks retries the acks that are due. The ack script is idempotent, so an ack that went through even though
+// we saw an error is harmless to retry. Acks that keep failing are recorded in Redis so that they show up in
+// Client.AckFailures.
+func (w *worker) reconcileAcks() {
+	if len(w.unacked) == 0 {
+		return
+	}
+
+	now := time.Now()
+	remaining := w.unacked[:0]
+	for _, pa := range w.unacked {
+		if now.Before(pa.retryAt) {
+			remaining = append(remaining, pa)
+			continue
+		}
+
+		err := w.ack([]*Job{pa.job}, []terminateOp{pa.fate})
+		if err == nil {
+			w.inProgress.release(1)
+			w.clearAckFailure(pa)
+			continue
+		}
+
+		pa.failures++
+		delay := time.Duration(pa.failures) * ackRetryDelay
+		if delay > ackRetryMaxDelay {
+			delay = ackRetryMaxDelay
+		}
+		pa.retryAt = now.Add(delay)
+		w.errors.reportJob("worker.reconcile_acks", pa.job, err)
+		w.recordAckFailure(pa, err)
+		remaining = append(remaining, pa)
+	}
+	w.unacked = remaining
+}
+
+func (w *worker) recordAckFailure(pa *pendingAck, ackErr error) {
+	rawJSON, err := json.Marshal(&AckFailure{
+		JobName:      pa.job.Name,
+		JobID:        pa.job.ID,
+		WorkerPoolID: w.poolID,
+		WorkerID:     w.workerID,
+		Failures:     pa.failures,
+		LastErr:      ackErr.Error(),
+		LastFailedAt: nowEpochSeconds(),
+	})
+	if err != nil {
+		w.errors.reportJob("worker.record_ack_failure.marshal", pa.job, err)
+		return
+	}
+
+	conn := getConn(w.pool, w.redisTimeout)
 	defer conn.Close()
+	if _, err := conn.Do("HSET", redisKeyAckFailures(w.namespace), pa.job.ID, rawJSON); err != nil {
+		w.errors.reportJob("worker.record_ack_failure", pa.job, err)
+	}
+}
 
-	conn.Send("MULTI")
-	conn.Send("LREM", job.inProgQueue, 1, job.rawJSON)
-	conn.Send("DECR", redisKeyJobsLock(w.namespace, job.Name))
-	conn.Send("HINCRBY", redisKeyJobsLockInfo(w.namespace, job.Name), w.poolID, -1)
-	fate(conn)
-	if _, err := conn.Do("EXEC"); err != nil {
-		logError("worker.remove_job_from_in_progress.lrem", err)
+func (w *worker) clearAckFailure(pa *pendingAck) {
+	// Failures are only recorded once the first retry fails
+	if pa.failures < 2 {
+		return
 	}
+
+	conn := getConn(w.pool, w.redisTimeout)
+	defer conn.Close()
+	if _, err := conn.Do("HDEL", redisKeyAckFailures(w.namespace), pa.job.ID); err != nil {
+		w.errors.reportJob("worker.clear_ack_failure", pa.job, err)
+	}
+}
+
+// terminateOp describes what happens to a job once it's removed from its in-progress queue.
+type terminateOp struct {
+	zsetKey string // the retry or dead zset to add the job to. Empty if the job is simply done.
+	score   int64
+	rawJSON []byte
+
+	// Once the job is added, jobs in zsetKey with a score below trimBefore are deleted, and all but the maxKept most
+	// recent ones, if those are set. See WorkerPoolOptions.DeadJobRetention and MaxDeadJobs.
+	trimBefore int64
+	maxKept    int64
+
+	followUps []followUp // jobs to enqueue once the job is acknowledged
 }
 
-type terminateOp func(conn redis.Conn)
+var terminateOnly = terminateOp{}
 
-func terminateOnly(_ redis.Conn) { return }
 func terminateAndRetry(w *worker, jt *jobType, job *Job) terminateOp {
 	rawJSON, err := job.serialize()
 	if err != nil {
-		logError("worker.terminate_and_retry.serialize", err)
+		w.errors.reportJob("worker.terminate_and_retry.serialize", job, err)
 		return terminateOnly
 	}
-	return func(conn redis.Conn) {
-		conn.Send("ZADD", redisKeyRetry(w.namespace), nowEpochSeconds()+jt.calcBackoff(job), rawJSON)
+	zsetKey := redisKeyRetry(w.namespace)
+	if jt.ExpressRetries && w.config.capable(CapabilityExpressRetries) {
+		zsetKey = redisKeyRetryExpress(w.namespace)
+	} else if jt.OwnFailureQueues {
+		zsetKey = redisKeyRetryOf(w.namespace, jt.Name)
+	}
+	return terminateOp{
+		zsetKey: zsetKey,
+		score:   nowEpochSeconds() + jt.calcBackoff(job),
+		rawJSON: rawJSON,
 	}
 }
-func terminateAndDead(w *worker, job *Job) terminateOp {
+
+// terminateAndDead sends job to the dead queue; jt may be nil for jobs of an unknown type.
+func terminateAndDead(w *worker, jt *jobType, job *Job) terminateOp {
 	rawJSON, err := job.serialize()
 	if err != nil {
-		logError("worker.terminate_and_dead.serialize", err)
+		w.errors.reportJob("worker.terminate_and_dead.serialize", job, err)
 		return terminateOnly
 	}
-	return func(conn redis.Conn) {
-		// NOTE: sidekiq limits the # of jobs: only keep jobs for 6 months, and only keep a max # of jobs
-		// The max # of jobs seems really horrible. Seems like operations should be on top of it.
-		// conn.Send("ZREMRANGEBYSCORE", redisKeyDead(w.namespace), "-inf", now - keepInterval)
-		// conn.Send("ZREMRANGEBYRANK", redisKeyDead(w.namespace), 0, -maxJobs)
-
-		conn.Send("ZADD", redisKeyDead(w.namespace), nowEpochSeconds(), rawJSON)
+	// The dead queue is also trimmed by the config watcher, see ConfigDeadRetention and ConfigMaxDeadJobs
+	zsetKey := redisKeyDead(w.namespace)
+	if jt != nil && jt.OwnFailureQueues {
+		zsetKey = redisKeyDeadOf(w.namespace, jt.Name)
+	}
+	now := nowEpochSeconds()
+	op := terminateOp{
+		zsetKey: zsetKey,
+		score:   now,
+		rawJSON: rawJSON,
+		maxKept: int64(w.maxDeadJobs),
 	}
+	if w.deadJobRetention > 0 {
+		op.trimBefore = now - int64(w.deadJobRetention/time.Second)
+	}
+	return op
 }
 
-func (w *worker) jobFate(jt *jobType, job *Job) terminateOp {
+// jobFate decides where a job that failed with err goes. err can be an Outcome saying so explicitly.
+func (w *worker) jobFate(jt *jobType, job *Job, err error) terminateOp {
+	var outcome Outcome
+	explicit := errors.As(err, &outcome)
 	if jt != nil {
+		if explicit && outcome.Kind == OutcomeDiscard {
+			w.stats.jobOutcome(job.Name, OutcomeDiscard)
+			return terminateOnly
+		}
 		failsRemaining := int64(jt.MaxFails) - job.Fails
-		if failsRemaining > 0 {
-			return terminateAndRetry(w, jt, job)
+		tooOld := jt.MaxAge > 0 && job.Age() >= jt.MaxAge
+		if failsRemaining > 0 && !tooOld && (!explicit || outcome.Kind == OutcomeRetry) {
+			w.stats.jobOutcome(job.Name, OutcomeRetry)
+			fate := terminateAndRetry(w, jt, job)
+			if explicit && fate.zsetKey != "" {
+				fate.score = nowEpochSeconds() + int64(outcome.Delay/time.Second)
+			}
+			return fate
 		}
 		if jt.SkipDead {
+			w.stats.jobOutcome(job.Name, OutcomeDiscard)
 			return terminateOnly
 		}
+		job.DeadRetention = int64(jt.DeadRetention / time.Second)
 	}
-	return terminateAndDead(w, job)
+	w.stats.jobOutcome(job.Name, OutcomeDead)
+	return terminateAndDead(w, jt, job)
 }
 
 // Default algorithm returns an fastly increasing backoff counter which grows in an unbounded fashion
@@ -1,13 +1,16 @@
 package work
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
-	"github.com/robfig/cron/v3"
 )
 
 // WorkerPool represents a pool of workers. It forms the primary API of gocraft/work. WorkerPools provide the public API of gocraft/work. You can attach jobs and middlware to them. You can start and stop them. Based on their concurrency setting, they'll spin up N worker goroutines.
@@ -17,19 +20,43 @@ type WorkerPool struct {
 	namespace     string // eg, "myapp-work"
 	pool          *redis.Pool
 	sleepBackoffs []int64
+	redisTimeout  time.Duration
+	errorHook     ErrorHook
+	logger        Logger
+	errors        *errorReporter
+	stats         *poolStats
+	disabled      *jobNameSet
+	quiet         *atomicFlag
+	config        *liveConfig
+	inProgress    *inProgressLimit
+	costs         *costBudget
+
+	emptyQueueCooldown time.Duration
+	warmup             time.Duration
+	startStagger       time.Duration
+	evictionCheck      EvictionCheck
 
 	contextType  reflect.Type
 	jobTypes     map[string]*jobType
 	middleware   []*middlewareHandler
 	started      bool
 	periodicJobs []*periodicJob
-
-	workers          []*worker
-	heartbeater      *workerPoolHeartbeater
-	retrier          *requeuer
-	scheduler        *requeuer
-	deadPoolReaper   *deadPoolReaper
-	periodicEnqueuer *periodicEnqueuer
+	alerts       []*alertState
+
+	workers         []*worker
+	cancelJobs      context.CancelFunc // cancels the contexts of the jobs the workers run, see Job.Context
+	heartbeater     *workerPoolHeartbeater
+	configWatcher   *configWatcher
+	wakeListener    *wakeListener
+	skipMaintenance bool
+	leaderElection  bool
+
+	leaderMtx     sync.Mutex // guards maintenance and leaderElector, which IsLeader reads from any goroutine
+	maintenance   *maintenance
+	leaderElector *leaderElector
+
+	restartTurn  *restartTurn
+	restartGrace time.Duration
 }
 
 type jobType struct {
@@ -39,6 +66,8 @@ type jobType struct {
 	IsGeneric      bool
 	GenericHandler GenericHandler
 	DynamicHandler reflect.Value
+
+	argsMigrations map[uint]ArgsMigration // keyed by the version they migrate from
 }
 
 func (jt *jobType) calcBackoff(j *Job) int64 {
@@ -61,11 +90,130 @@ type JobOptions struct {
 	SkipDead       bool              // If true, don't send failed jobs to the dead queue when retries are exhausted.
 	MaxConcurrency uint              // Max number of jobs to keep in flight (default is 0, meaning no max)
 	Backoff        BackoffCalculator // If not set, uses the default backoff algorithm
+	BatchSize      uint              // For tiny, high volume jobs: if > 1, a worker fetches and acknowledges up to this many jobs at once, running them back to back
+	RawArgs        bool              // If true, Job.Args is left nil and handlers read the arguments with Job.RawArgs, skipping the cost of decoding them
+	DeadRetention  time.Duration     // If set, dead jobs of this type are deleted this long after they died, rather than following the namespace's ConfigDeadRetention
+
+	// If true, failed jobs of this type go to retry and dead queues of their own rather than the namespace's, so that a
+	// flood of them doesn't crowd out other job types' in listings and trimming. The Client lists all of them together.
+	OwnFailureQueues bool
+
+	// If true, failed jobs of this type wait in the express retry queue, which is requeued ahead of the namespace's
+	// other retry queues, eg so that customer-facing jobs are retried first while a backlog of retries builds up
+	// during an outage. It takes precedence over OwnFailureQueues for retries; dead jobs still go to their own queue.
+	ExpressRetries bool
+
+	// If set, scheduled and retried jobs of this type are only moved onto its queue while the gate is open. See Gate.
+	Gate Gate
+
+	// If set, runs the jobs of this type in place of calling the handler directly, eg in a subprocess with
+	// SubprocessExecutor.
+	Executor Executor
+
+	// For handlers that process a list of items, the argument holding the list. Items the handler reports with
+	// Job.ReportItemFailure are retried on their own. See Job.ReportItemFailure.
+	ItemsArg string
+
+	// How much of the pool's WorkerPoolOptions.CostBudget each running job of this type takes up, eg in units of
+	// memory. Defaults to 1; jobs that cost more than the whole budget run on their own.
+	Cost uint
+
+	// Whether jobs of this type are CPUBound or IOBound, for the pool's WorkerPoolOptions.CPUConcurrency and
+	// IOConcurrency.
+	Class JobClass
+
+	// If set, jobs of this type that fail this long or longer after they were first enqueued, see Job.Age, go to the
+	// dead queue (unless SkipDead) rather than being retried, whatever their MaxFails, which bounds how late a job can
+	// run.
+	MaxAge time.Duration
+
+	// If set, the context of each job of this type, see Job.Context, is canceled this long after the job started. The
+	// handler isn't interrupted: it should watch the context and give up, eg by returning its error.
+	Timeout time.Duration
+
+	// If set, the longest acceptable time from when a job of this type is first enqueued until it completes
+	// successfully, retries included. Completed jobs are counted as meeting or breaching it in WorkerPoolStats.SLA,
+	// and breaches are handed to the pool's WorkerPoolOptions.SLAHook.
+	SLA time.Duration
 }
 
 // WorkerPoolOptions can be passed to NewWorkerPoolWithOptions.
 type WorkerPoolOptions struct {
-	SleepBackoffs []int64 // Sleep backoffs in milliseconds
+	SleepBackoffs []int64       // Sleep backoffs in milliseconds while fetches keep finding no job; the last repeats. Default up to 5s. Each is cut by up to 20% at random so idle workers spread out
+	RedisTimeout  time.Duration // If set, bounds each internal Redis command (and the wait for a connection). Default is no timeout.
+	ErrorHook     ErrorHook     // If set, called with every error encountered while fetching, acknowledging, heartbeating, requeueing, etc.
+	Logger        Logger        // If set, the pool logs to it rather than the package's logger, see SetLogger.
+
+	// If true, of all the pools with the same job types, only the elected leader runs the retry and scheduled job
+	// requeuers, the dead pool reaper and the periodic enqueuer, rather than every pool running them.
+	LeaderElection bool
+
+	// If true, the pool doesn't run the requeuers, reaper or periodic enqueuer at all, and its periodic jobs aren't
+	// enqueued. Run a Maintainer in another process instead.
+	SkipMaintenance bool
+
+	// If set, a worker that finds a queue empty leaves that job type out of its fetches for this long, which cuts the
+	// cost of each fetch when most of many job types are idle. Enqueuers with SetWakeWorkers end the cooldown as soon
+	// as they enqueue a job of that type; jobs that get to the queue otherwise, eg scheduled jobs and retries, can
+	// wait up to the cooldown. A second or so is a good start.
+	EmptyQueueCooldown time.Duration
+
+	// If set, caps the number of jobs the pool holds at once, regardless of its concurrency: jobs being run, jobs
+	// fetched along with them for JobOptions.BatchSize, and jobs whose acknowledgement failed and is being retried.
+	// Those are the jobs left in progress if the process crashes, for the dead pool reaper to requeue, so the cap
+	// bounds how many jobs a crash holds up. Workers over the cap wait for jobs to be acknowledged before fetching.
+	MaxInProgress uint
+
+	// If set, orders the job types' queues for each fetch instead of the WeightedSampler, which draws them by lottery
+	// weighted by priority. See Sampler.
+	Sampler Sampler
+
+	// If set, caps the total JobOptions.Cost of the jobs the pool runs at once, rather than just their number, so that
+	// one job that needs 8GB of memory takes up as much of the pool as eight that need 1GB. Workers leave job types
+	// that don't fit in what's left of the budget out of their fetches.
+	CostBudget uint
+
+	// If set, cap how many jobs of job types with JobOptions.Class CPUBound and IOBound the pool runs at once, eg the
+	// number of cores for CPU-bound jobs and many more for IO-bound ones. Jobs of both classes still share the pool's
+	// concurrency, which should be enough for the two together.
+	CPUConcurrency uint
+	IOConcurrency  uint
+
+	// If set, the pool waits this long after Start before fetching any jobs, eg while caches warm up and connection
+	// pools fill, so that a deploy doesn't fail a burst of jobs that would have worked moments later.
+	Warmup time.Duration
+
+	// If set, the pool's workers start fetching one after the other, this far apart, rather than all at once. The
+	// first starts after the Warmup.
+	StartStagger time.Duration
+
+	// If set, at most this many errors a minute are logged and handed to the ErrorHook for each key and job type, eg
+	// "runJob.panic" for one job type, or "worker.fetch" while Redis is down. The rest are counted and reported once
+	// the minute is up, as a SuppressedErrors, so that the logs of a mass failure stay readable.
+	ErrorsPerMinute uint
+
+	// What Start does if Redis's maxmemory-policy could evict the job queues, which loses their jobs without a trace:
+	// EvictionWarn, the default, reports an *EvictionError to the ErrorHook, and EvictionRefuse panics with it.
+	EvictionCheck EvictionCheck
+
+	// If true, jobs run with the pprof labels "job" (its name) and "job_id", so that CPU profiles of the process, and
+	// goroutine profiles, attribute the work to job types, eg with "go tool pprof -tagfocus job=export". Goroutines a
+	// handler starts can take the labels on with pprof.SetGoroutineLabels(job.Context()).
+	PprofLabels bool
+
+	// If set, called with each job that completed later than its type's JobOptions.SLA.
+	SLAHook SLAHook
+
+	// If set, gets metrics about each job the pool fetches and runs, eg for StatsD. See MetricsSink.
+	Metrics MetricsSink
+
+	// If set, each time one of the pool's workers sends a job to a dead queue, that queue is trimmed in the same
+	// script: jobs that died longer ago than DeadJobRetention are deleted, even those whose type has a longer
+	// JobOptions.DeadRetention, and only the MaxDeadJobs most recent ones are kept. Unlike ConfigDeadRetention and
+	// ConfigMaxDeadJobs, which the config watcher enforces once a minute, this keeps the dead queue bounded between
+	// checks, however fast jobs die.
+	DeadJobRetention time.Duration
+	MaxDeadJobs      int
 }
 
 // GenericHandler is a job handler without any custom context.
@@ -75,6 +223,7 @@ type GenericHandler func(*Job) error
 type GenericMiddlewareHandler func(*Job, NextMiddlewareFunc) error
 
 // NextMiddlewareFunc is a function type (whose instances are named 'next') that you call to advance to the next middleware.
+// It runs the rest of the chain, and the handler, each time it's called, and returns their error as is.
 type NextMiddlewareFunc func() error
 
 type middlewareHandler struct {
@@ -98,18 +247,46 @@ func NewWorkerPoolWithOptions(ctx interface{}, concurrency uint, namespace strin
 
 	ctxType := reflect.TypeOf(ctx)
 	validateContextType(ctxType)
+	costs := newCostBudget(workerPoolOpts.CostBudget, map[JobClass]uint{
+		CPUBound: workerPoolOpts.CPUConcurrency,
+		IOBound:  workerPoolOpts.IOConcurrency,
+	})
 	wp := &WorkerPool{
-		workerPoolID:  makeIdentifier(),
-		concurrency:   concurrency,
-		namespace:     namespace,
-		pool:          pool,
-		sleepBackoffs: workerPoolOpts.SleepBackoffs,
-		contextType:   ctxType,
-		jobTypes:      make(map[string]*jobType),
+		workerPoolID:       makeIdentifier(),
+		concurrency:        concurrency,
+		namespace:          namespace,
+		pool:               pool,
+		sleepBackoffs:      workerPoolOpts.SleepBackoffs,
+		redisTimeout:       workerPoolOpts.RedisTimeout,
+		errorHook:          workerPoolOpts.ErrorHook,
+		logger:             workerPoolOpts.Logger,
+		errors:             newErrorReporter(namespace, workerPoolOpts.ErrorHook, workerPoolOpts.Logger, workerPoolOpts.ErrorsPerMinute),
+		leaderElection:     workerPoolOpts.LeaderElection,
+		skipMaintenance:    workerPoolOpts.SkipMaintenance,
+		emptyQueueCooldown: workerPoolOpts.EmptyQueueCooldown,
+		warmup:             workerPoolOpts.Warmup,
+		startStagger:       workerPoolOpts.StartStagger,
+		evictionCheck:      workerPoolOpts.EvictionCheck,
+		stats:              &poolStats{sink: workerPoolOpts.Metrics},
+		disabled:           newJobNameSet(),
+		quiet:              &atomicFlag{},
+		config:             newLiveConfig(),
+		inProgress:         newInProgressLimit(workerPoolOpts.MaxInProgress),
+		costs:              costs,
+		contextType:        ctxType,
+		jobTypes:           make(map[string]*jobType),
 	}
 
 	for i := uint(0); i < wp.concurrency; i++ {
 		w := newWorker(wp.namespace, wp.workerPoolID, wp.pool, wp.contextType, nil, wp.jobTypes, wp.sleepBackoffs)
+		w.redisTimeout, w.errors = wp.redisTimeout, wp.errors
+		w.stats, w.disabled, w.quiet, w.config = wp.stats, wp.disabled, wp.quiet, wp.config
+		w.emptyQueueCooldown, w.inProgress, w.costs = wp.emptyQueueCooldown, wp.inProgress, wp.costs
+		w.customSampler, w.pprofLabels = workerPoolOpts.Sampler, workerPoolOpts.PprofLabels
+		w.slaHook = workerPoolOpts.SLAHook
+		w.deadJobRetention, w.maxDeadJobs = workerPoolOpts.DeadJobRetention, workerPoolOpts.MaxDeadJobs
+		w.observer.redisTimeout, w.observer.errorHook, w.observer.logger = wp.redisTimeout, wp.errorHook, wp.logger
+		w.checkpoints.redisTimeout = wp.redisTimeout
 		wp.workers = append(wp.workers, w)
 	}
 
@@ -119,6 +296,13 @@ func NewWorkerPoolWithOptions(ctx interface{}, concurrency uint, namespace strin
 // Middleware appends the specified function to the middleware chain. The fn can take one of these forms:
 // (*ContextType).func(*Job, NextMiddlewareFunc) error, (ContextType matches the type of ctx specified when creating a pool)
 // func(*Job, NextMiddlewareFunc) error, for the generic middleware format.
+//
+// Middleware runs in the order it's added, around the handler: each middleware runs before the ones added after it
+// once it calls next, and after them once next returns. One that returns without calling next skips the rest of the
+// chain, and one that calls next again, eg to retry in-process, runs the rest again. Errors pass through the chain as
+// returned, so middleware that wraps them with fmt.Errorf's %w keeps errors.Is and errors.As working for the ones
+// outside it, and for the worker, which looks for an Outcome in the error. Handler panics are recovered outside the
+// chain, so middleware doesn't see them.
 func (wp *WorkerPool) Middleware(fn interface{}) *WorkerPool {
 	vfn := reflect.ValueOf(fn)
 	validateMiddlewareType(wp.contextType, vfn)
@@ -145,8 +329,13 @@ func (wp *WorkerPool) Middleware(fn interface{}) *WorkerPool {
 // fn can take one of these forms:
 // (*ContextType).func(*Job) error, (ContextType matches the type of ctx specified when creating a pool)
 // func(*Job) error, for the generic handler format.
-func (wp *WorkerPool) Job(name string, fn interface{}) *WorkerPool {
-	return wp.JobWithOptions(name, JobOptions{}, fn)
+// opts set the job type's options, eg work.Priority(10); Job panics with a description of any that are invalid.
+func (wp *WorkerPool) Job(name string, fn interface{}, opts ...JobOption) *WorkerPool {
+	jobOpts, err := newJobOptions(opts)
+	if err != nil {
+		panic(fmt.Sprintf("work: job %q: %v", name, err))
+	}
+	return wp.JobWithOptions(name, jobOpts, fn)
 }
 
 // JobWithOptions adds a handler for 'name' jobs as per the Job function, but permits you specify additional options
@@ -164,6 +353,9 @@ func (wp *WorkerPool) JobWithOptions(name string, jobOpts JobOptions, fn interfa
 	if gh, ok := fn.(func(*Job) error); ok {
 		jt.IsGeneric = true
 		jt.GenericHandler = gh
+	} else if gh, ok := fn.(func(*Job) Outcome); ok {
+		jt.IsGeneric = true
+		jt.GenericHandler = func(job *Job) error { return gh(job) }
 	}
 
 	wp.jobTypes[name] = jt
@@ -179,39 +371,96 @@ func (wp *WorkerPool) JobWithOptions(name string, jobOpts JobOptions, fn interfa
 // The spec format is based on https://godoc.org/github.com/robfig/cron, which is a relatively standard cron format.
 // Note that the first value is the seconds!
 // If you have multiple worker pools on different machines, they'll all coordinate and only enqueue your job once.
-func (wp *WorkerPool) PeriodicallyEnqueue(spec string, jobName string) *WorkerPool {
-	p := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+// If no pool was running at the time of a run, it's skipped, unless opts sets another policy with CatchUp.
+func (wp *WorkerPool) PeriodicallyEnqueue(spec string, jobName string, opts ...PeriodicOption) *WorkerPool {
+	wp.periodicJobs = append(wp.periodicJobs, newPeriodicJob(spec, jobName, opts...))
 
-	schedule, err := p.Parse(spec)
-	if err != nil {
-		panic(err)
-	}
+	return wp
+}
+
+// PeriodicallyEnqueueWithArgs is PeriodicallyEnqueue for jobs that take arguments, built by args for each run, eg
+//
+//	pool.PeriodicallyEnqueueWithArgs("0 0 2 * * *", "nightly_report", work.PeriodicArgsTemplate(work.Q{"day": "{date}"}))
+func (wp *WorkerPool) PeriodicallyEnqueueWithArgs(spec string, jobName string, args PeriodicArgs, opts ...PeriodicOption) *WorkerPool {
+	pj := newPeriodicJob(spec, jobName, opts...)
+	pj.args = args
+	wp.periodicJobs = append(wp.periodicJobs, pj)
 
-	wp.periodicJobs = append(wp.periodicJobs, &periodicJob{jobName: jobName, spec: spec, schedule: schedule})
+	return wp
+}
+
+// PeriodicallyEnqueueEvery is PeriodicallyEnqueue for jobs that run every interval, eg every 5 seconds, which is more
+// than cron specs can express. Runs fall on the multiples of interval since the Unix epoch, so they don't drift and
+// pools agree on them; interval must be a whole number of seconds.
+func (wp *WorkerPool) PeriodicallyEnqueueEvery(interval time.Duration, jobName string, opts ...PeriodicOption) *WorkerPool {
+	wp.periodicJobs = append(wp.periodicJobs, newIntervalPeriodicJob(interval, jobName, opts...))
 
 	return wp
 }
 
+// Alert calls hook when the alert's queue crosses its threshold, eg more than 10000 jobs waiting for over 5 minutes,
+// and again when the queue is back under it. Queues are checked every 15 seconds, alongside the requeuers, so with
+// WorkerPoolOptions.LeaderElection only the leader's hooks are called; pools with SkipMaintenance never call them.
+func (wp *WorkerPool) Alert(alert Alert, hook AlertHook) *WorkerPool {
+	if err := alert.validate(); err != nil {
+		panic(err.Error())
+	}
+	wp.alerts = append(wp.alerts, &alertState{Alert: alert, hook: hook})
+	return wp
+}
+
 // Start starts the workers and associated processes.
 func (wp *WorkerPool) Start() {
 	if wp.started {
 		return
 	}
+	wp.checkEviction()
 	wp.started = true
 
 	// TODO: we should cleanup stale keys on startup from previously registered jobs
 	wp.writeConcurrencyControlsToRedis()
 	go wp.writeKnownJobsToRedis()
 
-	for _, w := range wp.workers {
+	wp.configWatcher = newConfigWatcher(wp.namespace, wp.pool, wp.jobTypes, wp.config)
+	wp.configWatcher.redisTimeout, wp.configWatcher.errorHook, wp.configWatcher.logger = wp.redisTimeout, wp.errorHook, wp.logger
+	wp.configWatcher.start()
+
+	if wp.emptyQueueCooldown > 0 {
+		wp.wakeListener = newWakeListener(wp.namespace, wp.pool, wp.jobTypes, wp.workers)
+		wp.wakeListener.errorHook, wp.wakeListener.logger = wp.errorHook, wp.logger
+		wp.wakeListener.start()
+	}
+
+	var ctx context.Context
+	ctx, wp.cancelJobs = context.WithCancel(context.Background())
+	for i, w := range wp.workers {
+		w.startDelay = wp.warmup + time.Duration(i)*wp.startStagger
+		w.ctx = ctx
 		go w.start()
 	}
 
 	wp.heartbeater = newWorkerPoolHeartbeater(wp.namespace, wp.pool, wp.workerPoolID, wp.jobTypes, wp.concurrency, wp.workerIDs())
+	wp.heartbeater.redisTimeout, wp.heartbeater.errorHook, wp.heartbeater.logger = wp.redisTimeout, wp.errorHook, wp.logger
+	wp.heartbeater.disabled, wp.heartbeater.quiet, wp.heartbeater.stats = wp.disabled, wp.quiet, wp.stats
 	wp.heartbeater.start()
-	wp.startRequeuers()
-	wp.periodicEnqueuer = newPeriodicEnqueuer(wp.namespace, wp.pool, wp.periodicJobs)
-	wp.periodicEnqueuer.start()
+	if wp.skipMaintenance {
+		return
+	}
+	m := newMaintenance(wp.namespace, wp.pool, wp.jobNames(), wp.periodicJobs)
+	m.redisTimeout, m.errorHook, m.logger = wp.redisTimeout, wp.errorHook, wp.logger
+	m.gates, m.alerts = wp.gates(), wp.alerts
+	var l *leaderElector
+	if wp.leaderElection {
+		l = newLeaderElector(redisKeyLeader(wp.namespace, leaderGroup(wp.jobNames())), wp.pool, wp.workerPoolID, m.start, m.stop)
+		l.redisTimeout, l.errorHook, l.logger = wp.redisTimeout, wp.errorHook, wp.logger
+		l.start()
+	} else {
+		m.start()
+	}
+
+	wp.leaderMtx.Lock()
+	wp.maintenance, wp.leaderElector = m, l
+	wp.leaderMtx.Unlock()
 }
 
 // Stop stops the workers and associated processes.
@@ -221,6 +470,47 @@ func (wp *WorkerPool) Stop() {
 	}
 	wp.started = false
 
+	// Running jobs are waited for, but their contexts tell them to wrap up
+	wp.cancelJobs()
+	<-wp.stopWorkers()
+	wp.stopProcesses()
+}
+
+// StopWithTimeout stops the pool like Stop, except that it waits at most timeout for the running jobs to finish. The
+// contexts of the jobs still running then are canceled, see Job.Context, and the jobs are put back on their queues
+// right away, for other pools to run, rather than left for the dead pool reaper to find. It returns whether all jobs
+// finished in time.
+//
+// Jobs that were put back may still be running in this process, since handlers can't be interrupted, so they may run
+// twice; their acknowledgements are ignored. A pool that didn't stop in time can't be started again.
+func (wp *WorkerPool) StopWithTimeout(timeout time.Duration) bool {
+	if !wp.started {
+		return true
+	}
+	wp.started = false
+
+	// Workers mustn't fetch more jobs while the ones running finish
+	wasQuiet := wp.quiet.isSet()
+	wp.quiet.set(true)
+
+	select {
+	case <-wp.stopWorkers():
+		wp.cancelJobs()
+		wp.quiet.set(wasQuiet)
+		wp.stopProcesses()
+		return true
+	case <-time.After(timeout):
+		wp.cancelJobs()
+		wp.requeueInProgress()
+		wp.stopProcesses()
+		return false
+	}
+}
+
+// stopWorkers stops the workers, which finish the jobs they're running first. The channel is closed once they're
+// stopped.
+func (wp *WorkerPool) stopWorkers() <-chan struct{} {
+	stopped := make(chan struct{})
 	wg := sync.WaitGroup{}
 	for _, w := range wp.workers {
 		wg.Add(1)
@@ -229,12 +519,48 @@ func (wp *WorkerPool) Stop() {
 			wg.Done()
 		}(w)
 	}
-	wg.Wait()
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	return stopped
+}
+
+// stopProcesses stops everything the pool runs besides its workers.
+func (wp *WorkerPool) stopProcesses() {
+	wp.errors.flush(time.Now(), true)
 	wp.heartbeater.stop()
-	wp.retrier.stop()
-	wp.scheduler.stop()
-	wp.deadPoolReaper.stop()
-	wp.periodicEnqueuer.stop()
+	wp.configWatcher.stop()
+	if wp.wakeListener != nil {
+		wp.wakeListener.stop()
+		wp.wakeListener = nil
+	}
+	wp.leaderMtx.Lock()
+	m, l := wp.maintenance, wp.leaderElector
+	wp.maintenance, wp.leaderElector = nil, nil
+	wp.leaderMtx.Unlock()
+	if l != nil {
+		l.stop()
+	} else if m != nil {
+		m.stop()
+	}
+	if wp.restartTurn != nil {
+		wp.restartTurn.stop(wp.restartGrace)
+		wp.restartTurn = nil
+	}
+}
+
+// requeueInProgress puts the jobs the pool has in progress back on their queues, as the dead pool reaper would once
+// the pool's heartbeat is stale.
+func (wp *WorkerPool) requeueInProgress() {
+	r := newDeadPoolReaper(wp.namespace, wp.pool, wp.jobNames())
+	r.redisTimeout, r.errorHook, r.logger = wp.redisTimeout, wp.errorHook, wp.logger
+	if err := r.requeueInProgressJobs(wp.workerPoolID, r.curJobTypes); err != nil {
+		reportError(wp.logger, wp.errorHook, "worker_pool.requeue_in_progress", err)
+	}
+	if err := r.cleanStaleLockInfo(wp.workerPoolID, r.curJobTypes); err != nil {
+		reportError(wp.logger, wp.errorHook, "worker_pool.requeue_in_progress.locks", err)
+	}
 }
 
 // Drain drains all jobs in the queue before returning. Note that if jobs are added faster than we can process them, this function wouldn't return.
@@ -250,17 +576,82 @@ func (wp *WorkerPool) Drain() {
 	wg.Wait()
 }
 
-func (wp *WorkerPool) startRequeuers() {
+// DisableJobType stops this pool's workers from fetching jobs named name until EnableJobType is called. Unlike
+// pausing a queue, this only affects the current process: other worker pools keep processing the queue. Jobs that are
+// already running are left to finish. Disabled job types are listed in the pool's heartbeat.
+func (wp *WorkerPool) DisableJobType(name string) {
+	wp.disabled.add(name)
+}
+
+// EnableJobType lets this pool's workers fetch jobs named name again after a call to DisableJobType.
+func (wp *WorkerPool) EnableJobType(name string) {
+	wp.disabled.remove(name)
+}
+
+// idle returns whether none of the pool's workers is fetching or running jobs, which once it's quiet is for good.
+func (wp *WorkerPool) idle() bool {
+	for _, w := range wp.workers {
+		if atomic.LoadInt32(&w.busy) == 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Quiet stops the pool's workers from fetching new jobs, like sending TSTP to a Sidekiq process: jobs that are already
+// running, or being fetched as Quiet is called, are left to finish, and the pool keeps heartbeating and running its
+// maintenance. Its heartbeat, which says it's quiet, is written right away. It's meant for quiescing a process ahead
+// of stopping it, so there's no going back short of Stop and a new pool.
+func (wp *WorkerPool) Quiet() {
+	wp.quiet.set(true)
+	if wp.started {
+		wp.heartbeater.heartbeat()
+	}
+}
+
+// IsQuiet returns whether Quiet was called on the pool.
+func (wp *WorkerPool) IsQuiet() bool {
+	return wp.quiet.isSet()
+}
+
+// Stats returns a snapshot of the jobs the pool has processed since it was created. It's kept in memory, so it only
+// covers this process and doesn't require a round trip to Redis.
+func (wp *WorkerPool) Stats() WorkerPoolStats {
+	return wp.stats.snapshot()
+}
+
+// IsLeader returns whether the pool is running the components that only one pool needs to run. That's always the case
+// for a started pool unless WorkerPoolOptions.LeaderElection or SkipMaintenance is set.
+func (wp *WorkerPool) IsLeader() bool {
+	wp.leaderMtx.Lock()
+	defer wp.leaderMtx.Unlock()
+	if wp.leaderElector != nil {
+		return wp.leaderElector.isLeading()
+	}
+	return wp.maintenance != nil
+}
+
+// gates returns the gates of the job types that have one.
+func (wp *WorkerPool) gates() map[string]Gate {
+	var gates map[string]Gate
+	for name, jt := range wp.jobTypes {
+		if jt.Gate != nil {
+			if gates == nil {
+				gates = make(map[string]Gate)
+			}
+			gates[name] = jt.Gate
+		}
+	}
+	return gates
+}
+
+func (wp *WorkerPool) jobNames() []string {
 	jobNames := make([]string, 0, len(wp.jobTypes))
 	for k := range wp.jobTypes {
 		jobNames = append(jobNames, k)
 	}
-	wp.retrier = newRequeuer(wp.namespace, wp.pool, redisKeyRetry(wp.namespace), jobNames)
-	wp.scheduler = newRequeuer(wp.namespace, wp.pool, redisKeyScheduled(wp.namespace), jobNames)
-	wp.deadPoolReaper = newDeadPoolReaper(wp.namespace, wp.pool, jobNames)
-	wp.retrier.start()
-	wp.scheduler.start()
-	wp.deadPoolReaper.start()
+	sort.Strings(jobNames)
+	return jobNames
 }
 
 func (wp *WorkerPool) workerIDs() []string {
@@ -277,7 +668,7 @@ func (wp *WorkerPool) writeKnownJobsToRedis() {
 		return
 	}
 
-	conn := wp.pool.Get()
+	conn := getConn(wp.pool, wp.redisTimeout)
 	defer conn.Close()
 	key := redisKeyKnownJobs(wp.namespace)
 	jobNames := make([]interface{}, 0, len(wp.jobTypes)+1)
@@ -287,7 +678,19 @@ func (wp *WorkerPool) writeKnownJobsToRedis() {
 	}
 
 	if _, err := conn.Do("SADD", jobNames...); err != nil {
-		logError("write_known_jobs", err)
+		reportError(wp.logger, wp.errorHook, "write_known_jobs", err)
+	}
+
+	ownFailureQueues := []interface{}{redisKeyOwnFailureQueues(wp.namespace)}
+	for k, jt := range wp.jobTypes {
+		if jt.OwnFailureQueues {
+			ownFailureQueues = append(ownFailureQueues, k)
+		}
+	}
+	if len(ownFailureQueues) > 1 {
+		if _, err := conn.Do("SADD", ownFailureQueues...); err != nil {
+			reportError(wp.logger, wp.errorHook, "write_known_jobs_own_failure_queues", err)
+		}
 	}
 }
 
@@ -296,15 +699,131 @@ func (wp *WorkerPool) writeConcurrencyControlsToRedis() {
 		return
 	}
 
-	conn := wp.pool.Get()
+	conn := getConn(wp.pool, wp.redisTimeout)
 	defer conn.Close()
+
+	// Overrides in the namespace config win over JobOptions
+	cfg, err := redis.StringMap(conn.Do("HGETALL", redisKeyConfig(wp.namespace)))
+	if err != nil {
+		reportError(wp.logger, wp.errorHook, "write_concurrency_controls_config", err)
+	}
 	for jobName, jobType := range wp.jobTypes {
-		if _, err := conn.Do("SET", redisKeyJobsConcurrency(wp.namespace, jobName), jobType.MaxConcurrency); err != nil {
-			logError("write_concurrency_controls_max_concurrency", err)
+		var maxConcurrency interface{} = jobType.MaxConcurrency
+		if v, ok := cfg[JobConfigKey(ConfigMaxConcurrency, jobName)]; ok {
+			maxConcurrency = v
+		}
+		if _, err := conn.Do("SET", redisKeyJobsConcurrency(wp.namespace, jobName), maxConcurrency); err != nil {
+			reportError(wp.logger, wp.errorHook, "write_concurrency_controls_max_concurrency", err)
 		}
 	}
 }
 
+// jobNameSet is a set of job names that can be safely read by workers while it's being changed. A nil *jobNameSet is
+// an empty set.
+type jobNameSet struct {
+	mtx   sync.RWMutex
+	names map[string]struct{}
+}
+
+func newJobNameSet() *jobNameSet {
+	return &jobNameSet{names: make(map[string]struct{})}
+}
+
+func (s *jobNameSet) add(name string) {
+	s.mtx.Lock()
+	s.names[name] = struct{}{}
+	s.mtx.Unlock()
+}
+
+func (s *jobNameSet) remove(name string) {
+	s.mtx.Lock()
+	delete(s.names, name)
+	s.mtx.Unlock()
+}
+
+func (s *jobNameSet) has(name string) bool {
+	if s == nil {
+		return false
+	}
+	s.mtx.RLock()
+	_, ok := s.names[name]
+	s.mtx.RUnlock()
+	return ok
+}
+
+// sorted returns the names in the set in alphabetical order.
+func (s *jobNameSet) sorted() []string {
+	if s == nil {
+		return nil
+	}
+	s.mtx.RLock()
+	names := make([]string, 0, len(s.names))
+	for name := range s.names {
+		names = append(names, name)
+	}
+	s.mtx.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// atomicFlag is a boolean that can be safely read by workers while it's being changed. A nil *atomicFlag is unset.
+type atomicFlag struct {
+	v int32
+}
+
+func (f *atomicFlag) set(v bool) {
+	var i int32
+	if v {
+		i = 1
+	}
+	atomic.StoreInt32(&f.v, i)
+}
+
+func (f *atomicFlag) isSet() bool {
+	return f != nil && atomic.LoadInt32(&f.v) == 1
+}
+
+// inProgressLimit caps how many jobs a pool's workers hold at once: fetched, but not yet acknowledged. A nil limit
+// doesn't cap anything.
+type inProgressLimit struct {
+	max int64
+	n   int64
+}
+
+func newInProgressLimit(max uint) *inProgressLimit {
+	if max == 0 {
+		return nil
+	}
+	return &inProgressLimit{max: int64(max)}
+}
+
+// take claims up to n of the jobs left under the limit and returns how many it got.
+func (l *inProgressLimit) take(n uint) uint {
+	if l == nil {
+		return n
+	}
+	for {
+		held := atomic.LoadInt64(&l.n)
+		got := l.max - held
+		if got <= 0 {
+			return 0
+		}
+		if got > int64(n) {
+			got = int64(n)
+		}
+		if atomic.CompareAndSwapInt64(&l.n, held, held+got) {
+			return uint(got)
+		}
+	}
+}
+
+// release gives back n jobs taken earlier.
+func (l *inProgressLimit) release(n int) {
+	if l != nil && n > 0 {
+		atomic.AddInt64(&l.n, -int64(n))
+	}
+}
+
 // validateContextType will panic if context is invalid
 func validateContextType(ctxType reflect.Type) {
 	if ctxType.Kind() != reflect.Struct {
@@ -327,11 +846,11 @@ func validateMiddlewareType(ctxType reflect.Type, vfn reflect.Value) {
 // Since it's easy to pass the wrong method as a middleware/handler, and since the user can't rely on static type checking since we use reflection,
 // lets be super helpful about what they did and what they need to do.
 // Arguments:
-//  - vfn is the failed method
-//  - addingType is for "You are adding {addingType} to a worker pool...". Eg, "middleware" or "a handler"
-//  - yourType is for "Your {yourType} function can have...". Eg, "middleware" or "handler" or "error handler"
-//  - args is like "rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc"
-//    - NOTE: args can be calculated if you pass in each type. BUT, it doesn't have example argument name, so it has less copy/paste value.
+//   - vfn is the failed method
+//   - addingType is for "You are adding {addingType} to a worker pool...". Eg, "middleware" or "a handler"
+//   - yourType is for "Your {yourType} function can have...". Eg, "middleware" or "handler" or "error handler"
+//   - args is like "rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc"
+//   - NOTE: args can be calculated if you pass in each type. BUT, it doesn't have example argument name, so it has less copy/paste value.
 func instructiveMessage(vfn reflect.Value, addingType string, yourType string, args string, ctxType reflect.Type) string {
 	// Get context type without package.
 	ctxString := ctxType.String()
@@ -354,6 +873,10 @@ func instructiveMessage(vfn reflect.Value, addingType string, yourType string, a
 	str += "* func (c *" + ctxString + ") YourFunctionName(" + args + ") error  // or,\n"
 	str += "* func YourFunctionName(c *" + ctxString + ", " + args + ") error\n"
 	str += "*\n"
+	if yourType == "handler" {
+		str += "* (Handlers can also return a work.Outcome instead of the error.)\n"
+		str += "*\n"
+	}
 	str += "* Unfortunately, your function has this signature: " + vfn.Type().String() + "\n"
 	str += "*\n"
 	str += strings.Repeat("*", 120) + "\n"
@@ -375,10 +898,11 @@ func isValidHandlerType(ctxType reflect.Type, vfn reflect.Value) bool {
 		return false
 	}
 
+	// Handlers return an error or an Outcome
 	outType := fnType.Out(0)
 	var e *error
 
-	if outType != reflect.TypeOf(e).Elem() {
+	if outType != reflect.TypeOf(e).Elem() && outType != reflect.TypeOf(Outcome{}) {
 		return false
 	}
 
@@ -2,8 +2,10 @@ package work
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -224,3 +226,238 @@ func setupTestWorkerPool(pool *redis.Pool, namespace, jobName string, concurrenc
 	sleepBackoffsInMilliseconds = []int64{10, 10, 10, 10, 10}
 	return wp
 }
+
+func TestWorkerPoolMaxInProgress(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	var mtx sync.Mutex
+	var running, maxRunning, maxBatch int
+	wp := NewWorkerPoolWithOptions(TestContext{}, 4, ns, pool, WorkerPoolOptions{MaxInProgress: 3})
+	wp.Job("slow", func(job *Job) error {
+		mtx.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mtx.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mtx.Lock()
+		running--
+		mtx.Unlock()
+		return nil
+	})
+	wp.Job("tiny", func(job *Job) error { return nil }, BatchSize(10))
+	wp.Middleware(func(job *Job, next NextMiddlewareFunc) error {
+		if job.Name == "tiny" {
+			mtx.Lock()
+			if n := int(listSize(pool, redisKeyJobsInProgress(ns, wp.workerPoolID, "tiny"))); n > maxBatch {
+				maxBatch = n
+			}
+			mtx.Unlock()
+		}
+		return next()
+	})
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 20; i++ {
+		_, err := enqueuer.Enqueue("slow", nil)
+		assert.NoError(t, err)
+	}
+	wp.Start()
+	wp.Drain()
+
+	// Four workers, but never more than three jobs at once
+	assert.EqualValues(t, 20, wp.Stats().Processed)
+	assert.True(t, maxRunning <= 3, "%d jobs ran at once", maxRunning)
+
+	// Which holds for batches too
+	for i := 0; i < 20; i++ {
+		_, err := enqueuer.Enqueue("tiny", nil)
+		assert.NoError(t, err)
+	}
+	wp.Drain()
+	wp.Stop()
+	assert.EqualValues(t, 40, wp.Stats().Processed)
+	assert.True(t, maxBatch > 0 && maxBatch <= 3, "%d jobs in progress at once", maxBatch)
+}
+
+func TestWorkerPoolWarmup(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	var mtx sync.Mutex
+	var startedAt []time.Time
+	release := make(chan struct{})
+	wp := NewWorkerPoolWithOptions(TestContext{}, 3, ns, pool, WorkerPoolOptions{Warmup: 50 * time.Millisecond, StartStagger: 20 * time.Millisecond})
+	wp.Job("wat", func(job *Job) error {
+		mtx.Lock()
+		startedAt = append(startedAt, time.Now())
+		mtx.Unlock()
+		<-release
+		return nil
+	})
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 3; i++ {
+		_, err := enqueuer.Enqueue("wat", nil)
+		assert.NoError(t, err)
+	}
+
+	start := time.Now()
+	wp.Start()
+	time.Sleep(30 * time.Millisecond)
+	assert.EqualValues(t, 3, listSize(pool, redisKeyJobs(ns, "wat")))
+
+	// Each worker picks up a job as it starts
+	for {
+		mtx.Lock()
+		n := len(startedAt)
+		mtx.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wp.Drain()
+	wp.Stop()
+
+	assert.True(t, startedAt[0].Sub(start) >= 50*time.Millisecond, "first job started after %v", startedAt[0].Sub(start))
+	assert.True(t, startedAt[2].Sub(startedAt[0]) >= 35*time.Millisecond, "jobs started %v apart", startedAt[2].Sub(startedAt[0]))
+	assert.EqualValues(t, 3, wp.Stats().Processed)
+}
+
+func TestInProgressLimit(t *testing.T) {
+	var unlimited *inProgressLimit
+	assert.EqualValues(t, 100, unlimited.take(100))
+	unlimited.release(100)
+
+	l := newInProgressLimit(3)
+	assert.EqualValues(t, 2, l.take(2))
+	assert.EqualValues(t, 1, l.take(2))
+	assert.EqualValues(t, 0, l.take(1))
+	l.release(2)
+	assert.EqualValues(t, 2, l.take(5))
+	assert.Nil(t, newInProgressLimit(0))
+}
+
+func TestWorkerPoolQuiet(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	wp := NewWorkerPool(TestContext{}, 2, ns, pool)
+	wp.Job("slow", func(job *Job) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	wp.Job("fast", func(job *Job) error { return nil })
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue("slow", nil)
+	assert.NoError(t, err)
+
+	wp.Start()
+	<-started
+	wp.Quiet()
+	assert.True(t, wp.IsQuiet())
+	time.Sleep(10 * time.Millisecond) // for fetches already under way
+
+	// The running job finishes, but nothing new is fetched
+	_, err = enqueuer.Enqueue("fast", nil)
+	assert.NoError(t, err)
+	close(release)
+	wp.Drain()
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobsInProgress(ns, wp.workerPoolID, "slow")))
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "fast")))
+	assert.EqualValues(t, 1, wp.Stats().Processed)
+
+	hbs, err := NewClient(ns, pool).WorkerPoolHeartbeats()
+	assert.NoError(t, err)
+	if assert.Len(t, hbs, 1) {
+		assert.True(t, hbs[0].Quiet)
+	}
+	wp.Stop()
+}
+
+func TestWorkerPoolJobContext(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	errs := make(chan error, 2)
+	started := make(chan struct{})
+	wp := NewWorkerPool(TestContext{}, 2, ns, pool)
+	wp.Job("slow", func(job *Job) error {
+		<-job.Context().Done()
+		errs <- job.Context().Err()
+		return nil
+	}, Timeout(10*time.Millisecond))
+	wp.Job("endless", func(job *Job) error {
+		close(started)
+		<-job.Context().Done()
+		errs <- job.Context().Err()
+		return nil
+	})
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue("slow", nil)
+	assert.NoError(t, err)
+	_, err = enqueuer.Enqueue("endless", nil)
+	assert.NoError(t, err)
+	wp.Start()
+
+	// The job type's timeout cancels its context
+	assert.Equal(t, context.DeadlineExceeded, <-errs)
+
+	// Stopping the pool cancels the others', and waits for them to return
+	<-started
+	wp.Stop()
+	assert.Equal(t, context.Canceled, <-errs)
+
+	assert.Equal(t, context.Background(), (&Job{}).Context())
+}
+
+func TestWorkerPoolStopWithTimeout(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job("stuck", func(job *Job) error {
+		close(started)
+		<-release
+		return nil
+	})
+	_, err := NewEnqueuer(ns, pool).Enqueue("stuck", nil)
+	assert.NoError(t, err)
+	wp.Start()
+	<-started
+
+	// The job that's still running is put back on its queue, and its late acknowledgement is ignored
+	assert.False(t, wp.StopWithTimeout(10*time.Millisecond))
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "stuck")))
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobsInProgress(ns, wp.workerPoolID, "stuck")))
+	assert.EqualValues(t, 0, getInt64(pool, redisKeyJobsLock(ns, "stuck")))
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, "stuck")))
+	assert.EqualValues(t, 0, getInt64(pool, redisKeyJobsLock(ns, "stuck")))
+
+	// Pools whose jobs finish in time stop like with Stop
+	wp = NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job("stuck", func(job *Job) error { return nil })
+	wp.Start()
+	wp.Drain()
+	assert.True(t, wp.StopWithTimeout(time.Second))
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, "stuck")))
+	assert.False(t, wp.IsQuiet())
+}
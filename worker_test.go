@@ -2,6 +2,7 @@ package work
 
 import (
 	"fmt"
+	"runtime/pprof"
 	"strconv"
 	"sync/atomic"
 	"testing"
@@ -301,6 +302,53 @@ func TestWorkerDead(t *testing.T) {
 	assert.True(t, (nowEpochSeconds()-job.FailedAt) <= 2)
 }
 
+func TestWorkerDeadTrimmed(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	deleteQueue(pool, ns, job1)
+	deleteRetryAndDead(pool, ns)
+	deletePausedAndLockedKeys(ns, job1, pool)
+
+	// Two jobs that died long ago, and three recent ones
+	now := nowEpochSeconds()
+	insertDeadJob(ns, pool, job1, now-7200, now-7200)
+	insertDeadJob(ns, pool, job1, now-7100, now-7100)
+	for i := int64(3); i > 0; i-- {
+		insertDeadJob(ns, pool, job1, now-i, now-i)
+	}
+
+	jobTypes := make(map[string]*jobType)
+	jobTypes[job1] = &jobType{
+		Name:       job1,
+		JobOptions: JobOptions{Priority: 1, MaxFails: 0},
+		IsGeneric:  true,
+		GenericHandler: func(job *Job) error {
+			return fmt.Errorf("sorry kid1")
+		},
+	}
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue(job1, Q{"new": true})
+	assert.Nil(t, err)
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.deadJobRetention, w.maxDeadJobs = time.Hour, 3
+	w.start()
+	w.drain()
+	w.stop()
+
+	// The old jobs are past the retention, and of the four recent ones, the oldest is over the max
+	assert.EqualValues(t, 3, zsetSize(pool, redisKeyDead(ns)))
+	conn := pool.Get()
+	defer conn.Close()
+	count, err := redis.Int64(conn.Do("ZCOUNT", redisKeyDead(ns), "-inf", now-3))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, count)
+	count, err = redis.Int64(conn.Do("ZCOUNT", redisKeyDead(ns), now, "+inf"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+}
+
 func TestWorkersPaused(t *testing.T) {
 	pool := newTestPool(":6379")
 	ns := "work"
@@ -363,8 +411,244 @@ func TestWorkersPaused(t *testing.T) {
 	assert.EqualValues(t, 0, len(h))
 }
 
+func TestWorkerDisabledJobType(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	job2 := "job2"
+	cleanKeyspace(ns, pool)
+
+	var ran []string
+	jobTypes := make(map[string]*jobType)
+	for _, name := range []string{job1, job2} {
+		jobTypes[name] = &jobType{
+			Name:       name,
+			JobOptions: JobOptions{Priority: 1},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				ran = append(ran, job.Name)
+				return nil
+			},
+		}
+	}
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue(job1, nil)
+	assert.Nil(t, err)
+	_, err = enqueuer.Enqueue(job2, nil)
+	assert.Nil(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.disabled = newJobNameSet()
+	w.disabled.add(job1)
+	w.start()
+	w.drain()
+
+	// Only job2 ran; job1 is still waiting on its queue
+	assert.Equal(t, []string{job2}, ran)
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobs(ns, job1)))
+
+	w.disabled.remove(job1)
+	w.drain()
+	w.stop()
+
+	assert.Equal(t, []string{job2, job1}, ran)
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, job1)))
+}
+
 // Test that in the case of an unavailable Redis server,
 // the worker loop exits in the case of a WorkerPool.Stop
+func TestWorkerAckReconciliation(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	jobTypes := map[string]*jobType{
+		job1: {
+			Name:           job1,
+			JobOptions:     JobOptions{Priority: 1},
+			IsGeneric:      true,
+			GenericHandler: func(job *Job) error { return nil },
+		},
+	}
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue(job1, nil)
+	assert.Nil(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	job, err := w.fetchJob()
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	// Redis is unreachable when the job is acked
+	w.pool = newTestPool(":1")
+	w.removeJobFromInProgress(job, terminateOnly)
+	assert.Equal(t, 1, len(w.unacked))
+	assert.EqualValues(t, 1, listSize(pool, redisKeyJobsInProgress(ns, "1", job1)))
+	assert.EqualValues(t, 1, getInt64(pool, redisKeyJobsLock(ns, job1)))
+
+	// Not due yet
+	w.pool = pool
+	w.reconcileAcks()
+	assert.Equal(t, 1, len(w.unacked))
+
+	// A repeated failure is surfaced through the client
+	pa := w.unacked[0]
+	pa.failures++
+	w.recordAckFailure(pa, fmt.Errorf("connection refused"))
+	failures, err := NewClient(ns, pool).AckFailures()
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(failures)) {
+		assert.Equal(t, job1, failures[0].JobName)
+		assert.Equal(t, job.ID, failures[0].JobID)
+		assert.Equal(t, "1", failures[0].WorkerPoolID)
+		assert.Equal(t, w.workerID, failures[0].WorkerID)
+		assert.EqualValues(t, 2, failures[0].Failures)
+		assert.Equal(t, "connection refused", failures[0].LastErr)
+	}
+
+	// Once due, the ack goes through and the failure record is cleared
+	pa.retryAt = time.Time{}
+	w.reconcileAcks()
+	assert.Equal(t, 0, len(w.unacked))
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobsInProgress(ns, "1", job1)))
+	assert.EqualValues(t, 0, getInt64(pool, redisKeyJobsLock(ns, job1)))
+	assert.EqualValues(t, 0, hgetInt64(pool, redisKeyJobsLockInfo(ns, job1), "1"))
+	failures, err = NewClient(ns, pool).AckFailures()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(failures))
+
+	// Acking again doesn't release the lock twice
+	w.removeJobFromInProgress(job, terminateAndDead(w, nil, job))
+	assert.Equal(t, 0, len(w.unacked))
+	assert.EqualValues(t, 0, getInt64(pool, redisKeyJobsLock(ns, job1)))
+	assert.EqualValues(t, 0, zsetSize(pool, redisKeyDead(ns)))
+}
+
+func TestWorkerBatch(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	var ran int64
+	jobTypes := map[string]*jobType{
+		job1: {
+			Name:       job1,
+			JobOptions: JobOptions{Priority: 1, MaxFails: 3, BatchSize: 5},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				atomic.AddInt64(&ran, 1)
+				if job.ArgBool("fail") {
+					return fmt.Errorf("sorry kid")
+				}
+				return nil
+			},
+		},
+	}
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 12; i++ {
+		_, err := enqueuer.Enqueue(job1, Q{"fail": i == 7})
+		assert.Nil(t, err)
+	}
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.start()
+	w.drain()
+	w.stop()
+
+	assert.EqualValues(t, 12, ran)
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobs(ns, job1)))
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobsInProgress(ns, "1", job1)))
+	assert.EqualValues(t, 0, getInt64(pool, redisKeyJobsLock(ns, job1)))
+	assert.EqualValues(t, 0, hgetInt64(pool, redisKeyJobsLockInfo(ns, job1), "1"))
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyRetry(ns)))
+}
+
+func TestWorkerFetchBatchMaxConcurrency(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	jobTypes := map[string]*jobType{
+		job1: {
+			Name:           job1,
+			JobOptions:     JobOptions{Priority: 1, BatchSize: 10, MaxConcurrency: 3},
+			IsGeneric:      true,
+			GenericHandler: func(job *Job) error { return nil },
+		},
+	}
+
+	enqueuer := NewEnqueuer(ns, pool)
+	for i := 0; i < 10; i++ {
+		_, err := enqueuer.Enqueue(job1, nil)
+		assert.Nil(t, err)
+	}
+	conn := pool.Get()
+	_, err := conn.Do("SET", redisKeyJobsConcurrency(ns, job1), 3)
+	conn.Close()
+	assert.NoError(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	job, err := w.fetchJob()
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	more, err := w.fetchBatch(job, 9)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(more))
+	assert.EqualValues(t, 3, listSize(pool, redisKeyJobsInProgress(ns, "1", job1)))
+	assert.EqualValues(t, 3, getInt64(pool, redisKeyJobsLock(ns, job1)))
+	assert.EqualValues(t, 3, hgetInt64(pool, redisKeyJobsLockInfo(ns, job1), "1"))
+
+	w.removeJobsFromInProgress(append(more, job), []terminateOp{terminateOnly, terminateOnly, terminateOnly})
+	assert.EqualValues(t, 0, listSize(pool, redisKeyJobsInProgress(ns, "1", job1)))
+	assert.EqualValues(t, 0, getInt64(pool, redisKeyJobsLock(ns, job1)))
+}
+
+func TestWorkerRawArgs(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	job1 := "job1"
+	cleanKeyspace(ns, pool)
+
+	var args map[string]interface{}
+	var rawArgs string
+	jobTypes := map[string]*jobType{
+		job1: {
+			Name:       job1,
+			JobOptions: JobOptions{Priority: 1, MaxFails: 3, RawArgs: true},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				args = job.Args
+				rawArgs = string(job.RawArgs())
+				return fmt.Errorf("sorry kid")
+			},
+		},
+	}
+
+	enqueuer := NewEnqueuer(ns, pool)
+	_, err := enqueuer.Enqueue(job1, Q{"a": 1})
+	assert.Nil(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.start()
+	w.drain()
+	w.stop()
+
+	assert.Nil(t, args)
+	assert.Equal(t, `{"a":1}`, rawArgs)
+
+	// The failed job's args are intact on the retry queue
+	_, job := jobOnZset(pool, redisKeyRetry(ns))
+	assert.Equal(t, map[string]interface{}{"a": 1.0}, job.Args)
+	assert.Equal(t, "sorry kid", job.LastErr)
+}
+
 func TestStop(t *testing.T) {
 	redisPool := &redis.Pool{
 		Dial: func() (redis.Conn, error) {
@@ -631,3 +915,232 @@ func TestWorkerPoolStop(t *testing.T) {
 		t.Errorf("Expected that jobs queue was not completely emptied.")
 	}
 }
+
+func TestWorkerJobHistory(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	tMock := nowEpochSeconds()
+	setNowEpochSecondsMock(tMock)
+	defer resetNowEpochSecondsMock()
+
+	jobTypes := map[string]*jobType{
+		"wat": {
+			Name:           "wat",
+			JobOptions:     JobOptions{Priority: 1, MaxFails: 2, Backoff: func(*Job) int64 { return 0 }},
+			IsGeneric:      true,
+			GenericHandler: func(job *Job) error { return fmt.Errorf("sorry kid") },
+		},
+	}
+	job, err := NewEnqueuer(ns, pool).Enqueue("wat", Q{"a": 1})
+	assert.NoError(t, err)
+	enqueued, err := job.serialize()
+	assert.NoError(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	reaper := newDeadPoolReaper(ns, pool, []string{"wat"})
+	requeuer := newRequeuer(ns, pool, redisKeyRetry(ns), []string{"wat"})
+
+	// The pool running the job dies; the job's bytes are kept as they were, plus its history
+	job, err = w.fetchJob()
+	assert.NoError(t, err)
+	assert.NoError(t, reaper.requeueInProgressJobs("1", []string{"wat"}))
+	raw, err := redis.String(pool.Get().Do("LINDEX", redisKeyJobs(ns, "wat"), 0))
+	assert.NoError(t, err)
+	assert.Equal(t, string(enqueued[:len(enqueued)-1])+fmt.Sprintf(`,"history":[{"event":"orphaned","at":%d}]}`, tMock), raw)
+
+	// It fails, is retried, and fails again, which kills it
+	job, err = w.fetchJob()
+	assert.NoError(t, err)
+	w.processJob(job)
+	setNowEpochSecondsMock(tMock + 10)
+	requeuer.processAll()
+	job, err = w.fetchJob()
+	assert.NoError(t, err)
+	w.processJob(job)
+
+	_, dead := jobOnZset(pool, redisKeyDead(ns))
+	failed := JobEvent{Event: JobFailed, At: tMock, Host: w.hostname, Pid: w.pid, Attempt: 1, Err: "sorry kid"}
+	failedAgain := failed
+	failedAgain.At, failedAgain.Attempt = tMock+10, 2
+	assert.Equal(t, []JobEvent{
+		{Event: JobOrphaned, At: tMock},
+		failed,
+		{Event: JobRetried, At: tMock + 10},
+		failedAgain,
+	}, dead.History)
+
+	// Until it's revived
+	assert.NoError(t, NewClient(ns, pool).RetryDeadJob(dead.FailedAt, dead.ID))
+	revived := jobOnQueue(pool, redisKeyJobs(ns, "wat"))
+	assert.Equal(t, JobEvent{Event: JobRevived, At: tMock + 10}, revived.History[4])
+	assert.EqualValues(t, 1, revived.ArgInt64("a"))
+}
+
+func TestWorkerItemFailures(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	var sent []string
+	wp := NewWorkerPool(TestContext{}, 1, ns, pool)
+	wp.Job("notify", func(job *Job) error {
+		for i, email := range job.Args["emails"].([]interface{}) {
+			if email == "bounces@example.com" {
+				job.ReportItemFailure(i, fmt.Errorf("bounced"))
+				continue
+			}
+			sent = append(sent, email.(string))
+		}
+		return nil
+	}, Items("emails"), MaxFails(2))
+	wp.Job("unlisted", func(job *Job) error {
+		job.ReportItemFailure(0, fmt.Errorf("bounced"))
+		return nil
+	})
+
+	enqueuer := NewEnqueuer(ns, pool)
+	job, err := enqueuer.Enqueue("notify", Q{"emails": []string{"a@example.com", "bounces@example.com", "b@example.com"}})
+	assert.NoError(t, err)
+	_, err = enqueuer.Enqueue("unlisted", nil)
+	assert.NoError(t, err)
+
+	wp.Start()
+	wp.Drain()
+	wp.Stop()
+
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, sent)
+	assert.EqualValues(t, 2, zsetSize(pool, redisKeyRetry(ns)))
+	retries, _, err := NewClient(ns, pool).RetryJobs(1)
+	assert.NoError(t, err)
+	for _, retry := range retries {
+		switch retry.Name {
+		case "notify":
+			// Only the item that failed is retried
+			assert.NotEqual(t, job.ID, retry.ID)
+			assert.Equal(t, []interface{}{"bounces@example.com"}, retry.Args["emails"])
+			assert.EqualValues(t, 1, retry.Fails)
+			assert.Equal(t, "1 of 3 items failed, eg item 1: bounced", retry.LastErr)
+		case "unlisted":
+			// Without JobOptions.ItemsArg, the whole job is retried
+			assert.Equal(t, "item failures reported, but the job type has no JobOptions.ItemsArg", retry.LastErr)
+		}
+	}
+	assert.EqualValues(t, 2, wp.Stats().Retried)
+}
+
+func TestWorkerJobAnnotations(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	var resumedFrom []string
+	jobTypes := map[string]*jobType{
+		"wat": {
+			Name:       "wat",
+			JobOptions: JobOptions{Priority: 1, MaxFails: 3, Backoff: func(*Job) int64 { return 0 }},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				resumedFrom = append(resumedFrom, job.Annotation("offset"))
+				job.Annotate("offset", fmt.Sprint(len(resumedFrom)*100))
+				job.Annotate("scratch", "")
+				return fmt.Errorf("sorry kid")
+			},
+		},
+	}
+	_, err := NewEnqueuer(ns, pool).Enqueue("wat", nil)
+	assert.NoError(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	requeuer := newRequeuer(ns, pool, redisKeyRetry(ns), []string{"wat"})
+	for i := 0; i < 3; i++ {
+		job, err := w.fetchJob()
+		assert.NoError(t, err)
+		w.processJob(job)
+		requeuer.processAll()
+	}
+
+	// Each attempt picks up where the last left off, and the last one's notes go to the dead queue
+	assert.Equal(t, []string{"", "100", "200"}, resumedFrom)
+	_, dead := jobOnZset(pool, redisKeyDead(ns))
+	assert.Equal(t, map[string]string{"offset": "300"}, dead.Annotations)
+
+	// Reviving the job keeps them too
+	assert.NoError(t, NewClient(ns, pool).RetryDeadJob(dead.FailedAt, dead.ID))
+	assert.Equal(t, "300", jobOnQueue(pool, redisKeyJobs(ns, "wat")).Annotation("offset"))
+}
+
+func TestWorkerMaxAge(t *testing.T) {
+	pool := newTestPool(":6379")
+	ns := "work"
+	cleanKeyspace(ns, pool)
+
+	jobTypes := map[string]*jobType{
+		"sync": {
+			Name:           "sync",
+			JobOptions:     JobOptions{Priority: 1, MaxFails: 4, MaxAge: time.Hour},
+			IsGeneric:      true,
+			GenericHandler: func(job *Job) error { return fmt.Errorf("sorry kid") },
+		},
+	}
+
+	// A job first enqueued two hours ago goes to the dead queue with fails to spare, a fresh one is retried
+	enqueuer := NewEnqueuer(ns, pool)
+	setNowEpochSecondsMock(time.Now().Add(-2 * time.Hour).Unix())
+	old, err := enqueuer.Enqueue("sync", nil)
+	resetNowEpochSecondsMock()
+	assert.NoError(t, err)
+	assert.True(t, old.Age() >= 2*time.Hour)
+	_, err = enqueuer.Enqueue("sync", nil)
+	assert.NoError(t, err)
+
+	w := newWorker(ns, "1", pool, tstCtxType, nil, jobTypes, nil)
+	w.start()
+	w.drain()
+	w.stop()
+
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyDead(ns)))
+	assert.EqualValues(t, 1, zsetSize(pool, redisKeyRetry(ns)))
+	_, dead := jobOnZset(pool, redisKeyDead(ns))
+	assert.Equal(t, old.ID, dead.ID)
+}
+
+func TestWorkerPprofLabels(t *testing.T) {
+	var labels []string
+	jobTypes := map[string]*jobType{
+		"export": {
+			Name:       "export",
+			JobOptions: JobOptions{Priority: 1},
+			IsGeneric:  true,
+			GenericHandler: func(job *Job) error {
+				name, _ := pprof.Label(job.Context(), "job")
+				id, _ := pprof.Label(job.Context(), "job_id")
+				labels = append(labels, name+" "+id)
+				return nil
+			},
+		},
+	}
+	w := newWorker("work", "1", nil, tstCtxType, nil, jobTypes, nil)
+	job := &Job{Name: "export", ID: "abc"}
+
+	assert.NoError(t, w.run(job, jobTypes["export"]))
+	w.pprofLabels = true
+	job.ctx = nil
+	assert.NoError(t, w.run(job, jobTypes["export"]))
+	assert.Equal(t, []string{" ", "export abc"}, labels)
+}
+
+func TestWorkerIdleSleep(t *testing.T) {
+	w := newWorker("work", "1", nil, tstCtxType, nil, nil, []int64{0, 100, 1000})
+
+	assert.Equal(t, time.Duration(0), w.idleSleep(0))
+	for i := 0; i < 100; i++ {
+		sleep := w.idleSleep(1)
+		assert.True(t, sleep > 80*time.Millisecond && sleep <= 100*time.Millisecond, sleep)
+
+		// The last backoff repeats
+		sleep = w.idleSleep(5)
+		assert.True(t, sleep > 800*time.Millisecond && sleep <= time.Second, sleep)
+	}
+}